@@ -0,0 +1,121 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"vm-monitoring-system/internal/services"
+)
+
+// parseQueryTimeRange 解析startTime、endTime查询参数（RFC3339），缺省时结束时间为当前时间、开始时间为其前defaultSpan
+func parseQueryTimeRange(c *gin.Context, defaultSpan time.Duration) (time.Time, time.Time, bool) {
+	end := c.DefaultQuery("endTime", time.Now().Format(time.RFC3339))
+	start := c.Query("startTime")
+	if start == "" {
+		endTime, err := time.Parse(time.RFC3339, end)
+		if err != nil {
+			endTime = time.Now()
+		}
+		start = endTime.Add(-defaultSpan).Format(time.RFC3339)
+	}
+	return parseTimeRange(c, start, end)
+}
+
+// analyticsQuery 解析响应时间统计的查询参数
+func analyticsQuery(c *gin.Context) (services.AnalyticsQuery, bool) {
+	startTime, endTime, ok := parseQueryTimeRange(c, 30*24*time.Hour)
+	if !ok {
+		return services.AnalyticsQuery{}, false
+	}
+	query := services.AnalyticsQuery{
+		Start:    startTime,
+		End:      endTime,
+		GroupBy:  c.DefaultQuery("groupBy", services.AnalyticsGroupSeverity),
+		Severity: c.Query("severity"),
+	}
+	if ruleParam := c.Query("ruleId"); ruleParam != "" {
+		ruleID, err := uuid.Parse(ruleParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "规则ID格式错误",
+			})
+			return query, false
+		}
+		query.RuleID = &ruleID
+	}
+	return query, true
+}
+
+// Analytics 获取告警响应时间统计：按级别、规则、VM分组或处理人统计MTTA/MTTR、百分位与SLA超时
+func (h *AlertHandler) Analytics(c *gin.Context) {
+	query, ok := analyticsQuery(c)
+	if !ok {
+		return
+	}
+
+	report, err := h.analytics.Compute(query, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    report,
+	})
+}
+
+// AnalyticsReport 导出告警响应时间与SLA报告（format=csv或json）
+func (h *AlertHandler) AnalyticsReport(c *gin.Context) {
+	query, ok := analyticsQuery(c)
+	if !ok {
+		return
+	}
+	format := c.DefaultQuery("format", "csv")
+	if format != "csv" && format != "json" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "不支持的导出格式: " + format,
+		})
+		return
+	}
+
+	report, err := h.analytics.Compute(query, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "统计失败: " + err.Error(),
+		})
+		return
+	}
+
+	var data []byte
+	contentType := "text/csv; charset=utf-8"
+	if format == "json" {
+		contentType = "application/json"
+		data, err = json.MarshalIndent(report, "", "  ")
+	} else {
+		data, err = services.MarshalAnalyticsCSV(report)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "导出失败: " + err.Error(),
+		})
+		return
+	}
+
+	filename := fmt.Sprintf("alert-sla-report-%s-%s.%s", query.Start.Format("20060102"), query.End.Format("20060102"), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
@@ -2,7 +2,9 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,19 +12,47 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 
+	"vm-monitoring-system/internal/logger"
 	"vm-monitoring-system/internal/models"
+	"vm-monitoring-system/internal/services"
 )
 
 // AlertHandler 告警处理器
 type AlertHandler struct {
-	db *gorm.DB
+	db        *gorm.DB
+	revisions *services.RuleRevisionService
+	workflow  *services.AlertWorkflowService
+	analytics *services.AlertAnalyticsService
+	backtest  *services.BacktestService
 }
 
 // NewAlertHandler 创建告警处理器
 func NewAlertHandler(db *gorm.DB) *AlertHandler {
-	return &AlertHandler{db: db}
+	return &AlertHandler{
+		db:        db,
+		revisions: services.NewRuleRevisionService(db),
+		workflow:  services.NewAlertWorkflowService(db),
+		analytics: services.NewAlertAnalyticsService(db),
+		backtest:  services.NewBacktestService(db, 0),
+	}
+}
+
+// SetWorkflow 设置告警处理流程服务（共享告警引擎使用的实例，使状态变更推送到WebSocket）
+func (h *AlertHandler) SetWorkflow(workflow *services.AlertWorkflowService) {
+	h.workflow = workflow
+}
+
+// SetAnalytics 设置告警响应统计服务（使用配置的SLA目标）
+func (h *AlertHandler) SetAnalytics(analytics *services.AlertAnalyticsService) {
+	h.analytics = analytics
+}
+
+// SetBacktest 设置规则回测服务（使用配置的评估间隔作为默认步长）
+func (h *AlertHandler) SetBacktest(backtest *services.BacktestService) {
+	h.backtest = backtest
 }
 
 // RuleRequest 告警规则请求
@@ -32,26 +62,111 @@ type RuleRequest struct {
 	Scope              string                 `json:"scope" binding:"required,oneof=all vm group cluster host datacenter"`
 	ScopeID            *uuid.UUID             `json:"scopeId,omitempty"`
 	ScopeName          string                 `json:"scopeName,omitempty"`
-	ConditionLogic     string                 `json:"conditionLogic" binding:"required,oneof=and or"`
+	ConditionLogic     string                 `json:"conditionLogic" binding:"omitempty,oneof=and or"`
+	Expression         string                 `json:"expression,omitempty" binding:"max=4000"`
 	Enabled            bool                   `json:"enabled"`
 	Cooldown           int                    `json:"cooldown" binding:"min=0,max=86400"`
+	For                *int                   `json:"for" binding:"omitempty,min=0,max=86400"`                     // 更新时不填写表示不修改
+	KeepFiringFor      *int                   `json:"keepFiringFor" binding:"omitempty,min=0,max=86400"`           // 更新时不填写表示不修改
+	FlapThreshold      *int                   `json:"flapThreshold" binding:"omitempty,min=0,max=100"`             // 更新时不填写表示不修改
+	FlapWindow         *int                   `json:"flapWindow" binding:"omitempty,min=0,max=86400"`              // 为0时恢复默认值，更新时不填写表示不修改
+	NoDataState        *string                `json:"noDataState" binding:"omitempty,oneof=ok alerting keep_last"` // 为空字符串时恢复默认值，更新时不填写表示不修改
+	EvaluationMode     string                 `json:"evaluationMode" binding:"omitempty,oneof=per_vm aggregate"`
 	Severity           string                 `json:"severity" binding:"required,oneof=critical high medium low"`
 	NotificationConfig map[string]interface{} `json:"notificationConfig"`
-	Conditions         []ConditionRequest     `json:"conditions" binding:"required,min=1,dive"`
+	Conditions         []ConditionRequest     `json:"conditions" binding:"omitempty,dive"`
+}
+
+// intValue 返回指针指向的整数，未填写时为0
+func intValue(p *int) int {
+	if p == nil {
+		return 0
+	}
+	return *p
+}
+
+// flapWindow 抖动检测窗口，未填写或为0时使用默认值
+func (r *RuleRequest) flapWindow() int {
+	if r.FlapWindow == nil || *r.FlapWindow == 0 {
+		return 3600
+	}
+	return *r.FlapWindow
+}
+
+// noDataState 缺少数据时的处理方式，未填写时使用默认值
+func (r *RuleRequest) noDataState() string {
+	if r.NoDataState == nil || *r.NoDataState == "" {
+		return models.NoDataStateKeepLast
+	}
+	return *r.NoDataState
+}
+
+// resolveExpression 校验规则表达式并返回规范化文本；未填写表达式时将条件列表翻译为表达式
+func (r *RuleRequest) resolveExpression() (string, error) {
+	if r.ConditionLogic == "" {
+		r.ConditionLogic = "and"
+	}
+	if r.EvaluationMode == "" {
+		r.EvaluationMode = models.EvaluationModePerVM
+	}
+	if r.EvaluationMode == models.EvaluationModeAggregate && r.Scope == "vm" {
+		return "", fmt.Errorf("VM范围不支持聚合评估")
+	}
+	expression, err := parseRuleExpression(r.Expression, r.ConditionLogic, r.Conditions)
+	if err != nil {
+		return "", err
+	}
+	if err := expression.CheckEvaluationMode(r.EvaluationMode); err != nil {
+		return "", err
+	}
+	return expression.String(), nil
+}
+
+// parseRuleExpression 解析表达式，表达式为空时翻译条件列表
+func parseRuleExpression(expression, logic string, conditions []ConditionRequest) (*services.Expression, error) {
+	if strings.TrimSpace(expression) != "" {
+		return services.ParseExpression(expression)
+	}
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("表达式和条件不能同时为空")
+	}
+
+	rows := make([]models.AlertCondition, len(conditions))
+	for i, cond := range conditions {
+		rows[i] = cond.toModel()
+	}
+	return services.ExpressionFromConditions(rows, logic)
 }
 
 // ConditionRequest 告警条件请求
 type ConditionRequest struct {
 	Metric       string  `json:"metric" binding:"required,max=50"`
 	MetricType   string  `json:"metricType" binding:"required,max=100"`
-	Operator     string  `json:"operator" binding:"required,oneof=> >= < <= == !="`
-	Threshold    float64 `json:"threshold" binding:"required"`
+	Operator     string  `json:"operator" binding:"required_unless=Type absent,omitempty,oneof=> >= < <= == !="`
+	Threshold    float64 `json:"threshold" binding:"required_unless=Type absent"`
 	ThresholdStr *string `json:"thresholdStr,omitempty"`
 	Duration     int     `json:"duration" binding:"min=0,max=3600"`
 	Aggregation  string  `json:"aggregation" binding:"omitempty,oneof=last avg max min sum"`
+	Type         string  `json:"type" binding:"omitempty,oneof=threshold anomaly absent"`
+	Algorithm    *string `json:"algorithm,omitempty" binding:"omitempty,oneof=zscore mad ewma seasonal"`
 	SortOrder    int     `json:"sortOrder"`
 }
 
+// toModel 转换为条件模型（不含ID与规则ID）
+func (r ConditionRequest) toModel() models.AlertCondition {
+	return models.AlertCondition{
+		Metric:       r.Metric,
+		MetricType:   r.MetricType,
+		Operator:     r.Operator,
+		Threshold:    r.Threshold,
+		ThresholdStr: r.ThresholdStr,
+		Duration:     r.Duration,
+		Aggregation:  r.Aggregation,
+		Type:         conditionType(r.Type),
+		Algorithm:    r.Algorithm,
+	}
+}
+
 // RecordFilter 告警记录筛选
 type RecordFilter struct {
 	Page           int        `form:"page" binding:"min=1"`
@@ -214,6 +329,12 @@ func (h *AlertHandler) CreateRule(c *gin.Context) {
 		return
 	}
 
+	expression, err := req.resolveExpression()
+	if err != nil {
+		respondExpressionError(c, err)
+		return
+	}
+
 	// 开启事务
 	tx := h.db.Begin()
 
@@ -228,8 +349,15 @@ func (h *AlertHandler) CreateRule(c *gin.Context) {
 		Scope:              req.Scope,
 		ScopeID:            req.ScopeID,
 		ConditionLogic:     req.ConditionLogic,
+		Expression:         &expression,
 		Enabled:            req.Enabled,
 		Cooldown:           req.Cooldown,
+		ForDuration:        intValue(req.For),
+		KeepFiringFor:      intValue(req.KeepFiringFor),
+		FlapThreshold:      intValue(req.FlapThreshold),
+		FlapWindow:         req.flapWindow(),
+		NoDataState:        req.noDataState(),
+		EvaluationMode:     req.EvaluationMode,
 		Severity:           req.Severity,
 		NotificationConfig: models.JSONMap{},
 		TriggerCount:       0,
@@ -280,6 +408,8 @@ func (h *AlertHandler) CreateRule(c *gin.Context) {
 			ThresholdStr: &thresholdStr,
 			Duration:     condReq.Duration,
 			Aggregation:  condReq.Aggregation,
+			Type:         conditionType(condReq.Type),
+			Algorithm:    condReq.Algorithm,
 			SortOrder:    i,
 			CreatedAt:    time.Now(),
 		}
@@ -294,6 +424,16 @@ func (h *AlertHandler) CreateRule(c *gin.Context) {
 		}
 	}
 
+	// 写入修订记录
+	if _, err := h.revisions.Record(tx, rule.ID, models.RuleRevisionCreate, rule.CreatedBy, ""); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -325,6 +465,15 @@ func (h *AlertHandler) UpdateRule(c *gin.Context) {
 		return
 	}
 
+	// 内置数据中断规则没有条件，只能启用或停用，超时时间由配置文件设置
+	if id == services.DeadmanRuleID {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "内置数据中断规则不能编辑，超时时间由 alert.deadman_timeout 配置",
+		})
+		return
+	}
+
 	var req RuleRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -334,6 +483,12 @@ func (h *AlertHandler) UpdateRule(c *gin.Context) {
 		return
 	}
 
+	expression, err := req.resolveExpression()
+	if err != nil {
+		respondExpressionError(c, err)
+		return
+	}
+
 	// 检查规则是否存在
 	var existingRule models.AlertRule
 	if err := h.db.Where("id = ? AND is_deleted = ?", id, false).First(&existingRule).Error; err != nil {
@@ -354,17 +509,50 @@ func (h *AlertHandler) UpdateRule(c *gin.Context) {
 	// 开启事务
 	tx := h.db.Begin()
 
+	// 首次修改启用修订历史前创建的规则时，先补录原始版本
+	if err := h.revisions.EnsureBaseline(tx, id); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// 更新规则字段
 	updates := map[string]interface{}{
 		"name":            req.Name,
 		"scope":           req.Scope,
 		"condition_logic": req.ConditionLogic,
+		"expression":      expression,
 		"enabled":         req.Enabled,
 		"cooldown":        req.Cooldown,
+		"evaluation_mode": req.EvaluationMode,
 		"severity":        req.Severity,
 		"updated_at":      time.Now(),
 	}
 
+	// 状态机参数只在填写时更新
+	if req.For != nil {
+		updates["for_duration"] = *req.For
+	}
+
+	if req.KeepFiringFor != nil {
+		updates["keep_firing_for"] = *req.KeepFiringFor
+	}
+
+	if req.FlapThreshold != nil {
+		updates["flap_threshold"] = *req.FlapThreshold
+	}
+
+	if req.FlapWindow != nil {
+		updates["flap_window"] = req.flapWindow()
+	}
+
+	if req.NoDataState != nil {
+		updates["no_data_state"] = req.noDataState()
+	}
+
 	if req.Description != "" {
 		updates["description"] = req.Description
 	}
@@ -384,9 +572,11 @@ func (h *AlertHandler) UpdateRule(c *gin.Context) {
 	}
 
 	// 获取用户信息
+	var updatedBy *uuid.UUID
 	if userID, exists := c.Get("userID"); exists {
 		if uid, ok := userID.(uuid.UUID); ok {
 			updates["updated_by"] = uid
+			updatedBy = &uid
 		}
 	}
 
@@ -426,6 +616,8 @@ func (h *AlertHandler) UpdateRule(c *gin.Context) {
 			ThresholdStr: &thresholdStr,
 			Duration:     condReq.Duration,
 			Aggregation:  condReq.Aggregation,
+			Type:         conditionType(condReq.Type),
+			Algorithm:    condReq.Algorithm,
 			SortOrder:    i,
 			CreatedAt:    time.Now(),
 		}
@@ -440,6 +632,15 @@ func (h *AlertHandler) UpdateRule(c *gin.Context) {
 		}
 	}
 
+	if _, err := h.revisions.Record(tx, id, models.RuleRevisionUpdate, updatedBy, ""); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": err.Error(),
+		})
+		return
+	}
+
 	// 提交事务
 	if err := tx.Commit().Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -493,14 +694,26 @@ func (h *AlertHandler) DeleteRule(c *gin.Context) {
 	}
 
 	// 获取用户信息
+	var deletedBy *uuid.UUID
 	if userID, exists := c.Get("userID"); exists {
 		if uid, ok := userID.(uuid.UUID); ok {
 			updates["deleted_by"] = uid
 			updates["updated_by"] = uid
+			deletedBy = &uid
 		}
 	}
 
-	if err := h.db.Model(&rule).Updates(updates).Error; err != nil {
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := h.revisions.EnsureBaseline(tx, id); err != nil {
+			return err
+		}
+		if err := tx.Model(&rule).Updates(updates).Error; err != nil {
+			return err
+		}
+		_, err := h.revisions.Record(tx, id, models.RuleRevisionDelete, deletedBy, "")
+		return err
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
 			"message": "删除失败: " + err.Error(),
@@ -562,40 +775,532 @@ func (h *AlertHandler) BatchUpdateRuleStatus(c *gin.Context) {
 		updates["updated_by"] = *updatedBy
 	}
 
-	result := h.db.Model(&models.AlertRule{}).
-		Where("id IN ? AND is_deleted = ?", ids, false).
-		Updates(updates)
+	note := "批量停用"
+	if req.Status {
+		note = "批量启用"
+	}
+
+	var updatedCount int64
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var targets []uuid.UUID
+		if err := tx.Model(&models.AlertRule{}).
+			Where("id IN ? AND is_deleted = ?", ids, false).
+			Pluck("id", &targets).Error; err != nil {
+			return err
+		}
+		for _, id := range targets {
+			if err := h.revisions.EnsureBaseline(tx, id); err != nil {
+				return err
+			}
+		}
 
-	if result.Error != nil {
+		result := tx.Model(&models.AlertRule{}).
+			Where("id IN ? AND is_deleted = ?", targets, false).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		updatedCount = result.RowsAffected
+
+		for _, id := range targets {
+			if _, err := h.revisions.Record(tx, id, models.RuleRevisionUpdate, updatedBy, note); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "批量更新失败: " + result.Error.Error(),
+			"message": "批量更新失败: " + err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": fmt.Sprintf("批量更新成功，已更新 %d 条规则", result.RowsAffected),
+		"message": fmt.Sprintf("批量更新成功，已更新 %d 条规则", updatedCount),
 		"data": gin.H{
-			"updatedCount": result.RowsAffected,
+			"updatedCount": updatedCount,
 		},
 	})
 }
 
-// ImportRules 导入规则
+// RuleImportRequest 规则导入请求，content为JSON/YAML规则文档或Prometheus规则文件
+type RuleImportRequest struct {
+	Content  string `json:"content" form:"content"`
+	Strategy string `json:"strategy" form:"strategy" binding:"omitempty,oneof=upsert skip rename"`
+	DryRun   bool   `json:"dryRun" form:"dryRun"`
+}
+
+// ImportRules 导入规则（支持JSON请求体或multipart上传文件file），同名规则按strategy处理，默认跳过
 func (h *AlertHandler) ImportRules(c *gin.Context) {
+	var req RuleImportRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.Strategy == "" {
+		req.Strategy = services.ImportStrategySkip
+	}
+
+	content := []byte(req.Content)
+	if file, err := c.FormFile("file"); err == nil {
+		f, err := file.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "读取上传文件失败: " + err.Error(),
+			})
+			return
+		}
+		defer f.Close()
+		if content, err = io.ReadAll(io.LimitReader(f, 5<<20)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "读取上传文件失败: " + err.Error(),
+			})
+			return
+		}
+	}
+	if len(strings.TrimSpace(string(content))) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "导入内容不能为空",
+		})
+		return
+	}
+
+	specs, err := services.ParseRuleImport(content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	var userID *uuid.UUID
+	if value, exists := c.Get("userID"); exists {
+		if uid, ok := value.(uuid.UUID); ok {
+			userID = &uid
+		}
+	}
+
+	result, err := services.NewRuleTransferService(h.db).Import(specs, req.Strategy, req.DryRun, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "导入失败: " + err.Error(),
+		})
+		return
+	}
+	if result.Invalid > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": fmt.Sprintf("%d条规则校验失败，未导入任何规则", result.Invalid),
+			"data":    result,
+		})
+		return
+	}
+
+	message := "导入成功"
+	if req.DryRun {
+		message = "校验通过"
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "导入成功",
+		"message": message,
+		"data":    result,
 	})
 }
 
-// ExportRules 导出规则
+// RuleExportRequest 规则导出请求，ids为空时导出全部规则
+type RuleExportRequest struct {
+	IDs       []uuid.UUID `json:"ids"`
+	Format    string      `json:"format" binding:"omitempty,oneof=json yaml prometheus"`
+	GroupName string      `json:"groupName" binding:"max=100"`
+}
+
+// ExportRules 导出规则为JSON/YAML文档或Prometheus规则文件（作为附件下载）
 func (h *AlertHandler) ExportRules(c *gin.Context) {
+	var req RuleExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.Format == "" {
+		req.Format = "json"
+	}
+
+	specs, err := services.NewRuleTransferService(h.db).Export(req.IDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "导出失败: " + err.Error(),
+		})
+		return
+	}
+
+	var data []byte
+	if req.Format == "prometheus" {
+		// 包含异常检测等无法转换的规则时返回错误
+		if data, err = services.MarshalPrometheusRules(specs, req.GroupName, 0); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": err.Error(),
+			})
+			return
+		}
+	} else if data, err = services.MarshalRuleDocument(specs, req.Format); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "导出失败: " + err.Error(),
+		})
+		return
+	}
+
+	contentType, ext := "application/x-yaml", "yaml"
+	if req.Format == "json" {
+		contentType, ext = "application/json", "json"
+	}
+	filename := fmt.Sprintf("alert-rules-%s.%s", time.Now().Format("20060102150405"), ext)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// ExpressionRequest 规则表达式校验请求，表达式为空时翻译条件列表
+type ExpressionRequest struct {
+	Expression     string             `json:"expression" binding:"max=4000"`
+	ConditionLogic string             `json:"conditionLogic" binding:"omitempty,oneof=and or"`
+	Conditions     []ConditionRequest `json:"conditions" binding:"omitempty,dive"`
+	EvaluationMode string             `json:"evaluationMode" binding:"omitempty,oneof=per_vm aggregate"`
+}
+
+// ValidateExpression 校验规则表达式（供规则编辑器实时检查），返回规范化表达式、引用的指标及错误位置
+func (h *AlertHandler) ValidateExpression(c *gin.Context) {
+	var req ExpressionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+	if req.ConditionLogic == "" {
+		req.ConditionLogic = "and"
+	}
+
+	result := gin.H{
+		"valid":     false,
+		"functions": services.ExprFunctionUsages(),
+	}
+
+	expression, err := parseRuleExpression(req.Expression, req.ConditionLogic, req.Conditions)
+	if err == nil {
+		err = expression.CheckEvaluationMode(req.EvaluationMode)
+	}
+	if err != nil {
+		if exprErr, ok := err.(*services.ExprError); ok {
+			result["error"] = exprErr
+		} else {
+			result["error"] = gin.H{"message": err.Error()}
+		}
+	} else {
+		result["valid"] = true
+		result["expression"] = expression.String()
+		result["metrics"] = expression.Metrics()
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "导出成功",
+		"message": "校验完成",
+		"data":    result,
+	})
+}
+
+// RuleTestRequest 规则回测请求：指定已保存规则的ID，或提供未保存的规则定义
+type RuleTestRequest struct {
+	RuleID *uuid.UUID   `json:"ruleId"`
+	Rule   *RuleRequest `json:"rule"`
+	Start  time.Time    `json:"start" binding:"required"`
+	End    time.Time    `json:"end" binding:"required"`
+	Step   int          `json:"step" binding:"omitempty,min=10,max=86400"` // 评估间隔（秒），默认与告警引擎一致
+}
+
+// TestRule 在历史数据上回测规则，返回每个VM会触发的告警，不写入告警记录
+func (h *AlertHandler) TestRule(c *gin.Context) {
+	var req RuleTestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	var rule models.AlertRule
+	var conditions []models.AlertCondition
+	switch {
+	case req.RuleID != nil:
+		if err := h.db.Where("id = ? AND is_deleted = ?", *req.RuleID, false).First(&rule).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{
+					"code":    404,
+					"message": "规则不存在",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "查询失败: " + err.Error(),
+			})
+			return
+		}
+		if err := h.db.Where("rule_id = ?", rule.ID).Order("sort_order").Find(&conditions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "查询规则条件失败: " + err.Error(),
+			})
+			return
+		}
+
+	case req.Rule != nil:
+		expression, err := req.Rule.resolveExpression()
+		if err != nil {
+			respondExpressionError(c, err)
+			return
+		}
+		rule = models.AlertRule{
+			Name:           req.Rule.Name,
+			Scope:          req.Rule.Scope,
+			ScopeID:        req.Rule.ScopeID,
+			ConditionLogic: req.Rule.ConditionLogic,
+			Expression:     &expression,
+			Cooldown:       req.Rule.Cooldown,
+			ForDuration:    intValue(req.Rule.For),
+			KeepFiringFor:  intValue(req.Rule.KeepFiringFor),
+			FlapThreshold:  intValue(req.Rule.FlapThreshold),
+			FlapWindow:     req.Rule.flapWindow(),
+			NoDataState:    req.Rule.noDataState(),
+			EvaluationMode: req.Rule.EvaluationMode,
+			Severity:       req.Rule.Severity,
+		}
+
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请指定规则ID或规则定义",
+		})
+		return
+	}
+
+	result, err := h.backtest.Run(rule, conditions, services.BacktestOptions{
+		Start: req.Start,
+		End:   req.End,
+		Step:  time.Duration(req.Step) * time.Second,
+	})
+	if err != nil {
+		if _, ok := err.(*services.ExprError); ok {
+			respondExpressionError(c, err)
+			return
+		}
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidBacktest) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"code":    status,
+			"message": "回测失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "回测完成",
+		"data":    result,
+	})
+}
+
+// respondExpressionError 返回表达式错误，解析错误附带出错位置
+func respondExpressionError(c *gin.Context, err error) {
+	response := gin.H{
+		"code":    400,
+		"message": "规则表达式错误: " + err.Error(),
+	}
+	if exprErr, ok := err.(*services.ExprError); ok {
+		response["data"] = exprErr
+	}
+	c.JSON(http.StatusBadRequest, response)
+}
+
+// conditionType 返回条件类型，未指定时为阈值条件
+func conditionType(t string) string {
+	if t == "" {
+		return services.ConditionTypeThreshold
+	}
+	return t
+}
+
+// ========== 规则修订历史 ==========
+
+// parseRuleID 解析路径中的规则ID，失败时返回400
+func parseRuleID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "规则ID格式错误",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// respondRevisionError 返回修订历史相关的错误
+func respondRevisionError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrRevisionNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+		status = http.StatusNotFound
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": err.Error(),
+	})
+}
+
+// ListRuleRevisions 获取规则的修订历史（按版本倒序）
+func (h *AlertHandler) ListRuleRevisions(c *gin.Context) {
+	id, ok := parseRuleID(c)
+	if !ok {
+		return
+	}
+
+	revisions, err := h.revisions.List(id)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    revisions,
+	})
+}
+
+// GetRuleRevision 获取规则指定版本的内容
+func (h *AlertHandler) GetRuleRevision(c *gin.Context) {
+	id, ok := parseRuleID(c)
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil || version < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "版本号格式错误",
+		})
+		return
+	}
+
+	revision, err := h.revisions.Get(id, version)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    revision,
+	})
+}
+
+// RevisionDiffQuery 版本比较参数
+type RevisionDiffQuery struct {
+	From int `form:"from" binding:"required,min=1"`
+	To   int `form:"to" binding:"required,min=1"`
+}
+
+// DiffRuleRevisions 比较规则的任意两个版本
+func (h *AlertHandler) DiffRuleRevisions(c *gin.Context) {
+	id, ok := parseRuleID(c)
+	if !ok {
+		return
+	}
+	var query RevisionDiffQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	changes, err := h.revisions.Diff(id, query.From, query.To)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data": gin.H{
+			"from":    query.From,
+			"to":      query.To,
+			"changes": changes,
+		},
+	})
+}
+
+// RollbackRequest 规则回滚请求
+type RollbackRequest struct {
+	Version int    `json:"version" binding:"required,min=1"`
+	Note    string `json:"note" binding:"max=500"`
+}
+
+// RollbackRule 将规则回滚到指定版本（会恢复已删除的规则），回滚本身记为一个新版本
+func (h *AlertHandler) RollbackRule(c *gin.Context) {
+	id, ok := parseRuleID(c)
+	if !ok {
+		return
+	}
+	var req RollbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	var userID *uuid.UUID
+	if value, exists := c.Get("userID"); exists {
+		if uid, ok := value.(uuid.UUID); ok {
+			userID = &uid
+		}
+	}
+
+	revision, err := h.revisions.Rollback(id, req.Version, userID, req.Note)
+	if err != nil {
+		respondRevisionError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "回滚成功",
+		"data":    revision,
 	})
 }
 
@@ -743,8 +1448,11 @@ func (h *AlertHandler) GetRecord(c *gin.Context) {
 		return
 	}
 
+	// 同时返回自动处置执行记录
 	var record models.AlertRecord
-	if err := h.db.First(&record, id).Error; err != nil {
+	if err := h.db.Preload("Remediations", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).First(&record, id).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{
 				"code":    404,
@@ -768,14 +1476,8 @@ func (h *AlertHandler) GetRecord(c *gin.Context) {
 
 // Acknowledge 确认告警
 func (h *AlertHandler) Acknowledge(c *gin.Context) {
-	recordID := c.Param("id")
-
-	var id uuid.UUID
-	if err := id.UnmarshalText([]byte(recordID)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "记录ID格式错误",
-		})
+	id, ok := parseRecordID(c)
+	if !ok {
 		return
 	}
 
@@ -783,63 +1485,10 @@ func (h *AlertHandler) Acknowledge(c *gin.Context) {
 	var req AcknowledgeRequest
 	c.ShouldBindJSON(&req)
 
-	// 查找记录
-	var record models.AlertRecord
-	if err := h.db.First(&record, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    404,
-				"message": "记录不存在",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "查询失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 检查是否已是确认状态
-	if record.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "该告警已" + record.Status + "，无法确认",
-		})
-		return
-	}
-
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":          "acknowledged",
-		"acknowledged_at": now,
-		"updated_at":      now,
-	}
-
-	// 获取用户信息
-	var userName string
-	if userID, exists := c.Get("userID"); exists {
-		if uid, ok := userID.(uuid.UUID); ok {
-			updates["acknowledged_by"] = uid
-
-			// 获取用户名
-			var user models.User
-			if err := h.db.Select("name").First(&user, uid).Error; err == nil {
-				userName = user.Name
-				updates["acknowledged_by_name"] = userName
-			}
-		}
-	}
-
-	if req.Note != "" {
-		updates["acknowledge_note"] = req.Note
-	}
-
-	if err := h.db.Model(&record).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "确认失败: " + err.Error(),
-		})
+	actor := h.workflow.Actor(requestUserID(c))
+	record, err := h.workflow.Transition(id, models.AlertStatusAcknowledged, actor, req.Note)
+	if err != nil {
+		respondWorkflowError(c, "确认失败", err)
 		return
 	}
 
@@ -847,10 +1496,10 @@ func (h *AlertHandler) Acknowledge(c *gin.Context) {
 		"code":    200,
 		"message": "确认成功",
 		"data": gin.H{
-			"id":             recordID,
-			"status":         "acknowledged",
-			"acknowledgedAt": now.Format(time.RFC3339),
-			"acknowledgedBy": userName,
+			"id":             record.ID,
+			"status":         record.Status,
+			"acknowledgedAt": record.AcknowledgedAt.Format(time.RFC3339),
+			"acknowledgedBy": actor.Name,
 		},
 	})
 }
@@ -886,49 +1535,21 @@ func (h *AlertHandler) BatchAcknowledge(c *gin.Context) {
 		ids = append(ids, id)
 	}
 
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":          "acknowledged",
-		"acknowledged_at": now,
-		"updated_at":      now,
-	}
-
-	if req.Note != "" {
-		updates["acknowledge_note"] = req.Note
-	}
-
-	// 获取用户信息
-	var userName string
-	if userID, exists := c.Get("userID"); exists {
-		if uid, ok := userID.(uuid.UUID); ok {
-			updates["acknowledged_by"] = uid
-
-			var user models.User
-			if err := h.db.Select("name").First(&user, uid).Error; err == nil {
-				userName = user.Name
-				updates["acknowledged_by_name"] = userName
-			}
-		}
-	}
-
-	// 只更新active状态的记录
-	result := h.db.Model(&models.AlertRecord{}).
-		Where("id IN ? AND status = ?", ids, "active").
-		Updates(updates)
-
-	if result.Error != nil {
+	// 只确认active状态的记录
+	updated, err := h.workflow.TransitionMany(ids, models.AlertStatusAcknowledged, h.workflow.Actor(requestUserID(c)), req.Note)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "批量确认失败: " + result.Error.Error(),
+			"message": "批量确认失败: " + err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": fmt.Sprintf("批量确认成功，已确认 %d 条告警", result.RowsAffected),
+		"message": fmt.Sprintf("批量确认成功，已确认 %d 条告警", updated),
 		"data": gin.H{
-			"updatedCount": result.RowsAffected,
+			"updatedCount": updated,
 		},
 	})
 }
@@ -940,82 +1561,18 @@ type ResolveRequest struct {
 
 // Resolve 解决告警
 func (h *AlertHandler) Resolve(c *gin.Context) {
-	recordID := c.Param("id")
-
-	var id uuid.UUID
-	if err := id.UnmarshalText([]byte(recordID)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "记录ID格式错误",
-		})
+	id, ok := parseRecordID(c)
+	if !ok {
 		return
 	}
 
 	var req ResolveRequest
 	c.ShouldBindJSON(&req)
 
-	// 查找记录
-	var record models.AlertRecord
-	if err := h.db.First(&record, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    404,
-				"message": "记录不存在",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "查询失败: " + err.Error(),
-		})
-		return
-	}
-
-	// 检查是否可以解决
-	if record.Status == "resolved" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "该告警已解决，无需重复操作",
-		})
-		return
-	}
-
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":      "resolved",
-		"resolved_at": now,
-		"updated_at":  now,
-	}
-
-	// 计算持续时间
-	if record.Status == "active" || record.Status == "acknowledged" {
-		duration := int(now.Sub(record.TriggeredAt).Minutes())
-		updates["duration"] = duration
-	}
-
-	// 获取用户信息
-	var userName string
-	if userID, exists := c.Get("userID"); exists {
-		if uid, ok := userID.(uuid.UUID); ok {
-			updates["resolved_by"] = uid
-
-			var user models.User
-			if err := h.db.Select("name").First(&user, uid).Error; err == nil {
-				userName = user.Name
-				updates["resolved_by_name"] = userName
-			}
-		}
-	}
-
-	if req.Resolution != "" {
-		updates["resolution"] = req.Resolution
-	}
-
-	if err := h.db.Model(&record).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "解决失败: " + err.Error(),
-		})
+	actor := h.workflow.Actor(requestUserID(c))
+	record, err := h.workflow.Transition(id, models.AlertStatusResolved, actor, req.Resolution)
+	if err != nil {
+		respondWorkflowError(c, "解决失败", err)
 		return
 	}
 
@@ -1023,89 +1580,70 @@ func (h *AlertHandler) Resolve(c *gin.Context) {
 		"code":    200,
 		"message": "解决成功",
 		"data": gin.H{
-			"id":         recordID,
-			"status":     "resolved",
-			"resolvedAt": now.Format(time.RFC3339),
-			"resolvedBy": userName,
+			"id":         record.ID,
+			"status":     record.Status,
+			"resolvedAt": record.ResolvedAt.Format(time.RFC3339),
+			"resolvedBy": actor.Name,
 		},
 	})
 }
 
 // Ignore 忽略告警
 func (h *AlertHandler) Ignore(c *gin.Context) {
-	recordID := c.Param("id")
-
-	var id uuid.UUID
-	if err := id.UnmarshalText([]byte(recordID)); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "记录ID格式错误",
-		})
+	id, ok := parseRecordID(c)
+	if !ok {
 		return
 	}
 
-	// 查找记录
-	var record models.AlertRecord
-	if err := h.db.First(&record, id).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, gin.H{
-				"code":    404,
-				"message": "记录不存在",
-			})
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"code":    500,
-			"message": "查询失败: " + err.Error(),
-		})
+	if _, err := h.workflow.Transition(id, models.AlertStatusIgnored, h.workflow.Actor(requestUserID(c)), ""); err != nil {
+		respondWorkflowError(c, "忽略失败", err)
 		return
 	}
 
-	// 检查是否已是忽略或解决状态
-	if record.Status == "ignored" || record.Status == "resolved" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"code":    400,
-			"message": "该告警已" + record.Status + "，无法忽略",
-		})
-		return
-	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已忽略",
+	})
+}
 
-	now := time.Now()
-	updates := map[string]interface{}{
-		"status":      "ignored",
-		"resolved_at": now,
-		"updated_at":  now,
-	}
+// ========== 告警状态 ==========
 
-	// 获取用户信息
-	if userID, exists := c.Get("userID"); exists {
-		if uid, ok := userID.(uuid.UUID); ok {
-			updates["resolved_by"] = uid
+// ListStates 获取告警状态机状态（可按规则、VM、状态筛选）
+func (h *AlertHandler) ListStates(c *gin.Context) {
+	query := h.db.Model(&models.AlertState{})
 
-			var user models.User
-			if err := h.db.Select("name").First(&user, uid).Error; err == nil {
-				updates["resolved_by_name"] = user.Name
-			}
-		}
+	if ruleID := c.Query("ruleId"); ruleID != "" {
+		query = query.Where("rule_id = ?", ruleID)
+	}
+	if vmID := c.Query("vmId"); vmID != "" {
+		query = query.Where("vm_id = ?", vmID)
+	}
+	if state := c.Query("state"); state != "" {
+		query = query.Where("state = ?", state)
+	}
+	if c.Query("flapping") == "true" {
+		query = query.Where("flapping = ?", true)
 	}
 
-	if err := h.db.Model(&record).Updates(updates).Error; err != nil {
+	var states []models.AlertState
+	if err := query.Order("updated_at DESC").Find(&states).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "忽略失败: " + err.Error(),
+			"message": "查询失败: " + err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
-		"message": "已忽略",
+		"message": "获取成功",
+		"data":    states,
 	})
 }
 
 // ========== 统计 ==========
 
-// Statistics 获取告警统计
+// Statistics 获取告警统计，MTTA/MTTR（秒）统计所选时间范围（默认最近30天）内触发的告警
 func (h *AlertHandler) Statistics(c *gin.Context) {
 	// 规则统计
 	var totalRules, activeRules int64
@@ -1159,10 +1697,36 @@ func (h *AlertHandler) Statistics(c *gin.Context) {
 		Limit(10).
 		Scan(&byVM)
 
+	// 响应时间按告警级别汇总
+	startTime, endTime, ok := parseQueryTimeRange(c, 30*24*time.Hour)
+	if !ok {
+		return
+	}
+	var stats models.AlertStatistics
+	stats.TimeRange.Start, stats.TimeRange.End = startTime, endTime
+	report, err := h.analytics.Compute(services.AnalyticsQuery{Start: startTime, End: endTime}, time.Now())
+	if err != nil {
+		logger.Error("统计告警响应时间失败", zap.Time("start", startTime), zap.Time("end", endTime), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "统计响应时间失败: " + err.Error(),
+		})
+		return
+	}
+	stats.MTTA = &models.ResponseTimeSummary{Avg: report.Overall.MTTA.Avg, BySeverity: map[string]float64{}}
+	stats.MTTR = &models.ResponseTimeSummary{Avg: report.Overall.MTTR.Avg, BySeverity: map[string]float64{}}
+	for _, group := range report.Groups {
+		stats.MTTA.BySeverity[group.Key] = group.MTTA.Avg
+		stats.MTTR.BySeverity[group.Key] = group.MTTR.Avg
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
 		"data": gin.H{
+			"mtta":      stats.MTTA,
+			"mttr":      stats.MTTR,
+			"timeRange": stats.TimeRange,
 			"overview": gin.H{
 				"totalRules":         totalRules,
 				"activeRules":        activeRules,
@@ -1181,19 +1745,9 @@ func (h *AlertHandler) Statistics(c *gin.Context) {
 // Trends 获取告警趋势
 func (h *AlertHandler) Trends(c *gin.Context) {
 	// 默认查询最近7天
-	endTime := time.Now()
-	startTime := endTime.AddDate(0, 0, -7)
-
-	// 获取查询参数
-	if startParam := c.Query("startTime"); startParam != "" {
-		if t, err := time.Parse(time.RFC3339, startParam); err == nil {
-			startTime = t
-		}
-	}
-	if endParam := c.Query("endTime"); endParam != "" {
-		if t, err := time.Parse(time.RFC3339, endParam); err == nil {
-			endTime = t
-		}
+	startTime, endTime, ok := parseQueryTimeRange(c, 7*24*time.Hour)
+	if !ok {
+		return
 	}
 
 	// 按天统计告警数量
@@ -1222,11 +1776,23 @@ func (h *AlertHandler) Trends(c *gin.Context) {
 
 	h.db.Raw(query, startTime, endTime).Scan(&trends)
 
+	// 每天触发的告警的平均确认与解决时间（秒）
+	responseTimes, err := h.analytics.Daily(startTime, endTime)
+	if err != nil {
+		logger.Error("统计每日响应时间失败", zap.Time("start", startTime), zap.Time("end", endTime), zap.Error(err))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "统计响应时间失败: " + err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
 		"data": gin.H{
-			"trends": trends,
+			"trends":        trends,
+			"responseTimes": responseTimes,
 			"timeRange": gin.H{
 				"start": startTime.Format(time.RFC3339),
 				"end":   endTime.Format(time.RFC3339),
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"vm-monitoring-system/internal/services"
+)
+
+// parseRecordID 解析路径中的告警记录ID，格式错误时直接返回400
+func parseRecordID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "记录ID格式错误",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// requestUserID 当前登录用户ID，未登录时为nil
+func requestUserID(c *gin.Context) *uuid.UUID {
+	if value, exists := c.Get("userID"); exists {
+		if uid, ok := value.(uuid.UUID); ok {
+			return &uid
+		}
+	}
+	return nil
+}
+
+// respondWorkflowError 返回告警处理流程相关的错误
+func respondWorkflowError(c *gin.Context, action string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrAlertNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidTransition),
+		errors.Is(err, services.ErrAssigneeNotFound),
+		errors.Is(err, services.ErrEmptyComment),
+		errors.Is(err, services.ErrCommentParentInvalid):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": action + ": " + err.Error(),
+	})
+}
+
+// ChangeStatusRequest 变更告警状态请求
+type ChangeStatusRequest struct {
+	Status string `json:"status" binding:"required,oneof=acknowledged investigating mitigated resolved ignored"`
+	Note   string `json:"note,omitempty" binding:"max=4000"`
+}
+
+// ChangeStatus 变更告警处理状态（确认、排查中、已缓解、解决、忽略）
+func (h *AlertHandler) ChangeStatus(c *gin.Context) {
+	id, ok := parseRecordID(c)
+	if !ok {
+		return
+	}
+
+	var req ChangeStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	record, err := h.workflow.Transition(id, req.Status, h.workflow.Actor(requestUserID(c)), req.Note)
+	if err != nil {
+		respondWorkflowError(c, "变更状态失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "状态已变更",
+		"data":    record,
+	})
+}
+
+// AssignRequest 指派告警请求，用户与团队都为空表示取消指派
+type AssignRequest struct {
+	UserID *uuid.UUID `json:"userId,omitempty"`
+	Team   string     `json:"team,omitempty" binding:"max=100"`
+	Note   string     `json:"note,omitempty" binding:"max=4000"`
+}
+
+// Assign 指派告警给用户或团队
+func (h *AlertHandler) Assign(c *gin.Context) {
+	id, ok := parseRecordID(c)
+	if !ok {
+		return
+	}
+
+	var req AssignRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	record, err := h.workflow.Assign(id, req.UserID, req.Team, h.workflow.Actor(requestUserID(c)), req.Note)
+	if err != nil {
+		respondWorkflowError(c, "指派失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "指派成功",
+		"data":    record,
+	})
+}
+
+// CommentRequest 评论请求，内容中的 @用户名 会记录为提及
+type CommentRequest struct {
+	Content  string     `json:"content" binding:"required,max=10000"`
+	ParentID *uuid.UUID `json:"parentId,omitempty"`
+}
+
+// AddComment 添加告警评论或回复
+func (h *AlertHandler) AddComment(c *gin.Context) {
+	id, ok := parseRecordID(c)
+	if !ok {
+		return
+	}
+
+	var req CommentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	comment, err := h.workflow.AddComment(id, req.ParentID, req.Content, h.workflow.Actor(requestUserID(c)))
+	if err != nil {
+		respondWorkflowError(c, "评论失败", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "评论成功",
+		"data":    comment,
+	})
+}
+
+// ListComments 获取告警评论（按回复关系组织）
+func (h *AlertHandler) ListComments(c *gin.Context) {
+	id, ok := parseRecordID(c)
+	if !ok {
+		return
+	}
+
+	threads, err := h.workflow.Comments(id)
+	if err != nil {
+		respondWorkflowError(c, "查询评论失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    threads,
+	})
+}
+
+// Activity 获取告警活动流：状态变更、指派与评论按时间排序
+func (h *AlertHandler) Activity(c *gin.Context) {
+	id, ok := parseRecordID(c)
+	if !ok {
+		return
+	}
+
+	activities, err := h.workflow.Activity(id)
+	if err != nil {
+		respondWorkflowError(c, "查询活动失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    activities,
+	})
+}
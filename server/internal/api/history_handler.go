@@ -3,7 +3,9 @@ package api
 import (
 	"encoding/csv"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -24,16 +26,32 @@ import (
 type HistoryHandler struct {
 	db               *gorm.DB
 	timeSeriesService *services.TimeSeriesService
+	anomalyService   *services.AnomalyService
+	correlationService *services.CorrelationService
 }
 
 // NewHistoryHandler 创建历史数据处理器
 func NewHistoryHandler(db *gorm.DB) *HistoryHandler {
+	timeSeriesService := services.NewTimeSeriesService(db)
 	return &HistoryHandler{
 		db:               db,
-		timeSeriesService: services.NewTimeSeriesService(db),
+		timeSeriesService: timeSeriesService,
+		anomalyService:   services.NewAnomalyService(db, timeSeriesService),
+		correlationService: services.NewCorrelationService(db, timeSeriesService),
 	}
 }
 
+// SetTimeSeriesService 设置时序数据服务（与写入路径共用热缓存）
+func (h *HistoryHandler) SetTimeSeriesService(timeSeriesService *services.TimeSeriesService) {
+	h.timeSeriesService = timeSeriesService
+	h.correlationService = services.NewCorrelationService(h.db, timeSeriesService)
+}
+
+// SetAnomalyService 设置异常检测服务（与后台基线学习共用）
+func (h *HistoryHandler) SetAnomalyService(anomalyService *services.AnomalyService) {
+	h.anomalyService = anomalyService
+}
+
 // QueryRequest 查询历史数据请求
 type QueryRequest struct {
 	VMIDs          []string `json:"vmIds" binding:"required"`
@@ -348,9 +366,18 @@ func (h *HistoryHandler) Trends(c *gin.Context) {
 	})
 }
 
+// AnomalyRequest 异常检测请求
+type AnomalyRequest struct {
+	QueryRequest
+	Algorithm string  `json:"algorithm" binding:"omitempty,oneof=zscore mad ewma seasonal"`
+	Window    int     `json:"window" binding:"omitempty,min=2,max=1000"`
+	Threshold float64 `json:"threshold" binding:"omitempty,gt=0"`
+	Alpha     float64 `json:"alpha" binding:"omitempty,gt=0,lte=1"`
+}
+
 // Anomalies 异常检测
 func (h *HistoryHandler) Anomalies(c *gin.Context) {
-	var req QueryRequest
+	var req AnomalyRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code":    400,
@@ -386,76 +413,392 @@ func (h *HistoryHandler) Anomalies(c *gin.Context) {
 		return
 	}
 
-	// 查询历史数据
-	metrics, err := h.timeSeriesService.QueryMetrics(req.VMIDs, req.Metrics, startTime, endTime)
+	opts := services.AnomalyOptions{
+		Algorithm: req.Algorithm,
+		Window:    req.Window,
+		Threshold: req.Threshold,
+		Alpha:     req.Alpha,
+	}
+
+	anomalies, err := h.anomalyService.Detect(req.VMIDs, req.Metrics, startTime, endTime, opts)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
-			"message": "查询历史数据失败",
+			"message": "异常检测失败: " + err.Error(),
 		})
 		return
 	}
 
-	// 简单异常检测（基于Z-score方法）
-	anomalies := []gin.H{}
-	metricValues := make(map[string][]float64)
-	
-	// 收集指标值
-	for _, m := range metrics {
-		key := fmt.Sprintf("%s:%s", m.VMID, m.Metric)
-		metricValues[key] = append(metricValues[key], m.Value)
-	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "查询成功",
+		"data": gin.H{
+			"anomalies": anomalies,
+			"total":     len(anomalies),
+		},
+	})
+}
 
-	// 检测异常
-	for key, values := range metricValues {
-		if len(values) < 2 {
-			continue
+// SeriesAnomalies 查询单个VM指标的异常点
+func (h *HistoryHandler) SeriesAnomalies(c *gin.Context) {
+	vmID := c.Param("vmId")
+	metric := c.Param("metric")
+
+	// 默认查询最近24小时
+	endTime := time.Now()
+	startTime := endTime.Add(-24 * time.Hour)
+
+	if startParam := c.Query("startTime"); startParam != "" {
+		t, err := time.Parse(time.RFC3339, startParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "开始时间格式错误",
+			})
+			return
+		}
+		startTime = t
+	}
+	if endParam := c.Query("endTime"); endParam != "" {
+		t, err := time.Parse(time.RFC3339, endParam)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "结束时间格式错误",
+			})
+			return
 		}
+		endTime = t
+	}
+
+	if endTime.Before(startTime) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "结束时间不能早于开始时间",
+		})
+		return
+	}
+
+	algorithm := c.DefaultQuery("algorithm", services.AnomalyAlgoZScore)
+	if !services.IsValidAnomalyAlgorithm(algorithm) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "未知的异常检测算法: " + algorithm,
+		})
+		return
+	}
+
+	opts := services.AnomalyOptions{Algorithm: algorithm}
+	if v, err := strconv.Atoi(c.Query("window")); err == nil {
+		opts.Window = v
+	}
+	if v, err := strconv.ParseFloat(c.Query("threshold"), 64); err == nil {
+		opts.Threshold = v
+	}
+	if v, err := strconv.ParseFloat(c.Query("alpha"), 64); err == nil {
+		opts.Alpha = v
+	}
+
+	anomalies, err := h.anomalyService.Detect([]string{vmID}, []string{metric}, startTime, endTime, opts)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "异常检测失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "查询成功",
+		"data": gin.H{
+			"vmId":      vmID,
+			"metric":    metric,
+			"algorithm": algorithm,
+			"anomalies": anomalies,
+			"total":     len(anomalies),
+		},
+	})
+}
+
+// GetBaseline 获取VM指标的季节性基线
+func (h *HistoryHandler) GetBaseline(c *gin.Context) {
+	vmID := c.Param("vmId")
+	metric := c.Param("metric")
 
-		// 计算均值和标准差
-		mean := 0.0
-		for _, v := range values {
-			mean += v
+	baseline, err := h.anomalyService.GetBaseline(vmID, metric)
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "基线尚未学习",
+			})
+			return
 		}
-		mean /= float64(len(values))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询基线失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    baseline,
+	})
+}
+
+// TrainBaselinesRequest 基线学习请求
+type TrainBaselinesRequest struct {
+	VMID   string `json:"vmId"`
+	Metric string `json:"metric"`
+}
+
+// TrainBaselines 立即学习季节性基线
+func (h *HistoryHandler) TrainBaselines(c *gin.Context) {
+	var req TrainBaselinesRequest
+	// 请求体可以为空，表示学习全部序列
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+		})
+		return
+	}
+
+	count, err := h.anomalyService.TrainBaselines(req.VMID, req.Metric)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "学习基线失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "基线学习完成",
+		"data": gin.H{
+			"updatedCount": count,
+		},
+	})
+}
+
+// CorrelateRequest 指标相关性分析请求
+type CorrelateRequest struct {
+	VMID      string   `json:"vmId" binding:"required"`
+	Metric    string   `json:"metric" binding:"required"`
+	VMIDs     []string `json:"vmIds"`   // 候选VM，必填，最多50个
+	Metrics   []string `json:"metrics"` // 候选指标，为空时使用参考指标，最多20个
+	StartTime string   `json:"startTime" binding:"required"`
+	EndTime   string   `json:"endTime" binding:"required"`
+	Method    string   `json:"method" binding:"omitempty,oneof=pearson spearman"`
+	Step      string   `json:"step"` // 对齐步长，默认1m，不小于10s
+	MaxLag    int      `json:"maxLag" binding:"omitempty,min=0,max=120"`
+	MinPoints int      `json:"minPoints" binding:"omitempty,min=3"`
+	Limit     int      `json:"limit" binding:"omitempty,min=1,max=500"`
+}
+
+// Correlate 按与参考序列的相关系数对其他序列排名
+func (h *HistoryHandler) Correlate(c *gin.Context) {
+	var req CorrelateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+		})
+		return
+	}
 
-		variance := 0.0
-		for _, v := range values {
-			variance += math.Pow(v-mean, 2)
+	startTime, endTime, ok := parseTimeRange(c, req.StartTime, req.EndTime)
+	if !ok {
+		return
+	}
+
+	step := time.Minute
+	if req.Step != "" {
+		d, err := parsePeriod(req.Step)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "对齐步长格式错误",
+			})
+			return
 		}
-		variance /= float64(len(values))
-		stdDev := math.Sqrt(variance)
+		step = d
+	}
 
-		// 检测Z-score > 3的异常点
-		for _, m := range metrics {
-			key2 := fmt.Sprintf("%s:%s", m.VMID, m.Metric)
-			if key == key2 {
-				zScore := (m.Value - mean) / stdDev
-				if math.Abs(zScore) > 3 {
-					anomalies = append(anomalies, gin.H{
-						"vmId":      m.VMID,
-						"metric":    m.Metric,
-						"value":     m.Value,
-						"timestamp": m.Timestamp.Format(time.RFC3339),
-						"zScore":    zScore,
-						"mean":      mean,
-						"stdDev":    stdDev,
-					})
-				}
-			}
+	opts := services.CorrelationOptions{
+		Method:    req.Method,
+		Step:      step,
+		MaxLag:    req.MaxLag,
+		MinPoints: req.MinPoints,
+		Limit:     req.Limit,
+	}
+	if opts.Limit == 0 {
+		opts.Limit = 20
+	}
+
+	ref := services.SeriesKey{VMID: req.VMID, Metric: req.Metric}
+	results, err := h.correlationService.Correlate(ref, req.VMIDs, req.Metrics, startTime, endTime, opts)
+	if errors.Is(err, services.ErrInvalidCorrelation) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "相关性分析失败: " + err.Error(),
+		})
+		return
+	}
+
+	method := req.Method
+	if method == "" {
+		method = services.CorrelationPearson
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "查询成功",
+		"data": gin.H{
+			"reference": ref,
+			"method":    method,
+			"step":      step.String(),
+			"results":   results,
+			"total":     len(results),
+		},
+	})
+}
+
+// CompareRequest 周期对比请求
+type CompareRequest struct {
+	VMID      string `json:"vmId" binding:"required"`
+	Metric    string `json:"metric" binding:"required"`
+	StartTime string `json:"startTime" binding:"required"`
+	EndTime   string `json:"endTime" binding:"required"`
+	Period    string `json:"period" binding:"required"`
+	Step      string `json:"step"`
+}
+
+// Compare 将序列与平移一个周期后的自身叠加对比
+func (h *HistoryHandler) Compare(c *gin.Context) {
+	var req CompareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误",
+		})
+		return
+	}
+
+	startTime, endTime, ok := parseTimeRange(c, req.StartTime, req.EndTime)
+	if !ok {
+		return
+	}
+
+	shift, err := parsePeriod(req.Period)
+	if err != nil || shift <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "对比周期格式错误",
+		})
+		return
+	}
+
+	step := time.Minute
+	if req.Step != "" {
+		d, err := parsePeriod(req.Step)
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": "对齐步长格式错误",
+			})
+			return
 		}
+		step = d
+	}
+
+	result, err := h.correlationService.Compare(services.SeriesKey{VMID: req.VMID, Metric: req.Metric}, startTime, endTime, shift, step)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "周期对比失败: " + err.Error(),
+		})
+		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "查询成功",
 		"data": gin.H{
-			"anomalies": anomalies,
-			"total":     len(anomalies),
+			"vmId":   result.VMID,
+			"metric": result.Metric,
+			"period": shift.String(),
+			"step":   step.String(),
+			"points": result.Points,
+			"stats":  result.Stats,
 		},
 	})
 }
 
+// parseTimeRange 解析RFC3339时间范围，失败时直接写入错误响应
+func parseTimeRange(c *gin.Context, start, end string) (time.Time, time.Time, bool) {
+	startTime, err := time.Parse(time.RFC3339, start)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "开始时间格式错误",
+		})
+		return time.Time{}, time.Time{}, false
+	}
+
+	endTime, err := time.Parse(time.RFC3339, end)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "结束时间格式错误",
+		})
+		return time.Time{}, time.Time{}, false
+	}
+
+	if endTime.Before(startTime) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "结束时间不能早于开始时间",
+		})
+		return time.Time{}, time.Time{}, false
+	}
+
+	return startTime, endTime, true
+}
+
+// parsePeriod 解析时长，在 time.ParseDuration 基础上支持 d(天) 和 w(周) 单位
+func parsePeriod(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if n := len(s); n > 1 {
+		unit := time.Duration(0)
+		switch s[n-1] {
+		case 'd':
+			unit = 24 * time.Hour
+		case 'w':
+			unit = 7 * 24 * time.Hour
+		}
+		if unit > 0 {
+			v, err := strconv.Atoi(s[:n-1])
+			if err != nil {
+				return 0, fmt.Errorf("无效的时长: %s", s)
+			}
+			return time.Duration(v) * unit, nil
+		}
+	}
+	return time.ParseDuration(s)
+}
+
 // Export 导出数据
 func (h *HistoryHandler) Export(c *gin.Context) {
 	var req QueryRequest
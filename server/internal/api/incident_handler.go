@@ -0,0 +1,235 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"vm-monitoring-system/internal/services"
+)
+
+// IncidentHandler 事件处理器
+type IncidentHandler struct {
+	db        *gorm.DB
+	incidents *services.IncidentService
+	workflow  *services.AlertWorkflowService
+}
+
+// NewIncidentHandler 创建事件处理器
+func NewIncidentHandler(db *gorm.DB, incidents *services.IncidentService) *IncidentHandler {
+	if incidents == nil {
+		incidents = services.NewIncidentService(db)
+	}
+	return &IncidentHandler{db: db, incidents: incidents, workflow: services.NewAlertWorkflowService(db)}
+}
+
+// respondIncidentError 返回事件相关的错误
+func respondIncidentError(c *gin.Context, action string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrIncidentNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidIncident), errors.Is(err, services.ErrAssigneeNotFound):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": action + ": " + err.Error(),
+	})
+}
+
+// parseIncidentID 解析路径中的事件ID
+func parseIncidentID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "事件ID格式错误",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// List 获取事件列表（可按状态筛选，按最近告警时间倒序）
+func (h *IncidentHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	incidents, total, err := h.incidents.List(c.Query("status"), page, pageSize)
+	if err != nil {
+		respondIncidentError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"list": incidents,
+			"pagination": gin.H{
+				"page":       page,
+				"pageSize":   pageSize,
+				"total":      total,
+				"totalPages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+			},
+		},
+	})
+}
+
+// Get 获取事件详情（成员告警与时间线）
+func (h *IncidentHandler) Get(c *gin.Context) {
+	id, ok := parseIncidentID(c)
+	if !ok {
+		return
+	}
+
+	detail, err := h.incidents.Get(id)
+	if err != nil {
+		respondIncidentError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    detail,
+	})
+}
+
+// Timeline 获取事件时间线
+func (h *IncidentHandler) Timeline(c *gin.Context) {
+	id, ok := parseIncidentID(c)
+	if !ok {
+		return
+	}
+
+	timeline, err := h.incidents.Timeline(id)
+	if err != nil {
+		respondIncidentError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    timeline,
+	})
+}
+
+// IncidentUpdateRequest 修改事件请求，未填写的字段不修改；commanderId为全零UUID表示取消指挥官
+type IncidentUpdateRequest struct {
+	Title       *string    `json:"title" binding:"omitempty,max=300"`
+	Status      *string    `json:"status" binding:"omitempty,oneof=open investigating mitigated resolved"`
+	CommanderID *uuid.UUID `json:"commanderId"`
+	Postmortem  *string    `json:"postmortem"`
+}
+
+// Update 修改事件标题、状态、指挥官或复盘记录
+func (h *IncidentHandler) Update(c *gin.Context) {
+	id, ok := parseIncidentID(c)
+	if !ok {
+		return
+	}
+
+	var req IncidentUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	incident, err := h.incidents.Update(id, services.IncidentUpdate{
+		Title:       req.Title,
+		Status:      req.Status,
+		CommanderID: req.CommanderID,
+		Postmortem:  req.Postmortem,
+	}, h.workflow.Actor(requestUserID(c)))
+	if err != nil {
+		respondIncidentError(c, "更新失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新成功",
+		"data":    incident,
+	})
+}
+
+// MergeIncidentsRequest 合并事件请求
+type MergeIncidentsRequest struct {
+	TargetID  uuid.UUID   `json:"targetId" binding:"required"`
+	SourceIDs []uuid.UUID `json:"sourceIds" binding:"required,min=1"`
+}
+
+// Merge 将多个事件合并到目标事件
+func (h *IncidentHandler) Merge(c *gin.Context) {
+	var req MergeIncidentsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	incident, err := h.incidents.Merge(req.TargetID, req.SourceIDs, h.workflow.Actor(requestUserID(c)))
+	if err != nil {
+		respondIncidentError(c, "合并失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "合并成功",
+		"data":    incident,
+	})
+}
+
+// SplitIncidentRequest 拆分事件请求
+type SplitIncidentRequest struct {
+	AlertIDs []uuid.UUID `json:"alertIds" binding:"required,min=1"`
+	Title    string      `json:"title" binding:"max=300"`
+}
+
+// Split 将事件中的部分告警拆分为新事件
+func (h *IncidentHandler) Split(c *gin.Context) {
+	id, ok := parseIncidentID(c)
+	if !ok {
+		return
+	}
+
+	var req SplitIncidentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	incident, err := h.incidents.Split(id, req.AlertIDs, req.Title, h.workflow.Actor(requestUserID(c)))
+	if err != nil {
+		respondIncidentError(c, "拆分失败", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "拆分成功",
+		"data":    incident,
+	})
+}
@@ -0,0 +1,205 @@
+package api
+
+import (
+	"net/http"
+
+	"vm-monitoring-system/internal/models"
+	"vm-monitoring-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InhibitHandler 告警抑制规则处理器
+type InhibitHandler struct {
+	db *gorm.DB
+}
+
+// NewInhibitHandler 创建告警抑制规则处理器
+func NewInhibitHandler(db *gorm.DB) *InhibitHandler {
+	return &InhibitHandler{db: db}
+}
+
+// InhibitRuleRequest 创建/更新抑制规则请求
+type InhibitRuleRequest struct {
+	Name           string            `json:"name" binding:"required,max=100"`
+	Description    *string           `json:"description"`
+	Enabled        *bool             `json:"enabled"`
+	SourceMatchers map[string]string `json:"sourceMatchers" binding:"required,min=1"`
+	TargetMatchers map[string]string `json:"targetMatchers" binding:"required,min=1"`
+	Equal          []string          `json:"equal"`
+}
+
+// apply 将请求内容写入抑制规则
+func (r *InhibitRuleRequest) apply(rule *models.InhibitRule) {
+	rule.Name = r.Name
+	rule.Description = r.Description
+	rule.Enabled = r.Enabled == nil || *r.Enabled
+	rule.SourceMatchers = models.JSONMap{}
+	for k, v := range r.SourceMatchers {
+		rule.SourceMatchers[k] = v
+	}
+	rule.TargetMatchers = models.JSONMap{}
+	for k, v := range r.TargetMatchers {
+		rule.TargetMatchers[k] = v
+	}
+	rule.Equal = models.StringArray(r.Equal)
+	if rule.Equal == nil {
+		rule.Equal = models.StringArray{}
+	}
+}
+
+// List 获取抑制规则列表
+func (h *InhibitHandler) List(c *gin.Context) {
+	var rules []models.InhibitRule
+	if err := h.db.Order("created_at").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"rules":  rules,
+			"labels": services.AlertLabelNames,
+		},
+	})
+}
+
+// Create 创建抑制规则
+func (h *InhibitHandler) Create(c *gin.Context) {
+	var req InhibitRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	rule := models.InhibitRule{ID: uuid.New()}
+	req.apply(&rule)
+	if err := services.ValidateInhibitRule(&rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if userID, exists := c.Get("userID"); exists {
+		if uid, ok := userID.(uuid.UUID); ok {
+			rule.CreatedBy = &uid
+		}
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "创建失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "创建成功",
+		"data":    rule,
+	})
+}
+
+// Update 更新抑制规则
+func (h *InhibitHandler) Update(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	var req InhibitRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	req.apply(rule)
+	if err := services.ValidateInhibitRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := h.db.Save(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "更新失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新成功",
+		"data":    rule,
+	})
+}
+
+// Delete 删除抑制规则
+func (h *InhibitHandler) Delete(c *gin.Context) {
+	rule, ok := h.findRule(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除成功",
+	})
+}
+
+// findRule 根据路径参数查询抑制规则，失败时直接写入错误响应
+func (h *InhibitHandler) findRule(c *gin.Context) (*models.InhibitRule, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "规则ID格式错误",
+		})
+		return nil, false
+	}
+
+	var rule models.InhibitRule
+	if err := h.db.Where("id = ?", id).First(&rule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "抑制规则不存在",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询失败: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	return &rule, true
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"vm-monitoring-system/internal/models"
+	"vm-monitoring-system/internal/services"
+)
+
+// NotificationTemplateHandler 通知模板处理器
+type NotificationTemplateHandler struct {
+	db        *gorm.DB
+	templates *services.NotificationTemplateService
+}
+
+// NewNotificationTemplateHandler 创建通知模板处理器
+func NewNotificationTemplateHandler(db *gorm.DB, templates *services.NotificationTemplateService) *NotificationTemplateHandler {
+	if templates == nil {
+		templates = services.NewNotificationTemplateService(db)
+	}
+	return &NotificationTemplateHandler{db: db, templates: templates}
+}
+
+// respondTemplateError 返回通知模板相关的错误
+func respondTemplateError(c *gin.Context, action string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrTemplateNotFound), errors.Is(err, services.ErrAlertNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidTemplate):
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": action + ": " + err.Error(),
+	})
+}
+
+// parseTemplateID 解析路径中的模板ID
+func parseTemplateID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "模板ID格式错误",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// NotificationTemplateRequest 创建或修改通知模板请求
+type NotificationTemplateRequest struct {
+	Name        string  `json:"name" binding:"required,max=100"`
+	Channel     string  `json:"channel" binding:"required,oneof=email sms webhook inApp"`
+	Language    string  `json:"language" binding:"max=20"`
+	Subject     string  `json:"subject"`
+	Body        string  `json:"body" binding:"required"`
+	Description *string `json:"description"`
+	IsDefault   bool    `json:"isDefault"`
+}
+
+// toModel 转换为模板模型
+func (r NotificationTemplateRequest) toModel() models.NotificationTemplate {
+	return models.NotificationTemplate{
+		Name:        r.Name,
+		Channel:     r.Channel,
+		Language:    r.Language,
+		Subject:     r.Subject,
+		Body:        r.Body,
+		Description: r.Description,
+		IsDefault:   r.IsDefault,
+	}
+}
+
+// List 获取通知模板列表（可按渠道、语言筛选）
+func (h *NotificationTemplateHandler) List(c *gin.Context) {
+	templates, err := h.templates.List(c.Query("channel"), c.Query("language"))
+	if err != nil {
+		respondTemplateError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    templates,
+	})
+}
+
+// Get 获取通知模板详情
+func (h *NotificationTemplateHandler) Get(c *gin.Context) {
+	id, ok := parseTemplateID(c)
+	if !ok {
+		return
+	}
+
+	tmpl, err := h.templates.Get(id)
+	if err != nil {
+		respondTemplateError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    tmpl,
+	})
+}
+
+// Create 创建通知模板
+func (h *NotificationTemplateHandler) Create(c *gin.Context) {
+	var req NotificationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	tmpl := req.toModel()
+	tmpl.CreatedBy = requestUserID(c)
+	if err := h.templates.Create(&tmpl); err != nil {
+		respondTemplateError(c, "创建失败", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "创建成功",
+		"data":    tmpl,
+	})
+}
+
+// Update 修改通知模板
+func (h *NotificationTemplateHandler) Update(c *gin.Context) {
+	id, ok := parseTemplateID(c)
+	if !ok {
+		return
+	}
+
+	var req NotificationTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	tmpl, err := h.templates.Update(id, req.toModel())
+	if err != nil {
+		respondTemplateError(c, "更新失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新成功",
+		"data":    tmpl,
+	})
+}
+
+// Delete 删除通知模板
+func (h *NotificationTemplateHandler) Delete(c *gin.Context) {
+	id, ok := parseTemplateID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.templates.Delete(id); err != nil {
+		respondTemplateError(c, "删除失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除成功",
+	})
+}
+
+// PreviewTemplateRequest 模板预览请求：templateId与template二选一，alertId为空时使用示例告警
+type PreviewTemplateRequest struct {
+	TemplateID *uuid.UUID `json:"templateId"`
+	Template   *struct {
+		Channel  string `json:"channel" binding:"required,oneof=email sms webhook inApp"`
+		Language string `json:"language" binding:"max=20"`
+		Subject  string `json:"subject"`
+		Body     string `json:"body" binding:"required"`
+	} `json:"template"`
+	AlertID *uuid.UUID `json:"alertId"`
+}
+
+// Preview 使用示例告警或真实告警渲染模板
+func (h *NotificationTemplateHandler) Preview(c *gin.Context) {
+	var req PreviewTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	var tmpl *models.NotificationTemplate
+	switch {
+	case req.TemplateID != nil:
+		stored, err := h.templates.Get(*req.TemplateID)
+		if err != nil {
+			respondTemplateError(c, "预览失败", err)
+			return
+		}
+		tmpl = stored
+	case req.Template != nil:
+		tmpl = &models.NotificationTemplate{
+			Channel:  req.Template.Channel,
+			Language: req.Template.Language,
+			Subject:  req.Template.Subject,
+			Body:     req.Template.Body,
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请指定templateId或template",
+		})
+		return
+	}
+
+	rendered, err := h.templates.Preview(tmpl, req.AlertID)
+	if err != nil {
+		respondTemplateError(c, "预览失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    rendered,
+	})
+}
@@ -2,47 +2,151 @@ package api
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
+	"vm-monitoring-system/internal/services"
+
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
 // RealtimeHandler 实时监控处理器
 type RealtimeHandler struct {
-	db      *gorm.DB
-	wsHub   *WebSocketHub
+	db                *gorm.DB
+	wsHub             *WebSocketHub
+	timeSeriesService *services.TimeSeriesService
 }
 
 // NewRealtimeHandler 创建实时监控处理器
 func NewRealtimeHandler(db *gorm.DB, wsHub *WebSocketHub) *RealtimeHandler {
 	return &RealtimeHandler{
-		db:    db,
-		wsHub: wsHub,
+		db:                db,
+		wsHub:             wsHub,
+		timeSeriesService: services.NewTimeSeriesService(db),
 	}
 }
 
+// SetTimeSeriesService 设置时序数据服务（与写入路径共用热缓存）
+func (h *RealtimeHandler) SetTimeSeriesService(timeSeriesService *services.TimeSeriesService) {
+	h.timeSeriesService = timeSeriesService
+}
+
+// latestPayload 组织VM最新指标
+func latestPayload(vmID string, points []services.MetricData) gin.H {
+	metrics := gin.H{}
+	var latest time.Time
+	for _, p := range points {
+		metrics[p.Metric] = gin.H{
+			"value":     p.Value,
+			"timestamp": p.Timestamp.Format(time.RFC3339),
+		}
+		if p.Timestamp.After(latest) {
+			latest = p.Timestamp
+		}
+	}
+
+	payload := gin.H{
+		"vmId":    vmID,
+		"metrics": metrics,
+	}
+	if !latest.IsZero() {
+		payload["timestamp"] = latest.Format(time.RFC3339)
+	}
+	return payload
+}
+
 // GetVMMetrics 获取VM实时指标
 func (h *RealtimeHandler) GetVMMetrics(c *gin.Context) {
 	vmID := c.Param("id")
-	
-	// TODO: 实现从时序数据库查询实时指标
-	// 使用 TimeSeriesService 查询最新的指标数据
-	
+
+	latest, err := h.timeSeriesService.GetLatestPoints([]string{vmID})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询实时指标失败",
+		})
+		return
+	}
+
+	points, ok := latest[vmID]
+	if !ok || len(points) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": "暂无该VM的指标数据",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    latestPayload(vmID, points),
+	})
+}
+
+// GetVMWindow 获取VM最近一段时间的指标（默认15分钟，最长为热缓存保留时长）
+func (h *RealtimeHandler) GetVMWindow(c *gin.Context) {
+	vmID := c.Param("id")
+
+	minutes, err := strconv.Atoi(c.DefaultQuery("minutes", "15"))
+	if err != nil || minutes <= 0 || minutes > 24*60 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "时间窗口参数错误",
+		})
+		return
+	}
+
+	var metrics []string
+	if m := c.Query("metrics"); m != "" {
+		metrics = strings.Split(m, ",")
+	} else {
+		latest, err := h.timeSeriesService.GetLatestPoints([]string{vmID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "查询实时指标失败",
+			})
+			return
+		}
+		for _, p := range latest[vmID] {
+			metrics = append(metrics, p.Metric)
+		}
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(minutes) * time.Minute)
+
+	series := gin.H{}
+	if len(metrics) > 0 {
+		points, err := h.timeSeriesService.QueryMetrics([]string{vmID}, metrics, startTime, endTime)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "查询实时指标失败",
+			})
+			return
+		}
+
+		for _, p := range points {
+			list, _ := series[p.Metric].([]gin.H)
+			series[p.Metric] = append(list, gin.H{
+				"timestamp": p.Timestamp.Format(time.RFC3339),
+				"value":     p.Value,
+			})
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
 		"data": gin.H{
 			"vmId":      vmID,
-			"timestamp": time.Now().Format(time.RFC3339),
-			"cpu": gin.H{
-				"usagePercent": 35.5,
-				"usageMHz":     1420,
-			},
-			"memory": gin.H{
-				"usagePercent": 52.3,
-				"usedMB":       4290,
-			},
+			"startTime": startTime.Format(time.RFC3339),
+			"endTime":   endTime.Format(time.RFC3339),
+			"series":    series,
 		},
 	})
 }
@@ -64,15 +168,42 @@ func (h *RealtimeHandler) BatchGetMetrics(c *gin.Context) {
 		return
 	}
 
-	// TODO: 实现批量查询逻辑
-	// 使用 TimeSeriesService 批量查询指标数据
-	
+	latest, err := h.timeSeriesService.GetLatestPoints(req.VMIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询实时指标失败",
+		})
+		return
+	}
+
+	wanted := make(map[string]bool, len(req.Metrics))
+	for _, m := range req.Metrics {
+		wanted[m] = true
+	}
+
+	result := []gin.H{}
+	notFound := []string{}
+	for _, vmID := range req.VMIDs {
+		points := []services.MetricData{}
+		for _, p := range latest[vmID] {
+			if len(wanted) == 0 || wanted[p.Metric] {
+				points = append(points, p)
+			}
+		}
+		if len(points) == 0 {
+			notFound = append(notFound, vmID)
+			continue
+		}
+		result = append(result, latestPayload(vmID, points))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
 		"data": gin.H{
-			"metrics": []gin.H{},
-			"notFound": []string{},
+			"metrics":  result,
+			"notFound": notFound,
 		},
 	})
 }
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"vm-monitoring-system/internal/services"
+)
+
+// RemediationHandler 自动处置处理器
+type RemediationHandler struct {
+	db          *gorm.DB
+	remediation *services.RemediationService
+	workflow    *services.AlertWorkflowService
+}
+
+// NewRemediationHandler 创建自动处置处理器
+func NewRemediationHandler(db *gorm.DB, remediation *services.RemediationService) *RemediationHandler {
+	if remediation == nil {
+		remediation = services.NewRemediationService(db)
+	}
+	return &RemediationHandler{db: db, remediation: remediation, workflow: services.NewAlertWorkflowService(db)}
+}
+
+// respondRemediationError 返回自动处置相关的错误
+func respondRemediationError(c *gin.Context, action string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrRemediationNotFound):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrInvalidRemediation), errors.Is(err, services.ErrRemediationHalted):
+		status = http.StatusConflict
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": action + ": " + err.Error(),
+	})
+}
+
+// parseRemediationID 解析路径中的执行记录ID
+func parseRemediationID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "执行记录ID格式错误",
+		})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+// List 获取处置执行记录（可按状态筛选，如pending_approval为待审批）
+func (h *RemediationHandler) List(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+
+	executions, total, err := h.remediation.List(c.Query("status"), page, pageSize)
+	if err != nil {
+		respondRemediationError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data": gin.H{
+			"list": executions,
+			"pagination": gin.H{
+				"page":       page,
+				"pageSize":   pageSize,
+				"total":      total,
+				"totalPages": int((total + int64(pageSize) - 1) / int64(pageSize)),
+			},
+		},
+	})
+}
+
+// ListForAlert 获取告警的处置执行记录与日志
+func (h *RemediationHandler) ListForAlert(c *gin.Context) {
+	id, ok := parseRecordID(c)
+	if !ok {
+		return
+	}
+
+	executions, err := h.remediation.ListForAlert(id)
+	if err != nil {
+		respondRemediationError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    executions,
+	})
+}
+
+// Approve 审批通过处置动作
+func (h *RemediationHandler) Approve(c *gin.Context) {
+	id, ok := parseRemediationID(c)
+	if !ok {
+		return
+	}
+
+	execution, err := h.remediation.Approve(id, h.workflow.Actor(requestUserID(c)))
+	if err != nil {
+		respondRemediationError(c, "审批失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已批准执行",
+		"data":    execution,
+	})
+}
+
+// RejectRemediationRequest 拒绝处置动作请求
+type RejectRemediationRequest struct {
+	Reason string `json:"reason" binding:"max=500"`
+}
+
+// Reject 拒绝处置动作
+func (h *RemediationHandler) Reject(c *gin.Context) {
+	id, ok := parseRemediationID(c)
+	if !ok {
+		return
+	}
+
+	var req RejectRemediationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	execution, err := h.remediation.Reject(id, h.workflow.Actor(requestUserID(c)), req.Reason)
+	if err != nil {
+		respondRemediationError(c, "操作失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "已拒绝执行",
+		"data":    execution,
+	})
+}
+
+// GetKillSwitch 获取紧急停止开关状态
+func (h *RemediationHandler) GetKillSwitch(c *gin.Context) {
+	sw, err := h.remediation.KillSwitch()
+	if err != nil {
+		respondRemediationError(c, "查询失败", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "success",
+		"data":    sw,
+	})
+}
+
+// KillSwitchRequest 紧急停止开关请求
+type KillSwitchRequest struct {
+	Engaged *bool  `json:"engaged" binding:"required"`
+	Reason  string `json:"reason" binding:"max=500"`
+}
+
+// SetKillSwitch 打开或关闭紧急停止开关，打开时取消所有待审批与排队中的动作
+func (h *RemediationHandler) SetKillSwitch(c *gin.Context) {
+	var req KillSwitchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	sw, err := h.remediation.SetKillSwitch(*req.Engaged, req.Reason, h.workflow.Actor(requestUserID(c)))
+	if err != nil {
+		respondRemediationError(c, "操作失败", err)
+		return
+	}
+
+	message := "已恢复自动处置"
+	if sw.Engaged {
+		message = "已紧急停止自动处置"
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": message,
+		"data":    sw,
+	})
+}
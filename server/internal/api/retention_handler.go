@@ -0,0 +1,243 @@
+package api
+
+import (
+	"net/http"
+
+	"vm-monitoring-system/internal/models"
+	"vm-monitoring-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RetentionHandler 数据保留策略处理器
+type RetentionHandler struct {
+	db               *gorm.DB
+	retentionService *services.RetentionService
+}
+
+// NewRetentionHandler 创建数据保留策略处理器
+func NewRetentionHandler(db *gorm.DB, retentionService *services.RetentionService) *RetentionHandler {
+	if retentionService == nil {
+		retentionService = services.NewRetentionService(db)
+	}
+	return &RetentionHandler{db: db, retentionService: retentionService}
+}
+
+// RetentionPolicyRequest 创建/更新保留策略请求
+type RetentionPolicyRequest struct {
+	Name                string     `json:"name" binding:"required,max=100"`
+	Description         *string    `json:"description"`
+	Target              string     `json:"target" binding:"omitempty,oneof=metrics audit_logs"`
+	MetricPattern       *string    `json:"metricPattern" binding:"omitempty,max=100"`
+	GroupID             *uuid.UUID `json:"groupId"`
+	TagKey              *string    `json:"tagKey" binding:"omitempty,max=100"`
+	TagValue            *string    `json:"tagValue" binding:"omitempty,max=200"`
+	RawRetentionDays    int        `json:"rawRetentionDays" binding:"required,min=1"`
+	RollupRetentionDays int        `json:"rollupRetentionDays" binding:"min=0"`
+	Priority            int        `json:"priority"`
+	Enabled             *bool      `json:"enabled"`
+}
+
+// apply 将请求内容写入策略
+func (r *RetentionPolicyRequest) apply(p *models.RetentionPolicy) {
+	p.Name = r.Name
+	p.Description = r.Description
+	p.Target = r.Target
+	p.MetricPattern = r.MetricPattern
+	p.GroupID = r.GroupID
+	p.TagKey = r.TagKey
+	p.TagValue = r.TagValue
+	p.RawRetentionDays = r.RawRetentionDays
+	p.RollupRetentionDays = r.RollupRetentionDays
+	p.Priority = r.Priority
+	p.Enabled = r.Enabled == nil || *r.Enabled
+}
+
+// ListPolicies 获取保留策略列表（按生效顺序）
+func (h *RetentionHandler) ListPolicies(c *gin.Context) {
+	policies, err := h.retentionService.ListPolicies()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    policies,
+	})
+}
+
+// CreatePolicy 创建保留策略
+func (h *RetentionHandler) CreatePolicy(c *gin.Context) {
+	var req RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	policy := models.RetentionPolicy{ID: uuid.New()}
+	req.apply(&policy)
+	if err := services.ValidateRetentionPolicy(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if userID, exists := c.Get("userID"); exists {
+		if uid, ok := userID.(uuid.UUID); ok {
+			policy.CreatedBy = &uid
+		}
+	}
+
+	if err := h.db.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "创建失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "创建成功",
+		"data":    policy,
+	})
+}
+
+// UpdatePolicy 更新保留策略
+func (h *RetentionHandler) UpdatePolicy(c *gin.Context) {
+	policy, ok := h.findPolicy(c)
+	if !ok {
+		return
+	}
+
+	var req RetentionPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	req.apply(policy)
+	if err := services.ValidateRetentionPolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := h.db.Save(policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "更新失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新成功",
+		"data":    policy,
+	})
+}
+
+// DeletePolicy 删除保留策略
+func (h *RetentionHandler) DeletePolicy(c *gin.Context) {
+	policy, ok := h.findPolicy(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除成功",
+	})
+}
+
+// DryRun 预演保留策略，返回每个策略将删除的行数
+func (h *RetentionHandler) DryRun(c *gin.Context) {
+	report, err := h.retentionService.DryRun()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "预演失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "预演成功",
+		"data":    report,
+	})
+}
+
+// Enforce 立即执行保留策略
+func (h *RetentionHandler) Enforce(c *gin.Context) {
+	report, err := h.retentionService.Enforce()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "执行失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "执行成功",
+		"data":    report,
+	})
+}
+
+// findPolicy 根据路径参数查询策略，失败时直接写入错误响应
+func (h *RetentionHandler) findPolicy(c *gin.Context) (*models.RetentionPolicy, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "策略ID格式错误",
+		})
+		return nil, false
+	}
+
+	var policy models.RetentionPolicy
+	if err := h.db.Where("id = ?", id).First(&policy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "策略不存在",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询失败: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	return &policy, true
+}
@@ -22,6 +22,17 @@ type Server struct {
 	db                   *gorm.DB
 	http                 *http.Server
 	alertEngine          *services.AlertEngine
+	alertWorkflow        *services.AlertWorkflowService
+	incidentService      *services.IncidentService
+	notifier             *services.NotificationService
+	templateService      *services.NotificationTemplateService
+	escalationService    *services.EscalationService
+	remediationService   *services.RemediationService
+	analyticsService     *services.AlertAnalyticsService
+	engineCluster        *services.EngineCluster
+	timeSeriesService    *services.TimeSeriesService
+	anomalyService       *services.AnomalyService
+	retentionService     *services.RetentionService
 	vsphereCollector     *services.VSphereCollector
 	permissionMiddleware *PermissionMiddleware
 	wsHub                *WebSocketHub
@@ -48,6 +59,81 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 	// 创建权限中间件
 	server.permissionMiddleware = NewPermissionMiddleware(db)
 
+	// 创建时序数据服务，写入时同步更新进程内热缓存
+	server.timeSeriesService = services.NewTimeSeriesService(db)
+	server.timeSeriesService.SetHotCache(services.NewHotCache(cfg.Retention.HotCacheDuration))
+
+	// 创建异常检测服务（基线学习在Start中启动）
+	server.anomalyService = services.NewAnomalyService(db, server.timeSeriesService)
+
+	// 创建数据保留服务（定期清理在Start中启动）
+	server.retentionService = services.NewRetentionService(db)
+	server.retentionService.SetInterval(cfg.Retention.EnforceInterval)
+	server.retentionService.SetBatchLimits(cfg.Retention.BatchSize, cfg.Retention.MaxBatchesPerRun)
+	server.retentionService.SetDefaults(cfg.Retention.DefaultRawDays, cfg.Retention.DefaultRollupDays, cfg.Retention.AuditLogDays)
+
+	// 创建告警引擎（在Start中启动），写入驱动评估时由时序数据服务在写入后通知引擎
+	server.templateService = services.NewNotificationTemplateService(db)
+	server.templateService.SetConsoleURL(cfg.Alert.ConsoleURL)
+	server.notifier = services.NewNotificationService()
+	server.notifier.SetTemplates(server.templateService)
+	server.notifier.Enable()
+	server.alertEngine = services.NewAlertEngine(db, server.notifier)
+	server.alertEngine.SetAnomalyService(server.anomalyService)
+	server.alertEngine.SetTimeSeriesService(server.timeSeriesService)
+	server.alertEngine.SetEvalInterval(cfg.Alert.EvalInterval)
+	server.alertEngine.SetDeadman(cfg.Alert.DeadmanTimeout)
+	server.alertEngine.SetSnapshotWindow(cfg.Alert.SnapshotWindow)
+
+	// 告警处理流程：状态变更、指派与评论写入历史，并通过WebSocket推送给实时看板
+	server.alertWorkflow = services.NewAlertWorkflowService(db)
+	server.alertWorkflow.SetPublisher(server.wsHub.BroadcastAlertActivity)
+	server.alertEngine.SetWorkflow(server.alertWorkflow)
+
+	// 事件：按配置的维度自动归并告警
+	server.incidentService = services.NewIncidentService(db)
+	if err := server.incidentService.SetGrouping(cfg.Alert.IncidentGroupBy, cfg.Alert.IncidentWindow); err != nil {
+		logger.Warn("事件归并配置无效，使用默认配置", zap.Error(err))
+	}
+	server.incidentService.SetNotifyPerIncident(cfg.Alert.IncidentNotify)
+	server.alertEngine.SetIncidents(server.incidentService)
+	server.alertWorkflow.SetIncidents(server.incidentService)
+
+	// 自动处置：规则配置的动作按告警级别审批后由后台执行（在Start中启动）
+	server.remediationService = services.NewRemediationService(db)
+	server.remediationService.SetEnabled(cfg.Remediation.Enabled)
+	server.remediationService.SetInterval(cfg.Remediation.Interval)
+	server.remediationService.SetApprovalSeverities(cfg.Remediation.ApprovalSeverities)
+	server.remediationService.SetMaxTimeout(cfg.Remediation.MaxTimeout)
+	server.remediationService.SetConcurrency(cfg.Remediation.Concurrency)
+	server.remediationService.SetTemplates(server.templateService)
+	if err := server.remediationService.SetScripts(cfg.Remediation.Scripts); err != nil {
+		logger.Warn("处置脚本白名单配置无效，不允许执行脚本", zap.Error(err))
+	}
+	server.alertEngine.SetRemediation(server.remediationService)
+
+	// 告警响应时间统计：按配置的各级别SLA目标统计超时
+	server.analyticsService = services.NewAlertAnalyticsService(db)
+	slaTargets := make(map[string]services.SLATarget, len(cfg.Alert.SLATargets))
+	for severity, target := range cfg.Alert.SLATargets {
+		slaTargets[severity] = services.SLATarget{Acknowledge: target.Acknowledge, Resolve: target.Resolve}
+	}
+	server.analyticsService.SetSLATargets(slaTargets)
+	if cfg.Alert.IngestEvaluation {
+		server.alertEngine.SetIngestEvaluation(true)
+		server.timeSeriesService.SetIngestListener(server.alertEngine.NotifyIngest)
+	}
+
+	// 创建告警引擎集群协调（心跳在Start中启动）
+	if cfg.Alert.Sharding {
+		server.engineCluster = services.NewEngineCluster(db, cfg.Alert.InstanceID)
+		server.engineCluster.SetShardCount(cfg.Alert.ShardCount)
+		server.engineCluster.SetLeaseTiming(cfg.Alert.LeaseTTL, cfg.Alert.HeartbeatInterval)
+		server.alertEngine.SetCluster(server.engineCluster)
+		// 其他实例写入的数据不在本进程热缓存中，集群有多个成员时查询回退到数据库
+		server.timeSeriesService.SetHotCacheGuard(server.engineCluster.SingleInstance)
+	}
+
 	// 注册中间件
 	server.setupMiddleware()
 
@@ -144,7 +230,9 @@ func (s *Server) setupRoutes() {
 			realtime := authorized.Group("/realtime")
 			{
 				realtimeHandler := NewRealtimeHandler(s.db, s.wsHub)
+				realtimeHandler.SetTimeSeriesService(s.timeSeriesService)
 				realtime.GET("/vms/:id", realtimeHandler.GetVMMetrics)
+				realtime.GET("/vms/:id/window", realtimeHandler.GetVMWindow)
 				realtime.POST("/vms/batch", realtimeHandler.BatchGetMetrics)
 				realtime.GET("/groups/:id", realtimeHandler.GetGroupMetrics)
 				realtime.GET("/clusters/:id", realtimeHandler.GetClusters)
@@ -162,10 +250,17 @@ func (s *Server) setupRoutes() {
 			history := authorized.Group("/history")
 			{
 				historyHandler := NewHistoryHandler(s.db)
+				historyHandler.SetTimeSeriesService(s.timeSeriesService)
+				historyHandler.SetAnomalyService(s.anomalyService)
 				history.POST("/query", historyHandler.Query)
 				history.POST("/aggregate", historyHandler.Aggregate)
 				history.POST("/trends", historyHandler.Trends)
 				history.POST("/anomalies", historyHandler.Anomalies)
+				history.GET("/anomalies/:vmId/:metric", historyHandler.SeriesAnomalies)
+				history.GET("/baselines/:vmId/:metric", historyHandler.GetBaseline)
+				history.POST("/baselines/train", historyHandler.TrainBaselines)
+				history.POST("/correlate", historyHandler.Correlate)
+				history.POST("/compare", historyHandler.Compare)
 				history.POST("/export", historyHandler.Export)
 				history.GET("/export/:id", historyHandler.GetExportTask)
 				history.GET("/timeline/:vmId", historyHandler.GetTimeline)
@@ -175,6 +270,10 @@ func (s *Server) setupRoutes() {
 			alerts := authorized.Group("/alerts")
 			{
 				alertHandler := NewAlertHandler(s.db)
+				alertHandler.SetWorkflow(s.alertWorkflow)
+				alertHandler.SetAnalytics(s.analyticsService)
+				alertHandler.SetBacktest(services.NewBacktestService(s.db, s.config.Alert.EvalInterval))
+				remediationHandler := NewRemediationHandler(s.db, s.remediationService)
 
 				// 告警规则
 				rules := alerts.Group("/rules")
@@ -187,6 +286,12 @@ func (s *Server) setupRoutes() {
 					rules.PUT("/batch/status", alertHandler.BatchUpdateRuleStatus)
 					rules.POST("/import", alertHandler.ImportRules)
 					rules.POST("/export", alertHandler.ExportRules)
+					rules.POST("/validate", alertHandler.ValidateExpression)
+					rules.POST("/test", alertHandler.TestRule)
+					rules.GET("/:id/revisions", alertHandler.ListRuleRevisions)
+					rules.GET("/:id/revisions/:version", alertHandler.GetRuleRevision)
+					rules.GET("/:id/diff", alertHandler.DiffRuleRevisions)
+					rules.POST("/:id/rollback", alertHandler.RollbackRule)
 				}
 
 				// 告警记录
@@ -198,11 +303,78 @@ func (s *Server) setupRoutes() {
 					records.PUT("/batch/acknowledge", alertHandler.BatchAcknowledge)
 					records.PUT("/:id/resolve", alertHandler.Resolve)
 					records.PUT("/:id/ignore", alertHandler.Ignore)
+					records.PUT("/:id/status", alertHandler.ChangeStatus)
+					records.PUT("/:id/assign", alertHandler.Assign)
+					records.GET("/:id/comments", alertHandler.ListComments)
+					records.POST("/:id/comments", alertHandler.AddComment)
+					records.GET("/:id/activity", alertHandler.Activity)
+					records.GET("/:id/remediations", remediationHandler.ListForAlert)
+				}
+
+				// 自动处置（审批与紧急停止开关）
+				remediations := alerts.Group("/remediations")
+				{
+					remediations.GET("", remediationHandler.List)
+					remediations.POST("/:id/approve", remediationHandler.Approve)
+					remediations.POST("/:id/reject", remediationHandler.Reject)
+					remediations.GET("/kill-switch", remediationHandler.GetKillSwitch)
+					remediations.PUT("/kill-switch", remediationHandler.SetKillSwitch)
+				}
+
+				// 事件（相关告警的归并）
+				incidents := alerts.Group("/incidents")
+				{
+					incidentHandler := NewIncidentHandler(s.db, s.incidentService)
+					incidents.GET("", incidentHandler.List)
+					incidents.GET("/:id", incidentHandler.Get)
+					incidents.PUT("/:id", incidentHandler.Update)
+					incidents.GET("/:id/timeline", incidentHandler.Timeline)
+					incidents.POST("/:id/split", incidentHandler.Split)
+					incidents.POST("/merge", incidentHandler.Merge)
+				}
+
+				// 通知模板
+				templates := alerts.Group("/notification-templates")
+				{
+					templateHandler := NewNotificationTemplateHandler(s.db, s.templateService)
+					templates.GET("", templateHandler.List)
+					templates.GET("/:id", templateHandler.Get)
+					templates.POST("", templateHandler.Create)
+					templates.PUT("/:id", templateHandler.Update)
+					templates.DELETE("/:id", templateHandler.Delete)
+					templates.POST("/preview", templateHandler.Preview)
+				}
+
+				// 告警状态
+				alerts.GET("/states", alertHandler.ListStates)
+
+				// 静默与维护窗口
+				silences := alerts.Group("/silences")
+				{
+					silenceHandler := NewSilenceHandler(s.db)
+					silences.GET("", silenceHandler.List)
+					silences.GET("/:id", silenceHandler.Get)
+					silences.POST("", silenceHandler.Create)
+					silences.PUT("/:id", silenceHandler.Update)
+					silences.POST("/:id/expire", silenceHandler.Expire)
+					silences.DELETE("/:id", silenceHandler.Delete)
+				}
+
+				// 抑制规则
+				inhibitRules := alerts.Group("/inhibit-rules")
+				{
+					inhibitHandler := NewInhibitHandler(s.db)
+					inhibitRules.GET("", inhibitHandler.List)
+					inhibitRules.POST("", inhibitHandler.Create)
+					inhibitRules.PUT("/:id", inhibitHandler.Update)
+					inhibitRules.DELETE("/:id", inhibitHandler.Delete)
 				}
 
 				// 统计
 				alerts.GET("/statistics", alertHandler.Statistics)
 				alerts.GET("/trends", alertHandler.Trends)
+				alerts.GET("/analytics", alertHandler.Analytics)
+				alerts.GET("/analytics/report", alertHandler.AnalyticsReport)
 			}
 
 			// 用户权限管理
@@ -263,11 +435,14 @@ func (s *Server) setupRoutes() {
 			system := authorized.Group("/system")
 			{
 				systemHandler := NewSystemHandler(s.db, s.config)
+				systemHandler.SetEngineCluster(s.engineCluster)
+				systemHandler.SetAlertEngine(s.alertEngine)
 				system.GET("/overview", systemHandler.Overview)
 				system.GET("/health-score", systemHandler.HealthScore)
 				system.GET("/health-trend", systemHandler.HealthTrend)
 				system.GET("/services", systemHandler.Services)
 				system.GET("/collectors", systemHandler.Collectors)
+				system.GET("/alert-engine", systemHandler.AlertEngine)
 				system.GET("/storage", systemHandler.Storage)
 				system.GET("/performance", systemHandler.Performance)
 				system.GET("/capacity", systemHandler.Capacity)
@@ -278,6 +453,14 @@ func (s *Server) setupRoutes() {
 				system.GET("/audit-logs", systemHandler.GetAuditLogs)
 				system.POST("/logs/export", systemHandler.ExportLogs)
 				system.POST("/maintenance/cleanup", systemHandler.Cleanup)
+
+				retentionHandler := NewRetentionHandler(s.db, s.retentionService)
+				system.GET("/retention/policies", retentionHandler.ListPolicies)
+				system.POST("/retention/policies", retentionHandler.CreatePolicy)
+				system.PUT("/retention/policies/:id", retentionHandler.UpdatePolicy)
+				system.DELETE("/retention/policies/:id", retentionHandler.DeletePolicy)
+				system.GET("/retention/dry-run", retentionHandler.DryRun)
+				system.POST("/retention/enforce", retentionHandler.Enforce)
 				system.GET("/maintenance/tasks", systemHandler.ListTasks)
 				system.GET("/maintenance/tasks/:id", systemHandler.GetTask)
 			}
@@ -314,8 +497,46 @@ func (s *Server) setupVSphereCollector() {
 	*/
 }
 
+// setupAlertEngine 初始化并启动告警引擎
+func (s *Server) setupAlertEngine() {
+	// 多实例部署时只评估本实例持有租约的分片
+	if s.engineCluster != nil {
+		if err := s.engineCluster.Start(); err != nil {
+			logger.Error("告警引擎集群协调启动失败", zap.Error(err))
+		}
+	}
+
+	if err := s.alertEngine.Start(); err != nil {
+		logger.Error("告警引擎启动失败", zap.Error(err))
+	}
+
+	// 告警升级与告警引擎共用通知服务
+	s.escalationService = services.NewEscalationService(s.db, s.notifier)
+	if err := s.escalationService.Start(); err != nil {
+		logger.Error("告警升级服务启动失败", zap.Error(err))
+	}
+
+	// VM操作动作通过vSphere采集器执行
+	if s.vsphereCollector != nil {
+		s.remediationService.SetOperator(s.vsphereCollector)
+	}
+	if err := s.remediationService.Start(); err != nil {
+		logger.Error("自动处置服务启动失败", zap.Error(err))
+	}
+}
+
 // Start 启动服务器
 func (s *Server) Start() error {
+	// 启动异常检测基线学习
+	if err := s.anomalyService.Start(); err != nil {
+		logger.Error("异常检测基线学习启动失败", zap.Error(err))
+	}
+
+	// 启动数据保留策略定期执行
+	if err := s.retentionService.Start(); err != nil {
+		logger.Error("数据保留任务启动失败", zap.Error(err))
+	}
+
 	// 启动告警引擎
 	s.setupAlertEngine()
 
@@ -336,6 +557,31 @@ func (s *Server) Stop() error {
 		logger.Info("告警引擎已停止")
 	}
 
+	// 释放分片租约，使其他实例立即接管
+	if s.engineCluster != nil {
+		s.engineCluster.Stop()
+	}
+
+	// 停止告警升级
+	if s.escalationService != nil {
+		s.escalationService.Stop()
+	}
+
+	// 停止自动处置
+	if s.remediationService != nil {
+		s.remediationService.Stop()
+	}
+
+	// 停止异常检测基线学习
+	if s.anomalyService != nil {
+		s.anomalyService.Stop()
+	}
+
+	// 停止数据保留任务
+	if s.retentionService != nil {
+		s.retentionService.Stop()
+	}
+
 	// 停止vSphere采集器
 	if s.vsphereCollector != nil {
 		s.vsphereCollector.Stop()
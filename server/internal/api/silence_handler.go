@@ -0,0 +1,291 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+	"vm-monitoring-system/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SilenceHandler 告警静默/维护窗口处理器
+type SilenceHandler struct {
+	db             *gorm.DB
+	silenceService *services.SilenceService
+}
+
+// NewSilenceHandler 创建告警静默处理器
+func NewSilenceHandler(db *gorm.DB) *SilenceHandler {
+	return &SilenceHandler{db: db, silenceService: services.NewSilenceService(db)}
+}
+
+// SilenceRequest 创建/更新静默请求
+type SilenceRequest struct {
+	Name            string     `json:"name" binding:"required,max=100"`
+	Type            string     `json:"type" binding:"omitempty,oneof=silence maintenance"`
+	Mode            string     `json:"mode" binding:"omitempty,oneof=suppress_notification suppress_creation"`
+	Comment         *string    `json:"comment"`
+	Enabled         *bool      `json:"enabled"`
+	RuleID          *uuid.UUID `json:"ruleId"`
+	VMID            *uuid.UUID `json:"vmId"`
+	GroupID         *uuid.UUID `json:"groupId"`
+	ClusterID       *string    `json:"clusterId" binding:"omitempty,max=100"`
+	TagKey          *string    `json:"tagKey" binding:"omitempty,max=100"`
+	TagValue        *string    `json:"tagValue" binding:"omitempty,max=200"`
+	Severity        *string    `json:"severity" binding:"omitempty,oneof=critical high medium low"`
+	StartsAt        *time.Time `json:"startsAt"`
+	EndsAt          *time.Time `json:"endsAt"`
+	Schedule        *string    `json:"schedule" binding:"omitempty,max=100"`
+	DurationMinutes int        `json:"durationMinutes" binding:"min=0,max=10080"`
+	Timezone        string     `json:"timezone" binding:"omitempty,max=64"`
+}
+
+// apply 将请求内容写入静默
+func (r *SilenceRequest) apply(s *models.Silence) {
+	s.Name = r.Name
+	s.Type = r.Type
+	s.Mode = r.Mode
+	s.Comment = r.Comment
+	s.Enabled = r.Enabled == nil || *r.Enabled
+	s.RuleID = r.RuleID
+	s.VMID = r.VMID
+	s.GroupID = r.GroupID
+	s.ClusterID = r.ClusterID
+	s.TagKey = r.TagKey
+	s.TagValue = r.TagValue
+	s.Severity = r.Severity
+	s.EndsAt = r.EndsAt
+	s.Schedule = r.Schedule
+	s.DurationMinutes = r.DurationMinutes
+	s.Timezone = r.Timezone
+
+	// 未指定开始时间时立即生效
+	if r.StartsAt != nil {
+		s.StartsAt = *r.StartsAt
+	} else if s.StartsAt.IsZero() {
+		s.StartsAt = time.Now()
+	}
+}
+
+// SilenceView 静默及其生效状态
+type SilenceView struct {
+	models.Silence
+	Active      bool                     `json:"active"`
+	NextWindows []services.SilenceWindow `json:"nextWindows"`
+}
+
+// newSilenceView 计算静默当前是否生效及接下来的生效区间
+func newSilenceView(s models.Silence, now time.Time) SilenceView {
+	return SilenceView{
+		Silence:     s,
+		Active:      services.SilenceActiveAt(&s, now),
+		NextWindows: services.NextWindows(&s, now, 5),
+	}
+}
+
+// List 获取静默列表（?active=true 只返回当前生效的静默）
+func (h *SilenceHandler) List(c *gin.Context) {
+	now := time.Now()
+	silences, err := h.silenceService.List(c.Query("active") == "true", now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询失败: " + err.Error(),
+		})
+		return
+	}
+
+	views := make([]SilenceView, len(silences))
+	for i, s := range silences {
+		views[i] = newSilenceView(s, now)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    views,
+	})
+}
+
+// Get 获取静默详情
+func (h *SilenceHandler) Get(c *gin.Context) {
+	silence, ok := h.findSilence(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    newSilenceView(*silence, time.Now()),
+	})
+}
+
+// Create 创建静默
+func (h *SilenceHandler) Create(c *gin.Context) {
+	var req SilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	silence := models.Silence{ID: uuid.New()}
+	req.apply(&silence)
+	if err := services.ValidateSilence(&silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if userID, exists := c.Get("userID"); exists {
+		if uid, ok := userID.(uuid.UUID); ok {
+			silence.CreatedBy = &uid
+
+			var user models.User
+			if err := h.db.Select("name").First(&user, uid).Error; err == nil {
+				silence.CreatedByName = &user.Name
+			}
+		}
+	}
+
+	if err := h.db.Create(&silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "创建失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"code":    201,
+		"message": "创建成功",
+		"data":    newSilenceView(silence, time.Now()),
+	})
+}
+
+// Update 更新静默
+func (h *SilenceHandler) Update(c *gin.Context) {
+	silence, ok := h.findSilence(c)
+	if !ok {
+		return
+	}
+
+	var req SilenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "请求参数错误: " + err.Error(),
+		})
+		return
+	}
+
+	req.apply(silence)
+	if err := services.ValidateSilence(silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if err := h.db.Save(silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "更新失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "更新成功",
+		"data":    newSilenceView(*silence, time.Now()),
+	})
+}
+
+// Expire 立即结束静默
+func (h *SilenceHandler) Expire(c *gin.Context) {
+	silence, ok := h.findSilence(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	if now.Before(silence.StartsAt) {
+		silence.StartsAt = now.Add(-time.Second)
+	}
+	silence.EndsAt = &now
+
+	if err := h.db.Save(silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "操作失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "静默已结束",
+	})
+}
+
+// Delete 删除静默
+func (h *SilenceHandler) Delete(c *gin.Context) {
+	silence, ok := h.findSilence(c)
+	if !ok {
+		return
+	}
+
+	if err := h.db.Delete(silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "删除失败: " + err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "删除成功",
+	})
+}
+
+// findSilence 根据路径参数查询静默，失败时直接写入错误响应
+func (h *SilenceHandler) findSilence(c *gin.Context) (*models.Silence, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": "静默ID格式错误",
+		})
+		return nil, false
+	}
+
+	var silence models.Silence
+	if err := h.db.Where("id = ?", id).First(&silence).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": "静默不存在",
+			})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "查询失败: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	return &silence, true
+}
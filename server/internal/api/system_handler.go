@@ -6,6 +6,7 @@ import (
 
 	"vm-monitoring-system/internal/config"
 	"vm-monitoring-system/internal/models"
+	"vm-monitoring-system/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -14,13 +15,59 @@ import (
 
 // SystemHandler 系统健康处理器
 type SystemHandler struct {
-	db     *gorm.DB
-	config *config.Config
+	db           *gorm.DB
+	config       *config.Config
+	storageStats *services.StorageStatsService
+	cluster      *services.EngineCluster
+	engine       *services.AlertEngine
 }
 
 // NewSystemHandler 创建系统健康处理器
 func NewSystemHandler(db *gorm.DB, cfg *config.Config) *SystemHandler {
-	return &SystemHandler{db: db, config: cfg}
+	return &SystemHandler{
+		db:           db,
+		config:       cfg,
+		storageStats: services.NewStorageStatsService(db),
+	}
+}
+
+// SetEngineCluster 设置告警引擎集群协调器（未启用分片时为空）
+func (h *SystemHandler) SetEngineCluster(cluster *services.EngineCluster) {
+	h.cluster = cluster
+}
+
+// SetAlertEngine 设置告警引擎（用于返回评估模式与延迟统计）
+func (h *SystemHandler) SetAlertEngine(engine *services.AlertEngine) {
+	h.engine = engine
+}
+
+// AlertEngine 获取告警引擎状态：本实例的评估模式与延迟统计，以及集群成员、心跳、各实例持有的分片
+func (h *SystemHandler) AlertEngine(c *gin.Context) {
+	data := gin.H{
+		"sharding": h.cluster != nil,
+	}
+	if h.engine != nil {
+		data["running"] = h.engine.IsRunning()
+		data["evaluation"] = h.engine.EvaluationMetrics()
+	}
+
+	if h.cluster != nil {
+		status, err := h.cluster.Status()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": "获取告警引擎状态失败: " + err.Error(),
+			})
+			return
+		}
+		data["cluster"] = status
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":    200,
+		"message": "获取成功",
+		"data":    data,
+	})
 }
 
 // Overview 获取系统概览
@@ -107,22 +154,21 @@ func (h *SystemHandler) Collectors(c *gin.Context) {
 	})
 }
 
-// Storage 获取存储状态
+// Storage 获取存储统计（默认缓存5分钟，refresh=true 强制刷新）
 func (h *SystemHandler) Storage(c *gin.Context) {
+	stats, err := h.storageStats.GetStats(c.Query("refresh") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": "获取存储统计失败: " + err.Error(),
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": "获取成功",
-		"data": gin.H{
-			"database": gin.H{
-				"status": "healthy",
-			},
-			"disk": gin.H{
-				"totalGB":       2000,
-				"usedGB":        850,
-				"freeGB":        1150,
-				"usagePercent":  42.5,
-			},
-		},
+		"data":    stats,
 	})
 }
 
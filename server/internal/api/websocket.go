@@ -10,6 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
+
+	"vm-monitoring-system/internal/services"
 )
 
 // WebSocketConfig WebSocket配置
@@ -22,11 +24,12 @@ type WebSocketConfig struct {
 
 // VMMetricsMessage VM指标消息
 type VMMetricsMessage struct {
-	Type      string                 `json:"type"`       // metrics, alert, heartbeat
+	Type      string                 `json:"type"`       // metrics, alert, alert_activity, heartbeat
 	VMID      string                 `json:"vmId"`      // VM ID
 	Timestamp time.Time             `json:"timestamp"`  // 时间戳
 	Data      map[string]interface{} `json:"data"`      // 指标数据
 	Alert     *AlertMessage         `json:"alert,omitempty"` // 告警信息
+	Activity  *services.AlertActivityEvent `json:"activity,omitempty"` // 告警处理活动
 }
 
 // AlertMessage 告警消息
@@ -187,6 +190,19 @@ func (h *WebSocketHub) Broadcast(message *VMMetricsMessage) {
 	}
 }
 
+// BroadcastAlertActivity 广播告警状态变更、指派与评论，供NOC看板实时刷新
+func (h *WebSocketHub) BroadcastAlertActivity(event services.AlertActivityEvent) {
+	message := &VMMetricsMessage{
+		Type:      "alert_activity",
+		Timestamp: event.Activity.CreatedAt,
+		Activity:  &event,
+	}
+	if event.VMID != nil {
+		message.VMID = event.VMID.String()
+	}
+	h.Broadcast(message)
+}
+
 // Subscribe 客户端订阅VM
 func (c *Client) Subscribe(vmIDs []string) {
 	for _, vmID := range vmIDs {
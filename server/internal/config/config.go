@@ -9,12 +9,15 @@ import (
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig   `mapstructure:"server"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Redis    RedisConfig    `mapstructure:"redis"`
-	VSphere  VSphereConfig  `mapstructure:"vsphere"`
-	JWT      JWTConfig      `mapstructure:"jwt"`
-	Log      LogConfig      `mapstructure:"log"`
+	Server      ServerConfig      `mapstructure:"server"`
+	Database    DatabaseConfig    `mapstructure:"database"`
+	Redis       RedisConfig       `mapstructure:"redis"`
+	VSphere     VSphereConfig     `mapstructure:"vsphere"`
+	JWT         JWTConfig         `mapstructure:"jwt"`
+	Log         LogConfig         `mapstructure:"log"`
+	Retention   RetentionConfig   `mapstructure:"retention"`
+	Alert       AlertConfig       `mapstructure:"alert"`
+	Remediation RemediationConfig `mapstructure:"remediation"`
 }
 
 // ServerConfig 服务器配置
@@ -69,6 +72,52 @@ type LogConfig struct {
 	MaxAge     int    `mapstructure:"max_age"`     // days
 }
 
+// RetentionConfig 数据保留配置（未被任何保留策略匹配的数据使用默认值）
+type RetentionConfig struct {
+	EnforceInterval   time.Duration `mapstructure:"enforce_interval"`
+	BatchSize         int           `mapstructure:"batch_size"`
+	MaxBatchesPerRun  int           `mapstructure:"max_batches_per_run"`
+	DefaultRawDays    int           `mapstructure:"default_raw_days"`
+	DefaultRollupDays int           `mapstructure:"default_rollup_days"`
+	AuditLogDays      int           `mapstructure:"audit_log_days"`
+	HotCacheDuration  time.Duration `mapstructure:"hot_cache_duration"` // 进程内热缓存保留时长（多实例部署需开启分片，集群有多个成员时查询不读取缓存）
+}
+
+// AlertConfig 告警引擎配置。多实例部署时通过数据库租约表将评估任务按分片分配给各实例
+type AlertConfig struct {
+	EvalInterval      time.Duration `mapstructure:"eval_interval"`      // 定时评估间隔
+	IngestEvaluation  bool          `mapstructure:"ingest_evaluation"`  // 新数据写入时立即评估引用相应指标的规则
+	Sharding          bool          `mapstructure:"sharding"`           // 是否启用分片评估（单实例部署也可开启）
+	InstanceID        string        `mapstructure:"instance_id"`        // 实例ID，为空时自动生成
+	ShardCount        int           `mapstructure:"shard_count"`        // 分片数量，所有实例必须一致
+	LeaseTTL          time.Duration `mapstructure:"lease_ttl"`          // 租约有效期，实例失联超过该时间后分片转移
+	HeartbeatInterval time.Duration `mapstructure:"heartbeat_interval"` // 心跳与租约续期间隔
+	DeadmanTimeout    time.Duration `mapstructure:"deadman_timeout"`    // VM或指标超过该时间未上报时触发数据中断告警，0表示关闭
+	SnapshotWindow    time.Duration `mapstructure:"snapshot_window"`    // 告警快照记录触发前指标序列的时间窗口
+	IncidentGroupBy   []string      `mapstructure:"incident_group_by"`  // 新告警自动归入事件的维度：rule, cluster, host, datacenter, vm，为空表示不归并
+	IncidentWindow    time.Duration `mapstructure:"incident_window"`    // 同一事件的最近一条告警在该时间内，新告警才归入该事件
+	IncidentNotify    bool          `mapstructure:"incident_notify"`    // 按事件发送通知（新事件或级别升高时），而非每条告警
+	ConsoleURL        string        `mapstructure:"console_url"`        // 通知模板中告警、规则、VM链接指向的前端地址，为空时使用相对路径
+
+	SLATargets map[string]SLATargetConfig `mapstructure:"sla_targets"` // 按告警级别的响应目标，用于MTTA/MTTR超时统计
+}
+
+// SLATargetConfig 告警响应目标，0表示不考核
+type SLATargetConfig struct {
+	Acknowledge time.Duration `mapstructure:"acknowledge"` // 触发到确认
+	Resolve     time.Duration `mapstructure:"resolve"`     // 触发到解决
+}
+
+// RemediationConfig 告警自动处置配置。规则在通知配置的actions中定义处置动作
+type RemediationConfig struct {
+	Enabled            bool              `mapstructure:"enabled"`             // 是否执行处置动作，运行中可通过紧急停止开关暂停
+	Interval           time.Duration     `mapstructure:"interval"`            // 执行队列检查间隔
+	ApprovalSeverities []string          `mapstructure:"approval_severities"` // 这些级别的告警触发的动作须人工审批后执行
+	MaxTimeout         time.Duration     `mapstructure:"max_timeout"`         // 单次执行的最长时间，动作配置的超时不能超过该值
+	Concurrency        int               `mapstructure:"concurrency"`         // 同时执行的动作数量上限
+	Scripts            map[string]string `mapstructure:"scripts"`             // 允许执行的脚本白名单：名称 -> 绝对路径
+}
+
 // DSN 构建数据库连接字符串
 func (c *DatabaseConfig) DSN() string {
 	return fmt.Sprintf(
@@ -178,4 +227,40 @@ func setDefaults() {
 	viper.SetDefault("log.max_size", 100)
 	viper.SetDefault("log.max_backups", 10)
 	viper.SetDefault("log.max_age", 30)
+
+	// Retention
+	viper.SetDefault("retention.enforce_interval", "1h")
+	viper.SetDefault("retention.batch_size", 5000)
+	viper.SetDefault("retention.max_batches_per_run", 200)
+	viper.SetDefault("retention.default_raw_days", 30)
+	viper.SetDefault("retention.default_rollup_days", 365)
+	viper.SetDefault("retention.audit_log_days", 30)
+	viper.SetDefault("retention.hot_cache_duration", "3h")
+
+	// Alert
+	viper.SetDefault("alert.eval_interval", "60s")
+	viper.SetDefault("alert.ingest_evaluation", true)
+	viper.SetDefault("alert.sharding", true)
+	viper.SetDefault("alert.instance_id", "")
+	viper.SetDefault("alert.shard_count", 64)
+	viper.SetDefault("alert.lease_ttl", "30s")
+	viper.SetDefault("alert.heartbeat_interval", "10s")
+	viper.SetDefault("alert.deadman_timeout", "10m")
+	viper.SetDefault("alert.snapshot_window", "30m")
+	viper.SetDefault("alert.incident_group_by", []string{"cluster"})
+	viper.SetDefault("alert.incident_window", "30m")
+	viper.SetDefault("alert.incident_notify", false)
+	viper.SetDefault("alert.console_url", "")
+	viper.SetDefault("alert.sla_targets", map[string]interface{}{
+		"critical": map[string]interface{}{"acknowledge": "5m", "resolve": "1h"},
+		"high":     map[string]interface{}{"acknowledge": "15m", "resolve": "4h"},
+		"medium":   map[string]interface{}{"acknowledge": "1h", "resolve": "24h"},
+		"low":      map[string]interface{}{"acknowledge": "4h", "resolve": "72h"},
+	})
+
+	viper.SetDefault("remediation.enabled", false)
+	viper.SetDefault("remediation.interval", "10s")
+	viper.SetDefault("remediation.approval_severities", []string{"critical"})
+	viper.SetDefault("remediation.max_timeout", "5m")
+	viper.SetDefault("remediation.concurrency", 4)
 }
@@ -15,8 +15,15 @@ type AlertRule struct {
 	ScopeID            *uuid.UUID         `gorm:"type:uuid" json:"scopeId,omitempty"`
 	ScopeName          *string            `gorm:"type:varchar(200)" json:"scopeName,omitempty"`
 	ConditionLogic     string             `gorm:"type:varchar(10);not null;default:'and'" json:"conditionLogic"`
+	Expression         *string            `gorm:"type:text" json:"expression,omitempty"` // 规则表达式，如 avg(cpu_usage, 5m) > 90 and memory_usage > 85
 	Enabled            bool               `gorm:"not null;default:true" json:"enabled"`
 	Cooldown           int                `gorm:"not null;default:300" json:"cooldown"`
+	ForDuration        int                `gorm:"not null;default:0" json:"for"`           // 条件持续满足多少秒后才触发（pending阶段）
+	KeepFiringFor      int                `gorm:"not null;default:0" json:"keepFiringFor"` // 条件不再满足后继续保持firing的秒数
+	FlapThreshold      int                `gorm:"not null;default:0" json:"flapThreshold"` // 窗口内状态切换超过该次数视为抖动，0表示不检测
+	FlapWindow         int                `gorm:"not null;default:3600" json:"flapWindow"` // 抖动检测窗口（秒）
+	NoDataState        string             `gorm:"type:varchar(20);not null;default:'keep_last'" json:"noDataState"` // 缺少数据时的处理：ok, alerting, keep_last
+	EvaluationMode     string             `gorm:"type:varchar(20);not null;default:'per_vm'" json:"evaluationMode"` // per_vm: 逐VM评估；aggregate: 按作用范围跨VM聚合评估
 	Severity           string             `gorm:"type:varchar(20);not null" json:"severity"`
 	NotificationConfig JSONMap            `gorm:"type:jsonb;not null" json:"notificationConfig"`
 	TriggerCount       int                `gorm:"not null;default:0" json:"triggerCount"`
@@ -37,6 +44,19 @@ func (AlertRule) TableName() string {
 	return "alert_rules"
 }
 
+// 规则缺少数据（表达式无法求值）时的处理方式
+const (
+	NoDataStateOK       = "ok"        // 视为条件不满足，告警恢复
+	NoDataStateAlerting = "alerting"  // 视为条件满足，以无数据告警触发
+	NoDataStateKeepLast = "keep_last" // 保持当前状态
+)
+
+// 规则评估模式
+const (
+	EvaluationModePerVM     = "per_vm"    // 对作用范围内每个VM分别评估，每个VM一条告警
+	EvaluationModeAggregate = "aggregate" // 对作用范围内的VM（未指定范围ID时按分组/集群/主机/数据中心划分）聚合评估，每个范围一条告警
+)
+
 // AlertCondition 告警条件
 type AlertCondition struct {
 	ID          uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
@@ -48,6 +68,8 @@ type AlertCondition struct {
 	ThresholdStr *string  `gorm:"type:varchar(255)" json:"thresholdStr,omitempty"`
 	Duration    int       `gorm:"not null;default:60" json:"duration"`
 	Aggregation string    `gorm:"type:varchar(20);default:'last'" json:"aggregation"`
+	Type        string    `gorm:"type:varchar(20);not null;default:'threshold'" json:"type"` // threshold, anomaly
+	Algorithm   *string   `gorm:"type:varchar(20)" json:"algorithm,omitempty"`                // 异常检测算法: zscore, mad, ewma, seasonal
 	SortOrder   int       `gorm:"default:0" json:"sortOrder"`
 	CreatedAt   time.Time `json:"createdAt"`
 }
@@ -60,9 +82,9 @@ func (AlertCondition) TableName() string {
 // AlertRecord 告警记录
 type AlertRecord struct {
 	ID                uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
-	RuleID            uuid.UUID  `gorm:"type:uuid;not null;index" json:"ruleId"`
+	RuleID            uuid.UUID  `gorm:"type:uuid;not null;index;index:idx_alert_records_target,priority:1" json:"ruleId"`
 	RuleName          string     `gorm:"type:varchar(200);not null" json:"ruleName"`
-	VMID              *uuid.UUID `gorm:"type:uuid;index" json:"vmId,omitempty"`
+	VMID              *uuid.UUID `gorm:"type:uuid;index;index:idx_alert_records_target,priority:2" json:"vmId,omitempty"`
 	VMName            *string    `gorm:"type:varchar(200)" json:"vmName,omitempty"`
 	GroupID           *uuid.UUID `gorm:"type:uuid" json:"groupId,omitempty"`
 	ClusterID         *string    `gorm:"type:varchar(100)" json:"clusterId,omitempty"`
@@ -74,7 +96,7 @@ type AlertRecord struct {
 	TriggeredAt       time.Time  `gorm:"not null;index" json:"triggeredAt"`
 	ResolvedAt        *time.Time `json:"resolvedAt,omitempty"`
 	Duration          *int       `json:"duration,omitempty"`
-	Status            string     `gorm:"type:varchar(20);not null;default:'active';index" json:"status"`
+	Status            string     `gorm:"type:varchar(20);not null;default:'active';index;index:idx_alert_records_target,priority:3" json:"status"`
 	AcknowledgedBy    *uuid.UUID `gorm:"type:uuid" json:"-"`
 	AcknowledgedByName *string   `gorm:"type:varchar(100)" json:"acknowledgedByName,omitempty"`
 	AcknowledgedAt    *time.Time `json:"acknowledgedAt,omitempty"`
@@ -82,10 +104,24 @@ type AlertRecord struct {
 	ResolvedBy        *uuid.UUID `gorm:"type:uuid" json:"-"`
 	ResolvedByName    *string    `gorm:"type:varchar(100)" json:"resolvedByName,omitempty"`
 	Resolution        *string    `gorm:"type:text" json:"resolution,omitempty"`
+	AssigneeID        *uuid.UUID `gorm:"type:uuid;index" json:"assigneeId,omitempty"`          // 指派的处理人
+	AssigneeName      *string    `gorm:"type:varchar(100)" json:"assigneeName,omitempty"`
+	AssigneeTeam      *string    `gorm:"type:varchar(100)" json:"assigneeTeam,omitempty"`      // 指派的团队
+	AssignedAt        *time.Time `json:"assignedAt,omitempty"`
+	IncidentID        *uuid.UUID `gorm:"type:uuid;index" json:"incidentId,omitempty"`          // 所属事件
 	Snapshot          JSONMap    `gorm:"type:jsonb" json:"snapshot,omitempty"`
 	NotificationStatus JSONMap   `gorm:"type:jsonb;default:'[]'" json:"notificationStatus,omitempty"`
+	Suppressed        bool       `gorm:"not null;default:false" json:"suppressed"`             // 是否抑制了通知
+	SuppressionReason *string    `gorm:"type:varchar(200)" json:"suppressionReason,omitempty"` // 抑制原因（静默、抖动等）
+	SilenceID         *uuid.UUID `gorm:"type:uuid" json:"silenceId,omitempty"`                 // 命中的静默规则
+	InhibitedBy       *uuid.UUID `gorm:"type:uuid" json:"inhibitedBy,omitempty"`               // 抑制此告警的源告警记录
+	EscalationLevel   int        `gorm:"not null;default:0" json:"escalationLevel"`            // 已执行的升级级别数
+	NextEscalationAt  *time.Time `gorm:"index" json:"nextEscalationAt,omitempty"`              // 下一次升级时间，为空表示不再升级
 	CreatedAt         time.Time  `json:"createdAt"`
 	UpdatedAt         time.Time  `json:"updatedAt"`
+
+	// 关联
+	Remediations []RemediationExecution `gorm:"foreignkey:AlertID" json:"remediations,omitempty"` // 自动处置执行记录
 }
 
 // TableName 指定表名
@@ -137,19 +173,24 @@ type AlertStatistics struct {
 		RuleName     string `json:"ruleName"`
 		TriggerCount int    `json:"triggerCount"`
 	} `json:"byRule"`
-	MTTR *struct {
-		Avg          float64            `json:"avg"`
-		BySeverity   map[string]float64 `json:"bySeverity"`
-	} `json:"mttr,omitempty"`
+	MTTA *ResponseTimeSummary `json:"mtta,omitempty"` // 触发到确认
+	MTTR *ResponseTimeSummary `json:"mttr,omitempty"` // 触发到解决
 	TimeRange struct {
 		Start time.Time `json:"start"`
 		End   time.Time `json:"end"`
 	} `json:"timeRange"`
 }
 
+// ResponseTimeSummary 告警响应时间汇总（秒）
+type ResponseTimeSummary struct {
+	Avg        float64            `json:"avg"`
+	BySeverity map[string]float64 `json:"bySeverity"`
+}
+
 // NotificationConfig 通知配置结构
 type NotificationConfig struct {
-	Methods []string `json:"methods"` // email, sms, webhook, inApp
+	Methods  []string `json:"methods"`            // email, sms, webhook, inApp
+	Language string   `json:"language,omitempty"` // 通知模板语言，如zh-CN、en-US
 	Email   *struct {
 		Enabled   bool     `json:"enabled"`
 		Recipients []string `json:"recipients"`
@@ -167,10 +208,12 @@ type NotificationConfig struct {
 		Method   string            `json:"method"` // POST, PUT
 		Headers  map[string]string `json:"headers,omitempty"`
 		Secret   string            `json:"secret,omitempty"`
+		Template string            `json:"template,omitempty"`
 	} `json:"webhook,omitempty"`
 	InApp *struct {
-		Enabled bool     `json:"enabled"`
-		Users   []string `json:"users,omitempty"` // 空表示全部管理员
+		Enabled  bool     `json:"enabled"`
+		Users    []string `json:"users,omitempty"` // 空表示全部管理员
+		Template string   `json:"template,omitempty"`
 	} `json:"inApp,omitempty"`
 	Escalation *struct {
 		Enabled bool `json:"enabled"`
@@ -0,0 +1,65 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 告警记录状态
+const (
+	AlertStatusActive        = "active"
+	AlertStatusAcknowledged  = "acknowledged"
+	AlertStatusInvestigating = "investigating" // 已有人排查
+	AlertStatusMitigated     = "mitigated"     // 影响已缓解，待根因修复
+	AlertStatusResolved      = "resolved"
+	AlertStatusIgnored       = "ignored"
+)
+
+// AlertOpenStatuses 尚未结束的告警状态，告警恢复时自动转为resolved
+var AlertOpenStatuses = []string{AlertStatusActive, AlertStatusAcknowledged, AlertStatusInvestigating, AlertStatusMitigated}
+
+// 告警历史的操作类型
+const (
+	AlertHistoryCreated      = "created"       // 告警触发
+	AlertHistoryTransition   = "transition"    // 人工变更状态
+	AlertHistoryAssign       = "assign"        // 指派给用户或团队
+	AlertHistoryAutoResolved = "auto_resolved" // 条件不再满足，自动恢复
+)
+
+// AlertHistory 告警状态变更历史（只增不改）
+type AlertHistory struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AlertID    uuid.UUID  `gorm:"type:uuid;not null;index:idx_alert_history_alert,priority:1" json:"alertId"`
+	Action     string     `gorm:"type:varchar(20);not null" json:"action"`
+	FromStatus *string    `gorm:"type:varchar(20)" json:"fromStatus,omitempty"`
+	ToStatus   string     `gorm:"type:varchar(20);not null" json:"toStatus"`
+	ActorID    *uuid.UUID `gorm:"type:uuid" json:"actorId,omitempty"`
+	ActorName  *string    `gorm:"type:varchar(100)" json:"actorName,omitempty"` // 为空表示系统操作
+	Note       *string    `gorm:"type:text" json:"note,omitempty"`
+	Details    JSONMap    `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null;index:idx_alert_history_alert,priority:2" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (AlertHistory) TableName() string {
+	return "alert_histories"
+}
+
+// AlertComment 告警评论，ParentID不为空时为对该评论的回复。Mentions为评论中@到的用户名
+type AlertComment struct {
+	ID        uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AlertID   uuid.UUID   `gorm:"type:uuid;not null;index" json:"alertId"`
+	ParentID  *uuid.UUID  `gorm:"type:uuid" json:"parentId,omitempty"`
+	UserID    *uuid.UUID  `gorm:"type:uuid" json:"userId,omitempty"`
+	UserName  string      `gorm:"type:varchar(100);not null" json:"userName"`
+	Content   string      `gorm:"type:text;not null" json:"content"`
+	Mentions  StringArray `gorm:"type:jsonb" json:"mentions"`
+	CreatedAt time.Time   `json:"createdAt"`
+	UpdatedAt time.Time   `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AlertComment) TableName() string {
+	return "alert_comments"
+}
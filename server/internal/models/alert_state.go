@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 告警状态（每个规则与VM组合一个状态机）
+const (
+	AlertStateInactive = "inactive" // 条件不满足
+	AlertStatePending  = "pending"  // 条件满足但未达到for持续时间
+	AlertStateFiring   = "firing"   // 已触发告警
+	AlertStateResolved = "resolved" // 已恢复，等待下次满足条件
+)
+
+// AlertState 告警状态机的持久化状态，重启后据此恢复，避免重复触发
+type AlertState struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RuleID        uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_alert_state_key" json:"ruleId"`
+	VMID          uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_alert_state_key" json:"vmId"`
+	State         string     `gorm:"type:varchar(20);not null;default:'inactive';index" json:"state"`
+	ActiveSince   *time.Time `json:"activeSince,omitempty"` // 本轮条件开始满足的时间
+	FiringSince   *time.Time `json:"firingSince,omitempty"` // 进入firing的时间
+	LastTrueAt    *time.Time `json:"lastTrueAt,omitempty"`  // 最近一次条件满足的时间（用于keep_firing_for）
+	ResolvedAt    *time.Time `json:"resolvedAt,omitempty"`  // 最近一次恢复的时间
+	LastFiredAt   *time.Time `json:"lastFiredAt,omitempty"` // 最近一次创建告警的时间（用于冷却期）
+	LastValue     float64    `gorm:"type:double precision;not null;default:0" json:"lastValue"`
+	AlertRecordID *uuid.UUID `gorm:"type:uuid" json:"alertRecordId,omitempty"` // 当前firing对应的告警记录
+	Flapping      bool       `gorm:"not null;default:false" json:"flapping"`
+	Transitions   Timestamps `gorm:"type:jsonb" json:"transitions,omitempty"` // 抖动窗口内的firing/resolved切换时间
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AlertState) TableName() string {
+	return "alert_states"
+}
+
+// Timestamps 以JSON数组存储的时间列表
+type Timestamps []time.Time
+
+// Value 实现driver.Valuer接口
+func (t Timestamps) Value() (driver.Value, error) {
+	if t == nil {
+		return nil, nil
+	}
+	return json.Marshal(t)
+}
+
+// Scan 实现sql.Scanner接口
+func (t *Timestamps) Scan(value interface{}) error {
+	if value == nil {
+		*t = nil
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("无法扫描类型 %T 到 Timestamps", value)
+	}
+
+	return json.Unmarshal(bytes, t)
+}
@@ -0,0 +1,79 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HoursPerWeek 一周的小时数（季节性基线的桶数）
+const HoursPerWeek = 7 * 24
+
+// AnomalyBaseline 异常检测季节性基线
+type AnomalyBaseline struct {
+	ID          uuid.UUID       `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	VMID        string          `gorm:"type:varchar(100);not null;uniqueIndex:idx_anomaly_baseline_series" json:"vmId"`
+	Metric      string          `gorm:"type:varchar(50);not null;uniqueIndex:idx_anomaly_baseline_series" json:"metric"`
+	Profile     SeasonalProfile `gorm:"type:jsonb;not null" json:"profile"`
+	SampleCount int64           `gorm:"not null;default:0" json:"sampleCount"`
+	WindowStart time.Time       `json:"windowStart"`
+	WindowEnd   time.Time       `json:"windowEnd"`
+	TrainedAt   time.Time       `gorm:"not null;index" json:"trainedAt"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (AnomalyBaseline) TableName() string {
+	return "anomaly_baselines"
+}
+
+// SeasonalProfile 按"星期几+小时"分桶的统计量，下标为 weekday*24+hour
+type SeasonalProfile struct {
+	Mean   []float64 `json:"mean"`
+	StdDev []float64 `json:"stdDev"`
+	Count  []int64   `json:"count"`
+}
+
+// NewSeasonalProfile 创建空的季节性统计
+func NewSeasonalProfile() SeasonalProfile {
+	return SeasonalProfile{
+		Mean:   make([]float64, HoursPerWeek),
+		StdDev: make([]float64, HoursPerWeek),
+		Count:  make([]int64, HoursPerWeek),
+	}
+}
+
+// HourOfWeek 计算时间所在的季节性桶（按UTC）
+func HourOfWeek(t time.Time) int {
+	t = t.UTC()
+	return int(t.Weekday())*24 + t.Hour()
+}
+
+// Value 实现driver.Valuer接口
+func (p SeasonalProfile) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Scan 实现sql.Scanner接口
+func (p *SeasonalProfile) Scan(value interface{}) error {
+	if value == nil {
+		*p = NewSeasonalProfile()
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("无法扫描类型 %T 到 SeasonalProfile", value)
+	}
+
+	return json.Unmarshal(bytes, p)
+}
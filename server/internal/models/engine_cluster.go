@@ -0,0 +1,71 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EngineInstance 告警引擎实例（集群成员），实例定期续期心跳，过期即视为已下线
+type EngineInstance struct {
+	ID               string     `gorm:"type:varchar(100);primary_key" json:"id"`
+	Hostname         string     `gorm:"type:varchar(200);not null" json:"hostname"`
+	StartedAt        time.Time  `gorm:"not null" json:"startedAt"`
+	HeartbeatAt      time.Time  `gorm:"not null" json:"heartbeatAt"`
+	ExpiresAt        time.Time  `gorm:"not null;index" json:"expiresAt"`
+	Shards           IntArray   `gorm:"type:jsonb" json:"shards"`                   // 当前持有租约的分片
+	RuleCount        int        `gorm:"not null;default:0" json:"ruleCount"`        // 已加载的规则数
+	Evaluations      int        `gorm:"not null;default:0" json:"evaluations"`      // 最近一轮评估的规则与VM组合数
+	LastEvaluationAt *time.Time `json:"lastEvaluationAt,omitempty"`                 // 最近一轮评估完成时间
+	LastEvaluationMs int64      `gorm:"not null;default:0" json:"lastEvaluationMs"` // 最近一轮评估耗时（毫秒）
+}
+
+// TableName 指定表名
+func (EngineInstance) TableName() string {
+	return "alert_engine_instances"
+}
+
+// EngineLease 评估分片的租约，同一时刻只有一个实例持有未过期的租约
+type EngineLease struct {
+	Shard     int       `gorm:"primary_key;autoIncrement:false" json:"shard"`
+	Owner     string    `gorm:"type:varchar(100);not null;default:''" json:"owner"`
+	ExpiresAt time.Time `gorm:"not null" json:"expiresAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (EngineLease) TableName() string {
+	return "alert_engine_leases"
+}
+
+// IntArray 以JSON数组存储的整数列表
+type IntArray []int
+
+// Value 实现driver.Valuer接口
+func (a IntArray) Value() (driver.Value, error) {
+	if a == nil {
+		return nil, nil
+	}
+	return json.Marshal(a)
+}
+
+// Scan 实现sql.Scanner接口
+func (a *IntArray) Scan(value interface{}) error {
+	if value == nil {
+		*a = nil
+		return nil
+	}
+
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("无法扫描类型 %T 到 IntArray", value)
+	}
+
+	return json.Unmarshal(bytes, a)
+}
@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 事件状态
+const (
+	IncidentStatusOpen          = "open"
+	IncidentStatusInvestigating = "investigating"
+	IncidentStatusMitigated     = "mitigated"
+	IncidentStatusResolved      = "resolved"
+	IncidentStatusMerged        = "merged" // 已合并到其他事件（MergedInto）
+)
+
+// 事件时间线条目类型
+const (
+	IncidentEventCreated      = "created"
+	IncidentEventAlertAdded   = "alert_added"
+	IncidentEventAlertRemoved = "alert_removed"
+	IncidentEventMerged       = "merged"
+	IncidentEventSplit        = "split"
+	IncidentEventStatus       = "status"
+	IncidentEventSeverity     = "severity"
+	IncidentEventCommander    = "commander"
+	IncidentEventPostmortem   = "postmortem"
+	IncidentEventTitle        = "title"
+	IncidentEventNotified     = "notified"
+)
+
+// Incident 事件：由同一根因产生的一组告警。自动归并时GroupKey为按归并维度（集群、主机、规则等）
+// 计算的键，同键且最近一条告警在时间窗口内的告警归入同一事件；严重级别为成员告警的最高级别
+type Incident struct {
+	ID            uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Title         string     `gorm:"type:varchar(300);not null" json:"title"`
+	Severity      string     `gorm:"type:varchar(20);not null" json:"severity"`
+	Status        string     `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	GroupKey      string     `gorm:"type:varchar(500);index" json:"groupKey"`
+	AlertCount    int        `gorm:"not null;default:0" json:"alertCount"`
+	CommanderID   *uuid.UUID `gorm:"type:uuid" json:"commanderId,omitempty"`
+	CommanderName *string    `gorm:"type:varchar(100)" json:"commanderName,omitempty"`
+	Postmortem    *string    `gorm:"type:text" json:"postmortem,omitempty"`
+	MergedInto    *uuid.UUID `gorm:"type:uuid" json:"mergedInto,omitempty"`
+	StartedAt     time.Time  `gorm:"not null;index" json:"startedAt"`
+	LastAlertAt   time.Time  `gorm:"not null" json:"lastAlertAt"`
+	ResolvedAt    *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (Incident) TableName() string {
+	return "incidents"
+}
+
+// IncidentEvent 事件时间线条目（只增不改）
+type IncidentEvent struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	IncidentID uuid.UUID  `gorm:"type:uuid;not null;index:idx_incident_event_incident,priority:1" json:"incidentId"`
+	Type       string     `gorm:"type:varchar(20);not null" json:"type"`
+	Message    string     `gorm:"type:text;not null" json:"message"`
+	AlertID    *uuid.UUID `gorm:"type:uuid" json:"alertId,omitempty"`
+	ActorID    *uuid.UUID `gorm:"type:uuid" json:"actorId,omitempty"`
+	ActorName  *string    `gorm:"type:varchar(100)" json:"actorName,omitempty"` // 为空表示系统操作
+	Details    JSONMap    `gorm:"type:jsonb" json:"details,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null;index:idx_incident_event_incident,priority:2" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (IncidentEvent) TableName() string {
+	return "incident_events"
+}
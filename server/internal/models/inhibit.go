@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// InhibitRule 告警抑制规则：存在匹配源条件的活动告警时，抑制匹配目标条件、且Equal中列出的标签取值相同的告警通知。
+// 匹配条件为标签名到取值的映射，可用标签见 services.AlertLabelNames
+type InhibitRule struct {
+	ID             uuid.UUID   `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name           string      `gorm:"type:varchar(100);not null" json:"name"`
+	Description    *string     `gorm:"type:text" json:"description,omitempty"`
+	Enabled        bool        `gorm:"not null;default:true" json:"enabled"`
+	SourceMatchers JSONMap     `gorm:"type:jsonb;not null" json:"sourceMatchers"`
+	TargetMatchers JSONMap     `gorm:"type:jsonb;not null" json:"targetMatchers"`
+	Equal          StringArray `gorm:"type:jsonb" json:"equal"`
+	CreatedBy      *uuid.UUID  `gorm:"type:uuid" json:"-"`
+	CreatedAt      time.Time   `json:"createdAt"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (InhibitRule) TableName() string {
+	return "alert_inhibit_rules"
+}
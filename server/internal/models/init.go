@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"database/sql/driver"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -77,8 +78,24 @@ func AutoMigrate(db *gorm.DB) error {
 		&VMGroupMember{},
 		&AlertRule{},
 		&AlertCondition{},
+		&AlertRuleRevision{},
 		&AlertRecord{},
+		&AlertHistory{},
+		&AlertComment{},
+		&Incident{},
+		&IncidentEvent{},
+		&NotificationTemplate{},
+		&RemediationExecution{},
+		&RemediationSwitch{},
+		&AlertState{},
+		&EngineInstance{},
+		&EngineLease{},
+		&Silence{},
+		&InhibitRule{},
 		&AuditLog{},
+		&AnomalyBaseline{},
+		&RetentionPolicy{},
+		&MetricRollup{},
 	)
 
 	// 尝试修改user_roles表的外键约束为CASCADE（忽略错误）
@@ -105,7 +122,7 @@ func AutoMigrate(db *gorm.DB) error {
 type JSONMap map[string]interface{}
 
 // Value 实现driver.Valuer接口
-func (j JSONMap) Value() (interface{}, error) {
+func (j JSONMap) Value() (driver.Value, error) {
 	if j == nil {
 		return nil, nil
 	}
@@ -119,8 +136,13 @@ func (j *JSONMap) Scan(value interface{}) error {
 		return nil
 	}
 
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		return fmt.Errorf("无法扫描类型 %T 到 JSONMap", value)
 	}
 
@@ -131,7 +153,7 @@ func (j *JSONMap) Scan(value interface{}) error {
 type StringArray []string
 
 // Value 实现driver.Valuer接口
-func (a StringArray) Value() (interface{}, error) {
+func (a StringArray) Value() (driver.Value, error) {
 	if a == nil {
 		return nil, nil
 	}
@@ -145,8 +167,13 @@ func (a *StringArray) Scan(value interface{}) error {
 		return nil
 	}
 
-	bytes, ok := value.([]byte)
-	if !ok {
+	var bytes []byte
+	switch v := value.(type) {
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
 		return fmt.Errorf("无法扫描类型 %T 到 StringArray", value)
 	}
 
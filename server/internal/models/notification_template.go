@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 通知模板的渠道
+const (
+	TemplateChannelEmail   = "email"
+	TemplateChannelSMS     = "sms"
+	TemplateChannelWebhook = "webhook"
+	TemplateChannelInApp   = "inApp"
+)
+
+// DefaultTemplateLanguage 未指定语言时使用的模板语言
+const DefaultTemplateLanguage = "zh-CN"
+
+// NotificationTemplate 用户自定义的通知模板，按渠道与语言区分。
+// 邮件正文使用html/template渲染，其余渠道使用text/template；Webhook正文渲染结果须为JSON
+type NotificationTemplate struct {
+	ID          uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name        string     `gorm:"type:varchar(100);not null;uniqueIndex:idx_notification_template_key,priority:1" json:"name"`
+	Channel     string     `gorm:"type:varchar(20);not null;uniqueIndex:idx_notification_template_key,priority:2" json:"channel"`
+	Language    string     `gorm:"type:varchar(20);not null;default:'zh-CN';uniqueIndex:idx_notification_template_key,priority:3" json:"language"`
+	Subject     string     `gorm:"type:text" json:"subject,omitempty"` // 邮件主题或站内通知标题
+	Body        string     `gorm:"type:text;not null" json:"body"`
+	Description *string    `gorm:"type:text" json:"description,omitempty"`
+	IsDefault   bool       `gorm:"not null;default:false" json:"isDefault"` // 规则未指定模板时，该渠道与语言使用的模板
+	CreatedBy   *uuid.UUID `gorm:"type:uuid" json:"createdBy,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	UpdatedAt   time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (NotificationTemplate) TableName() string {
+	return "notification_templates"
+}
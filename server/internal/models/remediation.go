@@ -0,0 +1,70 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 处置动作类型
+const (
+	RemediationTypeHTTP   = "http"   // 调用HTTP接口
+	RemediationTypeScript = "script" // 执行白名单中的本地脚本
+	RemediationTypeVM     = "vm"     // 通过采集器对VM执行操作
+)
+
+// 处置执行状态
+const (
+	RemediationPendingApproval = "pending_approval" // 等待审批
+	RemediationQueued          = "queued"           // 等待执行（含等待重试）
+	RemediationRunning         = "running"
+	RemediationSucceeded       = "succeeded"
+	RemediationFailed          = "failed"    // 重试次数用尽
+	RemediationRejected        = "rejected"  // 审批拒绝
+	RemediationCancelled       = "cancelled" // 告警已结束或紧急停止开关打开
+)
+
+// RemediationExecution 告警触发的一次处置动作执行，Log记录每次尝试的完整输出
+type RemediationExecution struct {
+	ID             uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	AlertID        uuid.UUID  `gorm:"type:uuid;not null;index" json:"alertId"`
+	RuleID         uuid.UUID  `gorm:"type:uuid;not null;index" json:"ruleId"`
+	VMID           *uuid.UUID `gorm:"type:uuid" json:"vmId,omitempty"`
+	ActionName     string     `gorm:"type:varchar(100);not null" json:"actionName"`
+	ActionType     string     `gorm:"type:varchar(20);not null" json:"actionType"`
+	Action         JSONMap    `gorm:"type:jsonb" json:"action"` // 触发时的动作配置快照
+	Status         string     `gorm:"type:varchar(20);not null;index:idx_remediation_queue,priority:1" json:"status"`
+	Attempts       int        `gorm:"not null;default:0" json:"attempts"`
+	MaxAttempts    int        `gorm:"not null;default:1" json:"maxAttempts"`
+	NextAttemptAt  *time.Time `gorm:"index:idx_remediation_queue,priority:2" json:"nextAttemptAt,omitempty"`
+	ApprovedBy     *uuid.UUID `gorm:"type:uuid" json:"approvedBy,omitempty"`
+	ApprovedByName *string    `gorm:"type:varchar(100)" json:"approvedByName,omitempty"` // 审批人（拒绝时为拒绝人）
+	ApprovedAt     *time.Time `json:"approvedAt,omitempty"`
+	Reason         *string    `gorm:"type:text" json:"reason,omitempty"` // 拒绝或取消原因
+	LastError      *string    `gorm:"type:text" json:"lastError,omitempty"`
+	Log            string     `gorm:"type:text" json:"log"`
+	StartedAt      *time.Time `json:"startedAt,omitempty"`
+	FinishedAt     *time.Time `json:"finishedAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (RemediationExecution) TableName() string {
+	return "remediation_executions"
+}
+
+// RemediationSwitch 处置动作的全局紧急停止开关（单行表），打开后所有实例停止执行并取消排队中的动作
+type RemediationSwitch struct {
+	ID            int        `gorm:"primary_key" json:"-"`
+	Engaged       bool       `gorm:"not null;default:false" json:"engaged"`
+	Reason        *string    `gorm:"type:text" json:"reason,omitempty"`
+	ChangedBy     *uuid.UUID `gorm:"type:uuid" json:"changedBy,omitempty"`
+	ChangedByName *string    `gorm:"type:varchar(100)" json:"changedByName,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (RemediationSwitch) TableName() string {
+	return "remediation_switch"
+}
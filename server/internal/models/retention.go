@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 保留策略作用对象
+const (
+	RetentionTargetMetrics   = "metrics"
+	RetentionTargetAuditLogs = "audit_logs"
+)
+
+// RetentionPolicy 数据保留策略
+// 指标数据按 指标名(支持*通配) / VM分组 / 标签 匹配，多个条件同时生效（AND）；
+// 同一行数据只受优先级最高的匹配策略约束。
+type RetentionPolicy struct {
+	ID                  uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name                string     `gorm:"type:varchar(100);not null;uniqueIndex" json:"name"`
+	Description         *string    `gorm:"type:text" json:"description,omitempty"`
+	Target              string     `gorm:"type:varchar(20);not null;default:'metrics'" json:"target"`
+	MetricPattern       *string    `gorm:"type:varchar(100)" json:"metricPattern,omitempty"`
+	GroupID             *uuid.UUID `gorm:"type:uuid;index" json:"groupId,omitempty"`
+	TagKey              *string    `gorm:"type:varchar(100)" json:"tagKey,omitempty"`
+	TagValue            *string    `gorm:"type:varchar(200)" json:"tagValue,omitempty"`
+	RawRetentionDays    int        `gorm:"not null" json:"rawRetentionDays"`
+	RollupRetentionDays int        `gorm:"not null;default:0" json:"rollupRetentionDays"` // 0 表示不保留汇总数据
+	Priority            int        `gorm:"not null;default:0" json:"priority"`
+	Enabled             bool       `gorm:"not null;default:true" json:"enabled"`
+	LastEnforcedAt      *time.Time `json:"lastEnforcedAt,omitempty"`
+	LastDeletedRows     int64      `gorm:"not null;default:0" json:"lastDeletedRows"`
+	CreatedAt           time.Time  `json:"createdAt"`
+	UpdatedAt           time.Time  `json:"updatedAt"`
+	CreatedBy           *uuid.UUID `gorm:"type:uuid" json:"-"`
+}
+
+// TableName 指定表名
+func (RetentionPolicy) TableName() string {
+	return "retention_policies"
+}
+
+// Specificity 匹配条件数量，条件越多越具体
+func (p *RetentionPolicy) Specificity() int {
+	n := 0
+	if p.MetricPattern != nil && *p.MetricPattern != "" {
+		n++
+	}
+	if p.GroupID != nil {
+		n++
+	}
+	if p.TagKey != nil && *p.TagKey != "" {
+		n++
+	}
+	return n
+}
+
+// MetricRollup 小时级指标汇总（原始数据过期后保留）
+type MetricRollup struct {
+	ID       uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	VMID     string    `gorm:"type:varchar(100);not null;uniqueIndex:idx_metric_rollup_series,priority:1" json:"vmId"`
+	Metric   string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_metric_rollup_series,priority:2" json:"metric"`
+	Bucket   time.Time `gorm:"not null;uniqueIndex:idx_metric_rollup_series,priority:3;index" json:"bucket"`
+	AvgValue float64   `gorm:"type:double precision;not null" json:"avg"`
+	MinValue float64   `gorm:"type:double precision;not null" json:"min"`
+	MaxValue float64   `gorm:"type:double precision;not null" json:"max"`
+	SumValue float64   `gorm:"type:double precision;not null" json:"sum"`
+	Count    int64     `gorm:"not null" json:"count"`
+}
+
+// TableName 指定表名
+func (MetricRollup) TableName() string {
+	return "metric_rollups"
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 规则修订的操作类型
+const (
+	RuleRevisionCreate   = "create"
+	RuleRevisionUpdate   = "update"
+	RuleRevisionDelete   = "delete"
+	RuleRevisionImport   = "import"
+	RuleRevisionRollback = "rollback"
+	RuleRevisionBaseline = "baseline" // 启用修订记录前已存在的规则，在首次修改前补录的原始状态
+)
+
+// AlertRuleRevision 告警规则修订记录（只增不改）。Snapshot为修改后的规则及条件，
+// Changes为相对上一版本的差异：字段路径 → {"old": 旧值, "new": 新值}
+type AlertRuleRevision struct {
+	ID         uuid.UUID  `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	RuleID     uuid.UUID  `gorm:"type:uuid;not null;uniqueIndex:idx_rule_revision_version" json:"ruleId"`
+	Version    int        `gorm:"not null;uniqueIndex:idx_rule_revision_version" json:"version"`
+	Action     string     `gorm:"type:varchar(20);not null" json:"action"`
+	Snapshot   JSONMap    `gorm:"type:jsonb;not null" json:"snapshot"`
+	Changes    JSONMap    `gorm:"type:jsonb" json:"changes,omitempty"`
+	Note       *string    `gorm:"type:text" json:"note,omitempty"`
+	AuthorID   *uuid.UUID `gorm:"type:uuid" json:"authorId,omitempty"`
+	AuthorName *string    `gorm:"type:varchar(100)" json:"authorName,omitempty"`
+	CreatedAt  time.Time  `gorm:"not null" json:"createdAt"`
+}
+
+// TableName 指定表名
+func (AlertRuleRevision) TableName() string {
+	return "alert_rule_revisions"
+}
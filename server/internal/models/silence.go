@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 静默类型
+const (
+	SilenceTypeSilence     = "silence"     // 一次性静默，在[StartsAt, EndsAt)内生效
+	SilenceTypeMaintenance = "maintenance" // 维护窗口，按cron表达式周期性生效
+)
+
+// 静默的抑制方式
+const (
+	SilenceModeNotification = "suppress_notification" // 照常创建告警记录，但不发送通知
+	SilenceModeCreation     = "suppress_creation"     // 不创建告警记录
+)
+
+// Silence 告警静默/维护窗口。匹配条件为空表示不限制，全部非空条件同时满足才算命中
+type Silence struct {
+	ID      uuid.UUID `gorm:"type:uuid;primary_key;default:gen_random_uuid()" json:"id"`
+	Name    string    `gorm:"type:varchar(100);not null" json:"name"`
+	Type    string    `gorm:"type:varchar(20);not null;default:'silence'" json:"type"`
+	Mode    string    `gorm:"type:varchar(30);not null;default:'suppress_notification'" json:"mode"`
+	Comment *string   `gorm:"type:text" json:"comment,omitempty"`
+	Enabled bool      `gorm:"not null;default:true" json:"enabled"`
+
+	// 匹配条件
+	RuleID    *uuid.UUID `gorm:"type:uuid;index" json:"ruleId,omitempty"`
+	VMID      *uuid.UUID `gorm:"type:uuid;index" json:"vmId,omitempty"`
+	GroupID   *uuid.UUID `gorm:"type:uuid" json:"groupId,omitempty"`
+	ClusterID *string    `gorm:"type:varchar(100)" json:"clusterId,omitempty"`
+	TagKey    *string    `gorm:"type:varchar(100)" json:"tagKey,omitempty"`
+	TagValue  *string    `gorm:"type:varchar(200)" json:"tagValue,omitempty"`
+	Severity  *string    `gorm:"type:varchar(20)" json:"severity,omitempty"`
+
+	// 生效时间：一次性静默为[StartsAt, EndsAt)；维护窗口为周期的有效期，EndsAt为空表示长期有效
+	StartsAt        time.Time  `gorm:"not null;index" json:"startsAt"`
+	EndsAt          *time.Time `gorm:"index" json:"endsAt,omitempty"`
+	Schedule        *string    `gorm:"type:varchar(100)" json:"schedule,omitempty"` // cron表达式（分 时 日 月 周），维护窗口的开始时间
+	DurationMinutes int        `gorm:"not null;default:0" json:"durationMinutes"`   // 每次维护窗口的持续分钟数
+	Timezone        string     `gorm:"type:varchar(64);not null;default:'UTC'" json:"timezone"`
+
+	CreatedBy     *uuid.UUID `gorm:"type:uuid" json:"createdBy,omitempty"`
+	CreatedByName *string    `gorm:"type:varchar(100)" json:"createdByName,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// TableName 指定表名
+func (Silence) TableName() string {
+	return "alert_silences"
+}
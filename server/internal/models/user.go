@@ -1,6 +1,7 @@
 package models
 
 import (
+	"database/sql/driver"
 	"time"
 
 	"github.com/google/uuid"
@@ -45,7 +46,7 @@ type UserPreferences struct {
 }
 
 // Value 实现driver.Valuer接口
-func (p UserPreferences) Value() (interface{}, error) {
+func (p UserPreferences) Value() (driver.Value, error) {
 	return JSONMap{
 		"language":   p.Language,
 		"theme":      p.Theme,
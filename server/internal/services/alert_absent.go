@@ -0,0 +1,173 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+)
+
+// deadmanLookback 数据中断检测只检查该时间内上报过的指标，更早停止上报的指标视为已下线
+const deadmanLookback = 6 * time.Hour
+
+// DeadmanRuleID 内置数据中断规则的固定ID（各实例一致）
+var DeadmanRuleID = uuid.NewSHA1(uuid.NameSpaceOID, []byte("vm-monitoring-system/alert-rules/deadman"))
+
+// SetDeadman 设置数据中断检测的超时时间：VM或其某个指标超过该时间未上报数据时告警，0表示关闭
+func (e *AlertEngine) SetDeadman(timeout time.Duration) {
+	e.deadmanTimeout = timeout
+}
+
+// ensureDeadmanRule 创建内置数据中断规则（已存在时保留启用状态等配置）
+func (e *AlertEngine) ensureDeadmanRule() error {
+	description := "内置规则：VM或其指标超过设定时间未上报数据时告警，超时时间由 alert.deadman_timeout 配置"
+	rule := models.AlertRule{
+		ID:                 DeadmanRuleID,
+		Name:               "VM数据中断",
+		Description:        &description,
+		Scope:              "all",
+		ConditionLogic:     "and",
+		Enabled:            true,
+		FlapWindow:         3600,
+		NoDataState:        models.NoDataStateAlerting,
+		Severity:           "high",
+		NotificationConfig: models.JSONMap{},
+	}
+	return e.db.Where("id = ?", DeadmanRuleID).Attrs(rule).FirstOrCreate(&models.AlertRule{}).Error
+}
+
+// evaluateDeadman 检查作用范围内的VM是否停止上报，返回检查的VM数量
+func (e *AlertEngine) evaluateDeadman(now time.Time) int {
+	e.rulesMutex.RLock()
+	rule := e.deadmanRule
+	e.rulesMutex.RUnlock()
+	if rule == nil || e.deadmanTimeout <= 0 || e.timeSeries == nil {
+		return 0
+	}
+
+	vms, err := e.getTargetVMs(rule.Scope, rule.ScopeID)
+	if err != nil {
+		logger.Error("数据中断检测获取VM失败", zap.Error(err))
+		return 0
+	}
+
+	targets := make([]models.VM, 0, len(vms))
+	vmIDs := make([]string, 0, len(vms))
+	for _, vm := range vms {
+		if e.cluster != nil && !e.cluster.Owns(rule.ID, vm.ID) {
+			continue
+		}
+		targets = append(targets, vm)
+		vmIDs = append(vmIDs, vm.ID.String())
+	}
+
+	lastSamples, err := e.timeSeries.LastSampleTimes(vmIDs, now.Add(-deadmanLookback))
+	if err != nil {
+		logger.Error("数据中断检测查询最后数据时间失败", zap.Error(err))
+		return 0
+	}
+
+	for _, vm := range targets {
+		metricData := e.deadmanCheck(vm, lastSamples[vm.ID.String()], now)
+		e.applyEvaluation(*rule, vm, metricData != nil, metricData, now)
+	}
+	return len(targets)
+}
+
+// deadmanCheck 判断VM是否停止上报：VM整体超时未上报，或部分指标超时未上报。未中断时返回nil
+func (e *AlertEngine) deadmanCheck(vm models.VM, lastSamples map[string]time.Time, now time.Time) *AlertMetricData {
+	// 已关机的VM不上报数据属于预期
+	if vm.Status == "poweredOff" || (vm.PowerState != nil && *vm.PowerState == "poweredOff") {
+		return nil
+	}
+
+	metricData := &AlertMetricData{
+		VMID:      vm.ID,
+		VMName:    vm.Name,
+		Metric:    AbsentMetric,
+		Condition: models.AlertCondition{Duration: int(e.deadmanTimeout / time.Second), Type: ConditionTypeAbsent},
+	}
+
+	if vm.LastSeen != nil && now.Sub(*vm.LastSeen) > e.deadmanTimeout {
+		metricData.Condition.Metric = "*"
+		metricData.Value = now.Sub(*vm.LastSeen).Seconds()
+		metricData.Timestamp = *vm.LastSeen
+		return metricData
+	}
+
+	var stale []string
+	for metric, ts := range lastSamples {
+		age := now.Sub(ts)
+		if age <= e.deadmanTimeout {
+			continue
+		}
+		stale = append(stale, metric)
+		if age.Seconds() > metricData.Value {
+			metricData.Value = age.Seconds()
+			metricData.Timestamp = ts
+		}
+	}
+	if len(stale) == 0 {
+		return nil
+	}
+	sort.Strings(stale)
+	metricData.Condition.Metric = strings.Join(stale, ",")
+	return metricData
+}
+
+// noDataResult 按规则的noDataState处理缺少数据的评估结果，apply为false表示保持当前状态
+func noDataResult(ruleWithCond *AlertRuleWithConditions, vm models.VM) (triggered bool, metricData *AlertMetricData, apply bool) {
+	switch ruleWithCond.Rule.NoDataState {
+	case models.NoDataStateOK:
+		return false, nil, true
+	case models.NoDataStateAlerting:
+		return true, noDataMetricData(ruleWithCond, vm), true
+	}
+	return false, nil, false
+}
+
+// noDataMetricData 规则因缺少数据而触发时的指标数据，条件中记录表达式引用的指标
+func noDataMetricData(ruleWithCond *AlertRuleWithConditions, vm models.VM) *AlertMetricData {
+	var metrics []string
+	if ruleWithCond.Expression != nil {
+		metrics = ruleWithCond.Expression.Metrics()
+	} else {
+		for _, cond := range ruleWithCond.Conditions {
+			metrics = append(metrics, cond.Metric)
+		}
+	}
+	return &AlertMetricData{
+		VMID:      vm.ID,
+		VMName:    vm.Name,
+		Metric:    AbsentMetric,
+		Timestamp: time.Now(),
+		Condition: models.AlertCondition{Metric: strings.Join(metrics, ","), Type: ConditionTypeAbsent},
+	}
+}
+
+// absentConditionString 无数据告警的条件描述
+func absentConditionString(cond models.AlertCondition, metricData *AlertMetricData) string {
+	metric := cond.Metric
+	if metric == "*" {
+		metric = "全部指标"
+	}
+	if cond.Duration <= 0 {
+		return fmt.Sprintf("%s 无数据", metric)
+	}
+	window := formatExprDuration(time.Duration(cond.Duration) * time.Second)
+	if metricData.Value > 0 {
+		return fmt.Sprintf("%s 超过 %s 无数据 (已中断: %s)", metric, window, (time.Duration(metricData.Value) * time.Second).String())
+	}
+	return fmt.Sprintf("%s 在 %s 内无数据", metric, window)
+}
+
+// isDeadmanRule 判断是否为内置数据中断规则
+func isDeadmanRule(rule models.AlertRule) bool {
+	return rule.ID == DeadmanRuleID
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpression_Absent(t *testing.T) {
+	expr, err := ParseExpression("absent(cpu_usage, 10m) or cpu_usage > 90")
+	require.NoError(t, err)
+	assert.Equal(t, "absent(cpu_usage, 10m) or cpu_usage > 90", expr.String())
+
+	_, err = ParseExpression("absent(cpu_usage) > 1")
+	require.Error(t, err)
+
+	triggered, match, err := expr.Evaluate(&fakeExprSource{})
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.Equal(t, AbsentMetric, match.Metric)
+	assert.Equal(t, "cpu_usage", match.Condition.Metric)
+	assert.Equal(t, ConditionTypeAbsent, match.Condition.Type)
+	assert.Equal(t, 600, match.Condition.Duration)
+
+	triggered, _, err = expr.Evaluate(&fakeExprSource{values: map[string]MetricData{"last|cpu_usage": {Value: 50}}})
+	require.NoError(t, err)
+	assert.False(t, triggered)
+
+	// 条件列表中的absent条件忽略运算符与阈值
+	fromConditions, err := ExpressionFromConditions([]models.AlertCondition{{Metric: "cpu_usage", Type: ConditionTypeAbsent, Duration: 120}}, "and")
+	require.NoError(t, err)
+	assert.Equal(t, "absent(cpu_usage, 2m)", fromConditions.String())
+
+	fromProm, err := expressionFromPromQL("absent_over_time(cpu_usage[10m])")
+	require.NoError(t, err)
+	assert.Equal(t, "absent(cpu_usage, 10m)", fromProm.String())
+	promExpr, err := promQL(pushDownNot(fromProm.root, false))
+	require.NoError(t, err)
+	assert.Equal(t, "absent_over_time(cpu_usage[10m])", promExpr)
+	_, err = promQL(pushDownNot(fromProm.root, true))
+	assert.Error(t, err)
+}
+
+func TestAlertEngine_NoDataState(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE metric_records (
+		id TEXT PRIMARY KEY, vm_id TEXT, metric TEXT, value REAL, timestamp DATETIME, tags TEXT, created_at DATETIME
+	)`).Error)
+	engine := NewAlertEngine(db, nil)
+	vm := models.VM{ID: uuid.New(), Name: "web-01"}
+
+	rule := func(noDataState string) *AlertRuleWithConditions {
+		expr, err := ParseExpression("cpu_usage > 90")
+		require.NoError(t, err)
+		r := models.AlertRule{ID: uuid.New(), Name: "cpu " + noDataState, Severity: "high", NoDataState: noDataState}
+		require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", r.ID).Error)
+		return &AlertRuleWithConditions{Rule: r, Expression: expr}
+	}
+
+	// keep_last保持当前状态，alerting以无数据告警触发
+	keepLast := rule(models.NoDataStateKeepLast)
+	engine.evaluateTarget(keepLast, vm)
+	assert.Equal(t, models.AlertStateInactive, engine.stateFor(keepLast.Rule.ID, vm.ID).State)
+
+	alerting := rule(models.NoDataStateAlerting)
+	engine.evaluateTarget(alerting, vm)
+	var record models.AlertRecord
+	require.NoError(t, db.Where("rule_id = ?", alerting.Rule.ID).First(&record).Error)
+	assert.Equal(t, AbsentMetric, record.Metric)
+	require.NotNil(t, record.ConditionStr)
+	assert.Equal(t, "cpu_usage 无数据", *record.ConditionStr)
+
+	// ok视为条件不满足，firing的告警恢复
+	alerting.Rule.NoDataState = models.NoDataStateOK
+	engine.evaluateTarget(alerting, vm)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "rule_id = ? AND status = ?", alerting.Rule.ID, "resolved"))
+}
+
+func TestAlertEngine_DeadmanCheck(t *testing.T) {
+	engine := NewAlertEngine(nil, nil)
+	engine.SetDeadman(10 * time.Minute)
+	now := time.Now()
+	recent, stale := now.Add(-time.Minute), now.Add(-30*time.Minute)
+
+	// VM整体超时未上报
+	vm := models.VM{ID: uuid.New(), Name: "web-01", LastSeen: &stale}
+	data := engine.deadmanCheck(vm, nil, now)
+	require.NotNil(t, data)
+	assert.Equal(t, AbsentMetric, data.Metric)
+	assert.Equal(t, "*", data.Condition.Metric)
+	assert.InDelta(t, 1800, data.Value, 1)
+	assert.Equal(t, "全部指标 超过 10m 无数据 (已中断: 30m0s)", absentConditionString(data.Condition, data))
+
+	// VM仍在上报，但部分指标中断
+	vm.LastSeen = &recent
+	data = engine.deadmanCheck(vm, map[string]time.Time{"cpu_usage": recent, "disk_usage": stale, "memory_usage": stale}, now)
+	require.NotNil(t, data)
+	assert.Equal(t, "disk_usage,memory_usage", data.Condition.Metric)
+
+	assert.Nil(t, engine.deadmanCheck(vm, map[string]time.Time{"cpu_usage": recent}, now))
+
+	// 已关机的VM不检查
+	poweredOff := "poweredOff"
+	vm.LastSeen, vm.PowerState = &stale, &poweredOff
+	assert.Nil(t, engine.deadmanCheck(vm, nil, now))
+}
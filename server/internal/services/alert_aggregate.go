@@ -0,0 +1,249 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+)
+
+// aggregateTarget 聚合评估的目标范围（一个分组、集群、主机、数据中心或全部VM）
+type aggregateTarget struct {
+	Scope string
+	Key   string    // 范围ID，全部VM时为all
+	VM    models.VM // 代表该范围的虚拟目标：ID由规则与范围生成，用作告警状态的键
+	VMs   []models.VM
+}
+
+// aggregateTargetID 由规则ID与范围生成固定的目标ID，各实例一致
+func aggregateTargetID(ruleID uuid.UUID, scope, key string) uuid.UUID {
+	return uuid.NewSHA1(ruleID, []byte(scope+":"+key))
+}
+
+// newAggregateTarget 创建聚合目标，分组与集群范围记录到告警的GroupID/ClusterID
+func newAggregateTarget(rule models.AlertRule, scope, key, name string) *aggregateTarget {
+	target := &aggregateTarget{Scope: scope, Key: key}
+	target.VM = models.VM{ID: aggregateTargetID(rule.ID, scope, key), Name: name}
+	k := key
+	switch scope {
+	case "group":
+		if groupID, err := uuid.Parse(key); err == nil {
+			target.VM.GroupID = &groupID
+		}
+	case "cluster":
+		target.VM.ClusterID = &k
+	case "host":
+		target.VM.HostID = &k
+	case "datacenter":
+		target.VM.DatacenterID = &k
+	}
+	return target
+}
+
+// evaluateAggregateRule 聚合评估模式：对每个目标范围评估一次，返回评估的范围数量
+func (e *AlertEngine) evaluateAggregateRule(ruleWithCond *AlertRuleWithConditions) (int, error) {
+	rule := ruleWithCond.Rule
+	targets, err := e.aggregateTargets(rule)
+	if err != nil {
+		return 0, fmt.Errorf("获取聚合目标失败: %w", err)
+	}
+
+	evaluated := 0
+	for _, target := range targets {
+		if e.cluster != nil && !e.cluster.Owns(rule.ID, target.VM.ID) {
+			continue
+		}
+		evaluated++
+		e.evaluateAggregateTarget(ruleWithCond, target)
+	}
+	return evaluated, nil
+}
+
+// evaluateAggregateTarget 对目标范围内的VM聚合求值并推进状态机
+func (e *AlertEngine) evaluateAggregateTarget(ruleWithCond *AlertRuleWithConditions, target *aggregateTarget) {
+	rule := ruleWithCond.Rule
+	if ruleWithCond.Expression == nil {
+		return
+	}
+
+	now := time.Now()
+	members := make([]ExprFleetMember, len(target.VMs))
+	for i, vm := range target.VMs {
+		members[i] = ExprFleetMember{VMID: vm.ID, VMName: vm.Name, Source: &engineExprSource{engine: e, vmID: vm.ID, at: now}}
+	}
+
+	var metricData *AlertMetricData
+	triggered, match, err := ruleWithCond.Expression.EvaluateFleet(members)
+	if errors.Is(err, ErrNoData) {
+		var apply bool
+		if triggered, metricData, apply = noDataResult(ruleWithCond, target.VM); !apply {
+			logger.Debug("聚合条件缺少数据，跳过评估", zap.String("rule_id", rule.ID.String()), zap.String("target", target.Scope+":"+target.Key))
+			return
+		}
+		if metricData != nil {
+			metricData.Target = target
+		}
+	} else if err != nil {
+		logger.Error("评估聚合条件失败", zap.String("rule_id", rule.ID.String()), zap.String("target", target.Scope+":"+target.Key), zap.Error(err))
+		return
+	} else if triggered {
+		metricData = &AlertMetricData{
+			VMID:         target.VM.ID,
+			VMName:       target.VM.Name,
+			Metric:       match.Metric,
+			Value:        match.Value,
+			Timestamp:    match.Timestamp,
+			Condition:    match.Condition,
+			Target:       target,
+			Members:      match.Members,
+			Contributors: match.Contributors,
+		}
+	}
+
+	e.applyEvaluation(rule, target.VM, triggered, metricData, now)
+}
+
+// aggregateTargets 获取规则的聚合目标：指定了范围ID（或范围为全部VM）时只有一个目标，
+// 否则按分组、集群、主机或数据中心划分，每个划分一个目标
+func (e *AlertEngine) aggregateTargets(rule models.AlertRule) ([]*aggregateTarget, error) {
+	if rule.Scope == "vm" {
+		return nil, fmt.Errorf("VM范围不支持聚合评估")
+	}
+
+	if rule.Scope == "all" || rule.ScopeID != nil {
+		vms, err := e.getTargetVMs(rule.Scope, rule.ScopeID)
+		if err != nil {
+			return nil, err
+		}
+		key, name := "all", "全部VM"
+		if rule.ScopeID != nil {
+			key, name = rule.ScopeID.String(), rule.ScopeID.String()
+		}
+		if rule.ScopeName != nil && *rule.ScopeName != "" {
+			name = *rule.ScopeName
+		}
+		target := newAggregateTarget(rule, rule.Scope, key, name)
+		target.VMs = vms
+		return []*aggregateTarget{target}, nil
+	}
+
+	var vms []models.VM
+	if err := e.db.Where("is_deleted = ?", false).Find(&vms).Error; err != nil {
+		return nil, err
+	}
+
+	targets := map[string]*aggregateTarget{}
+	add := func(key, name string, vm models.VM) {
+		target, ok := targets[key]
+		if !ok {
+			if name == "" {
+				name = key
+			}
+			target = newAggregateTarget(rule, rule.Scope, key, name)
+			targets[key] = target
+		}
+		target.VMs = append(target.VMs, vm)
+	}
+
+	switch rule.Scope {
+	case "group":
+		var members []struct {
+			GroupID uuid.UUID
+			VMID    uuid.UUID
+		}
+		if err := e.db.Table("vm_group_members").Select("group_id, vm_id").Find(&members).Error; err != nil {
+			return nil, err
+		}
+		groupIDs := make([]uuid.UUID, 0, len(members))
+		for _, m := range members {
+			groupIDs = append(groupIDs, m.GroupID)
+		}
+		names := map[uuid.UUID]string{}
+		var groups []models.VMGroup
+		if err := e.db.Select("id, name").Where("id IN ?", groupIDs).Find(&groups).Error; err == nil {
+			for _, g := range groups {
+				names[g.ID] = g.Name
+			}
+		}
+
+		byID := make(map[uuid.UUID]models.VM, len(vms))
+		for _, vm := range vms {
+			byID[vm.ID] = vm
+		}
+		for _, m := range members {
+			if vm, ok := byID[m.VMID]; ok {
+				add(m.GroupID.String(), names[m.GroupID], vm)
+			}
+		}
+
+	case "cluster", "host", "datacenter":
+		for _, vm := range vms {
+			var id, name *string
+			switch rule.Scope {
+			case "cluster":
+				id, name = vm.ClusterID, vm.ClusterName
+			case "host":
+				id, name = vm.HostID, vm.HostName
+			default:
+				id, name = vm.DatacenterID, vm.DatacenterName
+			}
+			if id == nil || *id == "" {
+				continue
+			}
+			label := ""
+			if name != nil {
+				label = *name
+			}
+			add(*id, label, vm)
+		}
+
+	default:
+		return nil, fmt.Errorf("未知的作用域类型: %s", rule.Scope)
+	}
+
+	result := make([]*aggregateTarget, 0, len(targets))
+	for _, target := range targets {
+		result = append(result, target)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result, nil
+}
+
+// aggregateSnapshot 聚合告警的快照：目标范围与参与聚合的VM
+func aggregateSnapshot(metricData *AlertMetricData) map[string]interface{} {
+	target := metricData.Target
+	contributors := metricData.Contributors
+	if contributors == nil {
+		contributors = []ExprContributor{}
+	}
+	return map[string]interface{}{
+		"target": map[string]interface{}{
+			"scope":   target.Scope,
+			"id":      target.Key,
+			"name":    target.VM.Name,
+			"vmCount": len(target.VMs),
+		},
+		"members":      metricData.Members,
+		"contributors": contributors,
+	}
+}
+
+// aggregateConditionString 聚合告警的条件描述
+func aggregateConditionString(metricData *AlertMetricData) string {
+	cond := metricData.Condition
+	return fmt.Sprintf("%s(%s) %s %.4f (实际值: %.4f, 参与VM: %d/%d)",
+		cond.Aggregation,
+		metricData.Metric,
+		cond.Operator,
+		cond.Threshold,
+		metricData.Value,
+		metricData.Members,
+		len(metricData.Target.VMs),
+	)
+}
@@ -0,0 +1,135 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpression_Fleet(t *testing.T) {
+	for _, src := range []string{
+		"fleet_avg(fleet_max(cpu_usage)) > 1",
+		"fleet_count(cpu_usage) > 1",
+		"fleet_avg(cpu_usage > 90) > 1",
+		"fleet_percentile(cpu_usage, 150) > 1",
+		"fleet_percentile(cpu_usage, memory_usage) > 1",
+	} {
+		_, err := ParseExpression(src)
+		assert.Error(t, err, src)
+	}
+
+	expr, err := ParseExpression("fleet_percentile(memory_usage, 95) > 90")
+	require.NoError(t, err)
+	assert.Equal(t, "fleet_percentile(memory_usage, 95) > 90", expr.String())
+	assert.NoError(t, expr.CheckEvaluationMode(models.EvaluationModeAggregate))
+	assert.Error(t, expr.CheckEvaluationMode(models.EvaluationModePerVM))
+	prom, err := promQL(pushDownNot(expr.root, false))
+	require.NoError(t, err)
+	assert.Equal(t, "quantile(0.95, memory_usage) > 90", prom)
+
+	mixed, err := ParseExpression("fleet_avg(cpu_usage) > 80 and memory_usage > 90")
+	require.NoError(t, err)
+	assert.Error(t, mixed.CheckEvaluationMode(models.EvaluationModeAggregate))
+	perVM, err := ParseExpression("cpu_usage > 90")
+	require.NoError(t, err)
+	assert.Error(t, perVM.CheckEvaluationMode(models.EvaluationModeAggregate))
+}
+
+func TestExpression_EvaluateFleet(t *testing.T) {
+	member := func(name string, cpu ...float64) ExprFleetMember {
+		source := &fakeExprSource{values: map[string]MetricData{}}
+		if len(cpu) > 0 {
+			source.values["last|cpu_usage"] = MetricData{Value: cpu[0]}
+		}
+		return ExprFleetMember{VMID: uuid.New(), VMName: name, Source: source}
+	}
+	members := []ExprFleetMember{member("web-01", 70), member("web-02", 95), member("web-03", 90), member("web-04")}
+
+	avg, err := ParseExpression("fleet_avg(cpu_usage) > 80")
+	require.NoError(t, err)
+	triggered, match, err := avg.EvaluateFleet(members)
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.InDelta(t, 85, match.Value, 1e-9)
+	assert.Equal(t, "cpu_usage", match.Metric)
+	assert.Equal(t, "fleet_avg", match.Condition.Aggregation)
+	assert.Equal(t, 3, match.Members)
+	require.Len(t, match.Contributors, 3)
+	assert.Equal(t, "web-02", match.Contributors[0].VMName)
+
+	p50, err := ParseExpression("fleet_percentile(cpu_usage, 50) >= 90")
+	require.NoError(t, err)
+	triggered, _, err = p50.EvaluateFleet(members)
+	require.NoError(t, err)
+	assert.True(t, triggered)
+
+	// 统计无数据的VM，参与者为满足条件的VM
+	offline, err := ParseExpression("fleet_count(absent(cpu_usage, 5m)) >= 1")
+	require.NoError(t, err)
+	triggered, match, err = offline.EvaluateFleet(members)
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	require.Len(t, match.Contributors, 1)
+	assert.Equal(t, "web-04", match.Contributors[0].VMName)
+
+	// 没有任何成员有数据时无法评估
+	_, _, err = avg.EvaluateFleet([]ExprFleetMember{member("web-05")})
+	assert.ErrorIs(t, err, ErrNoData)
+	_, _, err = avg.Evaluate(&fakeExprSource{})
+	assert.Error(t, err)
+}
+
+func TestAlertEngine_AggregateRule(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE vms (
+		id TEXT PRIMARY KEY, name TEXT, cluster_id TEXT, cluster_name TEXT, status TEXT, is_deleted BOOLEAN DEFAULT 0
+	)`).Error)
+	engine := NewAlertEngine(db, nil)
+	engine.timeSeries.SetHotCache(NewHotCache(time.Hour))
+
+	now := time.Now()
+	var samples []MetricData
+	vms := []struct {
+		cluster string
+		cpu     float64
+	}{{"c1", 95}, {"c1", 85}, {"c2", 10}}
+	for i, v := range vms {
+		id := uuid.New()
+		require.NoError(t, db.Exec("INSERT INTO vms (id, name, cluster_id, cluster_name, status) VALUES (?, ?, ?, ?, ?)",
+			id, "vm-"+string(rune('a'+i)), v.cluster, "cluster-"+v.cluster, "running").Error)
+		samples = append(samples,
+			MetricData{VMID: id.String(), Metric: "cpu_usage", Value: v.cpu, Timestamp: now.Add(-10 * time.Minute)},
+			MetricData{VMID: id.String(), Metric: "cpu_usage", Value: v.cpu, Timestamp: now.Add(-time.Minute)},
+		)
+	}
+	engine.timeSeries.hot.Append(samples)
+
+	expr, err := ParseExpression("fleet_avg(cpu_usage) > 80")
+	require.NoError(t, err)
+	rule := models.AlertRule{ID: uuid.New(), Name: "cluster cpu", Scope: "cluster", Severity: "high", EvaluationMode: models.EvaluationModeAggregate}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+
+	evaluated, err := engine.evaluateRule(&AlertRuleWithConditions{Rule: rule, Expression: expr})
+	require.NoError(t, err)
+	assert.Equal(t, 2, evaluated)
+
+	// 每个集群一条告警，记录集群ID而非VM
+	var records []models.AlertRecord
+	require.NoError(t, db.Find(&records).Error)
+	require.Len(t, records, 1)
+	assert.Nil(t, records[0].VMID)
+	require.NotNil(t, records[0].ClusterID)
+	assert.Equal(t, "c1", *records[0].ClusterID)
+	assert.InDelta(t, 90, records[0].TriggerValue, 1e-9)
+	assert.Len(t, records[0].Snapshot["contributors"], 2)
+	require.NotNil(t, records[0].ConditionStr)
+	assert.Equal(t, "fleet_avg(cpu_usage) > 80.0000 (实际值: 90.0000, 参与VM: 2/2)", *records[0].ConditionStr)
+
+	target := aggregateTargetID(rule.ID, "cluster", "c1")
+	assert.Equal(t, models.AlertStateFiring, engine.stateFor(rule.ID, target).State)
+}
@@ -0,0 +1,427 @@
+package services
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 响应时间统计的分组维度
+const (
+	AnalyticsGroupSeverity = "severity"
+	AnalyticsGroupRule     = "rule"
+	AnalyticsGroupGroup    = "group" // 告警所属VM分组
+	AnalyticsGroupAssignee = "assignee"
+)
+
+// AnalyticsGroupBys 支持的分组维度
+var AnalyticsGroupBys = []string{AnalyticsGroupSeverity, AnalyticsGroupRule, AnalyticsGroupGroup, AnalyticsGroupAssignee}
+
+// analyticsPercentiles 输出的百分位
+var analyticsPercentiles = []float64{50, 90, 95, 99}
+
+// SLATarget 某一告警级别的响应目标：触发到确认（MTTA）与触发到解决（MTTR），0表示不考核
+type SLATarget struct {
+	Acknowledge time.Duration
+	Resolve     time.Duration
+}
+
+// MarshalJSON 以秒输出响应目标
+func (t SLATarget) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]float64{
+		"acknowledge": t.Acknowledge.Seconds(),
+		"resolve":     t.Resolve.Seconds(),
+	})
+}
+
+// DefaultSLATargets 各告警级别的默认响应目标
+func DefaultSLATargets() map[string]SLATarget {
+	return map[string]SLATarget{
+		"critical": {Acknowledge: 5 * time.Minute, Resolve: time.Hour},
+		"high":     {Acknowledge: 15 * time.Minute, Resolve: 4 * time.Hour},
+		"medium":   {Acknowledge: time.Hour, Resolve: 24 * time.Hour},
+		"low":      {Acknowledge: 4 * time.Hour, Resolve: 72 * time.Hour},
+	}
+}
+
+// AnalyticsQuery 响应时间统计查询，按告警触发时间筛选
+type AnalyticsQuery struct {
+	Start    time.Time
+	End      time.Time
+	GroupBy  string
+	Severity string
+	RuleID   *uuid.UUID
+}
+
+// DurationStats 一组响应时间（秒）的统计。Breaches为超过SLA目标的告警数，
+// 其中OpenBreaches为尚未确认/解决但已超时的告警
+type DurationStats struct {
+	Count        int                `json:"count"`
+	Avg          float64            `json:"avg"`
+	Min          float64            `json:"min"`
+	Max          float64            `json:"max"`
+	Percentiles  map[string]float64 `json:"percentiles"`
+	Breaches     int                `json:"breaches"`
+	OpenBreaches int                `json:"openBreaches"`
+	BreachRate   float64            `json:"breachRate"` // 超时告警占已完成与超时未完成告警之和的百分比
+}
+
+// AnalyticsBucket 一个分组的告警响应统计
+type AnalyticsBucket struct {
+	Key   string        `json:"key"`
+	Label string        `json:"label"`
+	Total int           `json:"total"`
+	Open  int           `json:"open"`
+	MTTA  DurationStats `json:"mtta"`
+	MTTR  DurationStats `json:"mttr"`
+
+	mttaSamples []float64
+	mttrSamples []float64
+}
+
+// AlertAnalytics 告警响应时间与SLA统计报告
+type AlertAnalytics struct {
+	Start       time.Time            `json:"start"`
+	End         time.Time            `json:"end"`
+	GroupBy     string               `json:"groupBy"`
+	SLATargets  map[string]SLATarget `json:"slaTargets"`
+	Overall     AnalyticsBucket      `json:"overall"`
+	Groups      []AnalyticsBucket    `json:"groups"`
+	GeneratedAt time.Time            `json:"generatedAt"`
+}
+
+// DailyResponse 某一天触发的告警的平均响应时间（秒）
+type DailyResponse struct {
+	Date         string  `json:"date"`
+	Acknowledged int     `json:"acknowledged"`
+	MTTA         float64 `json:"mtta"`
+	Resolved     int     `json:"resolved"`
+	MTTR         float64 `json:"mttr"`
+}
+
+// AlertAnalyticsService 告警响应时间统计：MTTA为触发到首次确认（含转为排查中、已缓解），
+// MTTR为触发到解决（忽略的告警不计入），按告警级别的SLA目标统计超时数量
+type AlertAnalyticsService struct {
+	db      *gorm.DB
+	targets map[string]SLATarget
+}
+
+// NewAlertAnalyticsService 创建告警响应统计服务
+func NewAlertAnalyticsService(db *gorm.DB) *AlertAnalyticsService {
+	return &AlertAnalyticsService{db: db, targets: DefaultSLATargets()}
+}
+
+// SetSLATargets 覆盖指定级别的响应目标，未配置的级别使用默认值
+func (s *AlertAnalyticsService) SetSLATargets(targets map[string]SLATarget) {
+	for severity, target := range targets {
+		if target.Acknowledge < 0 || target.Resolve < 0 {
+			continue
+		}
+		s.targets[severity] = target
+	}
+}
+
+// SLATargets 当前的响应目标
+func (s *AlertAnalyticsService) SLATargets() map[string]SLATarget {
+	targets := make(map[string]SLATarget, len(s.targets))
+	for severity, target := range s.targets {
+		targets[severity] = target
+	}
+	return targets
+}
+
+// analyticsRecord 统计使用的告警字段
+type analyticsRecord struct {
+	RuleID         uuid.UUID
+	RuleName       string
+	GroupID        *uuid.UUID
+	Severity       string
+	Status         string
+	TriggeredAt    time.Time
+	AcknowledgedAt *time.Time
+	ResolvedAt     *time.Time
+	AssigneeID     *uuid.UUID
+	AssigneeName   *string
+	AssigneeTeam   *string
+}
+
+// loadRecords 查询时间范围内触发的告警
+func (s *AlertAnalyticsService) loadRecords(query AnalyticsQuery) ([]analyticsRecord, error) {
+	db := s.db.Model(&models.AlertRecord{}).
+		Select("rule_id, rule_name, group_id, severity, status, triggered_at, acknowledged_at, resolved_at, assignee_id, assignee_name, assignee_team").
+		Where("triggered_at >= ? AND triggered_at < ?", query.Start, query.End)
+	if query.Severity != "" {
+		db = db.Where("severity = ?", query.Severity)
+	}
+	if query.RuleID != nil {
+		db = db.Where("rule_id = ?", *query.RuleID)
+	}
+	var records []analyticsRecord
+	if err := db.Order("triggered_at").Scan(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询告警记录失败: %w", err)
+	}
+	return records, nil
+}
+
+// Compute 统计时间范围内触发的告警的MTTA、MTTR、百分位与SLA超时，now用于判断未完成告警是否已超时
+func (s *AlertAnalyticsService) Compute(query AnalyticsQuery, now time.Time) (*AlertAnalytics, error) {
+	if query.GroupBy == "" {
+		query.GroupBy = AnalyticsGroupSeverity
+	}
+	if !containsString(AnalyticsGroupBys, query.GroupBy) {
+		return nil, fmt.Errorf("不支持的分组维度: %s", query.GroupBy)
+	}
+	if !query.End.After(query.Start) {
+		return nil, fmt.Errorf("结束时间必须晚于开始时间")
+	}
+
+	records, err := s.loadRecords(query)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &AlertAnalytics{
+		Start:       query.Start,
+		End:         query.End,
+		GroupBy:     query.GroupBy,
+		SLATargets:  s.SLATargets(),
+		Overall:     AnalyticsBucket{Key: "all", Label: "全部"},
+		GeneratedAt: now,
+	}
+	buckets := map[string]*AnalyticsBucket{}
+	for _, record := range records {
+		key, label := analyticsGroupKey(query.GroupBy, record)
+		bucket, ok := buckets[key]
+		if !ok {
+			bucket = &AnalyticsBucket{Key: key, Label: label}
+			buckets[key] = bucket
+		}
+		s.accumulate(&report.Overall, record, now)
+		s.accumulate(bucket, record, now)
+	}
+
+	if query.GroupBy == AnalyticsGroupGroup {
+		s.labelGroups(buckets)
+	}
+	report.Overall.finalize()
+	report.Groups = make([]AnalyticsBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		bucket.finalize()
+		report.Groups = append(report.Groups, *bucket)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		if report.Groups[i].Total != report.Groups[j].Total {
+			return report.Groups[i].Total > report.Groups[j].Total
+		}
+		return report.Groups[i].Key < report.Groups[j].Key
+	})
+	return report, nil
+}
+
+// accumulate 将一条告警计入分组
+func (s *AlertAnalyticsService) accumulate(bucket *AnalyticsBucket, record analyticsRecord, now time.Time) {
+	target := s.targets[record.Severity]
+	open := isOpenStatus(record.Status)
+	bucket.Total++
+	if open {
+		bucket.Open++
+	}
+
+	if record.AcknowledgedAt != nil {
+		seconds := record.AcknowledgedAt.Sub(record.TriggeredAt).Seconds()
+		bucket.mttaSamples = append(bucket.mttaSamples, seconds)
+		if target.Acknowledge > 0 && seconds > target.Acknowledge.Seconds() {
+			bucket.MTTA.Breaches++
+		}
+	} else if record.Status == models.AlertStatusActive && target.Acknowledge > 0 && now.Sub(record.TriggeredAt) > target.Acknowledge {
+		bucket.MTTA.Breaches++
+		bucket.MTTA.OpenBreaches++
+	}
+
+	if record.Status == models.AlertStatusResolved && record.ResolvedAt != nil {
+		seconds := record.ResolvedAt.Sub(record.TriggeredAt).Seconds()
+		bucket.mttrSamples = append(bucket.mttrSamples, seconds)
+		if target.Resolve > 0 && seconds > target.Resolve.Seconds() {
+			bucket.MTTR.Breaches++
+		}
+	} else if open && target.Resolve > 0 && now.Sub(record.TriggeredAt) > target.Resolve {
+		bucket.MTTR.Breaches++
+		bucket.MTTR.OpenBreaches++
+	}
+}
+
+// finalize 根据样本计算平均值与百分位
+func (b *AnalyticsBucket) finalize() {
+	b.MTTA.fill(b.mttaSamples)
+	b.MTTR.fill(b.mttrSamples)
+	b.mttaSamples, b.mttrSamples = nil, nil
+}
+
+// fill 计算样本的统计值（秒，保留一位小数）
+func (d *DurationStats) fill(samples []float64) {
+	d.Count = len(samples)
+	d.Percentiles = map[string]float64{}
+	if eligible := d.Count + d.OpenBreaches; eligible > 0 {
+		d.BreachRate = roundTenth(float64(d.Breaches) / float64(eligible) * 100)
+	}
+	if d.Count == 0 {
+		return
+	}
+
+	d.Avg = roundTenth(fleetAggregate(samples, "avg", 0))
+	d.Min = roundTenth(fleetAggregate(samples, "min", 0))
+	d.Max = roundTenth(fleetAggregate(samples, "max", 0))
+	for _, p := range analyticsPercentiles {
+		d.Percentiles["p"+strconv.Itoa(int(p))] = roundTenth(fleetAggregate(samples, "percentile", p))
+	}
+}
+
+// roundTenth 保留一位小数
+func roundTenth(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+// analyticsGroupKey 告警在分组维度上的键与显示名称
+func analyticsGroupKey(groupBy string, record analyticsRecord) (string, string) {
+	switch groupBy {
+	case AnalyticsGroupRule:
+		return record.RuleID.String(), record.RuleName
+	case AnalyticsGroupGroup:
+		if record.GroupID == nil {
+			return "", "未分组"
+		}
+		return record.GroupID.String(), record.GroupID.String()
+	case AnalyticsGroupAssignee:
+		if record.AssigneeID != nil {
+			label := getStringValue(record.AssigneeName)
+			if label == "" {
+				label = record.AssigneeID.String()
+			}
+			return record.AssigneeID.String(), label
+		}
+		if team := getStringValue(record.AssigneeTeam); team != "" {
+			return "team:" + team, team
+		}
+		return "", "未指派"
+	default:
+		return record.Severity, getSeverityLabel(record.Severity)
+	}
+}
+
+// labelGroups 使用VM分组名称作为显示名称
+func (s *AlertAnalyticsService) labelGroups(buckets map[string]*AnalyticsBucket) {
+	ids := make([]string, 0, len(buckets))
+	for key := range buckets {
+		if key != "" {
+			ids = append(ids, key)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	var groups []models.VMGroup
+	if err := s.db.Select("id", "name").Where("id IN ?", ids).Find(&groups).Error; err != nil {
+		return
+	}
+	for _, group := range groups {
+		if bucket, ok := buckets[group.ID.String()]; ok {
+			bucket.Label = group.Name
+		}
+	}
+}
+
+// Daily 按触发日期统计平均响应时间，用于告警趋势
+func (s *AlertAnalyticsService) Daily(start, end time.Time) ([]DailyResponse, error) {
+	records, err := s.loadRecords(AnalyticsQuery{Start: start, End: end})
+	if err != nil {
+		return nil, err
+	}
+
+	days := map[string]*DailyResponse{}
+	var order []string
+	for _, record := range records {
+		date := record.TriggeredAt.Format("2006-01-02")
+		day, ok := days[date]
+		if !ok {
+			day = &DailyResponse{Date: date}
+			days[date] = day
+			order = append(order, date)
+		}
+		if record.AcknowledgedAt != nil {
+			day.Acknowledged++
+			day.MTTA += record.AcknowledgedAt.Sub(record.TriggeredAt).Seconds()
+		}
+		if record.Status == models.AlertStatusResolved && record.ResolvedAt != nil {
+			day.Resolved++
+			day.MTTR += record.ResolvedAt.Sub(record.TriggeredAt).Seconds()
+		}
+	}
+
+	result := make([]DailyResponse, 0, len(order))
+	for _, date := range order {
+		day := days[date]
+		if day.Acknowledged > 0 {
+			day.MTTA = roundTenth(day.MTTA / float64(day.Acknowledged))
+		}
+		if day.Resolved > 0 {
+			day.MTTR = roundTenth(day.MTTR / float64(day.Resolved))
+		}
+		result = append(result, *day)
+	}
+	return result, nil
+}
+
+// MarshalAnalyticsCSV 将统计报告导出为CSV：第一行为全部告警，其后每个分组一行，时间单位为秒
+func MarshalAnalyticsCSV(report *AlertAnalytics) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	header := []string{"group_by", "key", "label", "total", "open"}
+	for _, kind := range []string{"mtta", "mttr"} {
+		header = append(header, kind+"_count", kind+"_avg", kind+"_min", kind+"_max")
+		for _, p := range analyticsPercentiles {
+			header = append(header, fmt.Sprintf("%s_p%d", kind, int(p)))
+		}
+		header = append(header, kind+"_breaches", kind+"_open_breaches", kind+"_breach_rate")
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+
+	rows := append([]AnalyticsBucket{report.Overall}, report.Groups...)
+	for i, bucket := range rows {
+		groupBy := report.GroupBy
+		if i == 0 {
+			groupBy = "all"
+		}
+		row := []string{groupBy, bucket.Key, bucket.Label, strconv.Itoa(bucket.Total), strconv.Itoa(bucket.Open)}
+		for _, stats := range []DurationStats{bucket.MTTA, bucket.MTTR} {
+			row = append(row, strconv.Itoa(stats.Count), formatSeconds(stats.Avg), formatSeconds(stats.Min), formatSeconds(stats.Max))
+			for _, p := range analyticsPercentiles {
+				row = append(row, formatSeconds(stats.Percentiles["p"+strconv.Itoa(int(p))]))
+			}
+			row = append(row, strconv.Itoa(stats.Breaches), strconv.Itoa(stats.OpenBreaches), formatSeconds(stats.BreachRate))
+		}
+		if err := writer.Write(row); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// formatSeconds 格式化CSV中的数值
+func formatSeconds(v float64) string {
+	return strconv.FormatFloat(v, 'f', 1, 64)
+}
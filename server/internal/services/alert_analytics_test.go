@@ -0,0 +1,150 @@
+package services
+
+import (
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createAnalyticsAlert 创建指定确认、解决耗时的告警记录，ack或resolve为0表示未确认或未解决
+func createAnalyticsAlert(t *testing.T, db *gorm.DB, ruleID uuid.UUID, severity string, triggeredAt time.Time, ack, resolve time.Duration, assignee *uuid.UUID, team string) {
+	alert := &models.AlertRecord{ID: uuid.New(), RuleID: ruleID, RuleName: "rule-" + ruleID.String()[:4], Metric: "cpu_usage",
+		Severity: severity, TriggerValue: 95, Threshold: 90, Status: models.AlertStatusActive, TriggeredAt: triggeredAt}
+	if ack > 0 {
+		acknowledgedAt := triggeredAt.Add(ack)
+		alert.AcknowledgedAt = &acknowledgedAt
+		alert.Status = models.AlertStatusAcknowledged
+	}
+	if resolve > 0 {
+		resolvedAt := triggeredAt.Add(resolve)
+		alert.ResolvedAt = &resolvedAt
+		alert.Status = models.AlertStatusResolved
+	}
+	if assignee != nil {
+		name := "Alice"
+		alert.AssigneeID, alert.AssigneeName = assignee, &name
+	}
+	if team != "" {
+		alert.AssigneeTeam = &team
+	}
+	require.NoError(t, db.Create(alert).Error)
+}
+
+func TestAlertAnalytics_PercentilesAndBreaches(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	service := NewAlertAnalyticsService(db)
+	base := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	ruleA, ruleB := uuid.New(), uuid.New()
+	alice := uuid.New()
+
+	createAnalyticsAlert(t, db, ruleA, "critical", base, 2*time.Minute, 30*time.Minute, &alice, "")
+	createAnalyticsAlert(t, db, ruleA, "critical", base.Add(time.Minute), 10*time.Minute, 2*time.Hour, &alice, "")
+	createAnalyticsAlert(t, db, ruleA, "critical", base.Add(time.Hour), 0, 0, nil, "")
+	createAnalyticsAlert(t, db, ruleB, "low", base.Add(24*time.Hour), time.Hour, 2*time.Hour, nil, "ops")
+	// 范围之外的告警不计入
+	createAnalyticsAlert(t, db, ruleB, "low", base.Add(-48*time.Hour), time.Hour, 0, nil, "")
+
+	query := AnalyticsQuery{Start: base, End: base.Add(7 * 24 * time.Hour)}
+	now := base.Add(27 * time.Hour)
+	report, err := service.Compute(query, now)
+	require.NoError(t, err)
+	assert.Equal(t, AnalyticsGroupSeverity, report.GroupBy)
+	assert.Equal(t, 4, report.Overall.Total)
+	assert.Equal(t, 1, report.Overall.Open)
+
+	// MTTA样本: 120s, 600s, 3600s
+	mtta := report.Overall.MTTA
+	assert.Equal(t, 3, mtta.Count)
+	assert.Equal(t, 1440.0, mtta.Avg)
+	assert.Equal(t, 120.0, mtta.Min)
+	assert.Equal(t, 3600.0, mtta.Max)
+	assert.Equal(t, 600.0, mtta.Percentiles["p50"])
+	assert.Equal(t, 3000.0, mtta.Percentiles["p90"])
+	// 第二条超时确认，第三条未确认且已超过5分钟
+	assert.Equal(t, 2, mtta.Breaches)
+	assert.Equal(t, 1, mtta.OpenBreaches)
+	assert.Equal(t, 50.0, mtta.BreachRate)
+
+	require.Len(t, report.Groups, 2)
+	critical := report.Groups[0]
+	assert.Equal(t, "critical", critical.Key)
+	assert.Equal(t, 3, critical.Total)
+	assert.Equal(t, 2, critical.MTTR.Count)
+	assert.Equal(t, 4500.0, critical.MTTR.Avg)
+	assert.Equal(t, 2, critical.MTTR.Breaches)
+	assert.Equal(t, 1, critical.MTTR.OpenBreaches)
+	low := report.Groups[1]
+	assert.Equal(t, "low", low.Key)
+	assert.Equal(t, 0, low.MTTA.Breaches)
+	assert.Equal(t, 0, low.MTTR.Breaches)
+
+	// 放宽critical级别的目标后不再超时
+	service.SetSLATargets(map[string]SLATarget{"critical": {Acknowledge: time.Hour, Resolve: 0}})
+	report, err = service.Compute(query, base.Add(90*time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, report.Groups[0].MTTA.Breaches)
+	assert.Equal(t, 0, report.Groups[0].MTTR.Breaches)
+	assert.Equal(t, 4*time.Hour, service.SLATargets()["low"].Acknowledge)
+
+	_, err = service.Compute(AnalyticsQuery{Start: base, End: base.Add(time.Hour), GroupBy: "vm"}, now)
+	assert.Error(t, err)
+	_, err = service.Compute(AnalyticsQuery{Start: base, End: base}, now)
+	assert.Error(t, err)
+}
+
+func TestAlertAnalytics_GroupingDailyAndCSV(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	service := NewAlertAnalyticsService(db)
+	base := time.Date(2026, 10, 1, 10, 0, 0, 0, time.UTC)
+	ruleA, ruleB := uuid.New(), uuid.New()
+	alice := uuid.New()
+
+	createAnalyticsAlert(t, db, ruleA, "high", base, 2*time.Minute, 30*time.Minute, &alice, "")
+	createAnalyticsAlert(t, db, ruleA, "high", base.Add(time.Minute), 10*time.Minute, 0, &alice, "")
+	createAnalyticsAlert(t, db, ruleB, "low", base.Add(24*time.Hour), time.Hour, 2*time.Hour, nil, "ops")
+	createAnalyticsAlert(t, db, ruleB, "low", base.Add(25*time.Hour), 0, 0, nil, "")
+
+	query := AnalyticsQuery{Start: base, End: base.Add(7 * 24 * time.Hour), GroupBy: AnalyticsGroupAssignee}
+	report, err := service.Compute(query, base.Add(26*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 3)
+	assert.Equal(t, alice.String(), report.Groups[0].Key)
+	assert.Equal(t, "Alice", report.Groups[0].Label)
+	assert.Equal(t, 2, report.Groups[0].Total)
+	assert.Equal(t, 360.0, report.Groups[0].MTTA.Avg)
+	assert.Equal(t, "", report.Groups[1].Key)
+	assert.Equal(t, "未指派", report.Groups[1].Label)
+	assert.Equal(t, "team:ops", report.Groups[2].Key)
+
+	query.GroupBy = AnalyticsGroupRule
+	query.RuleID = &ruleB
+	report, err = service.Compute(query, base.Add(26*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, ruleB.String(), report.Groups[0].Key)
+	assert.Equal(t, 2, report.Groups[0].Total)
+
+	daily, err := service.Daily(base, base.Add(7*24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, daily, 2)
+	assert.Equal(t, DailyResponse{Date: "2026-10-01", Acknowledged: 2, MTTA: 360, Resolved: 1, MTTR: 1800}, daily[0])
+	assert.Equal(t, DailyResponse{Date: "2026-10-02", Acknowledged: 1, MTTA: 3600, Resolved: 1, MTTR: 7200}, daily[1])
+
+	data, err := MarshalAnalyticsCSV(report)
+	require.NoError(t, err)
+	rows, err := csv.NewReader(strings.NewReader(string(data))).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 3)
+	assert.Equal(t, []string{"group_by", "key", "label", "total", "open", "mtta_count", "mtta_avg"}, rows[0][:7])
+	assert.Equal(t, "mttr_breach_rate", rows[0][len(rows[0])-1])
+	assert.Equal(t, []string{"all", "all", "全部", "2", "1", "1", "3600.0"}, rows[1][:7])
+	assert.Equal(t, []string{"rule", ruleB.String()}, rows[2][:2])
+}
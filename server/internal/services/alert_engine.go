@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -25,14 +27,48 @@ type AlertEngine struct {
 	stopChan         chan struct{}
 	isRunning        bool
 	runningMutex     sync.RWMutex
-	triggerHistory   map[string]time.Time
-	historyMutex     sync.RWMutex
+	anomaly          *AnomalyService
+	timeSeries       *TimeSeriesService
+	states           map[string]*models.AlertState // 规则与VM组合的告警状态，键为 ruleID:vmID
+	statesMutex      sync.Mutex                    // 只保护states映射，单个状态由对应组合的评估锁保护
+	stateLocks       sync.Map                      // 规则与VM组合的评估锁（stateLockKey → *stateLock）：同一组合串行评估，不同组合互不阻塞
+	silences         *SilenceService
+	inhibitions      *InhibitService
+	cluster          *EngineCluster    // 多实例部署时的分片协调，为空表示单实例评估全部规则
+	shardGeneration  uint64            // 最近一次加载状态时集群分片的版本号
+	deadmanTimeout   time.Duration     // 数据中断检测超时，0表示关闭
+	deadmanRule      *models.AlertRule // 内置数据中断规则，未启用时为空（受rulesMutex保护）
+	snapshotWindow   time.Duration     // 告警快照记录触发前指标的时间窗口
+	workflow         *AlertWorkflowService // 告警触发与自动恢复写入处理历史并推送，为空时不记录
+	incidents        *IncidentService      // 新告警归入事件，为空时不归并
+	remediation      *RemediationService   // 新告警按规则配置生成处置动作，为空时不处置
+
+	// 写入驱动评估：新数据写入时只评估引用了相应指标的规则，定时评估负责无数据与时间窗口滑动
+	metricIndex      map[string][]uuid.UUID // 指标 → 规则，随规则一起加载（受rulesMutex保护）
+	ingestEnabled    bool
+	ingestQueue      chan ingestBatch
+	ingestDropped    int64
+	ingestEvaluated  map[string]time.Time // 规则与VM组合最近一次由写入触发评估的时间
+	ingestMutex      sync.Mutex
+	ingestLatency    *latencyTracker
+	tickLatency      *latencyTracker
 }
 
+// 告警条件类型
+const (
+	ConditionTypeThreshold = "threshold" // 指标值与阈值比较
+	ConditionTypeAnomaly   = "anomaly"   // 异常得分（绝对值）与阈值比较
+	ConditionTypeAbsent    = "absent"    // 指标在窗口内没有数据
+)
+
+// AbsentMetric 无数据告警（absent条件、noDataState为alerting、数据中断检测）记录的指标名
+const AbsentMetric = "__absent__"
+
 // AlertRuleWithConditions 带条件的告警规则
 type AlertRuleWithConditions struct {
 	Rule       models.AlertRule
 	Conditions []models.AlertCondition
+	Expression *Expression // 规则表达式，为空时由条件列表翻译
 }
 
 // AlertMetricData 告警指标数据
@@ -42,17 +78,35 @@ type AlertMetricData struct {
 	Metric    string
 	Value     float64
 	Timestamp time.Time
+	Condition models.AlertCondition // 触发告警的条件
+
+	// 聚合评估模式
+	Target       *aggregateTarget  // 目标范围，逐VM评估时为空
+	Members      int               // 参与聚合（有数据）的VM数量
+	Contributors []ExprContributor // 参与聚合的VM
 }
 
+// defaultConditionWindow 条件未配置持续时间时的取值窗口
+const defaultConditionWindow = 5 * time.Minute
+
 // NewAlertEngine 创建告警引擎
 func NewAlertEngine(db *gorm.DB, notifier *NotificationService) *AlertEngine {
 	return &AlertEngine{
-		db:             db,
-		notifier:       notifier,
-		rules:          make(map[uuid.UUID]*AlertRuleWithConditions),
-		evalInterval:   60 * time.Second, // 默认60秒评估一次
-		stopChan:       make(chan struct{}),
-		triggerHistory: make(map[string]time.Time),
+		db:              db,
+		notifier:        notifier,
+		rules:           make(map[uuid.UUID]*AlertRuleWithConditions),
+		evalInterval:    60 * time.Second, // 默认60秒评估一次
+		stopChan:        make(chan struct{}),
+		timeSeries:      NewTimeSeriesService(db),
+		states:          make(map[string]*models.AlertState),
+		silences:        NewSilenceService(db),
+		inhibitions:     NewInhibitService(db),
+		metricIndex:     make(map[string][]uuid.UUID),
+		ingestQueue:     make(chan ingestBatch, ingestQueueSize),
+		ingestEvaluated: make(map[string]time.Time),
+		ingestLatency:   &latencyTracker{},
+		tickLatency:     &latencyTracker{},
+		snapshotWindow:  defaultSnapshotWindow,
 	}
 }
 
@@ -65,18 +119,36 @@ func (e *AlertEngine) Start() error {
 		return fmt.Errorf("告警引擎已经在运行")
 	}
 
+	// 数据中断检测使用内置规则记录告警与状态
+	if e.deadmanTimeout > 0 {
+		if err := e.ensureDeadmanRule(); err != nil {
+			logger.Warn("创建内置数据中断规则失败", zap.Error(err))
+		}
+	}
+
 	// 加载所有启用的规则
 	if err := e.loadRules(); err != nil {
 		return fmt.Errorf("加载告警规则失败: %w", err)
 	}
 
+	// 恢复持久化的告警状态，避免重启后重复触发
+	if e.cluster != nil {
+		e.shardGeneration = e.cluster.Generation()
+	}
+	if err := e.loadStates(); err != nil {
+		return fmt.Errorf("加载告警状态失败: %w", err)
+	}
+
 	e.isRunning = true
 	e.stopChan = make(chan struct{})
 
 	// 启动评估循环
 	go e.evaluationLoop()
+	if e.ingestEnabled {
+		go e.ingestLoop(e.stopChan)
+	}
 
-	logger.Info("告警引擎已启动", zap.Duration("评估间隔", e.evalInterval))
+	logger.Info("告警引擎已启动", zap.Duration("评估间隔", e.evalInterval), zap.Bool("写入驱动评估", e.ingestEnabled))
 	return nil
 }
 
@@ -106,6 +178,51 @@ func (e *AlertEngine) SetEvalInterval(interval time.Duration) {
 	e.evalInterval = interval
 }
 
+// SetIngestEvaluation 启用写入驱动评估（需在Start之前设置，并将NotifyIngest注册为时序数据服务的写入监听器）
+func (e *AlertEngine) SetIngestEvaluation(enabled bool) {
+	e.ingestEnabled = enabled
+}
+
+// SetAnomalyService 设置异常检测服务（用于异常类型条件）
+func (e *AlertEngine) SetAnomalyService(anomaly *AnomalyService) {
+	e.anomaly = anomaly
+}
+
+// SetTimeSeriesService 设置时序数据服务（优先读取热缓存中的最新值）
+func (e *AlertEngine) SetTimeSeriesService(timeSeries *TimeSeriesService) {
+	e.timeSeries = timeSeries
+}
+
+// SetSilenceService 设置静默服务
+func (e *AlertEngine) SetSilenceService(silences *SilenceService) {
+	e.silences = silences
+}
+
+// SetInhibitService 设置告警抑制服务
+func (e *AlertEngine) SetInhibitService(inhibitions *InhibitService) {
+	e.inhibitions = inhibitions
+}
+
+// SetCluster 设置集群协调器，设置后只评估当前实例持有分片内的规则与VM组合
+func (e *AlertEngine) SetCluster(cluster *EngineCluster) {
+	e.cluster = cluster
+}
+
+// SetWorkflow 设置告警处理流程服务，告警触发与自动恢复时写入历史并推送
+func (e *AlertEngine) SetWorkflow(workflow *AlertWorkflowService) {
+	e.workflow = workflow
+}
+
+// SetIncidents 设置事件服务，新告警自动归入事件；配置按事件通知时只在新事件或事件级别升高时通知
+func (e *AlertEngine) SetIncidents(incidents *IncidentService) {
+	e.incidents = incidents
+}
+
+// SetRemediation 设置自动处置服务
+func (e *AlertEngine) SetRemediation(remediation *RemediationService) {
+	e.remediation = remediation
+}
+
 // ReloadRules 重新加载规则
 func (e *AlertEngine) ReloadRules() error {
 	return e.loadRules()
@@ -121,8 +238,16 @@ func (e *AlertEngine) loadRules() error {
 	}
 
 	newRules := make(map[uuid.UUID]*AlertRuleWithConditions)
+	var deadmanRule *models.AlertRule
 
 	for _, rule := range rules {
+		// 内置数据中断规则没有表达式，由evaluateDeadman单独评估
+		if isDeadmanRule(rule) {
+			rule := rule
+			deadmanRule = &rule
+			continue
+		}
+
 		// 加载条件
 		var conditions []models.AlertCondition
 		if err := e.db.Where("rule_id = ?", rule.ID).Order("sort_order").Find(&conditions).Error; err != nil {
@@ -130,20 +255,51 @@ func (e *AlertEngine) loadRules() error {
 			continue
 		}
 
+		expression, err := e.ruleExpression(rule, conditions)
+		if err == nil {
+			err = expression.CheckEvaluationMode(rule.EvaluationMode)
+		}
+		if err != nil {
+			logger.Error("规则表达式无效", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+			continue
+		}
+
 		newRules[rule.ID] = &AlertRuleWithConditions{
 			Rule:       rule,
 			Conditions: conditions,
+			Expression: expression,
 		}
 	}
 
+	index := buildMetricIndex(newRules)
+
 	e.rulesMutex.Lock()
 	e.rules = newRules
+	e.metricIndex = index
+	e.deadmanRule = deadmanRule
 	e.rulesMutex.Unlock()
+	e.pruneStateLocks(time.Now())
 
 	logger.Info("已加载告警规则", zap.Int("count", len(newRules)))
 	return nil
 }
 
+// ruleExpression 解析规则表达式；旧规则没有表达式时将条件列表翻译为表达式并保存
+func (e *AlertEngine) ruleExpression(rule models.AlertRule, conditions []models.AlertCondition) (*Expression, error) {
+	if rule.Expression != nil && *rule.Expression != "" {
+		return ParseExpression(*rule.Expression)
+	}
+
+	expression, err := ExpressionFromConditions(conditions, rule.ConditionLogic)
+	if err != nil {
+		return nil, err
+	}
+	if err := e.db.Model(&models.AlertRule{}).Where("id = ?", rule.ID).UpdateColumn("expression", expression.String()).Error; err != nil {
+		logger.Warn("保存翻译后的规则表达式失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+	}
+	return expression, nil
+}
+
 // evaluationLoop 评估循环
 func (e *AlertEngine) evaluationLoop() {
 	ticker := time.NewTicker(e.evalInterval)
@@ -171,61 +327,223 @@ func (e *AlertEngine) evaluateAllRules() {
 	}
 	e.rulesMutex.RUnlock()
 
+	// 分片转移后重新加载状态：新分片的状态由原持有实例持久化
+	if e.cluster != nil {
+		if generation := e.cluster.Generation(); generation != e.shardGeneration {
+			if err := e.loadStates(); err != nil {
+				logger.Error("分片变化后加载告警状态失败", zap.Error(err))
+			} else {
+				e.shardGeneration = generation
+			}
+		}
+	}
+
+	started := time.Now()
+	e.pruneIngestEvaluated(started)
+	evaluations := 0
 	for _, ruleWithCond := range rules {
-		if err := e.evaluateRule(ruleWithCond); err != nil {
+		count, err := e.evaluateRule(ruleWithCond)
+		evaluations += count
+		if err != nil {
 			logger.Error("评估规则失败", zap.String("rule_id", ruleWithCond.Rule.ID.String()), zap.Error(err))
 		}
 	}
+	evaluations += e.evaluateDeadman(started)
+	e.pruneStateLocks(time.Now())
+
+	e.tickLatency.observe(time.Since(started))
+
+	if e.cluster != nil {
+		e.cluster.ReportEvaluation(EngineEvaluationStats{
+			RuleCount:   len(rules),
+			Evaluations: evaluations,
+			At:          time.Now(),
+			Duration:    time.Since(started),
+		})
+	}
 }
 
-// evaluateRule 评估单个规则
-func (e *AlertEngine) evaluateRule(ruleWithCond *AlertRuleWithConditions) error {
+// evaluateRule 评估单个规则，返回评估的VM数量（不含其他实例负责的VM）
+func (e *AlertEngine) evaluateRule(ruleWithCond *AlertRuleWithConditions) (int, error) {
 	rule := ruleWithCond.Rule
-	_ = ruleWithCond.Conditions // 暂时不使用条件
-
-	// 检查冷却期
-	if !e.isCooldownExpired(rule.ID, rule.Cooldown) {
-		return nil
+	if rule.EvaluationMode == models.EvaluationModeAggregate {
+		return e.evaluateAggregateRule(ruleWithCond)
 	}
 
 	// 根据范围获取目标VM
 	vms, err := e.getTargetVMs(rule.Scope, rule.ScopeID)
 	if err != nil {
-		return fmt.Errorf("获取目标VM失败: %w", err)
+		return 0, fmt.Errorf("获取目标VM失败: %w", err)
 	}
 
-	// 对每个VM评估规则
+	// 对每个VM评估规则并推进状态机
+	evaluated := 0
+	now := time.Now()
 	for _, vm := range vms {
-		// 检查此VM是否在此规则下已触发且未恢复
-		alertKey := fmt.Sprintf("%s:%s", rule.ID, vm.ID)
-		if e.isActiveAlert(alertKey) {
-			// 检查是否已恢复
-			if e.checkRecovery(ruleWithCond, vm) {
-				e.resolveAlert(alertKey, vm.ID, rule.ID)
-			}
+		if e.cluster != nil && !e.cluster.Owns(rule.ID, vm.ID) {
+			continue
+		}
+		// 刚由新写入的数据评估过的组合无需重复评估
+		if e.ingestEnabled && e.recentlyIngested(rule.ID, vm.ID, now) {
 			continue
 		}
+		evaluated++
+		e.evaluateTarget(ruleWithCond, vm)
+	}
+
+	return evaluated, nil
+}
 
-		// 评估条件
-		triggered, metricData, err := e.evaluateConditions(ruleWithCond, vm)
+// evaluateTarget 评估规则在单个VM上的表达式并推进状态机
+func (e *AlertEngine) evaluateTarget(ruleWithCond *AlertRuleWithConditions, vm models.VM) {
+	rule := ruleWithCond.Rule
+	triggered, metricData, err := e.evaluateConditions(ruleWithCond, vm)
+	if errors.Is(err, ErrNoData) {
+		// 缺少数据时按规则的noDataState处理，默认保持当前状态
+		var apply bool
+		if triggered, metricData, apply = noDataResult(ruleWithCond, vm); !apply {
+			logger.Debug("条件缺少数据，跳过评估", zap.String("rule_id", rule.ID.String()), zap.String("vm_id", vm.ID.String()))
+			return
+		}
+		err = nil
+	}
+	if err != nil {
+		logger.Error("评估条件失败", zap.String("rule_id", rule.ID.String()), zap.String("vm_id", vm.ID.String()), zap.Error(err))
+		return
+	}
+
+	e.applyEvaluation(rule, vm, triggered, metricData, time.Now())
+}
+
+// applyEvaluation 根据评估结果推进告警状态，在进入firing时创建告警、进入resolved时恢复告警。
+// 快照、告警写入与事件归并、自动处置等只持有该组合的评估锁，不阻塞其他规则与VM的评估
+func (e *AlertEngine) applyEvaluation(rule models.AlertRule, vm models.VM, triggered bool, metricData *AlertMetricData, now time.Time) {
+	key := alertFingerprint(rule.ID, vm.ID)
+	unlock := e.lockState(rule.ID, vm.ID)
+	defer unlock()
+
+	state := e.stateFor(rule.ID, vm.ID)
+	previous := *state
+	if metricData != nil {
+		state.LastValue = metricData.Value
+	}
+
+	switch advanceAlertState(state, rule, triggered, now) {
+	case transitionNone:
+		// firing期间需要保存最近满足时间，以便重启后正确计算keep_firing_for
+		if !triggered || state.State != models.AlertStateFiring {
+			return
+		}
+
+	case transitionFiring:
+		// 同一规则与VM组合在冷却期内不重复触发，保持原状态等待下次评估
+		if inCooldown(state, rule, now) {
+			*state = previous
+			return
+		}
+
+		// 命中抑制创建的静默时同样保持原状态，静默结束后再触发
+		silence := e.matchSilence(rule, vm, now)
+		if silence != nil && silence.Mode == models.SilenceModeCreation {
+			logger.Info("告警被静默，未创建告警记录", zap.String("rule_name", rule.Name), zap.String("vm_name", vm.Name), zap.String("silence", silence.Name))
+			*state = previous
+			return
+		}
+
+		// 通知发送前依次检查静默、抑制规则和抖动
+		flapping := recordFlap(state, rule, now)
+		var suppression *alertSuppression
+		if silence != nil {
+			suppression = &alertSuppression{Reason: "silence: " + silence.Name, SilenceID: &silence.ID}
+		} else if inhibitRule, source := e.matchInhibition(rule, vm, metricData); inhibitRule != nil {
+			suppression = &alertSuppression{Reason: "inhibited: " + inhibitRule.Name, InhibitedBy: &source.ID}
+		} else if flapping {
+			suppression = &alertSuppression{Reason: "flapping"}
+		}
+
+		alert, err := e.createAlert(rule, vm, metricData, suppression)
 		if err != nil {
-			logger.Error("评估条件失败", zap.String("rule_id", rule.ID.String()), zap.String("vm_id", vm.ID.String()), zap.Error(err))
-			continue
+			// 创建失败时回退状态，下次评估重试
+			logger.Error("创建告警失败", zap.Error(err))
+			*state = previous
+			return
+		}
+		if alert != nil {
+			state.AlertRecordID = &alert.ID
 		}
+		state.LastFiredAt = &now
 
-		if triggered {
-			// 创建告警记录
-			if err := e.createAlert(rule, vm, metricData); err != nil {
-				logger.Error("创建告警失败", zap.Error(err))
-				continue
-			}
+	case transitionResolved:
+		recordFlap(state, rule, now)
+		e.resolveAlert(state, now)
+		state.AlertRecordID = nil
+	}
+
+	e.saveState(state)
+
+	// 评估期间重新加载了状态时以本次结果为准（不再由当前实例负责的组合除外）
+	e.statesMutex.Lock()
+	if current, ok := e.states[key]; ok && current != state {
+		e.states[key] = state
+	}
+	e.statesMutex.Unlock()
+}
+
+// stateLockKey 评估锁的键
+type stateLockKey struct {
+	ruleID uuid.UUID
+	vmID   uuid.UUID
+}
 
-			// 更新触发历史
-			e.updateTriggerHistory(rule.ID)
+// stateLock 规则与VM组合的评估锁，lastUsed为最近一次加锁的时间（UnixNano）
+type stateLock struct {
+	sync.Mutex
+	lastUsed atomic.Int64
+}
+
+// lockState 获取规则与VM组合的评估锁，返回解锁函数
+func (e *AlertEngine) lockState(ruleID, vmID uuid.UUID) func() {
+	key := stateLockKey{ruleID: ruleID, vmID: vmID}
+	for {
+		value, _ := e.stateLocks.LoadOrStore(key, &stateLock{})
+		lock := value.(*stateLock)
+		lock.Lock()
+		// 等待期间该锁可能已被清理，此时重新获取，保证同一组合只有一把锁
+		if current, ok := e.stateLocks.Load(key); ok && current == lock {
+			lock.lastUsed.Store(time.Now().UnixNano())
+			return lock.Unlock
 		}
+		lock.Unlock()
 	}
+}
 
-	return nil
+// pruneStateLocks 清理不再评估的组合的评估锁：规则已删除或停用、分片已转移到其他实例，
+// 或超过3个评估周期未使用（VM已删除或不再属于规则范围）。正在使用的锁留到下次清理
+func (e *AlertEngine) pruneStateLocks(now time.Time) {
+	e.rulesMutex.RLock()
+	loaded := make(map[uuid.UUID]bool, len(e.rules)+1)
+	for id := range e.rules {
+		loaded[id] = true
+	}
+	if e.deadmanRule != nil {
+		loaded[e.deadmanRule.ID] = true
+	}
+	e.rulesMutex.RUnlock()
+
+	idleBefore := now.Add(-3 * e.evalInterval).UnixNano()
+	e.stateLocks.Range(func(k, value interface{}) bool {
+		key, lock := k.(stateLockKey), value.(*stateLock)
+		if loaded[key.ruleID] && lock.lastUsed.Load() >= idleBefore &&
+			(e.cluster == nil || e.cluster.Owns(key.ruleID, key.vmID)) {
+			return true
+		}
+		if !lock.TryLock() {
+			return true
+		}
+		e.stateLocks.Delete(key)
+		lock.Unlock()
+		return true
+	})
 }
 
 // getTargetVMs 获取目标VM列表
@@ -295,166 +613,208 @@ func (e *AlertEngine) getTargetVMs(scope string, scopeID *uuid.UUID) ([]models.V
 	return vms, nil
 }
 
-// evaluateConditions 评估条件
-func (e *AlertEngine) evaluateConditions(ruleWithCond *AlertRuleWithConditions, vm models.VM) (bool, *MetricData, error) {
-	conditions := ruleWithCond.Conditions
-	logic := ruleWithCond.Rule.ConditionLogic
-
-	if len(conditions) == 0 {
-		return false, nil, nil
-	}
-
-	results := make([]bool, len(conditions))
-	var triggeredMetric *AlertMetricData
-
-	for i, cond := range conditions {
-		// 获取指标值
-		metricValue, err := e.getMetricValue(vm.ID, cond.Metric, cond.Aggregation, cond.Duration)
-		if err != nil {
-			// 如果无法获取指标值，认为条件不满足
-			results[i] = false
-			continue
+// evaluateConditions 评估规则表达式（没有表达式时翻译条件列表），返回是否触发以及首个成立的比较对应的指标数据。
+// 缺少数据的部分视为未知：若其余部分不足以决定结果，返回ErrNoData。
+func (e *AlertEngine) evaluateConditions(ruleWithCond *AlertRuleWithConditions, vm models.VM) (bool, *AlertMetricData, error) {
+	expression := ruleWithCond.Expression
+	if expression == nil {
+		if len(ruleWithCond.Conditions) == 0 {
+			return false, nil, nil
 		}
-
-		// 评估单个条件
-		result := e.evaluateSingleCondition(metricValue, cond.Operator, cond.Threshold)
-		results[i] = result
-
-		if result && triggeredMetric == nil {
-			triggeredMetric = &AlertMetricData{
-				VMID:      vm.ID,
-				VMName:    vm.Name,
-				Metric:    cond.Metric,
-				Value:     metricValue,
-				Timestamp: time.Now(),
-			}
+		var err error
+		if expression, err = ExpressionFromConditions(ruleWithCond.Conditions, ruleWithCond.Rule.ConditionLogic); err != nil {
+			return false, nil, err
 		}
 	}
 
-	// 根据逻辑组合条件结果
-	var triggered bool
-	switch logic {
-	case "and":
-		triggered = true
-		for _, r := range results {
-			if !r {
-				triggered = false
-				break
-			}
-		}
-	case "or":
-		triggered = false
-		for _, r := range results {
-			if r {
-				triggered = true
-				break
-			}
-		}
-	default:
-		triggered = false
+	triggered, match, err := expression.Evaluate(&engineExprSource{engine: e, vmID: vm.ID, at: time.Now()})
+	if err != nil || !triggered {
+		return false, nil, err
 	}
-
-	// 临时修复类型问题
-	// return triggered, triggeredMetric, nil
-	return triggered, (*MetricData)(nil), nil
+	return true, &AlertMetricData{
+		VMID:      vm.ID,
+		VMName:    vm.Name,
+		Metric:    match.Metric,
+		Value:     match.Value,
+		Timestamp: match.Timestamp,
+		Condition: match.Condition,
+	}, nil
 }
 
 // evaluateSingleCondition 评估单个条件
 func (e *AlertEngine) evaluateSingleCondition(value float64, operator string, threshold float64) bool {
-	switch operator {
-	case ">":
-		return value > threshold
-	case ">=":
-		return value >= threshold
-	case "<":
-		return value < threshold
-	case "<=":
-		return value <= threshold
-	case "=", "==":
-		return value == threshold
-	case "!=":
-		return value != threshold
-	default:
-		return false
+	if operator == "=" {
+		operator = "=="
 	}
+	return compareValues(value, operator, threshold)
 }
 
-// checkRecovery 检查是否已恢复
-func (e *AlertEngine) checkRecovery(ruleWithCond *AlertRuleWithConditions, vm models.VM) bool {
-	// 如果所有条件都不满足，则认为已恢复
-	triggered, _, _ := e.evaluateConditions(ruleWithCond, vm)
-	return !triggered
+// isActiveAlert 检查规则与VM组合是否存在未结束的告警（使用alert_records的规则/VM/状态组合索引）。
+// 聚合告警不记录VM，通过聚合目标（aggregateTargetID）对应的告警状态关联告警记录
+func (e *AlertEngine) isActiveAlert(ruleID, vmID uuid.UUID) bool {
+	stateAlerts := e.db.Model(&models.AlertState{}).
+		Select("alert_record_id").
+		Where("rule_id = ? AND vm_id = ? AND alert_record_id IS NOT NULL", ruleID, vmID)
+	var count int64
+	e.db.Model(&models.AlertRecord{}).
+		Where("rule_id = ? AND status IN ?", ruleID, models.AlertOpenStatuses).
+		Where("vm_id = ? OR id IN (?)", vmID, stateAlerts).
+		Count(&count)
+	return count > 0
 }
 
-// isCooldownExpired 检查冷却期是否已过
-func (e *AlertEngine) isCooldownExpired(ruleID uuid.UUID, cooldownSeconds int) bool {
-	e.historyMutex.RLock()
-	lastTriggered, exists := e.triggerHistory[ruleID.String()]
-	e.historyMutex.RUnlock()
+// alertSuppression 告警通知的抑制原因
+type alertSuppression struct {
+	Reason      string
+	SilenceID   *uuid.UUID
+	InhibitedBy *uuid.UUID
+}
 
-	if !exists {
-		return true
+// matchSilence 查找命中当前告警目标的静默，查询失败时视为未命中
+func (e *AlertEngine) matchSilence(rule models.AlertRule, vm models.VM, now time.Time) *models.Silence {
+	if e.silences == nil {
+		return nil
 	}
-
-	cooldown := time.Duration(cooldownSeconds) * time.Second
-	return time.Since(lastTriggered) >= cooldown
+	silence, err := e.silences.Match(SilenceTarget{RuleID: rule.ID, VM: vm, Severity: rule.Severity}, now)
+	if err != nil {
+		logger.Error("匹配静默失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+		return nil
+	}
+	return silence
 }
 
-// updateTriggerHistory 更新触发历史
-func (e *AlertEngine) updateTriggerHistory(ruleID uuid.UUID) {
-	e.historyMutex.Lock()
-	defer e.historyMutex.Unlock()
-	e.triggerHistory[ruleID.String()] = time.Now()
+// matchInhibition 查找抑制当前告警的规则与源告警，查询失败时视为未抑制
+func (e *AlertEngine) matchInhibition(rule models.AlertRule, vm models.VM, metricData *AlertMetricData) (*models.InhibitRule, *models.AlertRecord) {
+	if e.inhibitions == nil || metricData == nil {
+		return nil, nil
+	}
+	inhibitRule, source, err := e.inhibitions.FindInhibitor(NewAlertLabels(rule, vm, metricData.Metric))
+	if err != nil {
+		logger.Error("匹配抑制规则失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+		return nil, nil
+	}
+	return inhibitRule, source
 }
 
-// isActiveAlert 检查是否存在活动告警
-func (e *AlertEngine) isActiveAlert(alertKey string) bool {
-	var count int64
-	e.db.Model(&models.AlertRecord{}).
-		Where("rule_id || ':' || vm_id = ? AND status = ?", alertKey, "active").
-		Count(&count)
-	return count > 0
-}
+// resolveAlert 恢复状态对应的告警记录（未被人工处理的活动或已确认告警）
+func (e *AlertEngine) resolveAlert(state *models.AlertState, now time.Time) {
+	query := e.db.Where("status IN ?", models.AlertOpenStatuses)
+	if state.AlertRecordID != nil {
+		query = query.Where("id = ?", *state.AlertRecordID)
+	} else {
+		query = query.Where("vm_id = ? AND rule_id = ?", state.VMID, state.RuleID).Order("triggered_at DESC")
+	}
 
-// resolveAlert 解决告警
-func (e *AlertEngine) resolveAlert(alertKey string, vmID uuid.UUID, ruleID uuid.UUID) {
-	// 查找并更新活动告警
 	var alert models.AlertRecord
-	if err := e.db.Where("vm_id = ? AND rule_id = ? AND status = ?", vmID, ruleID, "active").
-		Order("triggered_at DESC").
-		First(&alert).Error; err != nil {
+	if err := query.First(&alert).Error; err != nil {
 		return
 	}
 
-	now := time.Now()
+	from := alert.Status
+	duration := int(now.Sub(alert.TriggeredAt).Minutes())
 	alert.Status = "resolved"
 	alert.ResolvedAt = &now
-	if alert.Duration != nil {
-		duration := int(now.Sub(alert.TriggeredAt).Minutes())
-		alert.Duration = &duration
-	}
+	alert.Duration = &duration
 
 	if err := e.db.Save(&alert).Error; err != nil {
 		logger.Error("解决告警失败", zap.Error(err))
 		return
 	}
+	if e.workflow != nil {
+		e.workflow.RecordSystem(&alert, models.AlertHistoryAutoResolved, &from, "")
+	}
+	if e.incidents != nil {
+		e.incidents.SyncAlertClosed(&alert)
+	}
 
 	logger.Info("告警已自动恢复", zap.String("rule_name", alert.RuleName))
 }
 
-// createAlert 创建告警记录
-func (e *AlertEngine) createAlert(rule models.AlertRule, vm models.VM, metricData *MetricData) error {
-	// 检查是否已存在相同的活动告警
-	var existingCount int64
-	e.db.Model(&models.AlertRecord{}).
-		Where("rule_id = ? AND vm_id = ? AND status = ?", rule.ID, vm.ID, "active").
-		Count(&existingCount)
+// loadStates 从数据库加载持久化的告警状态
+func (e *AlertEngine) loadStates() error {
+	var states []models.AlertState
+	if err := e.db.Find(&states).Error; err != nil {
+		return err
+	}
+
+	e.statesMutex.Lock()
+	defer e.statesMutex.Unlock()
+
+	e.states = make(map[string]*models.AlertState, len(states))
+	for i := range states {
+		state := &states[i]
+		// 集群部署时只保留当前实例负责的状态
+		if e.cluster != nil && !e.cluster.Owns(state.RuleID, state.VMID) {
+			continue
+		}
+		e.states[alertFingerprint(state.RuleID, state.VMID)] = state
+	}
 
-	if existingCount > 0 {
-		return nil // 已存在活动告警，不重复创建
+	logger.Info("已加载告警状态", zap.Int("count", len(e.states)))
+	return nil
+}
+
+// stateFor 获取规则与VM组合的状态（调用方需持有该组合的评估锁）。
+// 没有持久化状态但存在活动告警时（如升级前触发的告警），视为firing。
+func (e *AlertEngine) stateFor(ruleID, vmID uuid.UUID) *models.AlertState {
+	key := alertFingerprint(ruleID, vmID)
+	e.statesMutex.Lock()
+	state, ok := e.states[key]
+	e.statesMutex.Unlock()
+	if ok {
+		return state
+	}
+
+	state = &models.AlertState{
+		ID:     uuid.New(),
+		RuleID: ruleID,
+		VMID:   vmID,
+		State:  models.AlertStateInactive,
+	}
+	if e.db != nil && e.isActiveAlert(ruleID, vmID) {
+		now := time.Now()
+		state.State = models.AlertStateFiring
+		state.FiringSince = &now
+		state.LastTrueAt = &now
+	}
+
+	e.statesMutex.Lock()
+	defer e.statesMutex.Unlock()
+	if existing, ok := e.states[key]; ok {
+		return existing
+	}
+	e.states[key] = state
+	return state
+}
+
+// saveState 持久化告警状态
+func (e *AlertEngine) saveState(state *models.AlertState) {
+	if e.db == nil {
+		return
+	}
+	if err := e.db.Save(state).Error; err != nil {
+		logger.Error("保存告警状态失败", zap.String("rule_id", state.RuleID.String()), zap.String("vm_id", state.VMID.String()), zap.Error(err))
+	}
+}
+
+// alertFingerprint 告警指纹（规则与VM组合），用作状态与冷却期的键
+func alertFingerprint(ruleID, vmID uuid.UUID) string {
+	return fmt.Sprintf("%s:%s", ruleID, vmID)
+}
+
+// createAlert 创建告警记录，存在抑制原因（静默、抖动）时只记录不发送通知。已存在活动告警时返回nil
+func (e *AlertEngine) createAlert(rule models.AlertRule, vm models.VM, metricData *AlertMetricData, suppression *alertSuppression) (*models.AlertRecord, error) {
+	if metricData == nil {
+		return nil, fmt.Errorf("缺少触发告警的指标数据")
+	}
+
+	// 检查是否已存在相同的活动告警
+	if e.isActiveAlert(rule.ID, vm.ID) {
+		return nil, nil // 已存在活动告警，不重复创建
 	}
 
 	// 创建快照数据
+	now := time.Now()
 	snapshot := map[string]interface{}{
 		"vm": map[string]interface{}{
 			"id":         vm.ID,
@@ -464,20 +824,28 @@ func (e *AlertEngine) createAlert(rule models.AlertRule, vm models.VM, metricDat
 			"hostName":   vm.HostName,
 			"clusterName": vm.ClusterName,
 		},
-		"triggeredAt": time.Now(),
+		"triggeredAt": now,
+		"metric": map[string]interface{}{
+			"name":        metricData.Metric,
+			"value":       metricData.Value,
+			"aggregation": metricData.Condition.Aggregation,
+			"duration":    metricData.Condition.Duration,
+			"timestamp":   metricData.Timestamp,
+		},
 	}
 
-	_ , _ = json.Marshal(snapshot) // 暂时不使用snapshotJSON
-
-	// 查找触发的具体条件
-	var triggeredCondition models.AlertCondition
-	for _, cond := range rule.Conditions {
-		if cond.Metric == metricData.Metric {
-			triggeredCondition = cond
-			break
+	if metricData.Target != nil {
+		delete(snapshot, "vm")
+		for key, value := range aggregateSnapshot(metricData) {
+			snapshot[key] = value
 		}
 	}
 
+	// 记录触发前的指标序列、条件取值与最近事件，原始数据汇总后仍可查看现场
+	e.enrichSnapshot(snapshot, rule, vm, metricData, now)
+
+	triggeredCondition := metricData.Condition
+
 	// 构建条件字符串
 	conditionStr := fmt.Sprintf("%s %s %.4f (实际值: %.4f)",
 		metricData.Metric,
@@ -485,6 +853,11 @@ func (e *AlertEngine) createAlert(rule models.AlertRule, vm models.VM, metricDat
 		triggeredCondition.Threshold,
 		metricData.Value,
 	)
+	if triggeredCondition.Type == ConditionTypeAbsent {
+		conditionStr = absentConditionString(triggeredCondition, metricData)
+	} else if metricData.Target != nil {
+		conditionStr = aggregateConditionString(metricData)
+	}
 
 	alert := models.AlertRecord{
 		ID:            uuid.New(),
@@ -506,9 +879,39 @@ func (e *AlertEngine) createAlert(rule models.AlertRule, vm models.VM, metricDat
 		UpdatedAt:     time.Now(),
 	}
 
+	// 聚合告警记录目标范围而非单个VM
+	if metricData.Target != nil {
+		alert.VMID, alert.VMName = nil, nil
+		alert.GroupID, alert.ClusterID = metricData.Target.VM.GroupID, metricData.Target.VM.ClusterID
+	}
+
+	// 未被抑制的告警按规则的升级策略安排第一级升级
+	if suppression == nil {
+		alert.NextEscalationAt = nextEscalationAt(rule.NotificationConfig, alert.TriggeredAt, 0)
+	}
+
+	if suppression != nil {
+		alert.Suppressed = true
+		alert.SuppressionReason = &suppression.Reason
+		alert.SilenceID = suppression.SilenceID
+		alert.InhibitedBy = suppression.InhibitedBy
+	}
+
 	// 保存告警记录
 	if err := e.db.Create(&alert).Error; err != nil {
-		return fmt.Errorf("保存告警记录失败: %w", err)
+		return nil, fmt.Errorf("保存告警记录失败: %w", err)
+	}
+	if e.workflow != nil {
+		e.workflow.RecordSystem(&alert, models.AlertHistoryCreated, nil, conditionStr)
+	}
+
+	// 归入事件
+	var attachment *IncidentAttachment
+	if e.incidents != nil {
+		var err error
+		if attachment, err = e.incidents.Attach(&alert, rule, vm); err != nil {
+			logger.Error("告警归入事件失败", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+		}
 	}
 
 	// 更新规则触发计数
@@ -519,51 +922,57 @@ func (e *AlertEngine) createAlert(rule models.AlertRule, vm models.VM, metricDat
 			"last_triggered_at": time.Now(),
 		})
 
-	// 发送通知
-	if e.notifier != nil {
-		go e.notifier.SendAlert(context.Background(), alert, rule.NotificationConfig)
+	// 发送通知（静默、抑制或抖动时不发送）
+	if suppression != nil {
+		logger.Warn("告警通知已抑制", zap.String("rule_name", rule.Name), zap.String("vm_name", vm.Name), zap.String("reason", suppression.Reason))
+	} else if e.notifier != nil {
+		if attachment != nil && e.incidents.NotifyPerIncident() {
+			if attachment.Notify {
+				go e.incidents.Notify(e.notifier, attachment.Incident, alert, rule.NotificationConfig)
+			}
+		} else {
+			go e.notifier.SendAlert(context.Background(), alert, rule.NotificationConfig)
+		}
+	}
+
+	// 自动处置（静默、抑制或抖动时同样不执行）
+	if suppression == nil && e.remediation != nil {
+		e.remediation.Plan(&alert, rule)
 	}
 
 	logger.Info("告警已触发", zap.String("rule_name", rule.Name), zap.String("vm_name", vm.Name))
-	return nil
+	return &alert, nil
 }
 
-// getMetricValue 获取指标值（从数据库或缓存）
-func (e *AlertEngine) getMetricValue(vmID uuid.UUID, metric string, aggregation string, duration int) (float64, error) {
-	// 这里应该从时序数据库获取指标值
-	// 目前先用模拟数据，后续需要接入实际的数据采集系统
-
-	// TODO: 实现从TimescaleDB或Redis获取实时/历史指标数据
-	// 查询逻辑示例:
-	// 1. 优先从Redis缓存获取最新值
-	// 2. 如果没有，从TimescaleDB查询聚合值
-	// 3. 根据聚合类型(avg/max/min/last)计算结果
-
-	// 临时返回模拟数据，实际实现需要接入数据采集
-	return e.getSimulatedMetricValue(vmID, metric)
-}
-
-// getSimulatedMetricValue 获取模拟指标值（开发测试用）
-func (e *AlertEngine) getSimulatedMetricValue(vmID uuid.UUID, metric string) (float64, error) {
-	// 实际项目中应删除此函数，改为从真实数据源获取
-	// 这里仅用于演示，返回随机但合理的值
-	
-	switch metric {
-	case "cpu_usage":
-		return 75.5, nil // 75.5% CPU使用率
-	case "memory_usage":
-		return 82.3, nil // 82.3% 内存使用率
-	case "disk_usage":
-		return 68.0, nil // 68% 磁盘使用率
-	case "network_rx":
-		return 1024.0, nil // 1MB/s 网络接收
-	case "network_tx":
-		return 512.0, nil // 512KB/s 网络发送
-	case "disk_io_read":
-		return 100.0, nil // 100 IOPS 磁盘读
-	case "disk_io_write":
-		return 80.0, nil // 80 IOPS 磁盘写
-	default:
-		return 0, fmt.Errorf("未知的指标类型: %s", metric)
+// engineExprSource 告警引擎为表达式求值提供的数据来源（绑定VM与评估时间）
+type engineExprSource struct {
+	engine *AlertEngine
+	vmID   uuid.UUID
+	at     time.Time
+}
+
+// Aggregate 按聚合方式计算指标在评估时间前window内的值（优先读取热缓存，未覆盖时查询数据库）
+func (s *engineExprSource) Aggregate(metric, aggregation string, window time.Duration) (*MetricData, error) {
+	if s.engine.timeSeries == nil {
+		return nil, fmt.Errorf("时序数据服务未配置")
+	}
+	return s.engine.timeSeries.WindowAggregate(s.vmID.String(), metric, aggregation, s.at.Add(-window), s.at)
+}
+
+// Anomaly 获取指标最新异常得分的绝对值；最新数据点超过window（至少5分钟）未更新时返回ErrNoData
+func (s *engineExprSource) Anomaly(metric, algorithm string, window time.Duration) (*MetricData, error) {
+	if s.engine.anomaly == nil {
+		return nil, fmt.Errorf("异常检测服务未配置")
+	}
+
+	maxAge := window
+	if maxAge < defaultConditionWindow {
+		maxAge = defaultConditionWindow
+	}
+
+	point, err := s.engine.anomaly.LatestScore(s.vmID.String(), metric, maxAge, AnomalyOptions{Algorithm: algorithm})
+	if err != nil {
+		return nil, err
 	}
+	return &MetricData{VMID: s.vmID.String(), Metric: metric, Value: math.Abs(point.Score), Timestamp: point.Timestamp}, nil
 }
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWindowAggregate_Database(t *testing.T) {
+	db := setupRetentionTestDB(t)
+	service := NewTimeSeriesService(db)
+	start := time.Now().Add(-10 * time.Minute).Truncate(time.Second)
+	insertMetricRows(t, db, "vm-1", "cpu_usage", start, 10, 40, 20, 30)
+
+	end := start.Add(5 * time.Minute)
+	expected := map[string]float64{"last": 30, "avg": 25, "max": 40, "min": 10, "sum": 100}
+	for aggregation, value := range expected {
+		data, err := service.WindowAggregate("vm-1", "cpu_usage", aggregation, start, end)
+		require.NoError(t, err, aggregation)
+		assert.InDelta(t, value, data.Value, 1e-9, aggregation)
+		assert.True(t, data.Timestamp.Equal(start.Add(3*time.Minute)), aggregation)
+	}
+
+	_, err := service.WindowAggregate("vm-1", "cpu_usage", "avg", end, end.Add(time.Hour))
+	assert.ErrorIs(t, err, ErrNoData)
+
+	_, err = service.WindowAggregate("vm-1", "cpu_usage", "p99", start, end)
+	assert.Error(t, err)
+}
+
+func TestWindowAggregate_HotCache(t *testing.T) {
+	service := NewTimeSeriesService(nil)
+	service.SetHotCache(NewHotCache(time.Hour))
+	now := time.Now().Truncate(time.Second)
+	service.hot.Append([]MetricData{
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 5, Timestamp: now.Add(-3 * time.Minute)},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 15, Timestamp: now.Add(-2 * time.Minute)},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 10, Timestamp: now.Add(-time.Minute)},
+	})
+
+	data, err := service.WindowAggregate("vm-1", "cpu_usage", "max", now.Add(-150*time.Second), now)
+	require.NoError(t, err)
+	assert.Equal(t, 15.0, data.Value)
+	assert.True(t, data.Timestamp.Equal(now.Add(-time.Minute)))
+
+	_, err = service.WindowAggregate("vm-1", "cpu_usage", "avg", now.Add(-30*time.Second), now)
+	assert.ErrorIs(t, err, ErrNoData)
+}
+
+func TestAlertEngine_EvaluateConditions(t *testing.T) {
+	engine := NewAlertEngine(nil, nil)
+	engine.SetTimeSeriesService(NewTimeSeriesService(nil))
+	engine.timeSeries.SetHotCache(NewHotCache(time.Hour))
+
+	vm := models.VM{ID: uuid.New(), Name: "web-01"}
+	now := time.Now().Truncate(time.Second)
+	engine.timeSeries.hot.Append([]MetricData{
+		{VMID: vm.ID.String(), Metric: "cpu_usage", Value: 60, Timestamp: now.Add(-6 * time.Minute)},
+		{VMID: vm.ID.String(), Metric: "cpu_usage", Value: 70, Timestamp: now.Add(-4 * time.Minute)},
+		{VMID: vm.ID.String(), Metric: "memory_usage", Value: 50, Timestamp: now.Add(-6 * time.Minute)},
+		{VMID: vm.ID.String(), Metric: "cpu_usage", Value: 95, Timestamp: now.Add(-2 * time.Minute)},
+		{VMID: vm.ID.String(), Metric: "cpu_usage", Value: 80, Timestamp: now.Add(-time.Minute)},
+		{VMID: vm.ID.String(), Metric: "memory_usage", Value: 40, Timestamp: now.Add(-time.Minute)},
+	})
+
+	cpuAvg := models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 85, Duration: 150, Aggregation: "avg"}
+	cpuMax := models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90, Duration: 300, Aggregation: "max"}
+	memory := models.AlertCondition{Metric: "memory_usage", Operator: ">", Threshold: 90, Duration: 300, Aggregation: "last"}
+	disk := models.AlertCondition{Metric: "disk_usage", Operator: ">", Threshold: 90, Duration: 300, Aggregation: "last"}
+
+	evaluate := func(logic string, conditions ...models.AlertCondition) (bool, *AlertMetricData, error) {
+		return engine.evaluateConditions(&AlertRuleWithConditions{
+			Rule:       models.AlertRule{ConditionLogic: logic},
+			Conditions: conditions,
+		}, vm)
+	}
+
+	// 最近150秒平均值为87.5
+	triggered, metric, err := evaluate("and", cpuAvg)
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	require.NotNil(t, metric)
+	assert.InDelta(t, 87.5, metric.Value, 1e-9)
+	assert.Equal(t, "avg", metric.Condition.Aggregation)
+	assert.True(t, metric.Timestamp.Equal(now.Add(-time.Minute)))
+
+	triggered, metric, err = evaluate("or", memory, cpuMax)
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.Equal(t, 95.0, metric.Value)
+
+	triggered, metric, err = evaluate("and", cpuMax, memory)
+	require.NoError(t, err)
+	assert.False(t, triggered)
+	assert.Nil(t, metric)
+
+	// 缺少数据的条件无法决定结果时返回ErrNoData，能决定时忽略
+	_, _, err = evaluate("and", cpuMax, disk)
+	assert.ErrorIs(t, err, ErrNoData)
+	triggered, _, err = evaluate("and", memory, disk)
+	require.NoError(t, err)
+	assert.False(t, triggered)
+	triggered, _, err = evaluate("or", disk, cpuMax)
+	require.NoError(t, err)
+	assert.True(t, triggered)
+
+}
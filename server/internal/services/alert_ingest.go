@@ -0,0 +1,259 @@
+package services
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+)
+
+// 写入驱动评估参数
+const (
+	ingestQueueSize    = 256  // 待评估的写入批次队列长度，队列满时丢弃批次（由定时评估兜底）
+	latencySampleLimit = 1024 // 延迟统计保留的最近样本数
+)
+
+// ingestBatch 一次写入涉及的VM与指标
+type ingestBatch struct {
+	metrics    map[string]map[string]bool // vmID → 指标集合
+	receivedAt time.Time
+}
+
+// LatencyStats 评估延迟统计（毫秒），百分位基于最近的样本
+type LatencyStats struct {
+	Count int64   `json:"count"` // 累计样本数
+	Avg   float64 `json:"avg"`
+	P50   float64 `json:"p50"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+	Max   float64 `json:"max"`
+}
+
+// AlertEvaluationMetrics 告警引擎的评估指标
+type AlertEvaluationMetrics struct {
+	IngestEnabled  bool         `json:"ingestEnabled"`
+	EvalInterval   int          `json:"evalInterval"`   // 定时评估间隔（秒）
+	IndexedMetrics int          `json:"indexedMetrics"` // 指标→规则索引中的指标数
+	QueueDepth     int          `json:"queueDepth"`     // 等待评估的写入批次
+	DroppedBatches int64        `json:"droppedBatches"` // 队列满时丢弃的写入批次
+	Ingest         LatencyStats `json:"ingest"`         // 数据写入到相关规则评估完成的延迟
+	Tick           LatencyStats `json:"tick"`           // 每轮定时评估的耗时
+}
+
+// latencyTracker 记录最近的延迟样本
+type latencyTracker struct {
+	mu      sync.Mutex
+	samples []time.Duration
+	next    int
+	count   int64
+}
+
+// observe 记录一个延迟样本
+func (t *latencyTracker) observe(d time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.samples) < latencySampleLimit {
+		t.samples = append(t.samples, d)
+	} else {
+		t.samples[t.next] = d
+		t.next = (t.next + 1) % latencySampleLimit
+	}
+	t.count++
+}
+
+// stats 计算最近样本的平均值、百分位与最大值
+func (t *latencyTracker) stats() LatencyStats {
+	t.mu.Lock()
+	samples := append([]time.Duration(nil), t.samples...)
+	count := t.count
+	t.mu.Unlock()
+
+	result := LatencyStats{Count: count}
+	if len(samples) == 0 {
+		return result
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+
+	ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+	percentile := func(p float64) float64 {
+		return ms(samples[int(p*float64(len(samples)-1))])
+	}
+	var total time.Duration
+	for _, d := range samples {
+		total += d
+	}
+	result.Avg = ms(total / time.Duration(len(samples)))
+	result.P50 = percentile(0.50)
+	result.P95 = percentile(0.95)
+	result.P99 = percentile(0.99)
+	result.Max = ms(samples[len(samples)-1])
+	return result
+}
+
+// buildMetricIndex 构建指标 → 引用该指标的规则索引
+func buildMetricIndex(rules map[uuid.UUID]*AlertRuleWithConditions) map[string][]uuid.UUID {
+	index := make(map[string][]uuid.UUID)
+	for id, rule := range rules {
+		// 聚合规则依赖范围内全部VM的数据，只由定时评估处理
+		if rule.Expression == nil || rule.Rule.EvaluationMode == models.EvaluationModeAggregate {
+			continue
+		}
+		for _, metric := range rule.Expression.Metrics() {
+			index[metric] = append(index[metric], id)
+		}
+	}
+	return index
+}
+
+// NotifyIngest 接收新写入的指标（作为时序数据服务的写入监听器），将涉及的VM与指标放入评估队列。
+// 不阻塞写入：队列满时丢弃本批次，由定时评估兜底。
+func (e *AlertEngine) NotifyIngest(metrics []MetricData) {
+	if !e.ingestEnabled || len(metrics) == 0 || !e.IsRunning() {
+		return
+	}
+
+	batch := ingestBatch{metrics: map[string]map[string]bool{}, receivedAt: time.Now()}
+	for _, m := range metrics {
+		if batch.metrics[m.VMID] == nil {
+			batch.metrics[m.VMID] = map[string]bool{}
+		}
+		batch.metrics[m.VMID][m.Metric] = true
+	}
+
+	select {
+	case e.ingestQueue <- batch:
+	default:
+		atomic.AddInt64(&e.ingestDropped, 1)
+	}
+}
+
+// ingestLoop 逐个处理写入批次
+func (e *AlertEngine) ingestLoop(stop <-chan struct{}) {
+	for {
+		select {
+		case batch := <-e.ingestQueue:
+			e.evaluateIngest(batch)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// evaluateIngest 只评估引用了新写入指标、且作用范围包含对应VM的规则
+func (e *AlertEngine) evaluateIngest(batch ingestBatch) {
+	e.rulesMutex.RLock()
+	targets := make(map[string][]*AlertRuleWithConditions, len(batch.metrics))
+	for vmID, metrics := range batch.metrics {
+		seen := map[uuid.UUID]bool{}
+		for metric := range metrics {
+			for _, ruleID := range e.metricIndex[metric] {
+				if !seen[ruleID] {
+					seen[ruleID] = true
+					targets[vmID] = append(targets[vmID], e.rules[ruleID])
+				}
+			}
+		}
+	}
+	e.rulesMutex.RUnlock()
+
+	for vmID, rules := range targets {
+		var vm models.VM
+		if err := e.db.First(&vm, "id = ? AND is_deleted = ?", vmID, false).Error; err != nil {
+			logger.Debug("写入数据的VM不存在，跳过评估", zap.String("vm_id", vmID), zap.Error(err))
+			continue
+		}
+
+		for _, rule := range rules {
+			inScope, err := e.vmInScope(rule.Rule, vm)
+			if err != nil {
+				logger.Error("检查规则作用范围失败", zap.String("rule_id", rule.Rule.ID.String()), zap.Error(err))
+				continue
+			}
+			if !inScope || (e.cluster != nil && !e.cluster.Owns(rule.Rule.ID, vm.ID)) {
+				continue
+			}
+			e.evaluateTarget(rule, vm)
+			e.markIngestEvaluated(rule.Rule.ID, vm.ID, time.Now())
+		}
+	}
+
+	e.ingestLatency.observe(time.Since(batch.receivedAt))
+}
+
+// vmInScope 判断VM是否在规则的作用范围内（与getTargetVMs的筛选条件一致）
+func (e *AlertEngine) vmInScope(rule models.AlertRule, vm models.VM) (bool, error) {
+	scopeID := ""
+	if rule.ScopeID != nil {
+		scopeID = rule.ScopeID.String()
+	}
+
+	switch rule.Scope {
+	case "all":
+		return vm.Status != "unknown", nil
+	case "vm":
+		return vm.ID.String() == scopeID, nil
+	case "group":
+		var count int64
+		if err := e.db.Table("vm_group_members").Where("group_id = ? AND vm_id = ?", scopeID, vm.ID).Count(&count).Error; err != nil {
+			return false, err
+		}
+		return count > 0, nil
+	case "cluster":
+		return vm.ClusterID != nil && *vm.ClusterID == scopeID, nil
+	case "host":
+		return vm.HostID != nil && *vm.HostID == scopeID, nil
+	case "datacenter":
+		return vm.DatacenterID != nil && *vm.DatacenterID == scopeID, nil
+	}
+	return false, nil
+}
+
+// markIngestEvaluated 记录规则与VM组合最近一次由写入触发评估的时间
+func (e *AlertEngine) markIngestEvaluated(ruleID, vmID uuid.UUID, at time.Time) {
+	e.ingestMutex.Lock()
+	e.ingestEvaluated[alertFingerprint(ruleID, vmID)] = at
+	e.ingestMutex.Unlock()
+}
+
+// recentlyIngested 规则与VM组合在一个评估间隔内已由写入触发评估时，定时评估可以跳过
+func (e *AlertEngine) recentlyIngested(ruleID, vmID uuid.UUID, now time.Time) bool {
+	e.ingestMutex.Lock()
+	defer e.ingestMutex.Unlock()
+	at, ok := e.ingestEvaluated[alertFingerprint(ruleID, vmID)]
+	return ok && now.Sub(at) < e.evalInterval
+}
+
+// pruneIngestEvaluated 清理超过一个评估间隔的记录
+func (e *AlertEngine) pruneIngestEvaluated(now time.Time) {
+	e.ingestMutex.Lock()
+	defer e.ingestMutex.Unlock()
+	for key, at := range e.ingestEvaluated {
+		if now.Sub(at) >= e.evalInterval {
+			delete(e.ingestEvaluated, key)
+		}
+	}
+}
+
+// EvaluationMetrics 返回评估模式、写入队列与评估延迟统计
+func (e *AlertEngine) EvaluationMetrics() AlertEvaluationMetrics {
+	e.rulesMutex.RLock()
+	indexed := len(e.metricIndex)
+	e.rulesMutex.RUnlock()
+
+	return AlertEvaluationMetrics{
+		IngestEnabled:  e.ingestEnabled,
+		EvalInterval:   int(e.evalInterval / time.Second),
+		IndexedMetrics: indexed,
+		QueueDepth:     len(e.ingestQueue),
+		DroppedBatches: atomic.LoadInt64(&e.ingestDropped),
+		Ingest:         e.ingestLatency.stats(),
+		Tick:           e.tickLatency.stats(),
+	}
+}
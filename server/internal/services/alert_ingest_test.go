@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertEngine_EvaluateIngest(t *testing.T) {
+	db := setupInhibitTestDB(t)
+	for _, column := range []string{"name TEXT", "cluster_id TEXT", "status TEXT", "is_deleted BOOLEAN DEFAULT 0"} {
+		require.NoError(t, db.Exec("ALTER TABLE vms ADD COLUMN "+column).Error)
+	}
+
+	engine := NewAlertEngine(db, nil)
+	engine.SetIngestEvaluation(true)
+	engine.timeSeries.SetHotCache(NewHotCache(time.Hour))
+
+	vmA, vmB := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{vmA, vmB} {
+		require.NoError(t, db.Exec("INSERT INTO vms (id, name, status) VALUES (?, ?, ?)", id, "vm-"+id.String()[:4], "running").Error)
+	}
+
+	rule := func(expression, scope string, scopeID *uuid.UUID) *AlertRuleWithConditions {
+		expr, err := ParseExpression(expression)
+		require.NoError(t, err)
+		r := models.AlertRule{ID: uuid.New(), Name: expression, Scope: scope, ScopeID: scopeID, Severity: "high"}
+		require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", r.ID).Error)
+		return &AlertRuleWithConditions{Rule: r, Expression: expr}
+	}
+	cpuAll := rule("cpu_usage > 90", "all", nil)
+	memoryAll := rule("memory_usage > 90", "all", nil)
+	cpuOnB := rule("cpu_usage > 50", "vm", &vmB)
+	engine.rules = map[uuid.UUID]*AlertRuleWithConditions{cpuAll.Rule.ID: cpuAll, memoryAll.Rule.ID: memoryAll, cpuOnB.Rule.ID: cpuOnB}
+	engine.metricIndex = buildMetricIndex(engine.rules)
+	assert.Len(t, engine.metricIndex["cpu_usage"], 2)
+
+	now := time.Now()
+	samples := []MetricData{
+		{VMID: vmA.String(), Metric: "cpu_usage", Value: 10, Timestamp: now.Add(-10 * time.Minute)},
+		{VMID: vmA.String(), Metric: "cpu_usage", Value: 95, Timestamp: now},
+		{VMID: vmA.String(), Metric: "memory_usage", Value: 95, Timestamp: now.Add(-time.Hour)},
+	}
+	engine.timeSeries.hot.Append(samples)
+
+	// 只评估引用了cpu_usage且作用范围包含vmA的规则
+	engine.evaluateIngest(ingestBatch{metrics: map[string]map[string]bool{vmA.String(): {"cpu_usage": true}}, receivedAt: now})
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "1 = 1"))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "rule_id = ? AND vm_id = ?", cpuAll.Rule.ID, vmA))
+
+	// 定时评估跳过刚由写入评估过的组合
+	assert.True(t, engine.recentlyIngested(cpuAll.Rule.ID, vmA, time.Now()))
+	assert.False(t, engine.recentlyIngested(memoryAll.Rule.ID, vmA, time.Now()))
+	engine.pruneIngestEvaluated(time.Now().Add(engine.evalInterval))
+	assert.False(t, engine.recentlyIngested(cpuAll.Rule.ID, vmA, time.Now()))
+
+	metrics := engine.EvaluationMetrics()
+	assert.True(t, metrics.IngestEnabled)
+	assert.Equal(t, 2, metrics.IndexedMetrics)
+	assert.Equal(t, int64(1), metrics.Ingest.Count)
+
+	// 引擎未运行时不接收写入通知
+	engine.NotifyIngest(samples)
+	assert.Equal(t, 0, len(engine.ingestQueue))
+}
+
+func TestLatencyTracker_Stats(t *testing.T) {
+	tracker := &latencyTracker{}
+	assert.Equal(t, LatencyStats{}, tracker.stats())
+
+	for i := 1; i <= latencySampleLimit+100; i++ {
+		tracker.observe(time.Duration(i) * time.Millisecond)
+	}
+	stats := tracker.stats()
+	assert.Equal(t, int64(latencySampleLimit+100), stats.Count)
+	// 只保留最近的样本：101ms ~ 1124ms
+	assert.Equal(t, float64(latencySampleLimit+100), stats.Max)
+	assert.InDelta(t, 612.5, stats.Avg, 1)
+	assert.InDelta(t, 1073, stats.P95, 2)
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+)
+
+// 告警快照参数
+const (
+	defaultSnapshotWindow = 30 * time.Minute // 快照记录触发前的指标时间窗口
+	snapshotMaxPoints     = 120              // 每个序列最多保存的数据点，超出时降采样
+	snapshotSeriesVMLimit = 5                // 聚合告警最多保存序列的VM数（按取值降序）
+	snapshotEventLimit    = 20               // 最近事件条数
+	snapshotEventLookback = 24 * time.Hour   // 最近事件的回看时间
+)
+
+// snapshotRelatedMetrics 快照中始终记录的VM相关指标
+var snapshotRelatedMetrics = []string{"cpu_usage", "memory_usage", "disk_usage"}
+
+// SnapshotPoint 快照中的数据点
+type SnapshotPoint struct {
+	T time.Time `json:"t"`
+	V float64   `json:"v"`
+}
+
+// SnapshotSeries 快照中的指标序列，Role为trigger（规则引用的指标）或related（VM相关指标）
+type SnapshotSeries struct {
+	VMID   string          `json:"vmId"`
+	VMName string          `json:"vmName"`
+	Metric string          `json:"metric"`
+	Role   string          `json:"role"`
+	Points []SnapshotPoint `json:"points"`
+}
+
+// SnapshotEvent 快照中VM的最近事件（其他告警、人工操作）
+type SnapshotEvent struct {
+	Type      string    `json:"type"` // alert, operation
+	Title     string    `json:"title"`
+	Severity  string    `json:"severity,omitempty"`
+	Status    string    `json:"status,omitempty"`
+	VMName    string    `json:"vmName,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SetSnapshotWindow 设置告警快照记录的指标时间窗口
+func (e *AlertEngine) SetSnapshotWindow(window time.Duration) {
+	if window > 0 {
+		e.snapshotWindow = window
+	}
+}
+
+// enrichSnapshot 在快照中记录触发前的指标序列、全部条件的取值与VM最近事件，
+// 使原始数据汇总后告警详情仍能还原现场。单项失败只记录日志
+func (e *AlertEngine) enrichSnapshot(snapshot map[string]interface{}, rule models.AlertRule, vm models.VM, metricData *AlertMetricData, now time.Time) {
+	start := now.Add(-e.snapshotWindow)
+	snapshot["window"] = map[string]interface{}{
+		"start":   start,
+		"end":     now,
+		"minutes": int(e.snapshotWindow / time.Minute),
+	}
+
+	e.rulesMutex.RLock()
+	var expression *Expression
+	if ruleWithCond, ok := e.rules[rule.ID]; ok {
+		expression = ruleWithCond.Expression
+	}
+	e.rulesMutex.RUnlock()
+
+	// 聚合告警记录取值最高的几个VM，逐VM告警记录该VM
+	vms := []models.VM{vm}
+	if metricData.Target != nil {
+		vms = snapshotVMs(metricData)
+	}
+
+	if e.timeSeries != nil {
+		series, err := e.snapshotSeries(vms, snapshotTriggerMetrics(expression, metricData), start, now)
+		if err != nil {
+			logger.Warn("记录告警快照序列失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+		}
+		snapshot["series"] = series
+
+		if expression != nil {
+			var conditions []ExprComparison
+			if metricData.Target != nil {
+				members := make([]ExprFleetMember, len(metricData.Target.VMs))
+				for i, member := range metricData.Target.VMs {
+					members[i] = ExprFleetMember{VMID: member.ID, VMName: member.Name, Source: &engineExprSource{engine: e, vmID: member.ID, at: now}}
+				}
+				conditions, err = expression.ExplainFleet(members)
+			} else {
+				conditions, err = expression.Explain(&engineExprSource{engine: e, vmID: vm.ID, at: now})
+			}
+			if err != nil {
+				logger.Warn("记录告警条件取值失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+			}
+			snapshot["expression"] = expression.String()
+			snapshot["conditions"] = conditions
+		}
+	}
+
+	events, err := e.recentVMEvents(vms, now)
+	if err != nil {
+		logger.Warn("记录VM最近事件失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+	}
+	snapshot["events"] = events
+}
+
+// snapshotVMs 聚合告警中取值最高的VM
+func snapshotVMs(metricData *AlertMetricData) []models.VM {
+	byID := make(map[uuid.UUID]models.VM, len(metricData.Target.VMs))
+	for _, vm := range metricData.Target.VMs {
+		byID[vm.ID] = vm
+	}
+	vms := []models.VM{}
+	for _, contributor := range metricData.Contributors {
+		if vm, ok := byID[contributor.VMID]; ok {
+			vms = append(vms, vm)
+		}
+		if len(vms) == snapshotSeriesVMLimit {
+			break
+		}
+	}
+	return vms
+}
+
+// snapshotTriggerMetrics 规则引用的指标；没有表达式（如数据中断检测）时取告警条件中的指标
+func snapshotTriggerMetrics(expression *Expression, metricData *AlertMetricData) []string {
+	if expression != nil {
+		return expression.Metrics()
+	}
+	var metrics []string
+	for _, metric := range strings.Split(metricData.Condition.Metric, ",") {
+		if metric != "" && metric != "*" {
+			metrics = append(metrics, metric)
+		}
+	}
+	if len(metrics) == 0 && metricData.Metric != AbsentMetric {
+		metrics = append(metrics, metricData.Metric)
+	}
+	return metrics
+}
+
+// snapshotSeries 一次查询VM在窗口内的触发指标与相关指标
+func (e *AlertEngine) snapshotSeries(vms []models.VM, triggerMetrics []string, start, end time.Time) ([]SnapshotSeries, error) {
+	series := []SnapshotSeries{}
+	if len(vms) == 0 {
+		return series, nil
+	}
+
+	roles := map[string]string{}
+	var metrics []string
+	for _, metric := range triggerMetrics {
+		if _, ok := roles[metric]; !ok {
+			roles[metric] = "trigger"
+			metrics = append(metrics, metric)
+		}
+	}
+	for _, metric := range snapshotRelatedMetrics {
+		if _, ok := roles[metric]; !ok {
+			roles[metric] = "related"
+			metrics = append(metrics, metric)
+		}
+	}
+
+	vmIDs := make([]string, len(vms))
+	for i, vm := range vms {
+		vmIDs[i] = vm.ID.String()
+	}
+	data, err := e.timeSeries.QueryMetrics(vmIDs, metrics, start, end)
+	if err != nil {
+		return series, err
+	}
+
+	points := map[string][]SnapshotPoint{}
+	for _, d := range data {
+		key := d.VMID + "|" + d.Metric
+		points[key] = append(points[key], SnapshotPoint{T: d.Timestamp, V: d.Value})
+	}
+
+	for _, vm := range vms {
+		for _, metric := range metrics {
+			key := vm.ID.String() + "|" + metric
+			if _, ok := points[key]; !ok && roles[metric] == "related" {
+				continue
+			}
+			series = append(series, SnapshotSeries{
+				VMID:   vm.ID.String(),
+				VMName: vm.Name,
+				Metric: metric,
+				Role:   roles[metric],
+				Points: downsamplePoints(points[key], snapshotMaxPoints),
+			})
+		}
+	}
+	return series, nil
+}
+
+// downsamplePoints 按固定步长抽取数据点，保留最后一个点
+func downsamplePoints(points []SnapshotPoint, limit int) []SnapshotPoint {
+	if points == nil {
+		return []SnapshotPoint{}
+	}
+	if len(points) <= limit {
+		return points
+	}
+	step := (len(points) + limit - 1) / limit
+	sampled := make([]SnapshotPoint, 0, limit+1)
+	for i := 0; i < len(points); i += step {
+		sampled = append(sampled, points[i])
+	}
+	if last := points[len(points)-1]; sampled[len(sampled)-1] != last {
+		sampled = append(sampled, last)
+	}
+	return sampled
+}
+
+// recentVMEvents VM最近的告警与人工操作（审计日志），按时间倒序
+func (e *AlertEngine) recentVMEvents(vms []models.VM, now time.Time) ([]SnapshotEvent, error) {
+	events := []SnapshotEvent{}
+	if len(vms) == 0 || e.db == nil {
+		return events, nil
+	}
+
+	names := make(map[string]string, len(vms))
+	vmIDs := make([]string, len(vms))
+	for i, vm := range vms {
+		vmIDs[i] = vm.ID.String()
+		names[vm.ID.String()] = vm.Name
+	}
+	since := now.Add(-snapshotEventLookback)
+
+	var alerts []models.AlertRecord
+	if err := e.db.Select("id, rule_name, vm_id, severity, status, triggered_at").
+		Where("vm_id IN ? AND triggered_at >= ?", vmIDs, since).
+		Order("triggered_at DESC").
+		Limit(snapshotEventLimit).
+		Find(&alerts).Error; err != nil {
+		return events, fmt.Errorf("查询最近告警失败: %w", err)
+	}
+	for _, alert := range alerts {
+		event := SnapshotEvent{Type: "alert", Title: alert.RuleName, Severity: alert.Severity, Status: alert.Status, Timestamp: alert.TriggeredAt}
+		if alert.VMID != nil {
+			event.VMName = names[alert.VMID.String()]
+		}
+		events = append(events, event)
+	}
+
+	var operations []AuditLog
+	if err := e.db.Select("action, resource, resource_id, username, status, created_at").
+		Where("resource_id IN ? AND created_at >= ?", vmIDs, since).
+		Order("created_at DESC").
+		Limit(snapshotEventLimit).
+		Find(&operations).Error; err != nil {
+		return events, fmt.Errorf("查询最近操作失败: %w", err)
+	}
+	for _, op := range operations {
+		events = append(events, SnapshotEvent{
+			Type:      "operation",
+			Title:     strings.TrimSpace(op.Action + " " + op.Resource),
+			Status:    op.Status,
+			VMName:    names[op.ResourceID],
+			User:      op.Username,
+			Timestamp: op.CreatedAt,
+		})
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp.After(events[j].Timestamp) })
+	if len(events) > snapshotEventLimit {
+		events = events[:snapshotEventLimit]
+	}
+	return events, nil
+}
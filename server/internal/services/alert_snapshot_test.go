@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsamplePoints(t *testing.T) {
+	start := time.Now()
+	points := make([]SnapshotPoint, 250)
+	for i := range points {
+		points[i] = SnapshotPoint{T: start.Add(time.Duration(i) * time.Minute), V: float64(i)}
+	}
+
+	sampled := downsamplePoints(points, 100)
+	assert.LessOrEqual(t, len(sampled), 101)
+	assert.Equal(t, 0.0, sampled[0].V)
+	assert.Equal(t, 249.0, sampled[len(sampled)-1].V)
+
+	assert.Len(t, downsamplePoints(points[:10], 100), 10)
+	assert.NotNil(t, downsamplePoints(nil, 100))
+}
+
+func TestAlertEngine_CreateAlertSnapshot(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE metric_records (
+		id TEXT PRIMARY KEY, vm_id TEXT, metric TEXT, value REAL, timestamp DATETIME, tags TEXT, created_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE audit_logs (
+		id TEXT PRIMARY KEY, module TEXT, action TEXT, user_id TEXT, username TEXT, resource TEXT, resource_id TEXT,
+		details TEXT, ip_address TEXT, user_agent TEXT, status TEXT, error_msg TEXT, created_at DATETIME
+	)`).Error)
+	engine := NewAlertEngine(db, nil)
+	engine.SetSnapshotWindow(15 * time.Minute)
+
+	now := time.Now()
+	vm := models.VM{ID: uuid.New(), Name: "web-01"}
+	insertMetricRows(t, db, vm.ID.String(), "cpu_usage", now.Add(-20*time.Minute), 50, 60, 70, 80, 85, 88, 90, 91, 92, 93, 94, 95, 96, 97, 98, 99, 99, 99, 99, 99)
+	insertMetricRows(t, db, vm.ID.String(), "memory_usage", now.Add(-5*time.Minute), 40, 41, 42)
+
+	// VM最近的其他告警与人工操作
+	require.NoError(t, db.Exec("INSERT INTO alert_records (id, rule_name, vm_id, severity, status, triggered_at) VALUES (?, ?, ?, ?, ?, ?)",
+		uuid.New(), "memory high", vm.ID, "medium", "resolved", now.Add(-2*time.Hour)).Error)
+	require.NoError(t, db.Exec("INSERT INTO audit_logs (id, action, resource, resource_id, username, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		uuid.New(), "restart", "vm", vm.ID.String(), "admin", "success", now.Add(-time.Hour)).Error)
+	require.NoError(t, db.Exec("INSERT INTO audit_logs (id, action, resource, resource_id, username, status, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		uuid.New(), "restart", "vm", vm.ID.String(), "admin", "success", now.Add(-48*time.Hour)).Error)
+
+	expr, err := ParseExpression("cpu_usage > 90 and memory_usage < 80")
+	require.NoError(t, err)
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high"}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	engine.rules[rule.ID] = &AlertRuleWithConditions{Rule: rule, Expression: expr}
+
+	metric := &AlertMetricData{VMID: vm.ID, VMName: vm.Name, Metric: "cpu_usage", Value: 99, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+	alert, err := engine.createAlert(rule, vm, metric, nil)
+	require.NoError(t, err)
+	require.NotNil(t, alert)
+
+	var record models.AlertRecord
+	require.NoError(t, db.First(&record, "id = ?", alert.ID).Error)
+	snapshot := record.Snapshot
+	assert.Equal(t, "cpu_usage > 90 and memory_usage < 80", snapshot["expression"])
+	assert.EqualValues(t, 15, snapshot["window"].(map[string]interface{})["minutes"])
+
+	// 触发指标只保留窗口内的点，相关指标没有数据时省略
+	series := snapshot["series"].([]interface{})
+	require.Len(t, series, 2)
+	cpu := series[0].(map[string]interface{})
+	assert.Equal(t, "cpu_usage", cpu["metric"])
+	assert.Equal(t, "trigger", cpu["role"])
+	assert.Len(t, cpu["points"], 14)
+	assert.Equal(t, "memory_usage", series[1].(map[string]interface{})["metric"])
+
+	// 每个比较条件的两侧取值
+	conditions := snapshot["conditions"].([]interface{})
+	require.Len(t, conditions, 2)
+	first := conditions[0].(map[string]interface{})
+	assert.Equal(t, "cpu_usage > 90", first["expression"])
+	assert.EqualValues(t, 99, first["left"])
+	assert.Equal(t, true, first["result"])
+	assert.Equal(t, true, conditions[1].(map[string]interface{})["result"])
+
+	// 24小时内的事件，按时间倒序
+	events := snapshot["events"].([]interface{})
+	require.Len(t, events, 2)
+	assert.Equal(t, "operation", events[0].(map[string]interface{})["type"])
+	assert.Equal(t, "admin", events[0].(map[string]interface{})["user"])
+	assert.Equal(t, "memory high", events[1].(map[string]interface{})["title"])
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"time"
+
+	"vm-monitoring-system/internal/models"
+)
+
+// alertTransition 一次评估引起的状态转换
+type alertTransition int
+
+const (
+	transitionNone     alertTransition = iota
+	transitionPending                  // inactive/resolved → pending
+	transitionFiring                   // inactive/resolved/pending → firing，需要创建告警
+	transitionInactive                 // pending → inactive，未达到for时长即恢复
+	transitionResolved                 // firing → resolved，需要恢复告警
+)
+
+// advanceAlertState 根据本次评估结果推进状态机：
+// 条件满足后先进入pending，持续满足for秒后进入firing；firing状态下条件不再满足时，
+// 若距最近一次满足未超过keep_firing_for秒则保持firing，否则进入resolved。
+func advanceAlertState(state *models.AlertState, rule models.AlertRule, triggered bool, now time.Time) alertTransition {
+	forDuration := time.Duration(rule.ForDuration) * time.Second
+	keepFiringFor := time.Duration(rule.KeepFiringFor) * time.Second
+
+	if triggered {
+		state.LastTrueAt = &now
+	}
+
+	switch state.State {
+	case models.AlertStateFiring:
+		if triggered {
+			return transitionNone
+		}
+		if keepFiringFor > 0 && state.LastTrueAt != nil && now.Sub(*state.LastTrueAt) < keepFiringFor {
+			return transitionNone
+		}
+		state.State = models.AlertStateResolved
+		state.ActiveSince = nil
+		state.FiringSince = nil
+		state.ResolvedAt = &now
+		return transitionResolved
+
+	case models.AlertStatePending:
+		if !triggered {
+			state.State = models.AlertStateInactive
+			state.ActiveSince = nil
+			return transitionInactive
+		}
+		if state.ActiveSince == nil {
+			state.ActiveSince = &now
+		}
+		if now.Sub(*state.ActiveSince) < forDuration {
+			return transitionNone
+		}
+		state.State = models.AlertStateFiring
+		state.FiringSince = &now
+		return transitionFiring
+
+	default: // inactive、resolved
+		if !triggered {
+			return transitionNone
+		}
+		state.ActiveSince = &now
+		if forDuration > 0 {
+			state.State = models.AlertStatePending
+			return transitionPending
+		}
+		state.State = models.AlertStateFiring
+		state.FiringSince = &now
+		return transitionFiring
+	}
+}
+
+// inCooldown 检查规则与VM组合是否仍处于上次触发后的冷却期
+func inCooldown(state *models.AlertState, rule models.AlertRule, now time.Time) bool {
+	if state.LastFiredAt == nil || rule.Cooldown <= 0 {
+		return false
+	}
+	return now.Sub(*state.LastFiredAt) < time.Duration(rule.Cooldown)*time.Second
+}
+
+// recordFlap 记录一次firing/resolved切换，并按规则的抖动阈值更新抖动标记
+func recordFlap(state *models.AlertState, rule models.AlertRule, now time.Time) bool {
+	window := time.Duration(rule.FlapWindow) * time.Second
+	if rule.FlapThreshold <= 0 || window <= 0 {
+		state.Transitions = nil
+		state.Flapping = false
+		return false
+	}
+
+	kept := models.Timestamps{}
+	for _, t := range state.Transitions {
+		if now.Sub(t) < window {
+			kept = append(kept, t)
+		}
+	}
+	state.Transitions = append(kept, now)
+	state.Flapping = len(state.Transitions) > rule.FlapThreshold
+	return state.Flapping
+}
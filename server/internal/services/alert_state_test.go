@@ -0,0 +1,251 @@
+package services
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestAdvanceAlertState_ForAndKeepFiring(t *testing.T) {
+	rule := models.AlertRule{ForDuration: 120, KeepFiringFor: 60}
+	state := &models.AlertState{State: models.AlertStateInactive}
+	start := time.Now()
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+
+	assert.Equal(t, transitionPending, advanceAlertState(state, rule, true, at(0)))
+	assert.Equal(t, transitionNone, advanceAlertState(state, rule, true, at(60)))
+	assert.Equal(t, models.AlertStatePending, state.State)
+
+	// pending期间条件不满足则回到inactive，重新计时
+	assert.Equal(t, transitionInactive, advanceAlertState(state, rule, false, at(90)))
+	assert.Equal(t, transitionPending, advanceAlertState(state, rule, true, at(100)))
+	assert.Equal(t, transitionNone, advanceAlertState(state, rule, true, at(200)))
+	assert.Equal(t, transitionFiring, advanceAlertState(state, rule, true, at(220)))
+	assert.Equal(t, models.AlertStateFiring, state.State)
+
+	// keep_firing_for内保持firing
+	assert.Equal(t, transitionNone, advanceAlertState(state, rule, false, at(250)))
+	assert.Equal(t, transitionNone, advanceAlertState(state, rule, false, at(270)))
+	assert.Equal(t, transitionResolved, advanceAlertState(state, rule, false, at(280)))
+	assert.Equal(t, models.AlertStateResolved, state.State)
+	assert.Nil(t, state.ActiveSince)
+
+	// 未配置for时立即firing
+	state = &models.AlertState{State: models.AlertStateResolved}
+	assert.Equal(t, transitionFiring, advanceAlertState(state, models.AlertRule{}, true, at(0)))
+	assert.Equal(t, transitionResolved, advanceAlertState(state, models.AlertRule{}, false, at(1)))
+}
+
+func TestRecordFlap(t *testing.T) {
+	rule := models.AlertRule{FlapThreshold: 3, FlapWindow: 600}
+	state := &models.AlertState{}
+	start := time.Now()
+
+	for i := 0; i < 3; i++ {
+		assert.False(t, recordFlap(state, rule, start.Add(time.Duration(i)*time.Minute)))
+	}
+	assert.True(t, recordFlap(state, rule, start.Add(3*time.Minute)))
+	assert.True(t, state.Flapping)
+
+	// 旧的切换移出窗口后解除抖动
+	assert.False(t, recordFlap(state, rule, start.Add(12*time.Minute)))
+	assert.Len(t, state.Transitions, 2)
+
+	assert.False(t, recordFlap(state, models.AlertRule{}, start))
+	assert.Empty(t, state.Transitions)
+}
+
+func setupAlertStateTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	require.NoError(t, db.Exec(`CREATE TABLE alert_records (
+		id TEXT PRIMARY KEY, rule_id TEXT, rule_name TEXT, vm_id TEXT, vm_name TEXT, group_id TEXT,
+		cluster_id TEXT, metric TEXT, severity TEXT, trigger_value REAL, threshold REAL,
+		condition_str TEXT, triggered_at DATETIME, resolved_at DATETIME, duration INTEGER,
+		status TEXT, acknowledged_by TEXT, acknowledged_by_name TEXT, acknowledged_at DATETIME,
+		acknowledge_note TEXT, resolved_by TEXT, resolved_by_name TEXT, resolution TEXT,
+		assignee_id TEXT, assignee_name TEXT, assignee_team TEXT, assigned_at DATETIME, incident_id TEXT,
+		snapshot TEXT, notification_status TEXT, suppressed BOOLEAN DEFAULT 0, suppression_reason TEXT,
+		silence_id TEXT, inhibited_by TEXT, escalation_level INTEGER DEFAULT 0, next_escalation_at DATETIME,
+		created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_rules (
+		id TEXT PRIMARY KEY, trigger_count INTEGER DEFAULT 0, last_triggered_at DATETIME, notification_config TEXT,
+		updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_states (
+		id TEXT PRIMARY KEY, rule_id TEXT, vm_id TEXT, state TEXT, active_since DATETIME,
+		firing_since DATETIME, last_true_at DATETIME, resolved_at DATETIME, last_fired_at DATETIME, last_value REAL,
+		alert_record_id TEXT, flapping BOOLEAN, transitions TEXT, created_at DATETIME, updated_at DATETIME,
+		UNIQUE (rule_id, vm_id)
+	)`).Error)
+
+	return db
+}
+
+func TestAlertEngine_ApplyEvaluationPersistsState(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	engine := NewAlertEngine(db, nil)
+
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high", ForDuration: 60}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	vm := models.VM{ID: uuid.New(), Name: "web-01"}
+	metric := &AlertMetricData{VMID: vm.ID, Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+	start := time.Now()
+
+	engine.applyEvaluation(rule, vm, true, metric, start)
+	assert.Equal(t, int64(0), countRows(t, db, "alert_records", "1 = 1"))
+	engine.applyEvaluation(rule, vm, true, metric, start.Add(time.Minute))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "status = ?", "active"))
+
+	// 重启后从数据库恢复firing状态，不会重复触发
+	restarted := NewAlertEngine(db, nil)
+	require.NoError(t, restarted.loadStates())
+	restarted.applyEvaluation(rule, vm, true, metric, start.Add(2*time.Minute))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "1 = 1"))
+
+	restarted.applyEvaluation(rule, vm, false, nil, start.Add(3*time.Minute))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "status = ?", "resolved"))
+
+	var state models.AlertState
+	require.NoError(t, db.Where("rule_id = ? AND vm_id = ?", rule.ID, vm.ID).First(&state).Error)
+	assert.Equal(t, models.AlertStateResolved, state.State)
+	assert.Nil(t, state.AlertRecordID)
+}
+
+func TestAlertEngine_CooldownPerTarget(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	engine := NewAlertEngine(db, nil)
+
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high", Cooldown: 600}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	vmA := models.VM{ID: uuid.New(), Name: "web-01"}
+	vmB := models.VM{ID: uuid.New(), Name: "web-02"}
+	metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+	start := time.Now()
+
+	engine.applyEvaluation(rule, vmA, true, metric, start)
+	engine.applyEvaluation(rule, vmA, false, nil, start.Add(time.Minute))
+
+	// 其他VM不受vmA冷却期影响
+	engine.applyEvaluation(rule, vmB, true, metric, start.Add(2*time.Minute))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "vm_id = ?", vmB.ID))
+
+	// 冷却期持久化，重启后仍然生效
+	restarted := NewAlertEngine(db, nil)
+	require.NoError(t, restarted.loadStates())
+	restarted.applyEvaluation(rule, vmA, true, metric, start.Add(5*time.Minute))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "vm_id = ?", vmA.ID))
+	assert.Equal(t, models.AlertStateResolved, restarted.stateFor(rule.ID, vmA.ID).State)
+
+	restarted.applyEvaluation(rule, vmA, true, metric, start.Add(11*time.Minute))
+	assert.Equal(t, int64(2), countRows(t, db, "alert_records", "vm_id = ?", vmA.ID))
+}
+
+func TestAlertEngine_ApplyEvaluationConcurrent(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // 内存数据库每个连接相互独立
+	engine := NewAlertEngine(db, nil)
+
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high"}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+	shared := models.VM{ID: uuid.New(), Name: "web-01"}
+	now := time.Now()
+
+	// 同一组合的并发评估串行执行，只创建一条告警；不同VM各自触发
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			engine.applyEvaluation(rule, shared, true, metric, now)
+		}()
+		go func(i int) {
+			defer wg.Done()
+			engine.applyEvaluation(rule, models.VM{ID: uuid.New(), Name: fmt.Sprintf("db-%02d", i)}, true, metric, now)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "vm_id = ?", shared.ID))
+	assert.Equal(t, int64(9), countRows(t, db, "alert_records", "1 = 1"))
+	assert.Equal(t, models.AlertStateFiring, engine.stateFor(rule.ID, shared.ID).State)
+}
+
+func TestAlertEngine_OpenAlertNotDuplicated(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	engine := NewAlertEngine(db, nil)
+
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high"}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	vm := models.VM{ID: uuid.New(), Name: "web-01"}
+	metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+
+	// 排查中、已缓解的告警同样视为未结束，不重复创建
+	alert, err := engine.createAlert(rule, vm, metric, nil)
+	require.NoError(t, err)
+	for _, status := range []string{models.AlertStatusInvestigating, models.AlertStatusMitigated} {
+		require.NoError(t, db.Model(&models.AlertRecord{}).Where("id = ?", alert.ID).Update("status", status).Error)
+		duplicate, err := engine.createAlert(rule, vm, metric, nil)
+		require.NoError(t, err)
+		assert.Nil(t, duplicate)
+	}
+	require.NoError(t, db.Model(&models.AlertRecord{}).Where("id = ?", alert.ID).Update("status", models.AlertStatusResolved).Error)
+	assert.False(t, engine.isActiveAlert(rule.ID, vm.ID))
+
+	// 聚合告警按规则与聚合目标匹配
+	target := newAggregateTarget(rule, "cluster", "c1", "prod")
+	aggregate := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: metric.Condition, Target: target}
+	engine.applyEvaluation(rule, target.VM, true, aggregate, time.Now())
+	assert.True(t, engine.isActiveAlert(rule.ID, target.VM.ID))
+	restarted := NewAlertEngine(db, nil)
+	duplicate, err := restarted.createAlert(rule, target.VM, aggregate, nil)
+	require.NoError(t, err)
+	assert.Nil(t, duplicate)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "vm_id IS NULL"))
+}
+
+func TestAlertEngine_PruneStateLocks(t *testing.T) {
+	engine := NewAlertEngine(nil, nil)
+	kept, deleted := uuid.New(), uuid.New()
+	engine.rules = map[uuid.UUID]*AlertRuleWithConditions{kept: {Rule: models.AlertRule{ID: kept}}}
+	vmA, vmB := uuid.New(), uuid.New()
+	countLocks := func() int {
+		n := 0
+		engine.stateLocks.Range(func(_, _ interface{}) bool { n++; return true })
+		return n
+	}
+
+	engine.lockState(kept, vmA)()
+	engine.lockState(deleted, vmA)()
+	unlock := engine.lockState(deleted, vmB)
+
+	// 已删除规则的锁被清理，正在使用的锁保留到下次
+	now := time.Now()
+	engine.pruneStateLocks(now)
+	assert.Equal(t, 2, countLocks())
+	unlock()
+	engine.pruneStateLocks(now)
+	assert.Equal(t, 1, countLocks())
+
+	// 长时间未使用（VM已删除或不再属于规则范围）的锁被清理
+	engine.pruneStateLocks(now.Add(4 * engine.evalInterval))
+	assert.Equal(t, 0, countLocks())
+
+	// 清理后重新加锁得到新的锁
+	engine.lockState(kept, vmA)()
+	assert.Equal(t, 1, countLocks())
+}
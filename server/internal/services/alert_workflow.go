@@ -0,0 +1,527 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 告警处理流程的错误
+var (
+	ErrAlertNotFound        = errors.New("告警记录不存在")
+	ErrInvalidTransition    = errors.New("告警当前状态不允许该操作")
+	ErrAssigneeNotFound     = errors.New("指派的用户不存在")
+	ErrEmptyComment         = errors.New("评论内容不能为空")
+	ErrCommentParentInvalid = errors.New("回复的评论不存在")
+)
+
+// alertTransitions 告警状态允许的人工变更，resolved为终态，ignored只能再标记为resolved
+var alertTransitions = map[string][]string{
+	models.AlertStatusActive:        {models.AlertStatusAcknowledged, models.AlertStatusInvestigating, models.AlertStatusMitigated, models.AlertStatusResolved, models.AlertStatusIgnored},
+	models.AlertStatusAcknowledged:  {models.AlertStatusInvestigating, models.AlertStatusMitigated, models.AlertStatusResolved, models.AlertStatusIgnored},
+	models.AlertStatusInvestigating: {models.AlertStatusMitigated, models.AlertStatusResolved, models.AlertStatusIgnored},
+	models.AlertStatusMitigated:     {models.AlertStatusInvestigating, models.AlertStatusResolved, models.AlertStatusIgnored},
+	models.AlertStatusIgnored:       {models.AlertStatusResolved},
+}
+
+// CanTransition 告警能否从from变更为to
+func CanTransition(from, to string) bool {
+	for _, status := range alertTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// mentionPattern 评论中的@用户名
+var mentionPattern = regexp.MustCompile(`@([A-Za-z0-9_.\-]+)`)
+
+// AlertActor 执行操作的用户，ID为空表示系统
+type AlertActor struct {
+	ID   *uuid.UUID
+	Name string
+}
+
+// 活动流条目的类型
+const (
+	AlertActivityHistory = "history"
+	AlertActivityComment = "comment"
+)
+
+// AlertActivity 告警活动流中的一条：状态变更历史或评论
+type AlertActivity struct {
+	ID         uuid.UUID              `json:"id"`
+	AlertID    uuid.UUID              `json:"alertId"`
+	Kind       string                 `json:"kind"`             // history, comment
+	Action     string                 `json:"action,omitempty"` // 历史的操作类型
+	FromStatus *string                `json:"fromStatus,omitempty"`
+	ToStatus   string                 `json:"toStatus,omitempty"`
+	ActorID    *uuid.UUID             `json:"actorId,omitempty"`
+	ActorName  string                 `json:"actorName,omitempty"`
+	Note       string                 `json:"note,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	ParentID   *uuid.UUID             `json:"parentId,omitempty"` // 评论回复的评论
+	Mentions   []string               `json:"mentions,omitempty"`
+	CreatedAt  time.Time              `json:"createdAt"`
+}
+
+// AlertActivityEvent 推送给实时看板的告警活动，附带告警变更后的概要
+type AlertActivityEvent struct {
+	AlertID      uuid.UUID     `json:"alertId"`
+	RuleName     string        `json:"ruleName"`
+	VMID         *uuid.UUID    `json:"vmId,omitempty"`
+	VMName       *string       `json:"vmName,omitempty"`
+	Severity     string        `json:"severity"`
+	Status       string        `json:"status"`
+	AssigneeName *string       `json:"assigneeName,omitempty"`
+	AssigneeTeam *string       `json:"assigneeTeam,omitempty"`
+	Activity     AlertActivity `json:"activity"`
+}
+
+// AlertCommentThread 评论及其回复
+type AlertCommentThread struct {
+	models.AlertComment
+	Replies []*AlertCommentThread `json:"replies"`
+}
+
+// AlertWorkflowService 告警处理流程：状态变更、指派、评论，每次变更写入历史并推送给订阅者
+type AlertWorkflowService struct {
+	db        *gorm.DB
+	publisher func(AlertActivityEvent)
+	incidents *IncidentService // 告警人工结束后同步事件状态，为空时不同步
+}
+
+// NewAlertWorkflowService 创建告警处理流程服务
+func NewAlertWorkflowService(db *gorm.DB) *AlertWorkflowService {
+	return &AlertWorkflowService{db: db}
+}
+
+// SetPublisher 设置活动推送函数（如WebSocket广播），函数不应阻塞
+func (s *AlertWorkflowService) SetPublisher(publisher func(AlertActivityEvent)) {
+	s.publisher = publisher
+}
+
+// SetIncidents 设置事件服务，告警解决或忽略后事件的全部告警都已结束时自动解决事件
+func (s *AlertWorkflowService) SetIncidents(incidents *IncidentService) {
+	s.incidents = incidents
+}
+
+// Actor 根据用户ID查找操作人姓名，用户ID为空时为系统
+func (s *AlertWorkflowService) Actor(userID *uuid.UUID) AlertActor {
+	actor := AlertActor{ID: userID}
+	if userID != nil {
+		var users []models.User
+		if err := s.db.Select("name").Where("id = ?", *userID).Limit(1).Find(&users).Error; err == nil && len(users) > 0 {
+			actor.Name = users[0].Name
+		}
+	}
+	return actor
+}
+
+// Transition 人工变更告警状态。进入排查或缓解时若尚未确认，同时记录为确认
+func (s *AlertWorkflowService) Transition(alertID uuid.UUID, to string, actor AlertActor, note string) (*models.AlertRecord, error) {
+	var alert models.AlertRecord
+	var history *models.AlertHistory
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", alertID).First(&alert).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAlertNotFound
+			}
+			return fmt.Errorf("查询告警失败: %w", err)
+		}
+		from := alert.Status
+		if !CanTransition(from, to) {
+			return fmt.Errorf("%w: %s → %s", ErrInvalidTransition, from, to)
+		}
+
+		now := time.Now()
+		updates := transitionUpdates(&alert, to, actor, note, now)
+		// 以原状态为条件更新，并发变更时只有一个成功
+		result := tx.Model(&models.AlertRecord{}).Where("id = ? AND status = ?", alertID, from).Updates(updates)
+		if result.Error != nil {
+			return fmt.Errorf("更新告警状态失败: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return fmt.Errorf("%w: 告警状态已被其他操作变更", ErrInvalidTransition)
+		}
+		if err := tx.Where("id = ?", alertID).First(&alert).Error; err != nil {
+			return err
+		}
+
+		var err error
+		history, err = recordAlertHistory(tx, alertID, models.AlertHistoryTransition, &from, to, actor, note, nil, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(&alert, historyActivity(*history))
+	if s.incidents != nil && !isOpenStatus(alert.Status) {
+		s.incidents.SyncAlertClosed(&alert)
+	}
+	return &alert, nil
+}
+
+// TransitionMany 批量变更告警状态，跳过当前状态不允许变更的告警，返回变更的数量
+func (s *AlertWorkflowService) TransitionMany(alertIDs []uuid.UUID, to string, actor AlertActor, note string) (int, error) {
+	changed := 0
+	for _, id := range alertIDs {
+		if _, err := s.Transition(id, to, actor, note); err != nil {
+			if errors.Is(err, ErrInvalidTransition) || errors.Is(err, ErrAlertNotFound) {
+				continue
+			}
+			return changed, err
+		}
+		changed++
+	}
+	return changed, nil
+}
+
+// transitionUpdates 状态变更需要更新的字段
+func transitionUpdates(alert *models.AlertRecord, to string, actor AlertActor, note string, now time.Time) map[string]interface{} {
+	updates := map[string]interface{}{
+		"status":     to,
+		"updated_at": now,
+	}
+	actorName := optionalString(actor.Name)
+
+	switch to {
+	case models.AlertStatusAcknowledged, models.AlertStatusInvestigating, models.AlertStatusMitigated:
+		if alert.AcknowledgedAt == nil {
+			updates["acknowledged_at"] = now
+			updates["acknowledged_by"] = actor.ID
+			updates["acknowledged_by_name"] = actorName
+			if to == models.AlertStatusAcknowledged && note != "" {
+				updates["acknowledge_note"] = note
+			}
+		}
+	case models.AlertStatusResolved:
+		updates["resolved_at"] = now
+		// 已忽略的告警保留忽略时记录的结束时间，不计算持续时间
+		if isOpenStatus(alert.Status) {
+			updates["duration"] = int(now.Sub(alert.TriggeredAt).Minutes())
+		}
+		updates["resolved_by"] = actor.ID
+		updates["resolved_by_name"] = actorName
+		if note != "" {
+			updates["resolution"] = note
+		}
+	case models.AlertStatusIgnored:
+		updates["resolved_at"] = now
+		updates["resolved_by"] = actor.ID
+		updates["resolved_by_name"] = actorName
+	}
+	return updates
+}
+
+// Assign 将未结束的告警指派给用户和/或团队，两者都为空时取消指派
+func (s *AlertWorkflowService) Assign(alertID uuid.UUID, assigneeID *uuid.UUID, team string, actor AlertActor, note string) (*models.AlertRecord, error) {
+	var alert models.AlertRecord
+	var history *models.AlertHistory
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ?", alertID).First(&alert).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrAlertNotFound
+			}
+			return fmt.Errorf("查询告警失败: %w", err)
+		}
+		if !isOpenStatus(alert.Status) {
+			return fmt.Errorf("%w: 告警已%s", ErrInvalidTransition, alert.Status)
+		}
+
+		var assigneeName *string
+		if assigneeID != nil {
+			var users []models.User
+			if err := tx.Select("id, name").Where("id = ?", *assigneeID).Limit(1).Find(&users).Error; err != nil {
+				return fmt.Errorf("查询用户失败: %w", err)
+			}
+			if len(users) == 0 {
+				return ErrAssigneeNotFound
+			}
+			assigneeName = &users[0].Name
+		}
+		team = strings.TrimSpace(team)
+
+		details := map[string]interface{}{
+			"assigneeId":           assigneeID,
+			"assigneeName":         assigneeName,
+			"assigneeTeam":         optionalString(team),
+			"previousAssigneeName": alert.AssigneeName,
+			"previousAssigneeTeam": alert.AssigneeTeam,
+		}
+
+		now := time.Now()
+		var assignedAt *time.Time
+		if assigneeID != nil || team != "" {
+			assignedAt = &now
+		}
+		if err := tx.Model(&models.AlertRecord{}).Where("id = ?", alertID).Updates(map[string]interface{}{
+			"assignee_id":   assigneeID,
+			"assignee_name": assigneeName,
+			"assignee_team": optionalString(team),
+			"assigned_at":   assignedAt,
+			"updated_at":    now,
+		}).Error; err != nil {
+			return fmt.Errorf("指派告警失败: %w", err)
+		}
+		if err := tx.Where("id = ?", alertID).First(&alert).Error; err != nil {
+			return err
+		}
+
+		var err error
+		history, err = recordAlertHistory(tx, alertID, models.AlertHistoryAssign, nil, alert.Status, actor, note, details, now)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.publish(&alert, historyActivity(*history))
+	return &alert, nil
+}
+
+// AddComment 添加评论或回复，解析内容中@到的用户（按用户名匹配）
+func (s *AlertWorkflowService) AddComment(alertID uuid.UUID, parentID *uuid.UUID, content string, actor AlertActor) (*models.AlertComment, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyComment
+	}
+
+	var alert models.AlertRecord
+	if err := s.db.Where("id = ?", alertID).First(&alert).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlertNotFound
+		}
+		return nil, fmt.Errorf("查询告警失败: %w", err)
+	}
+	if parentID != nil {
+		var count int64
+		if err := s.db.Model(&models.AlertComment{}).Where("id = ? AND alert_id = ?", *parentID, alertID).Count(&count).Error; err != nil {
+			return nil, fmt.Errorf("查询评论失败: %w", err)
+		}
+		if count == 0 {
+			return nil, ErrCommentParentInvalid
+		}
+	}
+
+	mentions, err := s.resolveMentions(content)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	comment := models.AlertComment{
+		ID:        uuid.New(),
+		AlertID:   alertID,
+		ParentID:  parentID,
+		UserID:    actor.ID,
+		UserName:  actor.Name,
+		Content:   content,
+		Mentions:  models.StringArray(mentions),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.db.Create(&comment).Error; err != nil {
+		return nil, fmt.Errorf("保存评论失败: %w", err)
+	}
+
+	s.publish(&alert, commentActivity(comment))
+	return &comment, nil
+}
+
+// resolveMentions 返回评论中@到的、存在的用户名（去重，按出现顺序）
+func (s *AlertWorkflowService) resolveMentions(content string) ([]string, error) {
+	var names []string
+	seen := map[string]bool{}
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.TrimRight(match[1], ".-")
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return []string{}, nil
+	}
+
+	var users []models.User
+	if err := s.db.Select("username").Where("username IN ?", names).Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("查询提及的用户失败: %w", err)
+	}
+	exists := make(map[string]bool, len(users))
+	for _, user := range users {
+		exists[user.Username] = true
+	}
+	mentions := []string{}
+	for _, name := range names {
+		if exists[name] {
+			mentions = append(mentions, name)
+		}
+	}
+	return mentions, nil
+}
+
+// Comments 告警的评论，按回复关系组织成树，同级按时间排序
+func (s *AlertWorkflowService) Comments(alertID uuid.UUID) ([]*AlertCommentThread, error) {
+	var comments []models.AlertComment
+	if err := s.db.Where("alert_id = ?", alertID).Order("created_at").Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("查询评论失败: %w", err)
+	}
+
+	nodes := make(map[uuid.UUID]*AlertCommentThread, len(comments))
+	for _, comment := range comments {
+		nodes[comment.ID] = &AlertCommentThread{AlertComment: comment, Replies: []*AlertCommentThread{}}
+	}
+	roots := []*AlertCommentThread{}
+	for _, comment := range comments {
+		node := nodes[comment.ID]
+		if comment.ParentID != nil {
+			if parent, ok := nodes[*comment.ParentID]; ok {
+				parent.Replies = append(parent.Replies, node)
+				continue
+			}
+		}
+		roots = append(roots, node)
+	}
+	return roots, nil
+}
+
+// Activity 告警的活动流：状态变更历史与评论按时间顺序合并
+func (s *AlertWorkflowService) Activity(alertID uuid.UUID) ([]AlertActivity, error) {
+	var count int64
+	if err := s.db.Model(&models.AlertRecord{}).Where("id = ?", alertID).Count(&count).Error; err != nil {
+		return nil, fmt.Errorf("查询告警失败: %w", err)
+	}
+	if count == 0 {
+		return nil, ErrAlertNotFound
+	}
+
+	var histories []models.AlertHistory
+	if err := s.db.Where("alert_id = ?", alertID).Order("created_at").Find(&histories).Error; err != nil {
+		return nil, fmt.Errorf("查询告警历史失败: %w", err)
+	}
+	var comments []models.AlertComment
+	if err := s.db.Where("alert_id = ?", alertID).Order("created_at").Find(&comments).Error; err != nil {
+		return nil, fmt.Errorf("查询评论失败: %w", err)
+	}
+
+	activities := make([]AlertActivity, 0, len(histories)+len(comments))
+	for _, history := range histories {
+		activities = append(activities, historyActivity(history))
+	}
+	for _, comment := range comments {
+		activities = append(activities, commentActivity(comment))
+	}
+	sort.SliceStable(activities, func(i, j int) bool { return activities[i].CreatedAt.Before(activities[j].CreatedAt) })
+	return activities, nil
+}
+
+// RecordSystem 记录系统产生的告警历史（触发、自动恢复）并推送，失败只记录日志
+func (s *AlertWorkflowService) RecordSystem(alert *models.AlertRecord, action string, from *string, note string) {
+	history, err := recordAlertHistory(s.db, alert.ID, action, from, alert.Status, AlertActor{}, note, nil, time.Now())
+	if err != nil {
+		logger.Error("记录告警历史失败", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+		return
+	}
+	s.publish(alert, historyActivity(*history))
+}
+
+// publish 推送告警活动
+func (s *AlertWorkflowService) publish(alert *models.AlertRecord, activity AlertActivity) {
+	if s.publisher == nil {
+		return
+	}
+	s.publisher(AlertActivityEvent{
+		AlertID:      alert.ID,
+		RuleName:     alert.RuleName,
+		VMID:         alert.VMID,
+		VMName:       alert.VMName,
+		Severity:     alert.Severity,
+		Status:       alert.Status,
+		AssigneeName: alert.AssigneeName,
+		AssigneeTeam: alert.AssigneeTeam,
+		Activity:     activity,
+	})
+}
+
+// recordAlertHistory 在tx中写入一条告警历史
+func recordAlertHistory(tx *gorm.DB, alertID uuid.UUID, action string, from *string, to string, actor AlertActor, note string, details map[string]interface{}, now time.Time) (*models.AlertHistory, error) {
+	history := models.AlertHistory{
+		ID:         uuid.New(),
+		AlertID:    alertID,
+		Action:     action,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actor.ID,
+		ActorName:  optionalString(actor.Name),
+		Note:       optionalString(note),
+		Details:    models.JSONMap(details),
+		CreatedAt:  now,
+	}
+	if err := tx.Create(&history).Error; err != nil {
+		return nil, fmt.Errorf("写入告警历史失败: %w", err)
+	}
+	return &history, nil
+}
+
+// historyActivity 历史记录转为活动流条目
+func historyActivity(history models.AlertHistory) AlertActivity {
+	activity := AlertActivity{
+		ID:         history.ID,
+		AlertID:    history.AlertID,
+		Kind:       AlertActivityHistory,
+		Action:     history.Action,
+		FromStatus: history.FromStatus,
+		ToStatus:   history.ToStatus,
+		ActorID:    history.ActorID,
+		Details:    history.Details,
+		CreatedAt:  history.CreatedAt,
+	}
+	if history.ActorName != nil {
+		activity.ActorName = *history.ActorName
+	}
+	if history.Note != nil {
+		activity.Note = *history.Note
+	}
+	return activity
+}
+
+// commentActivity 评论转为活动流条目
+func commentActivity(comment models.AlertComment) AlertActivity {
+	return AlertActivity{
+		ID:        comment.ID,
+		AlertID:   comment.AlertID,
+		Kind:      AlertActivityComment,
+		ActorID:   comment.UserID,
+		ActorName: comment.UserName,
+		Note:      comment.Content,
+		ParentID:  comment.ParentID,
+		Mentions:  comment.Mentions,
+		CreatedAt: comment.CreatedAt,
+	}
+}
+
+// isOpenStatus 告警是否尚未结束
+func isOpenStatus(status string) bool {
+	return containsString(models.AlertOpenStatuses, status)
+}
+
+// optionalString 空字符串返回nil
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
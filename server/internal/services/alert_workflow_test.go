@@ -0,0 +1,162 @@
+package services
+
+import (
+	"testing"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupWorkflowTestDB(t *testing.T) *gorm.DB {
+	db := setupAlertStateTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_histories (
+		id TEXT PRIMARY KEY, alert_id TEXT, action TEXT, from_status TEXT, to_status TEXT,
+		actor_id TEXT, actor_name TEXT, note TEXT, details TEXT, created_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_comments (
+		id TEXT PRIMARY KEY, alert_id TEXT, parent_id TEXT, user_id TEXT, user_name TEXT,
+		content TEXT, mentions TEXT, created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE users (id TEXT PRIMARY KEY, username TEXT, name TEXT)`).Error)
+	return db
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(models.AlertStatusActive, models.AlertStatusInvestigating))
+	assert.True(t, CanTransition(models.AlertStatusMitigated, models.AlertStatusInvestigating))
+	assert.False(t, CanTransition(models.AlertStatusInvestigating, models.AlertStatusAcknowledged))
+	assert.False(t, CanTransition(models.AlertStatusResolved, models.AlertStatusActive))
+	assert.True(t, CanTransition(models.AlertStatusIgnored, models.AlertStatusResolved))
+	assert.False(t, CanTransition(models.AlertStatusIgnored, models.AlertStatusActive))
+}
+
+func TestAlertWorkflow_ResolveIgnored(t *testing.T) {
+	db := setupWorkflowTestDB(t)
+	workflow := NewAlertWorkflowService(db)
+	id := uuid.New()
+	require.NoError(t, db.Exec("INSERT INTO alert_records (id, rule_id, rule_name, metric, severity, status, triggered_at) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)",
+		id, uuid.New(), "r", "cpu_usage", "high", models.AlertStatusIgnored).Error)
+
+	// 已忽略的告警仍可标记为已解决，已解决后不能再变更
+	record, err := workflow.Transition(id, models.AlertStatusResolved, AlertActor{Name: "Alice"}, "fixed")
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusResolved, record.Status)
+	assert.Equal(t, "fixed", *record.Resolution)
+	assert.Nil(t, record.Duration)
+	_, err = workflow.Transition(id, models.AlertStatusIgnored, AlertActor{Name: "Alice"}, "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+}
+
+func TestAlertWorkflow_Lifecycle(t *testing.T) {
+	db := setupWorkflowTestDB(t)
+	aliceID, bobID := uuid.New(), uuid.New()
+	require.NoError(t, db.Exec("INSERT INTO users (id, username, name) VALUES (?, ?, ?), (?, ?, ?)",
+		aliceID, "alice", "Alice", bobID, "bob", "Bob").Error)
+
+	workflow := NewAlertWorkflowService(db)
+	var events []AlertActivityEvent
+	workflow.SetPublisher(func(event AlertActivityEvent) { events = append(events, event) })
+
+	// 告警引擎触发时写入历史
+	engine := NewAlertEngine(db, nil)
+	engine.SetWorkflow(workflow)
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high"}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+	alert, err := engine.createAlert(rule, models.VM{ID: uuid.New(), Name: "web-01"}, metric, nil)
+	require.NoError(t, err)
+
+	alice := workflow.Actor(&aliceID)
+	assert.Equal(t, "Alice", alice.Name)
+
+	// 进入排查时记录为确认
+	record, err := workflow.Transition(alert.ID, models.AlertStatusInvestigating, alice, "looking")
+	require.NoError(t, err)
+	assert.Equal(t, models.AlertStatusInvestigating, record.Status)
+	require.NotNil(t, record.AcknowledgedAt)
+	assert.Equal(t, "Alice", *record.AcknowledgedByName)
+
+	_, err = workflow.Transition(alert.ID, models.AlertStatusAcknowledged, alice, "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+	_, err = workflow.Transition(uuid.New(), models.AlertStatusResolved, alice, "")
+	assert.ErrorIs(t, err, ErrAlertNotFound)
+
+	// 指派
+	record, err = workflow.Assign(alert.ID, &bobID, "dba", alice, "")
+	require.NoError(t, err)
+	assert.Equal(t, "Bob", *record.AssigneeName)
+	assert.Equal(t, "dba", *record.AssigneeTeam)
+	missing := uuid.New()
+	_, err = workflow.Assign(alert.ID, &missing, "", alice, "")
+	assert.ErrorIs(t, err, ErrAssigneeNotFound)
+
+	// 评论与回复，只记录存在的用户
+	comment, err := workflow.AddComment(alert.ID, nil, "@bob please check disk, cc @nobody", alice)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"bob"}, []string(comment.Mentions))
+	_, err = workflow.AddComment(alert.ID, &comment.ID, "on it", workflow.Actor(&bobID))
+	require.NoError(t, err)
+	_, err = workflow.AddComment(alert.ID, nil, "  ", alice)
+	assert.ErrorIs(t, err, ErrEmptyComment)
+	unknown := uuid.New()
+	_, err = workflow.AddComment(alert.ID, &unknown, "reply", alice)
+	assert.ErrorIs(t, err, ErrCommentParentInvalid)
+
+	threads, err := workflow.Comments(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, threads, 1)
+	require.Len(t, threads[0].Replies, 1)
+	assert.Equal(t, "Bob", threads[0].Replies[0].UserName)
+
+	record, err = workflow.Transition(alert.ID, models.AlertStatusMitigated, workflow.Actor(&bobID), "")
+	require.NoError(t, err)
+	assert.Equal(t, "Alice", *record.AcknowledgedByName)
+
+	// 自动恢复后为终态，不能再指派
+	engine.resolveAlert(&models.AlertState{RuleID: rule.ID, AlertRecordID: &alert.ID}, record.UpdatedAt)
+	_, err = workflow.Assign(alert.ID, nil, "", alice, "")
+	assert.ErrorIs(t, err, ErrInvalidTransition)
+
+	activities, err := workflow.Activity(alert.ID)
+	require.NoError(t, err)
+	var kinds []string
+	for _, activity := range activities {
+		kinds = append(kinds, activity.Kind+":"+activity.Action+activity.ToStatus)
+	}
+	assert.Equal(t, []string{
+		"history:createdactive",
+		"history:transitioninvestigating",
+		"history:assigninvestigating",
+		"comment:",
+		"comment:",
+		"history:transitionmitigated",
+		"history:auto_resolvedresolved",
+	}, kinds)
+	require.NotNil(t, activities[len(activities)-1].FromStatus)
+	assert.Equal(t, models.AlertStatusMitigated, *activities[len(activities)-1].FromStatus)
+
+	// 每次变更都推送
+	require.Len(t, events, len(activities))
+	assert.Equal(t, models.AlertStatusResolved, events[len(events)-1].Status)
+	assert.Equal(t, []string{"bob"}, events[3].Activity.Mentions)
+}
+
+func TestAlertWorkflow_TransitionMany(t *testing.T) {
+	db := setupWorkflowTestDB(t)
+	workflow := NewAlertWorkflowService(db)
+
+	ids := []uuid.UUID{uuid.New(), uuid.New(), uuid.New()}
+	for i, status := range []string{models.AlertStatusActive, models.AlertStatusActive, models.AlertStatusResolved} {
+		require.NoError(t, db.Exec("INSERT INTO alert_records (id, rule_id, rule_name, metric, severity, status, triggered_at) VALUES (?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)",
+			ids[i], uuid.New(), "r", "cpu_usage", "high", status).Error)
+	}
+
+	updated, err := workflow.TransitionMany(append(ids, uuid.New()), models.AlertStatusAcknowledged, AlertActor{}, "batch")
+	require.NoError(t, err)
+	assert.Equal(t, 2, updated)
+	assert.Equal(t, int64(2), countRows(t, db, "alert_histories", "action = ?", models.AlertHistoryTransition))
+}
@@ -0,0 +1,499 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+)
+
+// 异常检测算法
+const (
+	AnomalyAlgoZScore   = "zscore"   // 滚动窗口Z-score
+	AnomalyAlgoMAD      = "mad"      // 滚动窗口中位数/MAD（修正Z-score）
+	AnomalyAlgoEWMA     = "ewma"     // 指数加权移动平均
+	AnomalyAlgoSeasonal = "seasonal" // 按星期几+小时的季节性基线
+)
+
+// madScale 修正Z-score系数，使MAD与正态分布标准差可比
+const madScale = 0.6745
+
+// AnomalyOptions 异常检测参数
+type AnomalyOptions struct {
+	Algorithm string  `json:"algorithm"`
+	Window    int     `json:"window"`    // 滚动窗口点数（zscore/mad），ewma为预热点数
+	Threshold float64 `json:"threshold"` // 得分绝对值达到该值视为异常
+	Alpha     float64 `json:"alpha"`     // ewma平滑系数 (0,1]
+	MinCount  int64   `json:"minCount"`  // seasonal桶内最少样本数
+}
+
+// AnomalyPoint 异常检测结果点
+type AnomalyPoint struct {
+	VMID      string    `json:"vmId"`
+	Metric    string    `json:"metric"`
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+	Expected  float64   `json:"expected"`
+	Lower     float64   `json:"lower"`
+	Upper     float64   `json:"upper"`
+	Score     float64   `json:"score"`
+	Algorithm string    `json:"algorithm"`
+
+	spread float64 // 离散度（标准差/MAD等），用于换算上下界
+}
+
+// IsValidAnomalyAlgorithm 检查算法名称是否有效
+func IsValidAnomalyAlgorithm(algorithm string) bool {
+	switch algorithm {
+	case AnomalyAlgoZScore, AnomalyAlgoMAD, AnomalyAlgoEWMA, AnomalyAlgoSeasonal:
+		return true
+	}
+	return false
+}
+
+// withDefaults 填充默认参数
+func (o AnomalyOptions) withDefaults() AnomalyOptions {
+	if o.Algorithm == "" {
+		o.Algorithm = AnomalyAlgoZScore
+	}
+	if o.Window <= 1 {
+		o.Window = 30
+	}
+	if o.Threshold <= 0 {
+		if o.Algorithm == AnomalyAlgoMAD {
+			o.Threshold = 3.5
+		} else {
+			o.Threshold = 3
+		}
+	}
+	if o.Alpha <= 0 || o.Alpha > 1 {
+		o.Alpha = 0.3
+	}
+	if o.MinCount <= 0 {
+		o.MinCount = 3
+	}
+	return o
+}
+
+// AnomalyService 异常检测服务
+type AnomalyService struct {
+	db            *gorm.DB
+	timeSeries    *TimeSeriesService
+	trainInterval time.Duration
+	trainWeeks    int
+	stopChan      chan struct{}
+	isRunning     bool
+	runningMutex  sync.Mutex
+}
+
+// NewAnomalyService 创建异常检测服务
+func NewAnomalyService(db *gorm.DB, timeSeries *TimeSeriesService) *AnomalyService {
+	return &AnomalyService{
+		db:            db,
+		timeSeries:    timeSeries,
+		trainInterval: 6 * time.Hour, // 默认每6小时重新学习基线
+		trainWeeks:    4,             // 默认使用最近4周数据
+		stopChan:      make(chan struct{}),
+	}
+}
+
+// SetTrainInterval 设置基线学习间隔
+func (s *AnomalyService) SetTrainInterval(interval time.Duration) {
+	s.trainInterval = interval
+}
+
+// SetTrainWeeks 设置基线学习使用的周数
+func (s *AnomalyService) SetTrainWeeks(weeks int) {
+	if weeks > 0 {
+		s.trainWeeks = weeks
+	}
+}
+
+// Start 启动基线定期学习
+func (s *AnomalyService) Start() error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("基线学习已经在运行")
+	}
+
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	go s.trainingLoop()
+
+	logger.Info("异常检测基线学习已启动", zap.Duration("学习间隔", s.trainInterval))
+	return nil
+}
+
+// Stop 停止基线定期学习
+func (s *AnomalyService) Stop() {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	close(s.stopChan)
+	s.isRunning = false
+	logger.Info("异常检测基线学习已停止")
+}
+
+// trainingLoop 基线学习循环
+func (s *AnomalyService) trainingLoop() {
+	ticker := time.NewTicker(s.trainInterval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.TrainBaselines("", ""); err != nil {
+			logger.Error("学习异常检测基线失败", zap.Error(err))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// TrainBaselines 学习并保存季节性基线，vmID/metric为空表示全部
+func (s *AnomalyService) TrainBaselines(vmID, metric string) (int, error) {
+	windowEnd := time.Now()
+	windowStart := windowEnd.AddDate(0, 0, -7*s.trainWeeks)
+
+	var rows []struct {
+		VMID   string
+		Metric string
+		Bucket int
+		Mean   float64
+		StdDev float64
+		Count  int64
+	}
+
+	// 分桶按UTC计算，与models.HourOfWeek一致，不受数据库会话时区影响
+	query := s.db.Model(&MetricRecord{}).
+		Select("vm_id, metric, "+
+			"CAST(EXTRACT(DOW FROM timestamp AT TIME ZONE 'UTC') * 24 + EXTRACT(HOUR FROM timestamp AT TIME ZONE 'UTC') AS INTEGER) AS bucket, "+
+			"AVG(value) AS mean, COALESCE(STDDEV_POP(value), 0) AS std_dev, COUNT(*) AS count").
+		Where("timestamp BETWEEN ? AND ?", windowStart, windowEnd)
+
+	if vmID != "" {
+		query = query.Where("vm_id = ?", vmID)
+	}
+	if metric != "" {
+		query = query.Where("metric = ?", metric)
+	}
+
+	if err := query.Group("vm_id, metric, bucket").Scan(&rows).Error; err != nil {
+		return 0, fmt.Errorf("统计季节性基线失败: %w", err)
+	}
+
+	// 按序列组织分桶结果
+	type seriesKey struct{ vmID, metric string }
+	baselines := make(map[seriesKey]*models.AnomalyBaseline)
+	for _, r := range rows {
+		if r.Bucket < 0 || r.Bucket >= models.HoursPerWeek {
+			continue
+		}
+		key := seriesKey{r.VMID, r.Metric}
+		baseline, ok := baselines[key]
+		if !ok {
+			baseline = &models.AnomalyBaseline{
+				ID:          uuid.New(),
+				VMID:        r.VMID,
+				Metric:      r.Metric,
+				Profile:     models.NewSeasonalProfile(),
+				WindowStart: windowStart,
+				WindowEnd:   windowEnd,
+				TrainedAt:   windowEnd,
+			}
+			baselines[key] = baseline
+		}
+		baseline.Profile.Mean[r.Bucket] = r.Mean
+		baseline.Profile.StdDev[r.Bucket] = r.StdDev
+		baseline.Profile.Count[r.Bucket] = r.Count
+		baseline.SampleCount += r.Count
+	}
+
+	saved := 0
+	for _, baseline := range baselines {
+		if err := s.db.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "vm_id"}, {Name: "metric"}},
+			DoUpdates: clause.AssignmentColumns([]string{"profile", "sample_count", "window_start", "window_end", "trained_at", "updated_at"}),
+		}).Create(baseline).Error; err != nil {
+			logger.Error("保存异常检测基线失败",
+				zap.String("vm_id", baseline.VMID), zap.String("metric", baseline.Metric), zap.Error(err))
+			continue
+		}
+		saved++
+	}
+
+	logger.Info("异常检测基线已更新", zap.Int("count", saved))
+	return saved, nil
+}
+
+// GetBaseline 获取序列的季节性基线
+func (s *AnomalyService) GetBaseline(vmID, metric string) (*models.AnomalyBaseline, error) {
+	var baseline models.AnomalyBaseline
+	if err := s.db.Where("vm_id = ? AND metric = ?", vmID, metric).First(&baseline).Error; err != nil {
+		return nil, err
+	}
+	return &baseline, nil
+}
+
+// Detect 检测指定时间范围内的异常点
+func (s *AnomalyService) Detect(vmIDs []string, metrics []string, startTime, endTime time.Time, opts AnomalyOptions) ([]AnomalyPoint, error) {
+	opts = opts.withDefaults()
+	if !IsValidAnomalyAlgorithm(opts.Algorithm) {
+		return nil, fmt.Errorf("未知的异常检测算法: %s", opts.Algorithm)
+	}
+
+	data, err := s.timeSeries.QueryMetrics(vmIDs, metrics, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+
+	anomalies := []AnomalyPoint{}
+	for _, series := range groupSeries(data) {
+		// 滚动类算法需要起始时间之前的窗口数据作为参考
+		if opts.Algorithm != AnomalyAlgoSeasonal {
+			preceding, err := s.timeSeries.QueryLastN(series[0].VMID, series[0].Metric, startTime, opts.Window)
+			if err != nil {
+				return nil, err
+			}
+			series = append(preceding, series...)
+		}
+
+		scored, err := s.scoreSeries(series, opts)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range scored {
+			if p.Timestamp.Before(startTime) {
+				continue
+			}
+			if math.Abs(p.Score) >= opts.Threshold {
+				anomalies = append(anomalies, p)
+			}
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Timestamp.Before(anomalies[j].Timestamp)
+	})
+	return anomalies, nil
+}
+
+// LatestScore 计算序列最新数据点的异常得分（供告警条件使用）
+// 最新数据点早于maxAge时视为无数据
+func (s *AnomalyService) LatestScore(vmID, metric string, maxAge time.Duration, opts AnomalyOptions) (*AnomalyPoint, error) {
+	opts = opts.withDefaults()
+	if !IsValidAnomalyAlgorithm(opts.Algorithm) {
+		return nil, fmt.Errorf("未知的异常检测算法: %s", opts.Algorithm)
+	}
+
+	now := time.Now()
+	data, err := s.timeSeries.QueryLastN(vmID, metric, now.Add(time.Second), opts.Window+1)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 || (maxAge > 0 && now.Sub(data[len(data)-1].Timestamp) > maxAge) {
+		return nil, fmt.Errorf("指标 %s 无最新数据: %w", metric, ErrNoData)
+	}
+
+	scored, err := s.scoreSeries(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(scored) == 0 || !scored[len(scored)-1].Timestamp.Equal(data[len(data)-1].Timestamp) {
+		return nil, fmt.Errorf("指标 %s 的最新数据点无法计算异常得分", metric)
+	}
+
+	latest := scored[len(scored)-1]
+	return &latest, nil
+}
+
+// scoreSeries 对单个序列（已按时间升序）计算异常得分
+func (s *AnomalyService) scoreSeries(series []MetricData, opts AnomalyOptions) ([]AnomalyPoint, error) {
+	var scored []AnomalyPoint
+
+	switch opts.Algorithm {
+	case AnomalyAlgoZScore:
+		scored = scoreRollingZScore(series, opts.Window)
+	case AnomalyAlgoMAD:
+		scored = scoreRollingMAD(series, opts.Window)
+	case AnomalyAlgoEWMA:
+		scored = scoreEWMA(series, opts.Alpha, opts.Window)
+	case AnomalyAlgoSeasonal:
+		if len(series) == 0 {
+			return nil, nil
+		}
+		baseline, err := s.GetBaseline(series[0].VMID, series[0].Metric)
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				// 尚未学习出基线，不产生异常
+				return nil, nil
+			}
+			return nil, fmt.Errorf("查询季节性基线失败: %w", err)
+		}
+		scored = scoreSeasonal(series, baseline.Profile, opts.MinCount)
+	}
+
+	for i := range scored {
+		scored[i].Algorithm = opts.Algorithm
+		scored[i].Lower = scored[i].Expected - opts.Threshold*scored[i].spread
+		scored[i].Upper = scored[i].Expected + opts.Threshold*scored[i].spread
+	}
+	return scored, nil
+}
+
+// groupSeries 按VM和指标对数据分组，保持时间顺序
+func groupSeries(data []MetricData) [][]MetricData {
+	index := make(map[string]int)
+	var series [][]MetricData
+	for _, m := range data {
+		key := m.VMID + "\x00" + m.Metric
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, nil)
+		}
+		series[i] = append(series[i], m)
+	}
+	return series
+}
+
+// newScoredPoint 构造得分点
+func newScoredPoint(m MetricData, expected, spread float64) AnomalyPoint {
+	return AnomalyPoint{
+		VMID:      m.VMID,
+		Metric:    m.Metric,
+		Timestamp: m.Timestamp,
+		Value:     m.Value,
+		Expected:  expected,
+		Score:     (m.Value - expected) / spread,
+		spread:    spread,
+	}
+}
+
+// scoreRollingZScore 用前window个点的均值和标准差评估当前点
+// 标准差为0（窗口内数据恒定）时无法得出有意义的得分，跳过该点
+func scoreRollingZScore(series []MetricData, window int) []AnomalyPoint {
+	var result []AnomalyPoint
+	var sum, sumSq float64
+
+	for i, m := range series {
+		if i >= window {
+			n := float64(window)
+			mean := sum / n
+			variance := sumSq/n - mean*mean
+			if variance > 0 {
+				if std := math.Sqrt(variance); std > 1e-9 {
+					result = append(result, newScoredPoint(m, mean, std))
+				}
+			}
+
+			old := series[i-window].Value
+			sum -= old
+			sumSq -= old * old
+		}
+		sum += m.Value
+		sumSq += m.Value * m.Value
+	}
+	return result
+}
+
+// scoreRollingMAD 用前window个点的中位数和MAD评估当前点（修正Z-score）
+// MAD为0时跳过该点
+func scoreRollingMAD(series []MetricData, window int) []AnomalyPoint {
+	var result []AnomalyPoint
+	buf := make([]float64, window)
+	dev := make([]float64, window)
+
+	for i := window; i < len(series); i++ {
+		for j := 0; j < window; j++ {
+			buf[j] = series[i-window+j].Value
+		}
+		median := medianOf(buf)
+		for j, v := range buf {
+			dev[j] = math.Abs(v - median)
+		}
+		mad := medianOf(dev)
+		if mad <= 1e-9 {
+			continue
+		}
+		result = append(result, newScoredPoint(series[i], median, mad/madScale))
+	}
+	return result
+}
+
+// scoreEWMA 用指数加权均值和方差评估当前点，前warmup个点只用于预热
+func scoreEWMA(series []MetricData, alpha float64, warmup int) []AnomalyPoint {
+	var result []AnomalyPoint
+	if len(series) == 0 {
+		return result
+	}
+
+	mean := series[0].Value
+	variance := 0.0
+	for i := 1; i < len(series); i++ {
+		m := series[i]
+		if i >= warmup && variance > 0 {
+			if std := math.Sqrt(variance); std > 1e-9 {
+				result = append(result, newScoredPoint(m, mean, std))
+			}
+		}
+
+		diff := m.Value - mean
+		increment := alpha * diff
+		mean += increment
+		variance = (1 - alpha) * (variance + diff*increment)
+	}
+	return result
+}
+
+// scoreSeasonal 用同一"星期几+小时"桶的历史均值和标准差评估当前点
+// 样本不足或标准差为0的桶跳过
+func scoreSeasonal(series []MetricData, profile models.SeasonalProfile, minCount int64) []AnomalyPoint {
+	var result []AnomalyPoint
+	if len(profile.Mean) < models.HoursPerWeek || len(profile.StdDev) < models.HoursPerWeek || len(profile.Count) < models.HoursPerWeek {
+		return result
+	}
+
+	for _, m := range series {
+		bucket := models.HourOfWeek(m.Timestamp)
+		if profile.Count[bucket] < minCount || profile.StdDev[bucket] <= 1e-9 {
+			continue
+		}
+		result = append(result, newScoredPoint(m, profile.Mean[bucket], profile.StdDev[bucket]))
+	}
+	return result
+}
+
+// medianOf 计算中位数（不修改入参）
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+
+	n := len(sorted)
+	if n == 0 {
+		return 0
+	}
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"vm-monitoring-system/internal/models"
+)
+
+func buildSeries(values []float64, start time.Time, step time.Duration) []MetricData {
+	series := make([]MetricData, len(values))
+	for i, v := range values {
+		series[i] = MetricData{
+			VMID:      "vm-001",
+			Metric:    "cpu_usage",
+			Value:     v,
+			Timestamp: start.Add(time.Duration(i) * step),
+		}
+	}
+	return series
+}
+
+func noisyValues(n int, base float64) []float64 {
+	values := make([]float64, n)
+	for i := range values {
+		values[i] = base + float64(i%5) - 2
+	}
+	return values
+}
+
+func TestScoreRollingZScore(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ConstantWindowIsSkipped", func(t *testing.T) {
+		values := []float64{50, 50, 50, 50, 50, 90}
+		scored := scoreRollingZScore(buildSeries(values, start, time.Minute), 5)
+		assert.Empty(t, scored)
+	})
+
+	t.Run("SpikeIsDetected", func(t *testing.T) {
+		values := append(noisyValues(30, 50), 95)
+		scored := scoreRollingZScore(buildSeries(values, start, time.Minute), 20)
+		assert.NotEmpty(t, scored)
+
+		last := scored[len(scored)-1]
+		assert.Equal(t, 95.0, last.Value)
+		assert.Greater(t, last.Score, 3.0)
+		for _, p := range scored[:len(scored)-1] {
+			assert.Less(t, math.Abs(p.Score), 3.0)
+			assert.False(t, math.IsInf(p.Score, 0) || math.IsNaN(p.Score))
+		}
+	})
+}
+
+func TestScoreRollingMAD(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	t.Run("ZeroMADIsSkipped", func(t *testing.T) {
+		values := []float64{10, 10, 10, 10, 10, 10, 40}
+		scored := scoreRollingMAD(buildSeries(values, start, time.Minute), 5)
+		assert.Empty(t, scored)
+	})
+
+	t.Run("RobustToEarlierOutlier", func(t *testing.T) {
+		values := noisyValues(20, 50)
+		values[10] = 500
+		values = append(values, 90)
+		scored := scoreRollingMAD(buildSeries(values, start, time.Minute), 15)
+
+		last := scored[len(scored)-1]
+		assert.Equal(t, 90.0, last.Value)
+		assert.InDelta(t, 50.0, last.Expected, 1)
+		assert.Greater(t, last.Score, 3.5)
+	})
+}
+
+func TestScoreEWMA(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	values := append(noisyValues(40, 20), 60)
+	scored := scoreEWMA(buildSeries(values, start, time.Minute), 0.3, 10)
+	assert.NotEmpty(t, scored)
+
+	last := scored[len(scored)-1]
+	assert.Equal(t, 60.0, last.Value)
+	assert.Greater(t, last.Score, 3.0)
+	assert.True(t, scored[0].Timestamp.After(start.Add(9*time.Minute)))
+}
+
+func TestScoreSeasonal(t *testing.T) {
+	// 2026-01-05 是周一
+	monday9 := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	bucket := models.HourOfWeek(monday9)
+	assert.Equal(t, 1*24+9, bucket)
+
+	profile := models.NewSeasonalProfile()
+	profile.Mean[bucket] = 80
+	profile.StdDev[bucket] = 5
+	profile.Count[bucket] = 10
+
+	series := buildSeries([]float64{82, 30}, monday9, time.Minute)
+	// 下一个小时的桶没有样本，应被跳过
+	series = append(series, MetricData{VMID: "vm-001", Metric: "cpu_usage", Value: 1, Timestamp: monday9.Add(time.Hour)})
+
+	scored := scoreSeasonal(series, profile, 3)
+	assert.Len(t, scored, 2)
+	assert.InDelta(t, 0.4, scored[0].Score, 1e-9)
+	assert.InDelta(t, -10.0, scored[1].Score, 1e-9)
+}
+
+func TestGroupSeries(t *testing.T) {
+	now := time.Now()
+	data := []MetricData{
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 1, Timestamp: now},
+		{VMID: "vm-2", Metric: "cpu_usage", Value: 2, Timestamp: now},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 3, Timestamp: now.Add(time.Minute)},
+		{VMID: "vm-1", Metric: "memory_usage", Value: 4, Timestamp: now},
+	}
+
+	series := groupSeries(data)
+	assert.Len(t, series, 3)
+	assert.Len(t, series[0], 2)
+	assert.Equal(t, 3.0, series[0][1].Value)
+}
+
+func TestAnomalyOptionsDefaults(t *testing.T) {
+	opts := AnomalyOptions{Algorithm: AnomalyAlgoMAD}.withDefaults()
+	assert.Equal(t, 30, opts.Window)
+	assert.Equal(t, 3.5, opts.Threshold)
+
+	opts = AnomalyOptions{}.withDefaults()
+	assert.Equal(t, AnomalyAlgoZScore, opts.Algorithm)
+	assert.Equal(t, 3.0, opts.Threshold)
+	assert.True(t, IsValidAnomalyAlgorithm(AnomalyAlgoSeasonal))
+	assert.False(t, IsValidAnomalyAlgorithm("prophet"))
+}
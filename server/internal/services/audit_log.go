@@ -72,12 +72,20 @@ const (
 
 // AuditLogService 审计日志服务
 type AuditLogService struct {
-	db *gorm.DB
+	db            *gorm.DB
+	retentionDays int
 }
 
 // NewAuditLogService 创建审计日志服务
 func NewAuditLogService(db *gorm.DB) *AuditLogService {
-	return &AuditLogService{db: db}
+	return &AuditLogService{db: db, retentionDays: 30}
+}
+
+// SetRetentionDays 设置自动清理的保留天数
+func (s *AuditLogService) SetRetentionDays(days int) {
+	if days > 0 {
+		s.retentionDays = days
+	}
 }
 
 // Create 创建审计日志
@@ -233,10 +241,15 @@ func (s *AuditLogService) Cleanup(before time.Time) (int64, error) {
 	return result.RowsAffected, result.Error
 }
 
-// AutoCleanup 自动清理（保留30天）
+// CleanupInBatches 分批清理旧日志，返回清理行数以及是否因达到批次上限而仍有剩余
+func (s *AuditLogService) CleanupInBatches(before time.Time, batchSize, maxBatches int) (int64, bool, error) {
+	return deleteInBatches(s.db, AuditLog{}.TableName(), sqlCond{sql: "created_at < ?", args: []interface{}{before}}, batchSize, maxBatches)
+}
+
+// AutoCleanup 自动清理（按保留天数，默认30天；定期清理由 RetentionService 按策略执行）
 func (s *AuditLogService) AutoCleanup() error {
-	cutoff := time.Now().AddDate(0, 0, -30)
-	count, err := s.Cleanup(cutoff)
+	cutoff := time.Now().AddDate(0, 0, -s.retentionDays)
+	count, _, err := s.CleanupInBatches(cutoff, 5000, 1000)
 	if err != nil {
 		return err
 	}
@@ -0,0 +1,320 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// 回测限制
+const (
+	maxBacktestRange = 31 * 24 * time.Hour // 最长回测时间范围
+	maxBacktestSteps = 50000               // 单个VM最多评估次数
+)
+
+// ErrInvalidBacktest 回测参数无效（时间范围、评估间隔等）
+var ErrInvalidBacktest = errors.New("回测参数无效")
+
+// BacktestOptions 回测参数，Step为评估间隔（为空时使用告警引擎的评估间隔）
+type BacktestOptions struct {
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// BacktestFiring 回测中一次会触发的告警
+type BacktestFiring struct {
+	StartsAt time.Time  `json:"startsAt"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"` // 为空表示回测结束时仍在触发
+	Duration int        `json:"duration"`         // 持续秒数（仍在触发时计算到回测结束）
+	Metric   string     `json:"metric"`
+	Value    float64    `json:"value"`
+	Flapping bool       `json:"flapping"` // 触发时处于抖动状态，通知会被抑制
+}
+
+// BacktestVMResult 单个VM的回测结果
+type BacktestVMResult struct {
+	VMID          uuid.UUID        `json:"vmId"`
+	VMName        string           `json:"vmName"`
+	Count         int              `json:"count"`
+	TotalDuration int              `json:"totalDuration"` // 触发总秒数
+	NoData        int              `json:"noData"`        // 缺少数据无法评估的次数
+	Firings       []BacktestFiring `json:"firings"`
+}
+
+// BacktestResult 规则回测结果
+type BacktestResult struct {
+	Expression  string             `json:"expression"`
+	Start       time.Time          `json:"start"`
+	End         time.Time          `json:"end"`
+	Step        int                `json:"step"` // 评估间隔（秒）
+	Evaluations int                `json:"evaluations"`
+	TotalAlerts int                `json:"totalAlerts"`
+	AffectedVMs int                `json:"affectedVms"`
+	VMs         []BacktestVMResult `json:"vms"`
+}
+
+// BacktestService 规则回测：在历史数据上按评估间隔重放告警引擎的评估与状态机，不写入告警记录
+type BacktestService struct {
+	db      *gorm.DB
+	engine  *AlertEngine
+	anomaly *AnomalyService
+}
+
+// NewBacktestService 创建规则回测服务，evalInterval为告警引擎的评估间隔，作为默认回测步长
+func NewBacktestService(db *gorm.DB, evalInterval time.Duration) *BacktestService {
+	engine := NewAlertEngine(db, nil)
+	if evalInterval > 0 {
+		engine.SetEvalInterval(evalInterval)
+	}
+	return &BacktestService{
+		db:      db,
+		engine:  engine,
+		anomaly: NewAnomalyService(db, engine.timeSeries),
+	}
+}
+
+// Run 对规则（可以是未保存的规则）执行回测。规则没有表达式时翻译条件列表。
+// 静默与抑制规则不参与回测，冷却期、for、keep_firing_for、无数据处理与抖动检测与告警引擎一致。
+func (s *BacktestService) Run(rule models.AlertRule, conditions []models.AlertCondition, opts BacktestOptions) (*BacktestResult, error) {
+	if rule.EvaluationMode == models.EvaluationModeAggregate {
+		return nil, fmt.Errorf("%w: 聚合评估模式的规则暂不支持回测", ErrInvalidBacktest)
+	}
+	if isDeadmanRule(rule) {
+		return nil, fmt.Errorf("%w: 内置数据中断规则不支持回测", ErrInvalidBacktest)
+	}
+
+	var expression *Expression
+	var err error
+	if rule.Expression != nil && *rule.Expression != "" {
+		expression, err = ParseExpression(*rule.Expression)
+	} else {
+		expression, err = ExpressionFromConditions(conditions, rule.ConditionLogic)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Step <= 0 {
+		opts.Step = s.engine.evalInterval
+	}
+	if !opts.End.After(opts.Start) {
+		return nil, fmt.Errorf("%w: 结束时间必须晚于开始时间", ErrInvalidBacktest)
+	}
+	if opts.End.Sub(opts.Start) > maxBacktestRange {
+		return nil, fmt.Errorf("%w: 回测时间范围不能超过%d天", ErrInvalidBacktest, int(maxBacktestRange.Hours()/24))
+	}
+	steps := int(opts.End.Sub(opts.Start)/opts.Step) + 1
+	if steps > maxBacktestSteps {
+		return nil, fmt.Errorf("%w: 评估次数过多（%d），请缩短时间范围或增大评估间隔", ErrInvalidBacktest, steps)
+	}
+
+	vms, err := s.engine.getTargetVMs(rule.Scope, rule.ScopeID)
+	if err != nil {
+		return nil, fmt.Errorf("获取目标VM失败: %w", err)
+	}
+
+	result := &BacktestResult{
+		Expression: expression.String(),
+		Start:      opts.Start,
+		End:        opts.End,
+		Step:       int(opts.Step / time.Second),
+		VMs:        []BacktestVMResult{},
+	}
+
+	for _, vm := range vms {
+		source, err := s.loadSeries(vm.ID.String(), expression, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		vmResult, err := replayRule(rule, expression, source, opts)
+		if err != nil {
+			return nil, fmt.Errorf("回测VM %s 失败: %w", vm.Name, err)
+		}
+		vmResult.VMID = vm.ID
+		vmResult.VMName = vm.Name
+
+		result.Evaluations += steps
+		result.TotalAlerts += vmResult.Count
+		if vmResult.Count > 0 {
+			result.AffectedVMs++
+		}
+		result.VMs = append(result.VMs, *vmResult)
+	}
+
+	// 触发次数多的VM排在前面
+	sort.SliceStable(result.VMs, func(i, j int) bool {
+		return result.VMs[i].Count > result.VMs[j].Count
+	})
+	return result, nil
+}
+
+// loadSeries 一次性加载VM在回测范围（包括最大窗口的回看）内引用的指标数据，并预先计算异常得分
+func (s *BacktestService) loadSeries(vmID string, expression *Expression, opts BacktestOptions) (*seriesExprSource, error) {
+	lookback := defaultConditionWindow
+	var metrics []string
+	seen := map[string]bool{}
+	for _, ref := range expression.metricRefs() {
+		if ref.window > lookback {
+			lookback = ref.window
+		}
+		if !seen[ref.metric] {
+			seen[ref.metric] = true
+			metrics = append(metrics, ref.metric)
+		}
+	}
+
+	data, err := s.engine.timeSeries.QueryMetrics([]string{vmID}, metrics, opts.Start.Add(-lookback), opts.End)
+	if err != nil {
+		return nil, err
+	}
+
+	source := &seriesExprSource{series: map[string][]MetricData{}, scores: map[string][]AnomalyPoint{}}
+	for _, point := range data {
+		source.series[point.Metric] = append(source.series[point.Metric], point)
+	}
+
+	for _, ref := range expression.metricRefs() {
+		if ref.aggregation != "anomaly" {
+			continue
+		}
+		key := ref.metric + "|" + ref.algorithm
+		if _, ok := source.scores[key]; ok {
+			continue
+		}
+
+		// 滚动类算法需要回看窗口之前的数据作为参考
+		series := source.series[ref.metric]
+		anomalyOpts := AnomalyOptions{Algorithm: ref.algorithm}.withDefaults()
+		if ref.algorithm != AnomalyAlgoSeasonal {
+			preceding, err := s.engine.timeSeries.QueryLastN(vmID, ref.metric, opts.Start.Add(-lookback), anomalyOpts.Window)
+			if err != nil {
+				return nil, err
+			}
+			series = append(preceding, series...)
+		}
+
+		scores := []AnomalyPoint{}
+		if len(series) > 0 {
+			if scores, err = s.anomaly.scoreSeries(series, anomalyOpts); err != nil {
+				return nil, err
+			}
+		}
+		source.scores[key] = scores
+	}
+	return source, nil
+}
+
+// replayRule 在数据来源上按评估间隔重放规则的状态机
+func replayRule(rule models.AlertRule, expression *Expression, source *seriesExprSource, opts BacktestOptions) (*BacktestVMResult, error) {
+	result := &BacktestVMResult{Firings: []BacktestFiring{}}
+	state := &models.AlertState{State: models.AlertStateInactive}
+	ruleWithCond := &AlertRuleWithConditions{Rule: rule, Expression: expression}
+	open := -1 // 当前未结束的触发
+
+	for at := opts.Start; !at.After(opts.End); at = at.Add(opts.Step) {
+		source.at = at
+		triggered, match, err := expression.Evaluate(source)
+		if errors.Is(err, ErrNoData) {
+			// 与告警引擎一样按规则的noDataState处理，默认保持当前状态
+			result.NoData++
+			noData, metricData, apply := noDataResult(ruleWithCond, models.VM{})
+			if !apply {
+				continue
+			}
+			triggered, match, err = noData, nil, nil
+			if metricData != nil {
+				match = &ExprMatch{Metric: metricData.Metric, Value: metricData.Value, Timestamp: at, Condition: metricData.Condition}
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		previous := *state
+		switch advanceAlertState(state, rule, triggered, at) {
+		case transitionFiring:
+			if inCooldown(state, rule, at) {
+				*state = previous
+				continue
+			}
+			fired := at
+			state.LastFiredAt = &fired
+			result.Firings = append(result.Firings, BacktestFiring{
+				StartsAt: at,
+				Metric:   match.Metric,
+				Value:    match.Value,
+				Flapping: recordFlap(state, rule, at),
+			})
+			open = len(result.Firings) - 1
+
+		case transitionResolved:
+			recordFlap(state, rule, at)
+			if open >= 0 {
+				ended := at
+				result.Firings[open].EndsAt = &ended
+				open = -1
+			}
+		}
+	}
+
+	for i := range result.Firings {
+		firing := &result.Firings[i]
+		end := opts.End
+		if firing.EndsAt != nil {
+			end = *firing.EndsAt
+		}
+		firing.Duration = int(end.Sub(firing.StartsAt) / time.Second)
+		result.TotalDuration += firing.Duration
+	}
+	result.Count = len(result.Firings)
+	return result, nil
+}
+
+// seriesExprSource 基于预加载序列的表达式数据来源，at为当前评估时间
+type seriesExprSource struct {
+	series map[string][]MetricData   // 指标 → 按时间升序的数据点
+	scores map[string][]AnomalyPoint // 指标|算法 → 按时间升序的异常得分
+	at     time.Time
+}
+
+// Aggregate 计算[at-window, at]内的聚合值
+func (s *seriesExprSource) Aggregate(metric, aggregation string, window time.Duration) (*MetricData, error) {
+	points := s.series[metric]
+	from := sort.Search(len(points), func(i int) bool { return !points[i].Timestamp.Before(s.at.Add(-window)) })
+	to := sort.Search(len(points), func(i int) bool { return points[i].Timestamp.After(s.at) })
+	if from >= to {
+		return nil, ErrNoData
+	}
+	if aggregation == "" {
+		aggregation = "last"
+	}
+	return aggregateWindow(points[from:to], aggregation), nil
+}
+
+// Anomaly 返回at时刻最新数据点的异常得分绝对值，与告警引擎一样要求数据点不早于max(window, 5分钟)
+func (s *seriesExprSource) Anomaly(metric, algorithm string, window time.Duration) (*MetricData, error) {
+	scores := s.scores[metric+"|"+algorithm]
+	i := sort.Search(len(scores), func(i int) bool { return scores[i].Timestamp.After(s.at) }) - 1
+	if i < 0 {
+		return nil, ErrNoData
+	}
+
+	maxAge := window
+	if maxAge < defaultConditionWindow {
+		maxAge = defaultConditionWindow
+	}
+	point := scores[i]
+	if s.at.Sub(point.Timestamp) > maxAge {
+		return nil, ErrNoData
+	}
+	return &MetricData{VMID: point.VMID, Metric: metric, Value: math.Abs(point.Score), Timestamp: point.Timestamp}, nil
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBacktestService_Run(t *testing.T) {
+	db := setupRetentionTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE vms (id TEXT PRIMARY KEY, name TEXT, status TEXT, is_deleted BOOLEAN DEFAULT 0)`).Error)
+	vmID := uuid.New()
+	require.NoError(t, db.Exec("INSERT INTO vms (id, name, status) VALUES (?, ?, ?)", vmID, "web-01", "running").Error)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Minute)
+	insertMetricRows(t, db, vmID.String(), "cpu_usage", start, 50, 95, 96, 50, 50, 97, 98, 99, 50)
+
+	expression := "cpu_usage > 90"
+	rule := models.AlertRule{Scope: "vm", ScopeID: &vmID, Expression: &expression, Cooldown: 300}
+	service := NewBacktestService(db, time.Minute)
+	result, err := service.Run(rule, nil, BacktestOptions{Start: start, End: start.Add(8 * time.Minute), Step: time.Minute})
+	require.NoError(t, err)
+
+	assert.Equal(t, "cpu_usage > 90", result.Expression)
+	assert.Equal(t, 9, result.Evaluations)
+	assert.Equal(t, 2, result.TotalAlerts)
+	require.Len(t, result.VMs, 1)
+
+	// 第二次满足条件（start+5m）时仍在冷却期内，start+6m才触发
+	vm := result.VMs[0]
+	assert.Equal(t, "web-01", vm.VMName)
+	require.Len(t, vm.Firings, 2)
+	assert.True(t, vm.Firings[0].StartsAt.Equal(start.Add(time.Minute)))
+	assert.Equal(t, 120, vm.Firings[0].Duration)
+	assert.Equal(t, 95.0, vm.Firings[0].Value)
+	assert.True(t, vm.Firings[1].StartsAt.Equal(start.Add(6*time.Minute)))
+	require.NotNil(t, vm.Firings[1].EndsAt)
+	assert.Equal(t, 240, vm.TotalDuration)
+
+	// 回测不写入告警记录
+	assert.False(t, db.Migrator().HasTable("alert_records"))
+
+	_, err = service.Run(rule, nil, BacktestOptions{Start: start, End: start.Add(-time.Minute)})
+	assert.Error(t, err)
+}
+
+func TestReplayRule_ForAndNoData(t *testing.T) {
+	start := time.Now().Truncate(time.Minute)
+	var points []MetricData
+	for i, v := range []float64{95, 95, 95, 20} {
+		points = append(points, MetricData{Metric: "cpu_usage", Value: v, Timestamp: start.Add(time.Duration(i) * time.Minute)})
+	}
+	source := &seriesExprSource{series: map[string][]MetricData{"cpu_usage": points}}
+
+	expression, err := ParseExpression("last(cpu_usage, 30s) > 90")
+	require.NoError(t, err)
+	rule := models.AlertRule{ForDuration: 120}
+
+	// 前两分钟处于pending，start+2m触发；数据结束后缺少数据不改变状态
+	result, err := replayRule(rule, expression, source, BacktestOptions{Start: start, End: start.Add(6 * time.Minute), Step: time.Minute})
+	require.NoError(t, err)
+	require.Len(t, result.Firings, 1)
+	assert.True(t, result.Firings[0].StartsAt.Equal(start.Add(2*time.Minute)))
+	assert.Equal(t, 60, result.Firings[0].Duration)
+	assert.Equal(t, 3, result.NoData)
+}
+
+func TestReplayRule_NoDataState(t *testing.T) {
+	start := time.Now().Truncate(time.Minute)
+	// start+2m、start+3m、start+5m缺少数据
+	source := &seriesExprSource{series: map[string][]MetricData{"cpu_usage": {
+		{Metric: "cpu_usage", Value: 20, Timestamp: start},
+		{Metric: "cpu_usage", Value: 20, Timestamp: start.Add(time.Minute)},
+		{Metric: "cpu_usage", Value: 95, Timestamp: start.Add(4 * time.Minute)},
+	}}}
+	expression, err := ParseExpression("last(cpu_usage, 30s) > 90")
+	require.NoError(t, err)
+	opts := BacktestOptions{Start: start, End: start.Add(5 * time.Minute), Step: time.Minute}
+
+	tests := []struct {
+		noDataState string
+		startsAt    time.Duration
+		metric      string
+		resolved    bool
+		duration    int
+	}{
+		// 保持当前状态：只有start+4m的数据触发
+		{models.NoDataStateKeepLast, 4 * time.Minute, "cpu_usage", false, 60},
+		// 缺少数据视为触发：start+2m以无数据告警触发并持续到结束
+		{models.NoDataStateAlerting, 2 * time.Minute, AbsentMetric, false, 180},
+		// 缺少数据视为恢复：start+4m触发，start+5m恢复
+		{models.NoDataStateOK, 4 * time.Minute, "cpu_usage", true, 60},
+	}
+	for _, tt := range tests {
+		t.Run(tt.noDataState, func(t *testing.T) {
+			result, err := replayRule(models.AlertRule{NoDataState: tt.noDataState}, expression, source, opts)
+			require.NoError(t, err)
+			require.Len(t, result.Firings, 1)
+			firing := result.Firings[0]
+			assert.True(t, firing.StartsAt.Equal(start.Add(tt.startsAt)))
+			assert.Equal(t, tt.metric, firing.Metric)
+			assert.Equal(t, tt.resolved, firing.EndsAt != nil)
+			assert.Equal(t, tt.duration, firing.Duration)
+			assert.Equal(t, 3, result.NoData)
+		})
+	}
+}
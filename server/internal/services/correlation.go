@@ -0,0 +1,453 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 相关性计算方法
+const (
+	CorrelationPearson  = "pearson"
+	CorrelationSpearman = "spearman"
+)
+
+// ErrInvalidCorrelation 相关性分析参数无效（步长、时间范围、候选序列数量等）
+var ErrInvalidCorrelation = errors.New("相关性分析参数无效")
+
+// 相关性分析的资源限制，避免单个请求加载过多数据
+const (
+	minCorrelationStep       = 10 * time.Second
+	maxCorrelationBuckets    = 10080 // 对齐后的时间桶数量上限（1分钟步长为7天）
+	maxCorrelationCandidates = 50    // 候选VM数量上限
+	maxCorrelationMetrics    = 20    // 候选指标数量上限
+)
+
+// SeriesKey 序列标识（VM + 指标）
+type SeriesKey struct {
+	VMID   string `json:"vmId"`
+	Metric string `json:"metric"`
+}
+
+// CorrelationOptions 相关性分析参数
+type CorrelationOptions struct {
+	Method    string        // pearson / spearman
+	Step      time.Duration // 对齐时间桶大小
+	MaxLag    int           // 最大滞后桶数（正负方向均会尝试）
+	MinPoints int           // 计算相关系数所需的最少对齐点数
+	Limit     int           // 返回结果数量上限，0 表示不限制
+}
+
+// withDefaults 填充默认参数
+func (o CorrelationOptions) withDefaults() CorrelationOptions {
+	if o.Method == "" {
+		o.Method = CorrelationPearson
+	}
+	if o.Step <= 0 {
+		o.Step = time.Minute
+	}
+	if o.MaxLag < 0 {
+		o.MaxLag = 0
+	}
+	if o.MinPoints < 3 {
+		o.MinPoints = 3
+	}
+	return o
+}
+
+// CorrelationResult 单条候选序列的相关性结果
+type CorrelationResult struct {
+	SeriesKey
+	Coefficient float64       `json:"coefficient"`
+	Lag         int           `json:"lag"`         // 滞后桶数，正数表示候选序列滞后于参考序列
+	LagDuration time.Duration `json:"lagDuration"` // 滞后时长
+	Points      int           `json:"points"`      // 参与计算的对齐点数
+}
+
+// ComparePoint 周期对比数据点
+type ComparePoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Value         float64   `json:"value"`
+	PreviousValue *float64  `json:"previousValue"`
+	Delta         *float64  `json:"delta"`
+}
+
+// CompareStats 周期对比统计
+type CompareStats struct {
+	CurrentAvg    float64  `json:"currentAvg"`
+	PreviousAvg   float64  `json:"previousAvg"`
+	CurrentMax    float64  `json:"currentMax"`
+	PreviousMax   float64  `json:"previousMax"`
+	CurrentMin    float64  `json:"currentMin"`
+	PreviousMin   float64  `json:"previousMin"`
+	AvgDelta      float64  `json:"avgDelta"`
+	AvgDeltaPct   *float64 `json:"avgDeltaPercent"` // 上一周期均值为0时为空
+	MeanAbsDelta  float64  `json:"meanAbsDelta"`
+	MaxDelta      float64  `json:"maxDelta"`
+	MinDelta      float64  `json:"minDelta"`
+	AlignedPoints int      `json:"alignedPoints"`
+}
+
+// CompareResult 周期对比结果
+type CompareResult struct {
+	SeriesKey
+	Shift  time.Duration  `json:"shift"`
+	Step   time.Duration  `json:"step"`
+	Points []ComparePoint `json:"points"`
+	Stats  CompareStats   `json:"stats"`
+}
+
+// CorrelationService 指标相关性与周期对比服务
+type CorrelationService struct {
+	db         *gorm.DB
+	timeSeries *TimeSeriesService
+}
+
+// NewCorrelationService 创建相关性分析服务
+func NewCorrelationService(db *gorm.DB, timeSeries *TimeSeriesService) *CorrelationService {
+	if timeSeries == nil {
+		timeSeries = NewTimeSeriesService(db)
+	}
+	return &CorrelationService{
+		db:         db,
+		timeSeries: timeSeries,
+	}
+}
+
+// IsValidCorrelationMethod 校验相关性计算方法
+func IsValidCorrelationMethod(method string) bool {
+	return method == CorrelationPearson || method == CorrelationSpearman
+}
+
+// Correlate 以参考序列为基准，对候选VM的同名或指定指标按相关系数绝对值降序排名。
+// 候选VM必须显式指定，步长、时间桶数量与候选数量超出限制时返回ErrInvalidCorrelation
+func (s *CorrelationService) Correlate(ref SeriesKey, vmIDs []string, metrics []string, startTime, endTime time.Time, opts CorrelationOptions) ([]CorrelationResult, error) {
+	opts = opts.withDefaults()
+	if !IsValidCorrelationMethod(opts.Method) {
+		return nil, fmt.Errorf("未知的相关性计算方法: %s", opts.Method)
+	}
+	if len(metrics) == 0 {
+		metrics = []string{ref.Metric}
+	}
+	if err := validateCorrelation(vmIDs, metrics, startTime, endTime, opts.Step); err != nil {
+		return nil, err
+	}
+
+	refData, err := s.timeSeries.QueryMetrics([]string{ref.VMID}, []string{ref.Metric}, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	refBuckets := bucketize(refData, startTime, opts.Step)
+	if len(refBuckets) < opts.MinPoints {
+		return []CorrelationResult{}, nil
+	}
+
+	// 候选序列的查询范围需要向两侧扩展最大滞后时长
+	margin := time.Duration(opts.MaxLag) * opts.Step
+	candData, err := s.timeSeries.QueryMetrics(vmIDs, metrics, startTime.Add(-margin), endTime.Add(margin))
+	if err != nil {
+		return nil, err
+	}
+
+	results := []CorrelationResult{}
+	for _, series := range groupSeries(candData) {
+		key := SeriesKey{VMID: series[0].VMID, Metric: series[0].Metric}
+		if key == ref {
+			continue
+		}
+
+		candBuckets := bucketize(series, startTime, opts.Step)
+		coef, lag, n, ok := bestLagCorrelation(refBuckets, candBuckets, opts.MaxLag, opts.MinPoints, opts.Method)
+		if !ok {
+			continue
+		}
+
+		results = append(results, CorrelationResult{
+			SeriesKey:   key,
+			Coefficient: coef,
+			Lag:         lag,
+			LagDuration: time.Duration(lag) * opts.Step,
+			Points:      n,
+		})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		return math.Abs(results[i].Coefficient) > math.Abs(results[j].Coefficient)
+	})
+
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+
+	return results, nil
+}
+
+// validateCorrelation 校验相关性分析的资源限制
+func validateCorrelation(vmIDs, metrics []string, startTime, endTime time.Time, step time.Duration) error {
+	if step < minCorrelationStep {
+		return fmt.Errorf("%w: 对齐步长不能小于%s", ErrInvalidCorrelation, minCorrelationStep)
+	}
+	if endTime.Sub(startTime)/step > maxCorrelationBuckets {
+		return fmt.Errorf("%w: 时间范围内的对齐点数超过%d，请缩小时间范围或增大步长", ErrInvalidCorrelation, maxCorrelationBuckets)
+	}
+	if len(vmIDs) == 0 {
+		return fmt.Errorf("%w: 请指定候选VM", ErrInvalidCorrelation)
+	}
+	if len(vmIDs) > maxCorrelationCandidates {
+		return fmt.Errorf("%w: 候选VM数量不能超过%d", ErrInvalidCorrelation, maxCorrelationCandidates)
+	}
+	if len(metrics) > maxCorrelationMetrics {
+		return fmt.Errorf("%w: 候选指标数量不能超过%d", ErrInvalidCorrelation, maxCorrelationMetrics)
+	}
+	return nil
+}
+
+// Compare 将序列与自身平移shift后的历史周期对齐叠加，并计算差值统计
+func (s *CorrelationService) Compare(key SeriesKey, startTime, endTime time.Time, shift, step time.Duration) (*CompareResult, error) {
+	if shift <= 0 {
+		return nil, fmt.Errorf("对比周期必须大于0")
+	}
+	if step <= 0 {
+		step = time.Minute
+	}
+
+	current, err := s.timeSeries.QueryMetrics([]string{key.VMID}, []string{key.Metric}, startTime, endTime)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.timeSeries.QueryMetrics([]string{key.VMID}, []string{key.Metric}, startTime.Add(-shift), endTime.Add(-shift))
+	if err != nil {
+		return nil, err
+	}
+
+	points, stats := compareBuckets(
+		bucketize(current, startTime, step),
+		bucketize(previous, startTime.Add(-shift), step),
+		startTime, step,
+	)
+
+	return &CompareResult{
+		SeriesKey: key,
+		Shift:     shift,
+		Step:      step,
+		Points:    points,
+		Stats:     stats,
+	}, nil
+}
+
+// bucketize 将序列按时间桶取平均，返回 桶序号 -> 均值
+func bucketize(data []MetricData, origin time.Time, step time.Duration) map[int64]float64 {
+	sums := make(map[int64]float64)
+	counts := make(map[int64]int)
+	for _, d := range data {
+		offset := d.Timestamp.Sub(origin)
+		idx := int64(offset / step)
+		if offset < 0 && offset%step != 0 {
+			idx--
+		}
+		sums[idx] += d.Value
+		counts[idx]++
+	}
+
+	buckets := make(map[int64]float64, len(sums))
+	for idx, sum := range sums {
+		buckets[idx] = sum / float64(counts[idx])
+	}
+	return buckets
+}
+
+// bestLagCorrelation 在 [-maxLag, maxLag] 范围内寻找相关系数绝对值最大的滞后
+func bestLagCorrelation(ref, cand map[int64]float64, maxLag, minPoints int, method string) (float64, int, int, bool) {
+	refIdx := sortedBucketKeys(ref)
+
+	found := false
+	bestCoef, bestLag, bestN := 0.0, 0, 0
+	for lag := -maxLag; lag <= maxLag; lag++ {
+		xs := make([]float64, 0, len(refIdx))
+		ys := make([]float64, 0, len(refIdx))
+		for _, idx := range refIdx {
+			if v, ok := cand[idx+int64(lag)]; ok {
+				xs = append(xs, ref[idx])
+				ys = append(ys, v)
+			}
+		}
+		if len(xs) < minPoints {
+			continue
+		}
+
+		var coef float64
+		var ok bool
+		if method == CorrelationSpearman {
+			coef, ok = spearman(xs, ys)
+		} else {
+			coef, ok = pearson(xs, ys)
+		}
+		if !ok {
+			continue
+		}
+
+		// 绝对值相同时优先选择滞后更小的结果
+		if !found || math.Abs(coef) > math.Abs(bestCoef) ||
+			(math.Abs(coef) == math.Abs(bestCoef) && absInt(lag) < absInt(bestLag)) {
+			bestCoef, bestLag, bestN = coef, lag, len(xs)
+			found = true
+		}
+	}
+
+	return bestCoef, bestLag, bestN, found
+}
+
+// compareBuckets 对齐当前周期与历史周期的时间桶并计算差值统计
+func compareBuckets(current, previous map[int64]float64, origin time.Time, step time.Duration) ([]ComparePoint, CompareStats) {
+	points := make([]ComparePoint, 0, len(current))
+	stats := CompareStats{}
+
+	var curSum, prevSum, absDeltaSum float64
+	curCount, prevCount := 0, 0
+
+	for _, idx := range sortedBucketKeys(current) {
+		value := current[idx]
+		point := ComparePoint{
+			Timestamp: origin.Add(time.Duration(idx) * step),
+			Value:     value,
+		}
+
+		if curCount == 0 || value > stats.CurrentMax {
+			stats.CurrentMax = value
+		}
+		if curCount == 0 || value < stats.CurrentMin {
+			stats.CurrentMin = value
+		}
+		curSum += value
+		curCount++
+
+		if prev, ok := previous[idx]; ok {
+			delta := value - prev
+			prevValue := prev
+			point.PreviousValue = &prevValue
+			point.Delta = &delta
+
+			if stats.AlignedPoints == 0 || delta > stats.MaxDelta {
+				stats.MaxDelta = delta
+			}
+			if stats.AlignedPoints == 0 || delta < stats.MinDelta {
+				stats.MinDelta = delta
+			}
+			absDeltaSum += math.Abs(delta)
+			stats.AlignedPoints++
+		}
+
+		points = append(points, point)
+	}
+
+	for _, idx := range sortedBucketKeys(previous) {
+		value := previous[idx]
+		if prevCount == 0 || value > stats.PreviousMax {
+			stats.PreviousMax = value
+		}
+		if prevCount == 0 || value < stats.PreviousMin {
+			stats.PreviousMin = value
+		}
+		prevSum += value
+		prevCount++
+	}
+
+	if curCount > 0 {
+		stats.CurrentAvg = curSum / float64(curCount)
+	}
+	if prevCount > 0 {
+		stats.PreviousAvg = prevSum / float64(prevCount)
+	}
+	stats.AvgDelta = stats.CurrentAvg - stats.PreviousAvg
+	if prevCount > 0 && stats.PreviousAvg != 0 {
+		pct := stats.AvgDelta / math.Abs(stats.PreviousAvg) * 100
+		stats.AvgDeltaPct = &pct
+	}
+	if stats.AlignedPoints > 0 {
+		stats.MeanAbsDelta = absDeltaSum / float64(stats.AlignedPoints)
+	}
+
+	return points, stats
+}
+
+// pearson 计算皮尔逊相关系数，任一序列方差为0时返回 false
+func pearson(xs, ys []float64) (float64, bool) {
+	n := len(xs)
+	if n == 0 || n != len(ys) {
+		return 0, false
+	}
+
+	var meanX, meanY float64
+	for i := 0; i < n; i++ {
+		meanX += xs[i]
+		meanY += ys[i]
+	}
+	meanX /= float64(n)
+	meanY /= float64(n)
+
+	var cov, varX, varY float64
+	for i := 0; i < n; i++ {
+		dx := xs[i] - meanX
+		dy := ys[i] - meanY
+		cov += dx * dy
+		varX += dx * dx
+		varY += dy * dy
+	}
+	if varX == 0 || varY == 0 {
+		return 0, false
+	}
+
+	return cov / math.Sqrt(varX*varY), true
+}
+
+// spearman 计算斯皮尔曼等级相关系数（并列值取平均秩）
+func spearman(xs, ys []float64) (float64, bool) {
+	return pearson(ranks(xs), ranks(ys))
+}
+
+// ranks 计算平均秩
+func ranks(values []float64) []float64 {
+	idx := make([]int, len(values))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		return values[idx[a]] < values[idx[b]]
+	})
+
+	result := make([]float64, len(values))
+	for i := 0; i < len(idx); {
+		j := i
+		for j+1 < len(idx) && values[idx[j+1]] == values[idx[i]] {
+			j++
+		}
+		rank := float64(i+j)/2 + 1
+		for k := i; k <= j; k++ {
+			result[idx[k]] = rank
+		}
+		i = j + 1
+	}
+	return result
+}
+
+// sortedBucketKeys 返回升序排列的桶序号
+func sortedBucketKeys(buckets map[int64]float64) []int64 {
+	keys := make([]int64, 0, len(buckets))
+	for k := range buckets {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// absInt 整数绝对值
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
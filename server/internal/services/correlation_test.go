@@ -0,0 +1,120 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPearsonAndSpearman(t *testing.T) {
+	xs := []float64{1, 2, 3, 4, 5}
+
+	coef, ok := pearson(xs, []float64{2, 4, 6, 8, 10})
+	assert.True(t, ok)
+	assert.InDelta(t, 1.0, coef, 1e-9)
+
+	coef, ok = pearson(xs, []float64{5, 4, 3, 2, 1})
+	assert.True(t, ok)
+	assert.InDelta(t, -1.0, coef, 1e-9)
+
+	// 单调但非线性：斯皮尔曼为1，皮尔逊小于1
+	ys := []float64{1, 8, 27, 64, 1000}
+	p, _ := pearson(xs, ys)
+	s, ok := spearman(xs, ys)
+	assert.True(t, ok)
+	assert.InDelta(t, 1.0, s, 1e-9)
+	assert.Less(t, p, 0.99)
+
+	_, ok = pearson(xs, []float64{3, 3, 3, 3, 3})
+	assert.False(t, ok)
+}
+
+func TestRanksWithTies(t *testing.T) {
+	assert.Equal(t, []float64{1, 2.5, 2.5, 4}, ranks([]float64{10, 20, 20, 30}))
+}
+
+func TestBucketize(t *testing.T) {
+	origin := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	data := []MetricData{
+		{Value: 1, Timestamp: origin.Add(10 * time.Second)},
+		{Value: 3, Timestamp: origin.Add(50 * time.Second)},
+		{Value: 7, Timestamp: origin.Add(70 * time.Second)},
+		{Value: 9, Timestamp: origin.Add(-30 * time.Second)},
+	}
+
+	buckets := bucketize(data, origin, time.Minute)
+	assert.Equal(t, map[int64]float64{-1: 9, 0: 2, 1: 7}, buckets)
+}
+
+func TestBestLagCorrelation(t *testing.T) {
+	signal := []float64{1, 5, 2, 8, 3, 9, 4, 7, 2, 6, 1, 5}
+	ref := make(map[int64]float64)
+	cand := make(map[int64]float64)
+	for i, v := range signal {
+		ref[int64(i)] = v
+		// 候选序列比参考序列滞后2个桶
+		cand[int64(i+2)] = v
+	}
+
+	coef, lag, n, ok := bestLagCorrelation(ref, cand, 3, 3, CorrelationPearson)
+	assert.True(t, ok)
+	assert.Equal(t, 2, lag)
+	assert.Equal(t, len(signal), n)
+	assert.InDelta(t, 1.0, coef, 1e-9)
+
+	_, _, _, ok = bestLagCorrelation(ref, cand, 0, len(signal), CorrelationPearson)
+	assert.False(t, ok)
+}
+
+func TestCompareBuckets(t *testing.T) {
+	origin := time.Date(2026, 1, 12, 0, 0, 0, 0, time.UTC)
+	current := map[int64]float64{0: 10, 1: 20, 2: 30}
+	previous := map[int64]float64{0: 5, 1: 25}
+
+	points, stats := compareBuckets(current, previous, origin, time.Hour)
+	assert.Len(t, points, 3)
+	assert.Equal(t, origin.Add(2*time.Hour), points[2].Timestamp)
+	assert.Nil(t, points[2].Delta)
+	assert.Equal(t, 5.0, *points[0].Delta)
+	assert.Equal(t, -5.0, *points[1].Delta)
+
+	assert.Equal(t, 2, stats.AlignedPoints)
+	assert.Equal(t, 20.0, stats.CurrentAvg)
+	assert.Equal(t, 15.0, stats.PreviousAvg)
+	assert.Equal(t, 5.0, stats.AvgDelta)
+	assert.InDelta(t, 33.333, *stats.AvgDeltaPct, 1e-3)
+	assert.Equal(t, 5.0, stats.MeanAbsDelta)
+	assert.Equal(t, 5.0, stats.MaxDelta)
+	assert.Equal(t, -5.0, stats.MinDelta)
+	assert.Equal(t, 30.0, stats.CurrentMax)
+	assert.Equal(t, 5.0, stats.PreviousMin)
+}
+
+func TestCorrelate_Limits(t *testing.T) {
+	service := NewCorrelationService(nil, nil)
+	ref := SeriesKey{VMID: "vm-1", Metric: "cpu_usage"}
+	end := time.Now()
+	start := end.Add(-time.Hour)
+	candidates := []string{"vm-2"}
+
+	tests := []struct {
+		name    string
+		vmIDs   []string
+		metrics []string
+		start   time.Time
+		step    time.Duration
+	}{
+		{"步长过小", candidates, nil, start, time.Nanosecond},
+		{"对齐点数过多", candidates, nil, end.Add(-30 * 24 * time.Hour), time.Minute},
+		{"未指定候选VM", nil, nil, start, time.Minute},
+		{"候选VM过多", make([]string, maxCorrelationCandidates+1), nil, start, time.Minute},
+		{"候选指标过多", candidates, make([]string, maxCorrelationMetrics+1), start, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.Correlate(ref, tt.vmIDs, tt.metrics, tt.start, end, CorrelationOptions{Step: tt.step})
+			assert.ErrorIs(t, err, ErrInvalidCorrelation)
+		})
+	}
+}
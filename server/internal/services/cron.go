@@ -0,0 +1,164 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CronSchedule 标准5段cron表达式（分 时 日 月 周），用于维护窗口的周期性开始时间
+type CronSchedule struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+	// 日与周都被限制时按"或"匹配（与标准cron一致）
+	dayRestricted     bool
+	weekdayRestricted bool
+}
+
+// cronSearchLimit 查找下一次触发时间的最大范围
+const cronSearchLimit = 366 * 24 * time.Hour
+
+// ParseCron 解析5段cron表达式，支持 *、数字、范围(a-b)、步长(*/n、a-b/n)和列表(a,b)，周日可写作0或7
+func ParseCron(expr string) (*CronSchedule, error) {
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron表达式需要5个字段（分 时 日 月 周），实际为%d个", len(fields))
+	}
+
+	s := &CronSchedule{}
+	if err := parseCronField(fields[0], 0, 59, s.minutes[:]); err != nil {
+		return nil, fmt.Errorf("分钟字段错误: %w", err)
+	}
+	if err := parseCronField(fields[1], 0, 23, s.hours[:]); err != nil {
+		return nil, fmt.Errorf("小时字段错误: %w", err)
+	}
+	if err := parseCronField(fields[2], 1, 31, s.days[:]); err != nil {
+		return nil, fmt.Errorf("日期字段错误: %w", err)
+	}
+	if err := parseCronField(fields[3], 1, 12, s.months[:]); err != nil {
+		return nil, fmt.Errorf("月份字段错误: %w", err)
+	}
+	weekdays := make([]bool, 8)
+	if err := parseCronField(fields[4], 0, 7, weekdays); err != nil {
+		return nil, fmt.Errorf("星期字段错误: %w", err)
+	}
+	copy(s.weekdays[:], weekdays[:7])
+	s.weekdays[0] = s.weekdays[0] || weekdays[7]
+
+	s.dayRestricted = fields[2] != "*"
+	s.weekdayRestricted = fields[4] != "*"
+	return s, nil
+}
+
+// parseCronField 解析单个字段，将命中的取值写入set
+func parseCronField(field string, min, max int, set []bool) error {
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("无效的步长: %s", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			a, err1 := strconv.Atoi(bounds[0])
+			b, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || a > b {
+				return fmt.Errorf("无效的范围: %s", part)
+			}
+			lo, hi = a, b
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return fmt.Errorf("无效的取值: %s", part)
+			}
+			lo, hi = n, n
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max {
+			return fmt.Errorf("取值超出范围[%d, %d]: %s", min, max, part)
+		}
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// matchDay 判断日期是否满足日、月、周字段
+func (s *CronSchedule) matchDay(t time.Time) bool {
+	if !s.months[t.Month()] {
+		return false
+	}
+	day := s.days[t.Day()]
+	weekday := s.weekdays[t.Weekday()]
+	if s.dayRestricted && s.weekdayRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+// Matches 判断时间（精确到分钟）是否命中表达式
+func (s *CronSchedule) Matches(t time.Time) bool {
+	return s.matchDay(t) && s.hours[t.Hour()] && s.minutes[t.Minute()]
+}
+
+// Next 返回严格晚于t的下一次触发时间（使用t所在时区），一年内没有触发时返回零值
+func (s *CronSchedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Prev 返回不晚于t且不早于t-within的最近一次触发时间，不存在时返回false
+func (s *CronSchedule) Prev(t time.Time, within time.Duration) (time.Time, bool) {
+	t = t.Truncate(time.Minute)
+	earliest := t.Add(-within)
+
+	for !t.Before(earliest) {
+		if !s.matchDay(t) {
+			// 跳到前一天的最后一分钟
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		if !s.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location()).Add(-time.Minute)
+			continue
+		}
+		if s.minutes[t.Minute()] {
+			return t, true
+		}
+		t = t.Add(-time.Minute)
+	}
+	return time.Time{}, false
+}
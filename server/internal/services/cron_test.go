@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	s, err := ParseCron("*/15 2-4 * * 1,3,7")
+	require.NoError(t, err)
+	assert.True(t, s.minutes[0] && s.minutes[45] && !s.minutes[10])
+	assert.True(t, s.hours[2] && s.hours[4] && !s.hours[5])
+	assert.True(t, s.weekdays[0] && s.weekdays[1] && !s.weekdays[2])
+
+	for _, expr := range []string{"* * * *", "60 * * * *", "* * 0 * *", "5-1 * * * *", "*/0 * * * *", "a * * * *"} {
+		_, err := ParseCron(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestCronSchedule_NextAndPrev(t *testing.T) {
+	// 每周六 22:30
+	s, err := ParseCron("30 22 * * 6")
+	require.NoError(t, err)
+
+	from := time.Date(2026, 3, 4, 10, 0, 0, 0, time.UTC) // 周三
+	next := s.Next(from)
+	assert.Equal(t, time.Date(2026, 3, 7, 22, 30, 0, 0, time.UTC), next)
+	assert.Equal(t, time.Date(2026, 3, 14, 22, 30, 0, 0, time.UTC), s.Next(next))
+
+	prev, ok := s.Prev(time.Date(2026, 3, 8, 0, 10, 0, 0, time.UTC), 2*time.Hour)
+	require.True(t, ok)
+	assert.Equal(t, next, prev)
+
+	_, ok = s.Prev(time.Date(2026, 3, 8, 1, 0, 0, 0, time.UTC), time.Hour)
+	assert.False(t, ok)
+
+	// 日与周同时限制时按"或"匹配
+	s, err = ParseCron("0 0 1 * 1")
+	require.NoError(t, err)
+	assert.True(t, s.Matches(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)))  // 1号（周日）
+	assert.True(t, s.Matches(time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)))  // 周一
+	assert.False(t, s.Matches(time.Date(2026, 3, 3, 0, 0, 0, 0, time.UTC))) // 周二
+}
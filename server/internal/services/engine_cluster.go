@@ -0,0 +1,392 @@
+package services
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+)
+
+// 告警引擎集群默认参数
+const (
+	defaultEngineShards    = 64
+	defaultEngineLeaseTTL  = 30 * time.Second
+	defaultEngineHeartbeat = 10 * time.Second
+)
+
+// EngineEvaluationStats 实例最近一轮评估的统计
+type EngineEvaluationStats struct {
+	RuleCount   int
+	Evaluations int
+	At          time.Time
+	Duration    time.Duration
+}
+
+// EngineClusterStatus 告警引擎集群状态（从数据库读取，任意实例都能返回完整状态）
+type EngineClusterStatus struct {
+	InstanceID  string                  `json:"instanceId"` // 响应请求的实例
+	ShardCount  int                     `json:"shardCount"`
+	OwnedShards []int                   `json:"ownedShards"` // 响应请求的实例持有的分片
+	Members     []models.EngineInstance `json:"members"`
+	Unassigned  []int                   `json:"unassigned"` // 没有有效租约的分片（正在重新分配）
+}
+
+// EngineCluster 告警引擎集群协调。评估任务按规则与VM组合的哈希划分为固定数量的分片，
+// 每个实例定期写入心跳，按存活成员列表用最高随机权重（rendezvous）哈希计算自己应持有的分片，
+// 并通过租约表抢占/续期对应租约。实例下线后其心跳与租约过期，分片自动转移到其他实例。
+type EngineCluster struct {
+	db         *gorm.DB
+	instanceID string
+	hostname   string
+	startedAt  time.Time
+	shardCount int
+	leaseTTL   time.Duration
+	heartbeat  time.Duration
+
+	mu         sync.RWMutex
+	owned      map[int]bool
+	validUntil time.Time // 本地认为租约有效的截止时间，续期失败时到期后停止评估
+	generation uint64    // 持有的分片每变化一次加1
+	members    int       // 最近一次心跳时的存活成员数量
+	stats      EngineEvaluationStats
+
+	stopChan     chan struct{}
+	isRunning    bool
+	runningMutex sync.Mutex
+}
+
+// NewEngineCluster 创建集群协调器，instanceID为空时使用 主机名-进程号-随机后缀
+func NewEngineCluster(db *gorm.DB, instanceID string) *EngineCluster {
+	hostname, _ := os.Hostname()
+	if instanceID == "" {
+		instanceID = fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8])
+	}
+	return &EngineCluster{
+		db:         db,
+		instanceID: instanceID,
+		hostname:   hostname,
+		startedAt:  time.Now(),
+		shardCount: defaultEngineShards,
+		leaseTTL:   defaultEngineLeaseTTL,
+		heartbeat:  defaultEngineHeartbeat,
+		owned:      map[int]bool{},
+		stopChan:   make(chan struct{}),
+	}
+}
+
+// SetShardCount 设置分片数量（所有实例必须一致）
+func (c *EngineCluster) SetShardCount(count int) {
+	if count > 0 {
+		c.shardCount = count
+	}
+}
+
+// SetLeaseTiming 设置租约有效期与心跳间隔，有效期应为心跳间隔的数倍
+func (c *EngineCluster) SetLeaseTiming(ttl, heartbeat time.Duration) {
+	if ttl > 0 {
+		c.leaseTTL = ttl
+	}
+	if heartbeat > 0 {
+		c.heartbeat = heartbeat
+	}
+}
+
+// InstanceID 返回当前实例ID
+func (c *EngineCluster) InstanceID() string {
+	return c.instanceID
+}
+
+// Start 初始化租约表并立即完成一次心跳，之后定期续期
+func (c *EngineCluster) Start() error {
+	c.runningMutex.Lock()
+	defer c.runningMutex.Unlock()
+
+	if c.isRunning {
+		return fmt.Errorf("告警引擎集群协调已经在运行")
+	}
+	if err := c.ensureLeases(); err != nil {
+		return fmt.Errorf("初始化分片租约失败: %w", err)
+	}
+	// 首次心跳失败时不持有任何分片，由心跳循环重试
+	if err := c.Heartbeat(time.Now()); err != nil {
+		logger.Error("告警引擎集群心跳失败", zap.Error(err))
+	}
+
+	c.isRunning = true
+	c.stopChan = make(chan struct{})
+	go c.loop()
+
+	logger.Info("告警引擎集群协调已启动", zap.String("instance", c.instanceID), zap.Int("分片数", c.shardCount), zap.Int("持有分片", len(c.OwnedShards())))
+	return nil
+}
+
+// Stop 停止心跳，释放持有的租约并注销实例，使分片立即转移到其他实例
+func (c *EngineCluster) Stop() {
+	c.runningMutex.Lock()
+	defer c.runningMutex.Unlock()
+
+	if !c.isRunning {
+		return
+	}
+	close(c.stopChan)
+	c.isRunning = false
+
+	c.mu.Lock()
+	c.owned = map[int]bool{}
+	c.generation++
+	c.mu.Unlock()
+
+	now := time.Now()
+	if err := c.db.Model(&models.EngineLease{}).Where("owner = ?", c.instanceID).
+		Updates(map[string]interface{}{"owner": "", "expires_at": now, "updated_at": now}).Error; err != nil {
+		logger.Warn("释放分片租约失败", zap.Error(err))
+	}
+	if err := c.db.Where("id = ?", c.instanceID).Delete(&models.EngineInstance{}).Error; err != nil {
+		logger.Warn("注销告警引擎实例失败", zap.Error(err))
+	}
+	logger.Info("告警引擎集群协调已停止", zap.String("instance", c.instanceID))
+}
+
+// loop 心跳循环
+func (c *EngineCluster) loop() {
+	ticker := time.NewTicker(c.heartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := c.Heartbeat(time.Now()); err != nil {
+				logger.Error("告警引擎集群心跳失败", zap.Error(err))
+			}
+		case <-c.stopChan:
+			return
+		}
+	}
+}
+
+// ensureLeases 创建全部分片的租约行（已存在时忽略）
+func (c *EngineCluster) ensureLeases() error {
+	leases := make([]models.EngineLease, c.shardCount)
+	for i := range leases {
+		leases[i] = models.EngineLease{Shard: i}
+	}
+	return c.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&leases).Error
+}
+
+// Heartbeat 续期实例心跳，按存活成员重新计算应持有的分片：先释放不再属于自己的租约，
+// 再抢占或续期属于自己的租约（原持有者释放或租约过期后才能抢到）
+func (c *EngineCluster) Heartbeat(now time.Time) error {
+	c.mu.RLock()
+	stats := c.stats
+	c.mu.RUnlock()
+
+	instance := models.EngineInstance{
+		ID:               c.instanceID,
+		Hostname:         c.hostname,
+		StartedAt:        c.startedAt,
+		HeartbeatAt:      now,
+		ExpiresAt:        now.Add(c.leaseTTL),
+		Shards:           models.IntArray(c.OwnedShards()),
+		RuleCount:        stats.RuleCount,
+		Evaluations:      stats.Evaluations,
+		LastEvaluationMs: stats.Duration.Milliseconds(),
+	}
+	if !stats.At.IsZero() {
+		instance.LastEvaluationAt = &stats.At
+	}
+	if err := c.db.Save(&instance).Error; err != nil {
+		return fmt.Errorf("写入心跳失败: %w", err)
+	}
+
+	var members []string
+	if err := c.db.Model(&models.EngineInstance{}).Where("expires_at > ?", now).Order("id").Pluck("id", &members).Error; err != nil {
+		return fmt.Errorf("查询集群成员失败: %w", err)
+	}
+
+	c.mu.Lock()
+	c.members = len(members)
+	c.mu.Unlock()
+
+	desired := map[int]bool{}
+	var desiredList []int
+	for shard := 0; shard < c.shardCount; shard++ {
+		if rendezvousOwner(members, shard) == c.instanceID {
+			desired[shard] = true
+			desiredList = append(desiredList, shard)
+		}
+	}
+
+	// 先在本地停止评估要移交的分片，再释放租约
+	c.mu.Lock()
+	for shard := range c.owned {
+		if !desired[shard] {
+			delete(c.owned, shard)
+			c.generation++
+		}
+	}
+	c.mu.Unlock()
+
+	release := c.db.Model(&models.EngineLease{}).Where("owner = ?", c.instanceID)
+	if len(desiredList) > 0 {
+		release = release.Where("shard NOT IN ?", desiredList)
+	}
+	if err := release.Updates(map[string]interface{}{"owner": "", "expires_at": now, "updated_at": now}).Error; err != nil {
+		return fmt.Errorf("释放分片租约失败: %w", err)
+	}
+
+	if len(desiredList) > 0 {
+		if err := c.db.Model(&models.EngineLease{}).
+			Where("shard IN ? AND (owner = ? OR expires_at < ?)", desiredList, c.instanceID, now).
+			Updates(map[string]interface{}{"owner": c.instanceID, "expires_at": now.Add(c.leaseTTL), "updated_at": now}).Error; err != nil {
+			return fmt.Errorf("续期分片租约失败: %w", err)
+		}
+	}
+
+	var shards []int
+	if err := c.db.Model(&models.EngineLease{}).Where("owner = ? AND expires_at > ?", c.instanceID, now).Order("shard").Pluck("shard", &shards).Error; err != nil {
+		return fmt.Errorf("查询分片租约失败: %w", err)
+	}
+
+	owned := make(map[int]bool, len(shards))
+	for _, shard := range shards {
+		owned[shard] = true
+	}
+
+	c.mu.Lock()
+	if len(owned) != len(c.owned) {
+		c.generation++
+	} else {
+		for shard := range owned {
+			if !c.owned[shard] {
+				c.generation++
+				break
+			}
+		}
+	}
+	c.owned = owned
+	// 留出一个心跳间隔的余量，确保在数据库中的租约过期前停止评估
+	c.validUntil = now.Add(c.leaseTTL - c.heartbeat)
+	c.mu.Unlock()
+
+	if err := c.db.Model(&instance).Update("shards", models.IntArray(shards)).Error; err != nil {
+		return fmt.Errorf("更新实例分片失败: %w", err)
+	}
+
+	// 清理长时间未续期的实例记录
+	return c.db.Where("expires_at < ?", now.Add(-10*c.leaseTTL)).Delete(&models.EngineInstance{}).Error
+}
+
+// SingleInstance 最近一次心跳时集群中是否只有当前实例（尚未完成心跳时返回false）
+func (c *EngineCluster) SingleInstance() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.members == 1
+}
+
+// ShardOf 返回规则与VM组合所属的分片
+func (c *EngineCluster) ShardOf(ruleID, vmID uuid.UUID) int {
+	h := fnv.New32a()
+	h.Write(ruleID[:])
+	h.Write(vmID[:])
+	return int(h.Sum32() % uint32(c.shardCount))
+}
+
+// Owns 判断当前实例是否持有规则与VM组合所属分片的有效租约
+func (c *EngineCluster) Owns(ruleID, vmID uuid.UUID) bool {
+	shard := c.ShardOf(ruleID, vmID)
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.owned[shard] && time.Now().Before(c.validUntil)
+}
+
+// OwnedShards 返回当前持有的分片（升序）
+func (c *EngineCluster) OwnedShards() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	shards := make([]int, 0, len(c.owned))
+	for shard := range c.owned {
+		shards = append(shards, shard)
+	}
+	sort.Ints(shards)
+	return shards
+}
+
+// Generation 返回持有分片的版本号，变化说明发生了分片转移
+func (c *EngineCluster) Generation() uint64 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.generation
+}
+
+// ReportEvaluation 记录最近一轮评估的统计，随下次心跳写入实例记录
+func (c *EngineCluster) ReportEvaluation(stats EngineEvaluationStats) {
+	c.mu.Lock()
+	c.stats = stats
+	c.mu.Unlock()
+}
+
+// Status 读取集群成员与分片分配情况
+func (c *EngineCluster) Status() (*EngineClusterStatus, error) {
+	now := time.Now()
+	status := &EngineClusterStatus{
+		InstanceID:  c.instanceID,
+		ShardCount:  c.shardCount,
+		OwnedShards: c.OwnedShards(),
+		Members:     []models.EngineInstance{},
+		Unassigned:  []int{},
+	}
+	if err := c.db.Where("expires_at > ?", now).Order("id").Find(&status.Members).Error; err != nil {
+		return nil, fmt.Errorf("查询集群成员失败: %w", err)
+	}
+
+	var assigned []int
+	if err := c.db.Model(&models.EngineLease{}).Where("owner <> '' AND expires_at > ?", now).Pluck("shard", &assigned).Error; err != nil {
+		return nil, fmt.Errorf("查询分片租约失败: %w", err)
+	}
+	held := make(map[int]bool, len(assigned))
+	for _, shard := range assigned {
+		held[shard] = true
+	}
+	for shard := 0; shard < c.shardCount; shard++ {
+		if !held[shard] {
+			status.Unassigned = append(status.Unassigned, shard)
+		}
+	}
+	return status, nil
+}
+
+// rendezvousOwner 用最高随机权重哈希从成员中选出分片的持有者，成员变化时只有少量分片需要转移
+func rendezvousOwner(members []string, shard int) string {
+	var owner string
+	var best uint64
+	for _, member := range members {
+		h := fnv.New64a()
+		h.Write([]byte(member))
+		h.Write([]byte{byte(shard >> 24), byte(shard >> 16), byte(shard >> 8), byte(shard)})
+		if score := mix64(h.Sum64()); owner == "" || score > best {
+			owner, best = member, score
+		}
+	}
+	return owner
+}
+
+// mix64 对FNV哈希做雪崩混合（murmur3 fmix64），避免只有末尾字符不同的成员得分相近
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
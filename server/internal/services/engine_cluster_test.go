@@ -0,0 +1,85 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupEngineClusterTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.AutoMigrate(&models.EngineInstance{}, &models.EngineLease{}))
+	return db
+}
+
+func newTestCluster(t *testing.T, db *gorm.DB, id string) *EngineCluster {
+	cluster := NewEngineCluster(db, id)
+	cluster.SetShardCount(16)
+	require.NoError(t, cluster.ensureLeases())
+	return cluster
+}
+
+func TestEngineCluster_ShardsRebalance(t *testing.T) {
+	db := setupEngineClusterTestDB(t)
+	a := newTestCluster(t, db, "instance-a")
+	b := newTestCluster(t, db, "instance-b")
+	now := time.Now()
+
+	assert.False(t, a.SingleInstance())
+	require.NoError(t, a.Heartbeat(now))
+	assert.Len(t, a.OwnedShards(), 16)
+	assert.True(t, a.SingleInstance())
+
+	// b加入后，a在下次心跳释放属于b的分片，b随后抢到租约
+	require.NoError(t, b.Heartbeat(now))
+	assert.Empty(t, b.OwnedShards())
+	generation := a.Generation()
+	require.NoError(t, a.Heartbeat(now.Add(time.Second)))
+	require.NoError(t, b.Heartbeat(now.Add(2*time.Second)))
+	assert.NotEqual(t, generation, a.Generation())
+	assert.NotEmpty(t, a.OwnedShards())
+	assert.NotEmpty(t, b.OwnedShards())
+	assert.Len(t, a.OwnedShards(), 16-len(b.OwnedShards()))
+	assert.False(t, a.SingleInstance())
+
+	// 每个规则与VM组合恰好由一个实例评估
+	for i := 0; i < 50; i++ {
+		ruleID, vmID := uuid.New(), uuid.New()
+		assert.NotEqual(t, a.Owns(ruleID, vmID), b.Owns(ruleID, vmID))
+	}
+
+	status, err := a.Status()
+	require.NoError(t, err)
+	assert.Len(t, status.Members, 2)
+	assert.Empty(t, status.Unassigned)
+
+	// a失联：心跳与租约过期后b接管全部分片
+	require.NoError(t, b.Heartbeat(now.Add(40*time.Second)))
+	assert.Len(t, b.OwnedShards(), 16)
+	assert.True(t, b.SingleInstance())
+	assert.Equal(t, int64(16), countRows(t, db, "alert_engine_leases", "owner = ?", "instance-b"))
+}
+
+func TestEngineCluster_StopReleasesLeases(t *testing.T) {
+	db := setupEngineClusterTestDB(t)
+	a := newTestCluster(t, db, "instance-a")
+	b := newTestCluster(t, db, "instance-b")
+
+	require.NoError(t, a.Start())
+	require.NoError(t, b.Heartbeat(time.Now()))
+	a.Stop()
+	assert.Empty(t, a.OwnedShards())
+	assert.Equal(t, int64(0), countRows(t, db, "alert_engine_instances", "id = ?", "instance-a"))
+
+	// 正常退出的实例立即释放租约，无需等待过期
+	require.NoError(t, b.Heartbeat(time.Now()))
+	assert.Len(t, b.OwnedShards(), 16)
+}
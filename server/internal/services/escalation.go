@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// EscalationLevel 升级策略中的一级（Delay为距告警触发的分钟数）
+type EscalationLevel struct {
+	Delay      int      `json:"delay"`
+	Methods    []string `json:"methods"`
+	Recipients []string `json:"recipients"`
+}
+
+// escalationLevels 解析规则通知配置中启用的升级级别，按延迟升序排列
+func escalationLevels(config models.JSONMap) []EscalationLevel {
+	raw, ok := config["escalation"]
+	if !ok || raw == nil {
+		return nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil
+	}
+
+	var escalation struct {
+		Enabled bool              `json:"enabled"`
+		Levels  []EscalationLevel `json:"levels"`
+	}
+	if err := json.Unmarshal(data, &escalation); err != nil || !escalation.Enabled {
+		return nil
+	}
+
+	sort.SliceStable(escalation.Levels, func(i, j int) bool {
+		return escalation.Levels[i].Delay < escalation.Levels[j].Delay
+	})
+	return escalation.Levels
+}
+
+// nextEscalationAt 计算告警第level级（从0开始）的升级时间，没有更多级别时返回nil
+func nextEscalationAt(config models.JSONMap, triggeredAt time.Time, level int) *time.Time {
+	levels := escalationLevels(config)
+	if level >= len(levels) {
+		return nil
+	}
+	at := triggeredAt.Add(time.Duration(levels[level].Delay) * time.Minute)
+	return &at
+}
+
+// escalationConfig 基于规则通知配置生成某一升级级别的通知配置：
+// 使用该级别的通知方式，收件人同时作为邮件收件人、短信号码和站内信用户
+func escalationConfig(base models.JSONMap, level EscalationLevel) models.JSONMap {
+	config := models.JSONMap{}
+	for k, v := range base {
+		config[k] = v
+	}
+	delete(config, "escalation")
+	config["methods"] = level.Methods
+
+	if len(level.Recipients) == 0 {
+		return config
+	}
+	override := map[string]string{"email": "recipients", "sms": "phoneNumbers", "inApp": "users"}
+	for method, field := range override {
+		section := map[string]interface{}{}
+		if existing, ok := base[method].(map[string]interface{}); ok {
+			for k, v := range existing {
+				section[k] = v
+			}
+		}
+		section["enabled"] = true
+		section[field] = level.Recipients
+		config[method] = section
+	}
+	return config
+}
+
+// EscalationService 告警升级调度：对未确认的活动告警按升级策略逐级发送通知。
+// 下一次升级时间保存在告警记录上，重启后继续调度
+type EscalationService struct {
+	db       *gorm.DB
+	notifier *NotificationService
+	interval time.Duration
+
+	stopChan     chan struct{}
+	isRunning    bool
+	runningMutex sync.Mutex
+}
+
+// NewEscalationService 创建告警升级服务
+func NewEscalationService(db *gorm.DB, notifier *NotificationService) *EscalationService {
+	return &EscalationService{
+		db:       db,
+		notifier: notifier,
+		interval: 30 * time.Second,
+	}
+}
+
+// SetInterval 设置检查间隔
+func (s *EscalationService) SetInterval(interval time.Duration) {
+	if interval > 0 {
+		s.interval = interval
+	}
+}
+
+// Start 启动升级调度
+func (s *EscalationService) Start() error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("告警升级服务已经在运行")
+	}
+
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	go s.loop()
+
+	logger.Info("告警升级服务已启动", zap.Duration("检查间隔", s.interval))
+	return nil
+}
+
+// Stop 停止升级调度
+func (s *EscalationService) Stop() {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+	close(s.stopChan)
+	s.isRunning = false
+	logger.Info("告警升级服务已停止")
+}
+
+// loop 定期检查到期的升级
+func (s *EscalationService) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.RunOnce(time.Now()); err != nil {
+				logger.Error("执行告警升级失败", zap.Error(err))
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// RunOnce 处理所有到期的升级，返回发送的升级通知数量。
+// 只处理活动状态的告警，告警被确认或恢复后不再升级
+func (s *EscalationService) RunOnce(now time.Time) (int, error) {
+	var alerts []models.AlertRecord
+	if err := s.db.Where("status = ? AND next_escalation_at IS NOT NULL AND next_escalation_at <= ?", "active", now).
+		Order("next_escalation_at").
+		Find(&alerts).Error; err != nil {
+		return 0, fmt.Errorf("查询待升级告警失败: %w", err)
+	}
+
+	sent := 0
+	for _, alert := range alerts {
+		var rule models.AlertRule
+		if err := s.db.Select("id", "notification_config").Where("id = ?", alert.RuleID).First(&rule).Error; err != nil {
+			logger.Error("查询告警规则失败", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+			continue
+		}
+
+		levels := escalationLevels(rule.NotificationConfig)
+		level := alert.EscalationLevel
+		if level >= len(levels) {
+			// 规则的升级策略已被修改或关闭
+			s.advance(alert, level, nil)
+			continue
+		}
+
+		// 条件更新，避免多个实例重复发送同一级别
+		if !s.advance(alert, level+1, nextEscalationAt(rule.NotificationConfig, alert.TriggeredAt, level+1)) {
+			continue
+		}
+
+		if s.notifier != nil {
+			results := s.notifier.SendAlert(context.Background(), alert, escalationConfig(rule.NotificationConfig, levels[level]))
+			logger.Info("告警已升级",
+				zap.String("alert_id", alert.ID.String()),
+				zap.String("rule_name", alert.RuleName),
+				zap.Int("level", level+1),
+				zap.Any("results", results))
+		}
+		sent++
+	}
+
+	return sent, nil
+}
+
+// advance 将告警的升级级别从当前值推进到level，并设置下一次升级时间；记录已被其他实例推进时返回false
+func (s *EscalationService) advance(alert models.AlertRecord, level int, next *time.Time) bool {
+	result := s.db.Model(&models.AlertRecord{}).
+		Where("id = ? AND escalation_level = ?", alert.ID, alert.EscalationLevel).
+		Updates(map[string]interface{}{
+			"escalation_level":   level,
+			"next_escalation_at": next,
+		})
+	if result.Error != nil {
+		logger.Error("更新告警升级状态失败", zap.String("alert_id", alert.ID.String()), zap.Error(result.Error))
+		return false
+	}
+	return result.RowsAffected > 0
+}
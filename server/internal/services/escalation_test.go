@@ -0,0 +1,87 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func escalationTestConfig() models.JSONMap {
+	return models.JSONMap{
+		"methods": []interface{}{"inApp"},
+		"escalation": map[string]interface{}{
+			"enabled": true,
+			"levels": []interface{}{
+				map[string]interface{}{"delay": 30, "methods": []interface{}{"email"}, "recipients": []interface{}{"lead@example.com"}},
+				map[string]interface{}{"delay": 10, "methods": []interface{}{"inApp"}, "recipients": []interface{}{"oncall"}},
+			},
+		},
+	}
+}
+
+func TestEscalationLevels(t *testing.T) {
+	levels := escalationLevels(escalationTestConfig())
+	require.Len(t, levels, 2)
+	assert.Equal(t, 10, levels[0].Delay)
+	assert.Equal(t, 30, levels[1].Delay)
+
+	assert.Nil(t, escalationLevels(models.JSONMap{"escalation": map[string]interface{}{"enabled": false}}))
+	assert.Nil(t, escalationLevels(nil))
+
+	config := escalationConfig(escalationTestConfig(), levels[1])
+	assert.Equal(t, []string{"email"}, config["methods"])
+	assert.NotContains(t, config, "escalation")
+	email := config["email"].(map[string]interface{})
+	assert.Equal(t, true, email["enabled"])
+	assert.Equal(t, []string{"lead@example.com"}, email["recipients"])
+}
+
+func TestEscalationService_RunOnce(t *testing.T) {
+	db := setupAlertStateTestDB(t)
+	notifier := NewNotificationService()
+	notifier.Enable()
+	engine := NewAlertEngine(db, notifier)
+	escalation := NewEscalationService(db, notifier)
+
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high", NotificationConfig: escalationTestConfig()}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id, notification_config) VALUES (?, ?)", rule.ID, rule.NotificationConfig).Error)
+	metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+
+	vmA := models.VM{ID: uuid.New(), Name: "web-01"}
+	vmB := models.VM{ID: uuid.New(), Name: "web-02"}
+	alertA, err := engine.createAlert(rule, vmA, metric, nil)
+	require.NoError(t, err)
+	alertB, err := engine.createAlert(rule, vmB, metric, nil)
+	require.NoError(t, err)
+	require.NotNil(t, alertA.NextEscalationAt)
+	start := alertA.TriggeredAt
+
+	// 未到第一级延迟
+	sent, err := escalation.RunOnce(start.Add(5 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	// 已确认的告警不再升级
+	require.NoError(t, db.Exec("UPDATE alert_records SET status = ? WHERE id = ?", "acknowledged", alertB.ID).Error)
+
+	sent, err = escalation.RunOnce(start.Add(11 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "id = ? AND escalation_level = ?", alertA.ID, 1))
+
+	// 同一级别不会重复发送
+	sent, err = escalation.RunOnce(start.Add(12 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 0, sent)
+
+	sent, err = escalation.RunOnce(start.Add(31 * time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "id = ? AND escalation_level = ? AND next_escalation_at IS NULL", alertA.ID, 2))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "id = ? AND escalation_level = ?", alertB.ID, 0))
+}
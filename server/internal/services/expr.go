@@ -0,0 +1,1476 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+
+	"vm-monitoring-system/internal/models"
+)
+
+// 告警规则表达式语言
+//
+//	expr    = or
+//	or      = and { ("or" | "||") and }
+//	and     = not { ("and" | "&&") not }
+//	not     = ("not" | "!") not | compare
+//	compare = sum [ (">" | ">=" | "<" | "<=" | "==" | "!=") sum ]
+//	sum     = product { ("+" | "-") product }
+//	product = unary { ("*" | "/" | "%") unary }
+//	unary   = "-" unary | primary
+//	primary = number | metric | call | "(" expr ")"
+//
+// 单独的指标名等价于 last(metric, 5m)。例如：
+//
+//	avg(cpu_usage, 5m) > 90 and memory_usage > 85 or rate(network_tx, 1m) > 1e8
+//
+// 聚合评估模式下使用 fleet_* 函数跨作用范围内的VM聚合，例如：
+//
+//	fleet_avg(avg(cpu_usage, 5m)) > 80 or fleet_count(absent(cpu_usage, 5m)) > 3
+
+// ExprError 表达式解析/校验错误，Pos为出错位置（从1开始的字符序号）
+type ExprError struct {
+	Pos     int    `json:"pos"`
+	Message string `json:"message"`
+}
+
+// Error 实现error接口
+func (e *ExprError) Error() string {
+	return fmt.Sprintf("第%d个字符处: %s", e.Pos, e.Message)
+}
+
+// exprType 表达式节点的值类型
+type exprType int
+
+const (
+	exprNumber exprType = iota
+	exprBool
+)
+
+// ========== 词法分析 ==========
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokDuration
+	tokString
+	tokIdent
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	num  float64 // 数字的值或时长的秒数
+	pos  int     // 字节偏移
+}
+
+// describe 用于错误信息的记号描述
+func (t exprToken) describe() string {
+	if t.kind == tokEOF {
+		return "表达式结尾"
+	}
+	return "'" + t.text + "'"
+}
+
+// durationUnits 时长单位对应的秒数
+var durationUnits = map[byte]float64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+
+// lexExpression 将表达式切分为记号
+func lexExpression(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	i := 0
+	for i < len(src) {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			start := i
+			for i < len(src) && (src[i] >= '0' && src[i] <= '9' || src[i] == '.') {
+				i++
+			}
+			// 科学计数法
+			if i < len(src) && (src[i] == 'e' || src[i] == 'E') {
+				j := i + 1
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				if j < len(src) && src[j] >= '0' && src[j] <= '9' {
+					for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+						j++
+					}
+					i = j
+				}
+			}
+			value, err := strconv.ParseFloat(src[start:i], 64)
+			if err != nil {
+				return nil, exprErrorAt(src, start, "无效的数字 '%s'", src[start:i])
+			}
+
+			// 数字后紧跟单位为时长，如 5m
+			if unit, ok := durationUnits[byteAt(src, i)]; ok && !isIdentByte(byteAt(src, i+1)) {
+				i++
+				tokens = append(tokens, exprToken{kind: tokDuration, text: src[start:i], num: value * unit, pos: start})
+				continue
+			}
+			if isIdentByte(byteAt(src, i)) {
+				return nil, exprErrorAt(src, i, "数字后出现意外的字符 '%c'（时长单位只支持 s、m、h、d、w）", src[i])
+			}
+			tokens = append(tokens, exprToken{kind: tokNumber, text: src[start:i], num: value, pos: start})
+
+		case c == '"' || c == '\'':
+			start := i
+			i++
+			for i < len(src) && src[i] != c {
+				i++
+			}
+			if i >= len(src) {
+				return nil, exprErrorAt(src, start, "字符串缺少结束引号")
+			}
+			i++
+			tokens = append(tokens, exprToken{kind: tokString, text: src[start+1 : i-1], pos: start})
+
+		case isIdentStart(c):
+			start := i
+			for i < len(src) && isIdentByte(src[i]) {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tokIdent, text: src[start:i], pos: start})
+
+		case c == '(':
+			tokens = append(tokens, exprToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, exprToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case c == ',':
+			tokens = append(tokens, exprToken{kind: tokComma, text: ",", pos: i})
+			i++
+
+		default:
+			op := ""
+			for _, candidate := range []string{">=", "<=", "==", "!=", "&&", "||", ">", "<", "=", "!", "+", "-", "*", "/", "%"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				r, _ := utf8.DecodeRuneInString(src[i:])
+				return nil, exprErrorAt(src, i, "无法识别的字符 '%c'", r)
+			}
+			tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, exprToken{kind: tokEOF, pos: len(src)}), nil
+}
+
+func byteAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return 0
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+func isIdentByte(c byte) bool {
+	return isIdentStart(c) || c >= '0' && c <= '9' || c == '.' || c == ':'
+}
+
+// isMetricIdent 判断指标名能否不加引号直接写在表达式中
+func isMetricIdent(name string) bool {
+	if name == "" || !isIdentStart(name[0]) || exprKeyword(name) != "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isIdentByte(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// exprKeyword 返回关键字对应的运算符，不是关键字时返回空
+func exprKeyword(ident string) string {
+	switch strings.ToLower(ident) {
+	case "and":
+		return "and"
+	case "or":
+		return "or"
+	case "not":
+		return "not"
+	}
+	return ""
+}
+
+// exprErrorAt 按字节偏移生成错误（位置换算为字符序号）
+func exprErrorAt(src string, offset int, format string, args ...interface{}) *ExprError {
+	if offset > len(src) {
+		offset = len(src)
+	}
+	return &ExprError{Pos: utf8.RuneCountInString(src[:offset]) + 1, Message: fmt.Sprintf(format, args...)}
+}
+
+// ========== 语法树 ==========
+
+// exprNode 表达式语法树节点
+type exprNode interface {
+	typ() exprType
+	pos() int
+	String() string
+}
+
+type numberNode struct {
+	value  float64
+	offset int
+}
+
+// metricNode 指标取值：aggregation在window内的聚合值，anomaly时为异常得分的绝对值；
+// absent时为条件，window内没有数据时成立
+type metricNode struct {
+	fn          string
+	metric      string
+	aggregation string
+	algorithm   string
+	window      time.Duration
+	explicit    bool // 是否显式写出函数调用
+	offset      int
+}
+
+type callNode struct {
+	fn     string
+	args   []exprNode
+	offset int
+}
+
+// fleetNode 聚合评估模式下跨VM的聚合：对作用范围内每个VM求arg的值后聚合，count统计arg成立的VM数
+type fleetNode struct {
+	fn         string // 函数名，如 fleet_avg
+	agg        string // avg, max, min, sum, count, percentile
+	arg        exprNode
+	percentile float64
+	offset     int
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+	offset  int
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+	offset      int
+}
+
+func (n *numberNode) typ() exprType { return exprNumber }
+func (n *metricNode) typ() exprType {
+	if n.fn == "absent" {
+		return exprBool
+	}
+	return exprNumber
+}
+func (n *callNode) typ() exprType  { return exprNumber }
+func (n *fleetNode) typ() exprType { return exprNumber }
+func (n *unaryNode) typ() exprType {
+	if n.op == "not" {
+		return exprBool
+	}
+	return exprNumber
+}
+func (n *binaryNode) typ() exprType {
+	if exprPrecedence(n.op) <= precCompare {
+		return exprBool
+	}
+	return exprNumber
+}
+
+func (n *numberNode) pos() int { return n.offset }
+func (n *metricNode) pos() int { return n.offset }
+func (n *callNode) pos() int   { return n.offset }
+func (n *fleetNode) pos() int  { return n.offset }
+func (n *unaryNode) pos() int  { return n.offset }
+func (n *binaryNode) pos() int { return n.offset }
+
+func (n *numberNode) String() string {
+	return strconv.FormatFloat(n.value, 'g', -1, 64)
+}
+
+func (n *metricNode) String() string {
+	metric := n.metric
+	if !isMetricIdent(metric) {
+		metric = strconv.Quote(metric)
+	} else if !n.explicit {
+		return metric
+	}
+	args := []string{metric}
+	if n.fn == "anomaly" {
+		args = append(args, strconv.Quote(n.algorithm))
+	}
+	args = append(args, formatExprDuration(n.window))
+	return n.fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (n *callNode) String() string {
+	args := make([]string, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.String()
+	}
+	return n.fn + "(" + strings.Join(args, ", ") + ")"
+}
+
+func (n *fleetNode) String() string {
+	if n.agg == "percentile" {
+		return n.fn + "(" + n.arg.String() + ", " + strconv.FormatFloat(n.percentile, 'g', -1, 64) + ")"
+	}
+	return n.fn + "(" + n.arg.String() + ")"
+}
+
+func (n *unaryNode) String() string {
+	operand := n.operand.String()
+	if b, ok := n.operand.(*binaryNode); ok && exprPrecedence(b.op) < precUnary {
+		operand = "(" + operand + ")"
+	}
+	if n.op == "not" {
+		return "not " + operand
+	}
+	return n.op + operand
+}
+
+func (n *binaryNode) String() string {
+	prec := exprPrecedence(n.op)
+	left, right := n.left.String(), n.right.String()
+	if b, ok := n.left.(*binaryNode); ok && exprPrecedence(b.op) < prec {
+		left = "(" + left + ")"
+	}
+	// 右侧同级运算也加括号，保持左结合的语义
+	if b, ok := n.right.(*binaryNode); ok && exprPrecedence(b.op) <= prec {
+		right = "(" + right + ")"
+	}
+	return left + " " + n.op + " " + right
+}
+
+// formatExprDuration 将时长格式化为表达式中的写法
+func formatExprDuration(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	for _, unit := range []struct {
+		suffix  string
+		seconds int64
+	}{{"w", 604800}, {"d", 86400}, {"h", 3600}, {"m", 60}} {
+		if seconds > 0 && seconds%unit.seconds == 0 {
+			return strconv.FormatInt(seconds/unit.seconds, 10) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(seconds, 10) + "s"
+}
+
+// 运算符优先级
+const (
+	precOr = iota + 1
+	precAnd
+	precCompare
+	precSum
+	precProduct
+	precUnary
+)
+
+func exprPrecedence(op string) int {
+	switch op {
+	case "or":
+		return precOr
+	case "and":
+		return precAnd
+	case ">", ">=", "<", "<=", "==", "!=":
+		return precCompare
+	case "+", "-":
+		return precSum
+	case "*", "/", "%":
+		return precProduct
+	}
+	return precUnary
+}
+
+// ========== 函数 ==========
+
+// exprFunction 表达式支持的函数
+type exprFunction struct {
+	aggregation string // 指标聚合方式，为空表示普通数值函数
+	fleet       string // 跨VM聚合方式，只能用于聚合评估模式
+	minArgs     int
+	maxArgs     int
+	usage       string
+}
+
+// exprFunctions 表达式支持的函数
+var exprFunctions = map[string]exprFunction{
+	"last":    {aggregation: "last", minArgs: 1, maxArgs: 2, usage: "last(metric[, window]) 窗口内最新值"},
+	"avg":     {aggregation: "avg", minArgs: 1, maxArgs: 2, usage: "avg(metric[, window]) 窗口内平均值"},
+	"max":     {aggregation: "max", minArgs: 1, maxArgs: 2, usage: "max(metric[, window]) 窗口内最大值"},
+	"min":     {aggregation: "min", minArgs: 1, maxArgs: 2, usage: "min(metric[, window]) 窗口内最小值"},
+	"sum":     {aggregation: "sum", minArgs: 1, maxArgs: 2, usage: "sum(metric[, window]) 窗口内求和"},
+	"rate":    {aggregation: "rate", minArgs: 2, maxArgs: 2, usage: "rate(metric, window) 窗口内每秒变化率"},
+	"delta":   {aggregation: "delta", minArgs: 2, maxArgs: 2, usage: "delta(metric, window) 窗口内首尾差值"},
+	"anomaly": {aggregation: "anomaly", minArgs: 1, maxArgs: 3, usage: "anomaly(metric[, algorithm[, window]]) 异常得分的绝对值"},
+	"absent":  {aggregation: "absent", minArgs: 1, maxArgs: 2, usage: "absent(metric[, window]) 窗口内没有数据时成立"},
+	"abs":     {minArgs: 1, maxArgs: 1, usage: "abs(x) 绝对值"},
+
+	"fleet_avg":        {fleet: "avg", minArgs: 1, maxArgs: 1, usage: "fleet_avg(x) 作用范围内各VM上x的平均值（聚合评估模式）"},
+	"fleet_max":        {fleet: "max", minArgs: 1, maxArgs: 1, usage: "fleet_max(x) 作用范围内各VM上x的最大值（聚合评估模式）"},
+	"fleet_min":        {fleet: "min", minArgs: 1, maxArgs: 1, usage: "fleet_min(x) 作用范围内各VM上x的最小值（聚合评估模式）"},
+	"fleet_sum":        {fleet: "sum", minArgs: 1, maxArgs: 1, usage: "fleet_sum(x) 作用范围内各VM上x的总和（聚合评估模式）"},
+	"fleet_percentile": {fleet: "percentile", minArgs: 2, maxArgs: 2, usage: "fleet_percentile(x, p) 作用范围内各VM上x的第p百分位数（聚合评估模式）"},
+	"fleet_count":      {fleet: "count", minArgs: 1, maxArgs: 1, usage: "fleet_count(条件) 作用范围内满足条件的VM数量（聚合评估模式）"},
+}
+
+// ExprFunctionUsages 返回函数用法说明（用于规则编辑器提示）
+func ExprFunctionUsages() map[string]string {
+	usages := make(map[string]string, len(exprFunctions))
+	for name, fn := range exprFunctions {
+		usages[name] = fn.usage
+	}
+	return usages
+}
+
+// ========== 语法分析 ==========
+
+type exprParser struct {
+	src     string
+	tokens  []exprToken
+	cur     int
+	inFleet bool // 正在解析跨VM聚合函数的参数
+}
+
+func (p *exprParser) peek() exprToken { return p.tokens[p.cur] }
+
+func (p *exprParser) next() exprToken {
+	t := p.tokens[p.cur]
+	if t.kind != tokEOF {
+		p.cur++
+	}
+	return t
+}
+
+func (p *exprParser) errorf(t exprToken, format string, args ...interface{}) *ExprError {
+	return exprErrorAt(p.src, t.pos, format, args...)
+}
+
+// binaryOp 返回当前记号对应的二元运算符（关键字与符号统一为 and/or），不是运算符时返回空
+func (p *exprParser) binaryOp() string {
+	t := p.peek()
+	switch t.kind {
+	case tokIdent:
+		if op := exprKeyword(t.text); op == "and" || op == "or" {
+			return op
+		}
+	case tokOp:
+		switch t.text {
+		case "&&":
+			return "and"
+		case "||":
+			return "or"
+		case "=":
+			return "=="
+		case "!":
+			return ""
+		}
+		return t.text
+	}
+	return ""
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseBinary(precOr)
+}
+
+// parseBinary 按优先级解析左结合的二元运算；比较运算不允许连写
+func (p *exprParser) parseBinary(prec int) (exprNode, error) {
+	if prec == precUnary {
+		return p.parseUnary()
+	}
+	if prec == precCompare {
+		return p.parseCompare()
+	}
+
+	left, err := p.parseBinary(prec + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := p.binaryOp()
+		if op == "" || exprPrecedence(op) != prec {
+			return left, nil
+		}
+		t := p.next()
+		right, err := p.parseBinary(prec + 1)
+		if err != nil {
+			return nil, err
+		}
+		node := &binaryNode{op: op, left: left, right: right, offset: t.pos}
+		if err := p.checkOperands(node); err != nil {
+			return nil, err
+		}
+		left = node
+	}
+}
+
+// parseCompare 解析 not 与比较运算
+func (p *exprParser) parseCompare() (exprNode, error) {
+	t := p.peek()
+	if (t.kind == tokIdent && exprKeyword(t.text) == "not") || (t.kind == tokOp && t.text == "!") {
+		p.next()
+		operand, err := p.parseCompare()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != exprBool {
+			return nil, exprErrorAt(p.src, operand.pos(), "not 的操作数必须是条件（如 cpu_usage > 90），实际为数值")
+		}
+		return &unaryNode{op: "not", operand: operand, offset: t.pos}, nil
+	}
+
+	left, err := p.parseBinary(precSum)
+	if err != nil {
+		return nil, err
+	}
+	op := p.binaryOp()
+	if op == "" || exprPrecedence(op) != precCompare {
+		return left, nil
+	}
+	opTok := p.next()
+	right, err := p.parseBinary(precSum)
+	if err != nil {
+		return nil, err
+	}
+	node := &binaryNode{op: op, left: left, right: right, offset: opTok.pos}
+	if err := p.checkOperands(node); err != nil {
+		return nil, err
+	}
+	if next := p.binaryOp(); next != "" && exprPrecedence(next) == precCompare {
+		return nil, p.errorf(p.peek(), "比较运算不能连写，请使用 and 连接多个比较")
+	}
+	return node, nil
+}
+
+// checkOperands 检查二元运算两侧的类型
+func (p *exprParser) checkOperands(n *binaryNode) error {
+	want := exprNumber
+	if n.op == "and" || n.op == "or" {
+		want = exprBool
+	}
+	for _, operand := range []exprNode{n.left, n.right} {
+		if operand.typ() == want {
+			continue
+		}
+		if want == exprBool {
+			return exprErrorAt(p.src, operand.pos(), "%s 的操作数必须是条件（如 cpu_usage > 90），实际为数值", n.op)
+		}
+		return exprErrorAt(p.src, operand.pos(), "%s 的操作数必须是数值，实际为条件", n.op)
+	}
+	return nil
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	t := p.peek()
+	if t.kind == tokOp && t.text == "-" {
+		p.next()
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if operand.typ() != exprNumber {
+			return nil, exprErrorAt(p.src, operand.pos(), "负号的操作数必须是数值")
+		}
+		if num, ok := operand.(*numberNode); ok {
+			return &numberNode{value: -num.value, offset: t.pos}, nil
+		}
+		return &unaryNode{op: "-", operand: operand, offset: t.pos}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &numberNode{value: t.num, offset: t.pos}, nil
+
+	case tokDuration:
+		return nil, p.errorf(t, "时长 %s 只能作为函数参数使用", t.text)
+
+	case tokString:
+		return nil, p.errorf(t, "字符串只能作为函数参数使用")
+
+	case tokLParen:
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, p.errorf(closing, "缺少 ')'，实际为%s", closing.describe())
+		}
+		return node, nil
+
+	case tokIdent:
+		if keyword := exprKeyword(t.text); keyword != "" {
+			return nil, p.errorf(t, "'%s' 前缺少操作数", t.text)
+		}
+		if p.peek().kind == tokLParen {
+			return p.parseCall(t)
+		}
+		return &metricNode{fn: "last", metric: t.text, aggregation: "last", window: defaultConditionWindow, offset: t.pos}, nil
+
+	case tokEOF:
+		return nil, p.errorf(t, "表达式不完整，缺少操作数")
+	}
+	return nil, p.errorf(t, "意外的%s，期望数字、指标或函数", t.describe())
+}
+
+// parseCall 解析函数调用，指标函数转换为metricNode
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	fnName := strings.ToLower(name.text)
+	fn, ok := exprFunctions[fnName]
+	if !ok {
+		return nil, p.errorf(name, "未知的函数 %s", name.text)
+	}
+	if fn.fleet != "" {
+		if p.inFleet {
+			return nil, p.errorf(name, "聚合函数 %s 不能嵌套使用", fnName)
+		}
+		p.inFleet = true
+		defer func() { p.inFleet = false }()
+	}
+	p.next() // (
+
+	var args []exprToken
+	var argNodes []exprNode
+	if p.peek().kind != tokRParen {
+		for {
+			if fn.aggregation != "" {
+				// 指标函数的参数只能是指标名、字符串或时长
+				arg := p.next()
+				if arg.kind != tokIdent && arg.kind != tokString && arg.kind != tokDuration {
+					return nil, p.errorf(arg, "%s 的参数无效：%s", fnName, fn.usage)
+				}
+				args = append(args, arg)
+			} else {
+				node, err := p.parseOr()
+				if err != nil {
+					return nil, err
+				}
+				if fn.fleet == "" && node.typ() != exprNumber {
+					return nil, exprErrorAt(p.src, node.pos(), "%s 的参数必须是数值", fnName)
+				}
+				argNodes = append(argNodes, node)
+			}
+			if p.peek().kind != tokComma {
+				break
+			}
+			p.next()
+		}
+	}
+	if closing := p.next(); closing.kind != tokRParen {
+		return nil, p.errorf(closing, "函数 %s 缺少 ')'，实际为%s", fnName, closing.describe())
+	}
+
+	count := len(args) + len(argNodes)
+	if count < fn.minArgs || count > fn.maxArgs {
+		return nil, p.errorf(name, "函数 %s 的参数个数错误：%s", fnName, fn.usage)
+	}
+	if fn.fleet != "" {
+		return p.fleetCall(name, fnName, fn, argNodes)
+	}
+	if fn.aggregation == "" {
+		return &callNode{fn: fnName, args: argNodes, offset: name.pos}, nil
+	}
+	return p.metricCall(name, fnName, fn, args)
+}
+
+// fleetCall 校验跨VM聚合函数的参数：count的参数为条件，其余为数值；percentile的第二个参数为百分位数
+func (p *exprParser) fleetCall(name exprToken, fnName string, fn exprFunction, args []exprNode) (exprNode, error) {
+	node := &fleetNode{fn: fnName, agg: fn.fleet, arg: args[0], offset: name.pos}
+	if fn.fleet == "count" {
+		if node.arg.typ() != exprBool {
+			return nil, exprErrorAt(p.src, node.arg.pos(), "%s 的参数必须是条件（如 cpu_usage > 90），实际为数值", fnName)
+		}
+		return node, nil
+	}
+	if node.arg.typ() != exprNumber {
+		return nil, exprErrorAt(p.src, node.arg.pos(), "%s 的参数必须是数值，实际为条件", fnName)
+	}
+	if fn.fleet == "percentile" {
+		q, ok := args[1].(*numberNode)
+		if !ok || q.value <= 0 || q.value > 100 {
+			return nil, exprErrorAt(p.src, args[1].pos(), "%s 的百分位数必须是 (0, 100] 之间的数字", fnName)
+		}
+		node.percentile = q.value
+	}
+	return node, nil
+}
+
+// metricCall 校验指标函数的参数：第一个为指标，anomaly可指定算法，最后为时长
+func (p *exprParser) metricCall(name exprToken, fnName string, fn exprFunction, args []exprToken) (exprNode, error) {
+	metric := args[0]
+	if metric.kind == tokDuration || (metric.kind == tokIdent && exprKeyword(metric.text) != "") || metric.text == "" {
+		return nil, p.errorf(metric, "%s 的第一个参数必须是指标名", fnName)
+	}
+
+	node := &metricNode{fn: fnName, metric: metric.text, aggregation: fn.aggregation, window: defaultConditionWindow, explicit: true, offset: name.pos}
+	rest := args[1:]
+
+	if fnName == "anomaly" {
+		node.algorithm = AnomalyAlgoZScore
+		if len(rest) > 0 && rest[0].kind != tokDuration {
+			if !IsValidAnomalyAlgorithm(rest[0].text) {
+				return nil, p.errorf(rest[0], "未知的异常检测算法 %s（支持 zscore、mad、ewma、seasonal）", rest[0].text)
+			}
+			node.algorithm = rest[0].text
+			rest = rest[1:]
+		}
+	}
+
+	if len(rest) > 0 {
+		window := rest[0]
+		if window.kind != tokDuration || len(rest) > 1 {
+			return nil, p.errorf(window, "%s 的参数无效：%s", fnName, fn.usage)
+		}
+		if window.num < 1 {
+			return nil, p.errorf(window, "时间窗口至少为1秒")
+		}
+		node.window = time.Duration(window.num) * time.Second
+	}
+	return node, nil
+}
+
+// ========== 表达式 ==========
+
+// Expression 已解析的规则表达式
+type Expression struct {
+	root exprNode
+}
+
+// ParseExpression 解析规则表达式，表达式结果必须是条件（布尔值）
+func ParseExpression(src string) (*Expression, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, &ExprError{Pos: 1, Message: "表达式不能为空"}
+	}
+
+	tokens, err := lexExpression(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{src: src, tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		if t.kind == tokRParen {
+			return nil, p.errorf(t, "多余的 ')'")
+		}
+		return nil, p.errorf(t, "意外的%s，期望运算符或表达式结尾", t.describe())
+	}
+	if root.typ() != exprBool {
+		return nil, &ExprError{Pos: 1, Message: "表达式的结果必须是条件（如 cpu_usage > 90），实际为数值"}
+	}
+	return &Expression{root: root}, nil
+}
+
+// String 返回规范化的表达式文本
+func (x *Expression) String() string {
+	return x.root.String()
+}
+
+// Metrics 返回表达式引用的指标（按出现顺序去重）
+func (x *Expression) Metrics() []string {
+	var metrics []string
+	seen := map[string]bool{}
+	walkExpr(x.root, func(n exprNode) {
+		if m, ok := n.(*metricNode); ok && !seen[m.metric] {
+			seen[m.metric] = true
+			metrics = append(metrics, m.metric)
+		}
+	})
+	return metrics
+}
+
+// IsAggregate 表达式是否使用了跨VM聚合函数
+func (x *Expression) IsAggregate() bool {
+	aggregate := false
+	walkExpr(x.root, func(n exprNode) {
+		if _, ok := n.(*fleetNode); ok {
+			aggregate = true
+		}
+	})
+	return aggregate
+}
+
+// CheckEvaluationMode 检查表达式与规则评估模式是否匹配：聚合模式下指标只能在fleet_*函数内使用，
+// 逐VM模式下不能使用fleet_*函数
+func (x *Expression) CheckEvaluationMode(mode string) error {
+	if mode != models.EvaluationModeAggregate {
+		if x.IsAggregate() {
+			return &ExprError{Pos: 1, Message: "fleet_* 聚合函数只能用于聚合评估模式"}
+		}
+		return nil
+	}
+
+	if !x.IsAggregate() {
+		return &ExprError{Pos: 1, Message: "聚合评估模式的表达式必须使用 fleet_* 聚合函数（如 fleet_avg(cpu_usage) > 80）"}
+	}
+	if metric := metricOutsideFleet(x.root); metric != nil {
+		return &ExprError{Pos: 1, Message: fmt.Sprintf("聚合评估模式下指标 %s 必须在 fleet_* 聚合函数内使用", metric.metric)}
+	}
+	return nil
+}
+
+// metricOutsideFleet 返回不在跨VM聚合函数内的第一个指标节点
+func metricOutsideFleet(n exprNode) *metricNode {
+	switch n := n.(type) {
+	case *metricNode:
+		return n
+	case *callNode:
+		for _, arg := range n.args {
+			if m := metricOutsideFleet(arg); m != nil {
+				return m
+			}
+		}
+	case *unaryNode:
+		return metricOutsideFleet(n.operand)
+	case *binaryNode:
+		if m := metricOutsideFleet(n.left); m != nil {
+			return m
+		}
+		return metricOutsideFleet(n.right)
+	}
+	return nil
+}
+
+// metricRefs 返回表达式中的全部指标取值节点
+func (x *Expression) metricRefs() []*metricNode {
+	var refs []*metricNode
+	walkExpr(x.root, func(n exprNode) {
+		if m, ok := n.(*metricNode); ok {
+			refs = append(refs, m)
+		}
+	})
+	return refs
+}
+
+// walkExpr 前序遍历语法树
+func walkExpr(n exprNode, fn func(exprNode)) {
+	fn(n)
+	switch n := n.(type) {
+	case *callNode:
+		for _, arg := range n.args {
+			walkExpr(arg, fn)
+		}
+	case *fleetNode:
+		walkExpr(n.arg, fn)
+	case *unaryNode:
+		walkExpr(n.operand, fn)
+	case *binaryNode:
+		walkExpr(n.left, fn)
+		walkExpr(n.right, fn)
+	}
+}
+
+// ExpressionFromConditions 将条件列表按条件逻辑翻译为表达式
+func ExpressionFromConditions(conditions []models.AlertCondition, logic string) (*Expression, error) {
+	if len(conditions) == 0 {
+		return nil, fmt.Errorf("条件不能为空")
+	}
+	if logic != "and" && logic != "or" {
+		return nil, fmt.Errorf("未知的条件逻辑: %s", logic)
+	}
+
+	var root exprNode
+	for _, cond := range conditions {
+		op := cond.Operator
+		if op == "=" {
+			op = "=="
+		}
+		if exprPrecedence(op) != precCompare && cond.Type != ConditionTypeAbsent {
+			return nil, fmt.Errorf("条件 %s 的运算符无效: %s", cond.Metric, cond.Operator)
+		}
+
+		metric := &metricNode{fn: "last", metric: cond.Metric, aggregation: "last", window: defaultConditionWindow, explicit: true}
+		if cond.Duration > 0 {
+			metric.window = time.Duration(cond.Duration) * time.Second
+		}
+
+		var node exprNode
+		if cond.Type == ConditionTypeAbsent {
+			// 无数据条件本身就是条件，忽略运算符与阈值
+			metric.fn, metric.aggregation = "absent", "absent"
+			node = metric
+		} else if cond.Type == ConditionTypeAnomaly {
+			metric.fn, metric.aggregation, metric.algorithm = "anomaly", "anomaly", AnomalyAlgoZScore
+			if cond.Algorithm != nil && *cond.Algorithm != "" {
+				metric.algorithm = *cond.Algorithm
+			}
+		} else if cond.Aggregation != "" {
+			if fn, ok := exprFunctions[cond.Aggregation]; !ok || fn.aggregation != cond.Aggregation || fn.aggregation == "anomaly" || fn.aggregation == "absent" {
+				return nil, fmt.Errorf("条件 %s 的聚合方式无效: %s", cond.Metric, cond.Aggregation)
+			}
+			metric.fn, metric.aggregation = cond.Aggregation, cond.Aggregation
+		}
+
+		if node == nil {
+			node = &binaryNode{op: op, left: metric, right: &numberNode{value: cond.Threshold}}
+		}
+		if root == nil {
+			root = node
+		} else {
+			root = &binaryNode{op: logic, left: root, right: node}
+		}
+	}
+
+	// 重新解析以保证与手写表达式完全一致（包括位置信息）
+	return ParseExpression(root.String())
+}
+
+// ========== 求值 ==========
+
+// ExprSource 表达式求值的数据来源（已绑定VM与评估时间）
+type ExprSource interface {
+	// Aggregate 返回指标在窗口内按aggregation（last/avg/max/min/sum/first）聚合的值，无数据时返回ErrNoData
+	Aggregate(metric, aggregation string, window time.Duration) (*MetricData, error)
+	// Anomaly 返回指标最新异常得分的绝对值，无数据时返回ErrNoData
+	Anomaly(metric, algorithm string, window time.Duration) (*MetricData, error)
+}
+
+// ExprMatch 触发告警的比较（表达式中首个成立的比较）
+type ExprMatch struct {
+	Metric    string
+	Value     float64
+	Timestamp time.Time
+	Condition models.AlertCondition
+
+	// 聚合评估模式下比较中的跨VM聚合结果
+	Members      int               // 参与聚合（有数据）的VM数量
+	Contributors []ExprContributor // 参与聚合的VM（count时为满足条件的VM），按取值降序
+}
+
+// ExprFleetMember 聚合评估模式下参与聚合的VM及其数据来源
+type ExprFleetMember struct {
+	VMID   uuid.UUID
+	VMName string
+	Source ExprSource
+}
+
+// ExprContributor 参与跨VM聚合的VM及其取值
+type ExprContributor struct {
+	VMID   uuid.UUID `json:"vmId"`
+	VMName string    `json:"vmName"`
+	Value  float64   `json:"value"`
+}
+
+// maxFleetContributors 每个聚合结果最多记录的VM数量
+const maxFleetContributors = 100
+
+// fleetResult 跨VM聚合的结果
+type fleetResult struct {
+	value        exprValue
+	members      int
+	contributors []ExprContributor
+}
+
+// exprValue 求值结果，known为false表示缺少数据无法确定
+type exprValue struct {
+	num   float64
+	truth bool
+	known bool
+	ts    time.Time
+	cause *ExprMatch // 决定该布尔结果的比较（或absent条件），随not、and、or向上传递
+}
+
+type exprEvaluator struct {
+	source ExprSource
+	cache  map[string]exprValue
+
+	// 聚合评估模式
+	members      []ExprFleetMember
+	memberCaches []map[string]exprValue
+	fleet        map[*fleetNode]*fleetResult
+}
+
+// Evaluate 对表达式求值。缺少数据的部分视为未知，其余部分足以决定结果时忽略；
+// 否则返回ErrNoData。结果为true时返回触发的比较。
+func (x *Expression) Evaluate(source ExprSource) (bool, *ExprMatch, error) {
+	return x.evaluate(&exprEvaluator{source: source, cache: map[string]exprValue{}})
+}
+
+// EvaluateFleet 在聚合评估模式下对表达式求值：fleet_*函数对每个成员VM求参数的值后聚合。
+// 没有任何成员有数据时聚合结果未知，其余规则与Evaluate一致。
+func (x *Expression) EvaluateFleet(members []ExprFleetMember) (bool, *ExprMatch, error) {
+	return x.evaluate(newFleetEvaluator(members))
+}
+
+func newFleetEvaluator(members []ExprFleetMember) *exprEvaluator {
+	ev := &exprEvaluator{
+		cache:        map[string]exprValue{},
+		members:      members,
+		memberCaches: make([]map[string]exprValue, len(members)),
+		fleet:        map[*fleetNode]*fleetResult{},
+	}
+	for i := range ev.memberCaches {
+		ev.memberCaches[i] = map[string]exprValue{}
+	}
+	return ev
+}
+
+// ExprComparison 表达式中一个比较（或absent条件）在评估时的取值，缺少数据的一侧为空
+type ExprComparison struct {
+	Expression string   `json:"expression"`
+	Left       *float64 `json:"left,omitempty"`
+	Operator   string   `json:"operator,omitempty"`
+	Right      *float64 `json:"right,omitempty"`
+	Result     *bool    `json:"result"` // 为空表示缺少数据无法确定
+}
+
+// Explain 计算表达式中每个比较的取值（不短路），用于记录告警发生时全部条件的状态
+func (x *Expression) Explain(source ExprSource) ([]ExprComparison, error) {
+	return x.explain(&exprEvaluator{source: source, cache: map[string]exprValue{}})
+}
+
+// ExplainFleet 聚合评估模式下计算表达式中每个比较的取值（fleet_*函数内部的比较不单独列出）
+func (x *Expression) ExplainFleet(members []ExprFleetMember) ([]ExprComparison, error) {
+	return x.explain(newFleetEvaluator(members))
+}
+
+func (x *Expression) explain(ev *exprEvaluator) ([]ExprComparison, error) {
+	comparisons := []ExprComparison{}
+	var visit func(n exprNode) error
+	visit = func(n exprNode) error {
+		switch n := n.(type) {
+		case *metricNode:
+			if n.fn != "absent" {
+				return nil
+			}
+			value, err := ev.eval(n)
+			if err != nil {
+				return err
+			}
+			comparisons = append(comparisons, ExprComparison{Expression: n.String(), Result: &value.truth})
+		case *unaryNode:
+			return visit(n.operand)
+		case *binaryNode:
+			if exprPrecedence(n.op) != precCompare {
+				if err := visit(n.left); err != nil {
+					return err
+				}
+				return visit(n.right)
+			}
+			left, err := ev.eval(n.left)
+			if err != nil {
+				return err
+			}
+			right, err := ev.eval(n.right)
+			if err != nil {
+				return err
+			}
+			comparison := ExprComparison{Expression: n.String(), Operator: n.op}
+			if left.known {
+				comparison.Left = &left.num
+			}
+			if right.known {
+				comparison.Right = &right.num
+			}
+			if left.known && right.known {
+				result := compareValues(left.num, n.op, right.num)
+				comparison.Result = &result
+			}
+			comparisons = append(comparisons, comparison)
+		}
+		return nil
+	}
+	if err := visit(x.root); err != nil {
+		return nil, err
+	}
+	return comparisons, nil
+}
+
+func (x *Expression) evaluate(ev *exprEvaluator) (bool, *ExprMatch, error) {
+	result, err := ev.eval(x.root)
+	if err != nil {
+		return false, nil, err
+	}
+	if !result.known {
+		return false, nil, ErrNoData
+	}
+	if !result.truth {
+		return false, nil, nil
+	}
+	return true, result.cause, nil
+}
+
+func (ev *exprEvaluator) eval(n exprNode) (exprValue, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return exprValue{num: n.value, known: true}, nil
+
+	case *metricNode:
+		if n.fn == "absent" {
+			return ev.evalAbsent(n)
+		}
+		return ev.fetch(n)
+
+	case *fleetNode:
+		result, err := ev.evalFleet(n)
+		if err != nil {
+			return exprValue{}, err
+		}
+		return result.value, nil
+
+	case *callNode:
+		arg, err := ev.eval(n.args[0])
+		if err != nil || !arg.known {
+			return arg, err
+		}
+		arg.num = math.Abs(arg.num) // 目前只有abs
+		return arg, nil
+
+	case *unaryNode:
+		operand, err := ev.eval(n.operand)
+		if err != nil || !operand.known {
+			return operand, err
+		}
+		if n.op == "not" {
+			operand.truth = !operand.truth
+		} else {
+			operand.num = -operand.num
+		}
+		return operand, nil
+
+	case *binaryNode:
+		switch n.op {
+		case "and", "or":
+			return ev.evalLogic(n)
+		}
+		left, err := ev.eval(n.left)
+		if err != nil {
+			return exprValue{}, err
+		}
+		right, err := ev.eval(n.right)
+		if err != nil {
+			return exprValue{}, err
+		}
+		if !left.known || !right.known {
+			return exprValue{}, nil
+		}
+		ts := left.ts
+		if right.ts.After(ts) {
+			ts = right.ts
+		}
+		if exprPrecedence(n.op) == precCompare {
+			truth := compareValues(left.num, n.op, right.num)
+			return exprValue{truth: truth, known: true, ts: ts, cause: ev.compareMatch(n, left, right, ts)}, nil
+		}
+		value, ok := arithmetic(left.num, n.op, right.num)
+		return exprValue{num: value, known: ok, ts: ts}, nil
+	}
+	return exprValue{}, fmt.Errorf("未知的表达式节点 %T", n)
+}
+
+// evalLogic 三值逻辑：一侧已能决定结果时忽略另一侧是否缺少数据。
+// 结果的触发依据取决定结果的一侧，两侧共同决定时取左侧
+func (ev *exprEvaluator) evalLogic(n *binaryNode) (exprValue, error) {
+	decisive := n.op == "or" // and遇到false、or遇到true即可决定结果
+
+	left, err := ev.eval(n.left)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if left.known && left.truth == decisive {
+		return left, nil
+	}
+	right, err := ev.eval(n.right)
+	if err != nil {
+		return exprValue{}, err
+	}
+	if right.known && right.truth == decisive {
+		return right, nil
+	}
+	if left.known && right.known {
+		return exprValue{truth: !decisive, known: true, cause: left.cause}, nil
+	}
+	return exprValue{}, nil
+}
+
+// compareMatch 生成比较的取值，作为告警的指标数据
+func (ev *exprEvaluator) compareMatch(n *binaryNode, left, right exprValue, ts time.Time) *ExprMatch {
+	match := &ExprMatch{Value: left.num, Timestamp: ts}
+	match.Condition = models.AlertCondition{Operator: n.op, Threshold: right.num, Type: ConditionTypeThreshold}
+	if metric := firstMetric(n.left); metric != nil {
+		match.Metric = metric.metric
+		match.Condition.Metric = metric.metric
+		match.Condition.Aggregation = metric.aggregation
+		match.Condition.Duration = int(metric.window / time.Second)
+		if metric.fn == "anomaly" {
+			match.Condition.Type = ConditionTypeAnomaly
+			algorithm := metric.algorithm
+			match.Condition.Algorithm = &algorithm
+		}
+	} else if metric := firstMetric(n.right); metric != nil {
+		match.Metric = metric.metric
+		match.Condition.Metric = metric.metric
+	}
+	if fleet := firstFleet(n); fleet != nil {
+		result := ev.fleet[fleet]
+		match.Condition.Aggregation = fleet.fn
+		match.Members = result.members
+		match.Contributors = result.contributors
+	}
+	return match
+}
+
+// evalAbsent 窗口内没有数据时成立，成立时记录为触发的比较（指标为AbsentMetric）
+func (ev *exprEvaluator) evalAbsent(n *metricNode) (exprValue, error) {
+	value, err := ev.fetch(&metricNode{fn: "last", metric: n.metric, aggregation: "last", window: n.window})
+	if err != nil {
+		return exprValue{}, err
+	}
+	match := &ExprMatch{Metric: AbsentMetric, Timestamp: value.ts}
+	match.Condition = models.AlertCondition{
+		Metric:   n.metric,
+		Duration: int(n.window / time.Second),
+		Type:     ConditionTypeAbsent,
+	}
+	return exprValue{truth: !value.known, known: true, ts: value.ts, cause: match}, nil
+}
+
+// firstFleet 返回子树中第一个跨VM聚合节点
+func firstFleet(n exprNode) *fleetNode {
+	var found *fleetNode
+	walkExpr(n, func(node exprNode) {
+		if f, ok := node.(*fleetNode); ok && found == nil {
+			found = f
+		}
+	})
+	return found
+}
+
+// evalFleet 对每个成员VM求参数的值后聚合，同一节点只计算一次
+func (ev *exprEvaluator) evalFleet(n *fleetNode) (*fleetResult, error) {
+	if ev.members == nil {
+		return nil, fmt.Errorf("%s 只能用于聚合评估模式", n.fn)
+	}
+	if result, ok := ev.fleet[n]; ok {
+		return result, nil
+	}
+
+	result := &fleetResult{contributors: []ExprContributor{}}
+	var values []float64
+	var ts time.Time
+	count := 0
+	for i, member := range ev.members {
+		sub := &exprEvaluator{source: member.Source, cache: ev.memberCaches[i]}
+		value, err := sub.eval(n.arg)
+		if err != nil {
+			return nil, fmt.Errorf("VM %s: %w", member.VMName, err)
+		}
+		if !value.known {
+			continue
+		}
+		result.members++
+		if value.ts.After(ts) {
+			ts = value.ts
+		}
+
+		contributor := ExprContributor{VMID: member.VMID, VMName: member.VMName, Value: value.num}
+		if n.agg == "count" {
+			if !value.truth {
+				continue
+			}
+			count++
+			contributor.Value = 0
+			if value.cause != nil {
+				contributor.Value = value.cause.Value
+			}
+		} else {
+			values = append(values, value.num)
+		}
+		result.contributors = append(result.contributors, contributor)
+	}
+
+	sort.SliceStable(result.contributors, func(i, j int) bool {
+		return result.contributors[i].Value > result.contributors[j].Value
+	})
+	if len(result.contributors) > maxFleetContributors {
+		result.contributors = result.contributors[:maxFleetContributors]
+	}
+
+	if result.members > 0 {
+		result.value = exprValue{known: true, ts: ts}
+		if n.agg == "count" {
+			result.value.num = float64(count)
+		} else {
+			result.value.num = fleetAggregate(values, n.agg, n.percentile)
+		}
+	}
+	ev.fleet[n] = result
+	return result, nil
+}
+
+// fleetAggregate 聚合各VM的取值，percentile使用线性插值
+func fleetAggregate(values []float64, agg string, percentile float64) float64 {
+	switch agg {
+	case "max":
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max
+	case "min":
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min
+	case "percentile":
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		rank := percentile / 100 * float64(len(sorted)-1)
+		lower := int(math.Floor(rank))
+		upper := int(math.Ceil(rank))
+		return sorted[lower] + (sorted[upper]-sorted[lower])*(rank-float64(lower))
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	if agg == "avg" {
+		return sum / float64(len(values))
+	}
+	return sum
+}
+
+// firstMetric 返回子树中第一个指标节点
+func firstMetric(n exprNode) *metricNode {
+	var found *metricNode
+	walkExpr(n, func(node exprNode) {
+		if m, ok := node.(*metricNode); ok && found == nil {
+			found = m
+		}
+	})
+	return found
+}
+
+// fetch 获取指标值，同一次求值中相同的取值只查询一次
+func (ev *exprEvaluator) fetch(n *metricNode) (exprValue, error) {
+	key := n.aggregation + "|" + n.algorithm + "|" + n.metric + "|" + n.window.String()
+	if value, ok := ev.cache[key]; ok {
+		return value, nil
+	}
+
+	value, err := ev.fetchUncached(n)
+	if errors.Is(err, ErrNoData) {
+		value, err = exprValue{}, nil
+	}
+	if err != nil {
+		return exprValue{}, fmt.Errorf("获取指标 %s 失败: %w", n.metric, err)
+	}
+	ev.cache[key] = value
+	return value, nil
+}
+
+func (ev *exprEvaluator) fetchUncached(n *metricNode) (exprValue, error) {
+	switch n.aggregation {
+	case "anomaly":
+		data, err := ev.source.Anomaly(n.metric, n.algorithm, n.window)
+		if err != nil {
+			return exprValue{}, err
+		}
+		return exprValue{num: data.Value, known: true, ts: data.Timestamp}, nil
+
+	case "rate", "delta":
+		first, err := ev.source.Aggregate(n.metric, "first", n.window)
+		if err != nil {
+			return exprValue{}, err
+		}
+		last, err := ev.source.Aggregate(n.metric, "last", n.window)
+		if err != nil {
+			return exprValue{}, err
+		}
+		value := last.Value - first.Value
+		if n.aggregation == "rate" {
+			// 窗口内只有一个点时无法计算变化率
+			elapsed := last.Timestamp.Sub(first.Timestamp).Seconds()
+			if elapsed <= 0 {
+				return exprValue{}, ErrNoData
+			}
+			value /= elapsed
+		}
+		return exprValue{num: value, known: true, ts: last.Timestamp}, nil
+
+	default:
+		data, err := ev.source.Aggregate(n.metric, n.aggregation, n.window)
+		if err != nil {
+			return exprValue{}, err
+		}
+		return exprValue{num: data.Value, known: true, ts: data.Timestamp}, nil
+	}
+}
+
+// compareValues 比较两个数值
+func compareValues(left float64, op string, right float64) bool {
+	switch op {
+	case ">":
+		return left > right
+	case ">=":
+		return left >= right
+	case "<":
+		return left < right
+	case "<=":
+		return left <= right
+	case "==":
+		return left == right
+	case "!=":
+		return left != right
+	}
+	return false
+}
+
+// arithmetic 四则运算，除数为零时结果未知
+func arithmetic(left float64, op string, right float64) (float64, bool) {
+	switch op {
+	case "+":
+		return left + right, true
+	case "-":
+		return left - right, true
+	case "*":
+		return left * right, true
+	case "/":
+		if right == 0 {
+			return 0, false
+		}
+		return left / right, true
+	case "%":
+		if right == 0 {
+			return 0, false
+		}
+		return math.Mod(left, right), true
+	}
+	return 0, false
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeExprSource 按“聚合方式|指标”返回固定值，缺少的键视为无数据
+type fakeExprSource struct {
+	values map[string]MetricData
+	calls  int
+}
+
+func (s *fakeExprSource) Aggregate(metric, aggregation string, window time.Duration) (*MetricData, error) {
+	s.calls++
+	if data, ok := s.values[aggregation+"|"+metric]; ok {
+		return &data, nil
+	}
+	return nil, ErrNoData
+}
+
+func (s *fakeExprSource) Anomaly(metric, algorithm string, window time.Duration) (*MetricData, error) {
+	return s.Aggregate(metric, "anomaly:"+algorithm, window)
+}
+
+func TestParseExpression_Normalize(t *testing.T) {
+	cases := map[string]string{
+		"avg(cpu_usage, 5m) > 90 and memory_usage > 85 or rate(network_tx, 1m) > 1e8": "avg(cpu_usage, 5m) > 90 and memory_usage > 85 or rate(network_tx, 1m) > 1e+08",
+		"cpu_usage > 90 && (mem > 1 || disk > 2)":                                     "cpu_usage > 90 and (mem > 1 or disk > 2)",
+		"NOT cpu_usage = 0":                                    "not (cpu_usage == 0)",
+		"(disk_used / disk_total) * 100 >= 95":                 "disk_used / disk_total * 100 >= 95",
+		"a - (b - c) > -5":                                     "a - (b - c) > -5",
+		"anomaly(cpu_usage) > 3 and abs(delta(temp, 1h)) > 10": "anomaly(cpu_usage, \"zscore\", 5m) > 3 and abs(delta(temp, 1h)) > 10",
+		"max(\"disk-io\", 90s) > 1":                            "max(\"disk-io\", 90s) > 1",
+	}
+	for src, want := range cases {
+		expr, err := ParseExpression(src)
+		require.NoError(t, err, src)
+		assert.Equal(t, want, expr.String(), src)
+
+		// 规范化结果可以再次解析且保持不变
+		again, err := ParseExpression(expr.String())
+		require.NoError(t, err, src)
+		assert.Equal(t, want, again.String(), src)
+	}
+
+	expr, err := ParseExpression("avg(cpu_usage, 5m) > 90 and cpu_usage > 80 or rate(network_tx, 1m) > 1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"cpu_usage", "network_tx"}, expr.Metrics())
+}
+
+func TestParseExpression_Errors(t *testing.T) {
+	cases := []struct {
+		src     string
+		pos     int
+		message string
+	}{
+		{"", 1, "表达式不能为空"},
+		{"cpu_usage > ", 13, "缺少操作数"},
+		{"cpu_usage + 1", 1, "结果必须是条件"},
+		{"avg(cpu_usage, 5m > 90", 19, "缺少 ')'"},
+		{"(cpu_usage > 90", 16, "缺少 ')'"},
+		{"cpu_usage > 90)", 15, "多余的 ')'"},
+		{"median(cpu_usage) > 1", 1, "未知的函数 median"},
+		{"rate(cpu_usage) > 1", 1, "参数个数错误"},
+		{"anomaly(cpu_usage, \"foo\") > 1", 20, "未知的异常检测算法"},
+		{"cpu_usage > 90 and 5", 20, "必须是条件"},
+		{"cpu_usage > 90 > 1", 16, "不能连写"},
+		{"cpu_usage > 5x", 14, "时长单位"},
+		{"cpu_usage > 90 # x", 16, "无法识别的字符"},
+		{"avg(cpu_usage, 5m) > 5m", 22, "只能作为函数参数"},
+	}
+	for _, tc := range cases {
+		_, err := ParseExpression(tc.src)
+		require.Error(t, err, tc.src)
+		exprErr, ok := err.(*ExprError)
+		require.True(t, ok, tc.src)
+		assert.Equal(t, tc.pos, exprErr.Pos, tc.src)
+		assert.Contains(t, exprErr.Message, tc.message, tc.src)
+	}
+}
+
+func TestExpressionFromConditions(t *testing.T) {
+	mad := "mad"
+	conditions := []models.AlertCondition{
+		{Metric: "cpu_usage", Operator: ">", Threshold: 90, Duration: 300, Aggregation: "avg"},
+		{Metric: "memory_usage", Operator: "=", Threshold: 85.5},
+		{Metric: "disk_io", Operator: ">=", Threshold: 3, Duration: 600, Type: ConditionTypeAnomaly, Algorithm: &mad},
+	}
+
+	expr, err := ExpressionFromConditions(conditions, "or")
+	require.NoError(t, err)
+	assert.Equal(t, `avg(cpu_usage, 5m) > 90 or last(memory_usage, 5m) == 85.5 or anomaly(disk_io, "mad", 10m) >= 3`, expr.String())
+
+	_, err = ExpressionFromConditions(conditions, "xor")
+	assert.Error(t, err)
+	_, err = ExpressionFromConditions(nil, "and")
+	assert.Error(t, err)
+}
+
+func TestExpression_Evaluate(t *testing.T) {
+	now := time.Now()
+	source := &fakeExprSource{values: map[string]MetricData{
+		"avg|cpu_usage":     {Value: 95, Timestamp: now},
+		"last|cpu_usage":    {Value: 80, Timestamp: now},
+		"last|memory_usage": {Value: 70, Timestamp: now},
+		"first|network_tx":  {Value: 1000, Timestamp: now.Add(-50 * time.Second)},
+		"last|network_tx":   {Value: 6000, Timestamp: now},
+		"last|disk_used":    {Value: 96, Timestamp: now},
+		"last|disk_total":   {Value: 100, Timestamp: now},
+		"last|zero":         {Value: 0, Timestamp: now},
+	}}
+
+	evaluate := func(src string) (bool, *ExprMatch, error) {
+		expr, err := ParseExpression(src)
+		require.NoError(t, err, src)
+		return expr.Evaluate(source)
+	}
+
+	triggered, match, err := evaluate("memory_usage > 85 or avg(cpu_usage, 5m) > 90")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.Equal(t, "cpu_usage", match.Metric)
+	assert.Equal(t, 95.0, match.Value)
+	assert.Equal(t, "avg", match.Condition.Aggregation)
+	assert.Equal(t, 300, match.Condition.Duration)
+	assert.Equal(t, 90.0, match.Condition.Threshold)
+
+	// rate = (6000 - 1000) / 50s
+	triggered, match, err = evaluate("rate(network_tx, 1m) > 99")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.InDelta(t, 100, match.Value, 1e-9)
+
+	triggered, _, err = evaluate("disk_used / disk_total * 100 >= 95 and not (cpu_usage > 90)")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+
+	// 缺少数据：其余部分能决定结果时忽略，否则返回ErrNoData
+	triggered, _, err = evaluate("cpu_usage > 90 and missing > 1")
+	require.NoError(t, err)
+	assert.False(t, triggered)
+	_, _, err = evaluate("cpu_usage > 50 and missing > 1")
+	assert.ErrorIs(t, err, ErrNoData)
+	triggered, _, err = evaluate("missing > 1 or cpu_usage > 50")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	_, _, err = evaluate("cpu_usage / zero > 1")
+	assert.ErrorIs(t, err, ErrNoData)
+
+	// 条件取反触发时以被取反的比较作为触发依据
+	triggered, match, err = evaluate("not (memory_usage > 85)")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.Equal(t, "memory_usage", match.Metric)
+
+	// 触发依据取决定结果的分支：不成立的and分支与not内部成立的比较不作为依据
+	triggered, match, err = evaluate("(cpu_usage > 50 and memory_usage > 85) or disk_used > 90")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.Equal(t, "disk_used", match.Metric)
+	triggered, match, err = evaluate("not (cpu_usage > 50 and memory_usage > 85) and disk_used > 90")
+	require.NoError(t, err)
+	assert.True(t, triggered)
+	assert.Equal(t, "memory_usage", match.Metric)
+	assert.Equal(t, 70.0, match.Value)
+
+	// 同一次求值中相同的取值只查询一次
+	source.calls = 0
+	_, _, err = evaluate("cpu_usage > 1 and cpu_usage < 100 and cpu_usage != 50")
+	require.NoError(t, err)
+	assert.Equal(t, 1, source.calls)
+}
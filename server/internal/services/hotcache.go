@@ -0,0 +1,584 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sort"
+	"sync"
+	"time"
+)
+
+// errBitStreamEOF 位流读取越界
+var errBitStreamEOF = errors.New("位流已读取完毕")
+
+// bitWriter 按位写入（高位在前）
+type bitWriter struct {
+	buf  []byte
+	used uint8 // 最后一个字节已使用的位数，0 表示需要新字节
+}
+
+// writeBit 写入一位
+func (w *bitWriter) writeBit(bit bool) {
+	if w.used == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	if bit {
+		w.buf[len(w.buf)-1] |= 1 << (7 - w.used)
+	}
+	w.used = (w.used + 1) % 8
+}
+
+// writeBits 写入 v 的低 n 位
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(v>>uint(i)&1 == 1)
+	}
+}
+
+// bitReader 按位读取
+type bitReader struct {
+	buf []byte
+	pos int
+}
+
+// readBit 读取一位
+func (r *bitReader) readBit() (bool, error) {
+	if r.pos >= len(r.buf)*8 {
+		return false, errBitStreamEOF
+	}
+	bit := r.buf[r.pos/8]>>(7-uint(r.pos%8))&1 == 1
+	r.pos++
+	return bit, nil
+}
+
+// readBits 读取 n 位
+func (r *bitReader) readBits(n int) (uint64, error) {
+	var v uint64
+	for i := 0; i < n; i++ {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		v <<= 1
+		if bit {
+			v |= 1
+		}
+	}
+	return v, nil
+}
+
+// dodBuckets 时间戳二阶差分编码区间：前缀位数、数据位数
+var dodBuckets = []struct {
+	prefix     uint64
+	prefixBits int
+	valueBits  int
+}{
+	{prefix: 0x2, prefixBits: 2, valueBits: 7},
+	{prefix: 0x6, prefixBits: 3, valueBits: 9},
+	{prefix: 0xe, prefixBits: 4, valueBits: 12},
+	{prefix: 0x1e, prefixBits: 5, valueBits: 20},
+}
+
+// gorillaBlock Gorilla压缩数据块：时间戳二阶差分 + 浮点值异或编码
+// 时间戳精度为微秒，与PostgreSQL一致。
+type gorillaBlock struct {
+	w         bitWriter
+	count     int
+	firstT    int64
+	lastT     int64
+	lastDelta int64
+	lastValue uint64
+	leading   uint8
+	trailing  uint8
+	tags      map[string]string
+}
+
+// newGorillaBlock 创建数据块，块内数据点共享同一组标签
+func newGorillaBlock(tags map[string]string) *gorillaBlock {
+	return &gorillaBlock{tags: tags, leading: 0xff}
+}
+
+// append 追加数据点，调用方保证时间戳严格递增
+func (b *gorillaBlock) append(t int64, v float64) {
+	vb := math.Float64bits(v)
+
+	if b.count == 0 {
+		b.w.writeBits(uint64(t), 64)
+		b.w.writeBits(vb, 64)
+		b.firstT, b.lastT, b.lastValue = t, t, vb
+		b.count = 1
+		return
+	}
+
+	delta := t - b.lastT
+	b.writeDod(delta - b.lastDelta)
+	b.lastDelta, b.lastT = delta, t
+
+	b.writeValue(vb)
+	b.lastValue = vb
+	b.count++
+}
+
+// writeDod 写入时间戳二阶差分
+func (b *gorillaBlock) writeDod(dod int64) {
+	if dod == 0 {
+		b.w.writeBit(false)
+		return
+	}
+	for _, bucket := range dodBuckets {
+		limit := int64(1) << uint(bucket.valueBits-1)
+		if dod >= -(limit-1) && dod <= limit {
+			b.w.writeBits(bucket.prefix, bucket.prefixBits)
+			b.w.writeBits(uint64(dod)&(1<<uint(bucket.valueBits)-1), bucket.valueBits)
+			return
+		}
+	}
+	b.w.writeBits(0x1f, 5)
+	b.w.writeBits(uint64(dod), 64)
+}
+
+// writeValue 写入与上一个值的异或结果
+func (b *gorillaBlock) writeValue(vb uint64) {
+	xor := vb ^ b.lastValue
+	if xor == 0 {
+		b.w.writeBit(false)
+		return
+	}
+	b.w.writeBit(true)
+
+	leading := uint8(bits.LeadingZeros64(xor))
+	trailing := uint8(bits.TrailingZeros64(xor))
+	if leading > 31 {
+		leading = 31
+	}
+
+	if b.leading != 0xff && leading >= b.leading && trailing >= b.trailing {
+		// 有效位落在上一个窗口内，复用窗口
+		b.w.writeBit(false)
+		b.w.writeBits(xor>>b.trailing, 64-int(b.leading)-int(b.trailing))
+		return
+	}
+
+	sig := 64 - int(leading) - int(trailing)
+	b.w.writeBit(true)
+	b.w.writeBits(uint64(leading), 5)
+	b.w.writeBits(uint64(sig-1), 6)
+	b.w.writeBits(xor>>trailing, sig)
+	b.leading, b.trailing = leading, trailing
+}
+
+// size 压缩后字节数
+func (b *gorillaBlock) size() int {
+	return len(b.w.buf)
+}
+
+// iterate 依次解码数据点，fn 返回 false 时停止
+func (b *gorillaBlock) iterate(fn func(t int64, v float64) bool) error {
+	if b.count == 0 {
+		return nil
+	}
+
+	r := &bitReader{buf: b.w.buf}
+	t64, err := r.readBits(64)
+	if err != nil {
+		return err
+	}
+	vb, err := r.readBits(64)
+	if err != nil {
+		return err
+	}
+	t := int64(t64)
+	if !fn(t, math.Float64frombits(vb)) {
+		return nil
+	}
+
+	var delta int64
+	leading, trailing := uint8(0), uint8(0)
+	for i := 1; i < b.count; i++ {
+		dod, err := readDod(r)
+		if err != nil {
+			return err
+		}
+		delta += dod
+		t += delta
+
+		bit, err := r.readBit()
+		if err != nil {
+			return err
+		}
+		if bit {
+			newWindow, err := r.readBit()
+			if err != nil {
+				return err
+			}
+			if newWindow {
+				l, err := r.readBits(5)
+				if err != nil {
+					return err
+				}
+				sig, err := r.readBits(6)
+				if err != nil {
+					return err
+				}
+				leading = uint8(l)
+				trailing = uint8(64 - int(leading) - int(sig+1))
+			}
+			xor, err := r.readBits(64 - int(leading) - int(trailing))
+			if err != nil {
+				return err
+			}
+			vb ^= xor << trailing
+		}
+
+		if !fn(t, math.Float64frombits(vb)) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// readDod 读取时间戳二阶差分
+func readDod(r *bitReader) (int64, error) {
+	prefixBits := 0
+	for prefixBits < 5 {
+		bit, err := r.readBit()
+		if err != nil {
+			return 0, err
+		}
+		if !bit {
+			break
+		}
+		prefixBits++
+	}
+
+	if prefixBits == 0 {
+		return 0, nil
+	}
+	if prefixBits == 5 {
+		v, err := r.readBits(64)
+		return int64(v), err
+	}
+
+	n := dodBuckets[prefixBits-1].valueBits
+	v, err := r.readBits(n)
+	if err != nil {
+		return 0, err
+	}
+	// 符号扩展：编码区间为 [-(2^(n-1)-1), 2^(n-1)]
+	if v > 1<<uint(n-1) {
+		return int64(v) - 1<<uint(n), nil
+	}
+	return int64(v), nil
+}
+
+// hotSeries 单个序列的压缩环形缓冲
+type hotSeries struct {
+	mutex       sync.RWMutex
+	blocks      []*gorillaBlock
+	latest      MetricData
+	coveredFrom time.Time // 该时间之后的数据全部在缓存中
+	dropped     int64     // 因乱序被丢弃的数据点
+}
+
+// HotCacheStats 热缓存统计
+type HotCacheStats struct {
+	Series        int     `json:"series"`
+	Points        int64   `json:"points"`
+	Blocks        int     `json:"blocks"`
+	Bytes         int64   `json:"bytes"`
+	BytesPerPoint float64 `json:"bytesPerPoint"`
+	Dropped       int64   `json:"dropped"`
+}
+
+// HotCache 进程内热数据缓存：每个序列保留最近一段时间的Gorilla压缩数据
+// 写入时同步更新，覆盖范围外的查询由调用方回退到数据库。
+// 缓存只包含本进程写入的数据点，Covers也只反映本进程的写入；多实例部署时
+// 其他实例写入的数据不在缓存中，查询需回退到数据库（见TimeSeriesService.SetHotCacheGuard）。
+type HotCache struct {
+	retention      time.Duration
+	pointsPerBlock int
+
+	mutex     sync.RWMutex
+	series    map[SeriesKey]*hotSeries
+	lastSweep time.Time
+}
+
+// NewHotCache 创建热数据缓存
+func NewHotCache(retention time.Duration) *HotCache {
+	if retention <= 0 {
+		retention = 3 * time.Hour
+	}
+	return &HotCache{
+		retention:      retention,
+		pointsPerBlock: 120,
+		series:         make(map[SeriesKey]*hotSeries),
+	}
+}
+
+// Retention 缓存保留时长
+func (c *HotCache) Retention() time.Duration {
+	return c.retention
+}
+
+// Append 写入数据点（同一序列时间戳需递增，乱序点被丢弃并缩小序列的覆盖范围）
+func (c *HotCache) Append(points []MetricData) {
+	now := time.Now()
+
+	sorted := make([]MetricData, len(points))
+	copy(sorted, points)
+	sortMetricData(sorted)
+
+	for _, p := range sorted {
+		key := SeriesKey{VMID: p.VMID, Metric: p.Metric}
+
+		c.mutex.RLock()
+		s, ok := c.series[key]
+		c.mutex.RUnlock()
+		if !ok {
+			c.mutex.Lock()
+			if s, ok = c.series[key]; !ok {
+				s = &hotSeries{coveredFrom: p.Timestamp}
+				c.series[key] = s
+			}
+			c.mutex.Unlock()
+		}
+
+		c.appendToSeries(s, p, now)
+	}
+
+	c.sweep(now)
+}
+
+// sweep 定期移除已停止写入且数据全部过期的序列
+func (c *HotCache) sweep(now time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if now.Sub(c.lastSweep) < c.retention/4 {
+		return
+	}
+	c.lastSweep = now
+
+	cutoff := now.Add(-c.retention)
+	for key, s := range c.series {
+		s.mutex.RLock()
+		expired := s.latest.Timestamp.Before(cutoff)
+		s.mutex.RUnlock()
+		if expired {
+			delete(c.series, key)
+		}
+	}
+}
+
+// appendToSeries 写入单个数据点并淘汰过期数据块
+func (c *HotCache) appendToSeries(s *hotSeries, p MetricData, now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	t := p.Timestamp.UnixMicro()
+	var block *gorillaBlock
+	if n := len(s.blocks); n > 0 {
+		block = s.blocks[n-1]
+		if t <= block.lastT {
+			// 乱序点已写入数据库但不在缓存中，此前的数据不再视为完整覆盖，相应查询回退到数据库
+			s.dropped++
+			if covered := time.UnixMicro(block.lastT + 1); covered.After(s.coveredFrom) {
+				s.coveredFrom = covered
+			}
+			return
+		}
+		if block.count >= c.pointsPerBlock || !sameTags(block.tags, p.Tags) {
+			block = nil
+		}
+	}
+	if block == nil {
+		block = newGorillaBlock(p.Tags)
+		s.blocks = append(s.blocks, block)
+	}
+
+	block.append(t, p.Value)
+	s.latest = MetricData{VMID: p.VMID, Metric: p.Metric, Value: p.Value, Timestamp: time.UnixMicro(t), Tags: p.Tags}
+
+	// 淘汰整块过期数据，保留至少一个数据块
+	cutoff := now.Add(-c.retention).UnixMicro()
+	evict := 0
+	for evict < len(s.blocks)-1 && s.blocks[evict].lastT < cutoff {
+		s.coveredFrom = time.UnixMicro(s.blocks[evict].lastT + 1)
+		evict++
+	}
+	if evict > 0 {
+		s.blocks = append(s.blocks[:0:0], s.blocks[evict:]...)
+	}
+}
+
+// lookup 查找序列
+func (c *HotCache) lookup(vmID, metric string) (*hotSeries, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	s, ok := c.series[SeriesKey{VMID: vmID, Metric: metric}]
+	return s, ok
+}
+
+// Latest 获取序列最新数据点
+func (c *HotCache) Latest(vmID, metric string) (MetricData, bool) {
+	s, ok := c.lookup(vmID, metric)
+	if !ok {
+		return MetricData{}, false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.latest, true
+}
+
+// LatestForVM 获取VM所有已缓存指标的最新数据点
+func (c *HotCache) LatestForVM(vmID string) []MetricData {
+	c.mutex.RLock()
+	matched := []*hotSeries{}
+	for key, s := range c.series {
+		if key.VMID == vmID {
+			matched = append(matched, s)
+		}
+	}
+	c.mutex.RUnlock()
+
+	result := make([]MetricData, 0, len(matched))
+	for _, s := range matched {
+		s.mutex.RLock()
+		result = append(result, s.latest)
+		s.mutex.RUnlock()
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Metric < result[j].Metric })
+	return result
+}
+
+// Covers 判断序列从 start 开始的数据是否全部在缓存中
+func (c *HotCache) Covers(vmID, metric string, start time.Time) bool {
+	s, ok := c.lookup(vmID, metric)
+	if !ok {
+		return false
+	}
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return !start.Before(s.coveredFrom)
+}
+
+// Window 读取 [start, end] 范围内的数据点；范围超出缓存覆盖时返回 false
+func (c *HotCache) Window(vmID, metric string, start, end time.Time) ([]MetricData, bool) {
+	s, ok := c.lookup(vmID, metric)
+	if !ok {
+		return nil, false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	if start.Before(s.coveredFrom) {
+		return nil, false
+	}
+
+	from, to := start.UnixMicro(), end.UnixMicro()
+	result := []MetricData{}
+	for _, block := range s.blocks {
+		if block.lastT < from || block.firstT > to {
+			continue
+		}
+		tags := block.tags
+		block.iterate(func(t int64, v float64) bool {
+			if t > to {
+				return false
+			}
+			if t >= from {
+				result = append(result, MetricData{VMID: vmID, Metric: metric, Value: v, Timestamp: time.UnixMicro(t), Tags: tags})
+			}
+			return true
+		})
+	}
+	return result, true
+}
+
+// LastN 读取 before 之前最近 n 个数据点（升序）；缓存中不足 n 个时返回 false
+func (c *HotCache) LastN(vmID, metric string, before time.Time, n int) ([]MetricData, bool) {
+	s, ok := c.lookup(vmID, metric)
+	if !ok {
+		return nil, false
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	limit := before.UnixMicro()
+	result := []MetricData{}
+	for i := len(s.blocks) - 1; i >= 0 && len(result) < n; i-- {
+		block := s.blocks[i]
+		if block.firstT >= limit {
+			continue
+		}
+		points := []MetricData{}
+		tags := block.tags
+		block.iterate(func(t int64, v float64) bool {
+			if t >= limit {
+				return false
+			}
+			points = append(points, MetricData{VMID: vmID, Metric: metric, Value: v, Timestamp: time.UnixMicro(t), Tags: tags})
+			return true
+		})
+		result = append(points, result...)
+	}
+
+	if len(result) < n {
+		return nil, false
+	}
+	return result[len(result)-n:], true
+}
+
+// Stats 缓存统计
+func (c *HotCache) Stats() HotCacheStats {
+	c.mutex.RLock()
+	all := make([]*hotSeries, 0, len(c.series))
+	for _, s := range c.series {
+		all = append(all, s)
+	}
+	c.mutex.RUnlock()
+
+	stats := HotCacheStats{Series: len(all)}
+	for _, s := range all {
+		s.mutex.RLock()
+		for _, b := range s.blocks {
+			stats.Points += int64(b.count)
+			stats.Bytes += int64(b.size())
+			stats.Blocks++
+		}
+		stats.Dropped += s.dropped
+		s.mutex.RUnlock()
+	}
+	if stats.Points > 0 {
+		stats.BytesPerPoint = float64(stats.Bytes) / float64(stats.Points)
+	}
+	return stats
+}
+
+// sameTags 比较两组标签是否一致
+func sameTags(a, b map[string]string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for k, v := range a {
+		if bv, ok := b[k]; !ok || bv != v {
+			return false
+		}
+	}
+	return true
+}
+
+// sortMetricData 按时间升序排序（时间相同按VM、指标排序）
+func sortMetricData(data []MetricData) {
+	sort.SliceStable(data, func(i, j int) bool {
+		if !data[i].Timestamp.Equal(data[j].Timestamp) {
+			return data[i].Timestamp.Before(data[j].Timestamp)
+		}
+		if data[i].VMID != data[j].VMID {
+			return data[i].VMID < data[j].VMID
+		}
+		return data[i].Metric < data[j].Metric
+	})
+}
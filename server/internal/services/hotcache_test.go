@@ -0,0 +1,214 @@
+package services
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func TestGorillaBlock_RoundTrip(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	block := newGorillaBlock(nil)
+
+	type point struct {
+		t int64
+		v float64
+	}
+	points := []point{}
+	ts := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC).UnixMicro()
+	value := 50.0
+	for i := 0; i < 500; i++ {
+		// 30秒间隔加上随机抖动，偶尔出现长时间中断
+		ts += 30_000_000 + rng.Int63n(20_000) - 10_000
+		if i%97 == 0 {
+			ts += 3600_000_000
+		}
+		switch i % 4 {
+		case 0:
+			value += rng.NormFloat64()
+		case 1:
+			// 值不变
+		case 2:
+			value = math.Round(value*100) / 100
+		case 3:
+			value = -value * 1e6
+		}
+		points = append(points, point{ts, value})
+		block.append(ts, value)
+	}
+
+	decoded := []point{}
+	require.NoError(t, block.iterate(func(t int64, v float64) bool {
+		decoded = append(decoded, point{t, v})
+		return true
+	}))
+	assert.Equal(t, points, decoded)
+	assert.Less(t, block.size(), len(points)*16)
+}
+
+func TestGorillaBlock_Compression(t *testing.T) {
+	block := newGorillaBlock(nil)
+	ts := time.Now().UnixMicro()
+	for i := 0; i < 120; i++ {
+		block.append(ts+int64(i)*30_000_000, 42.5)
+	}
+	// 规则间隔且值不变时，除首个差分外每个点约2位
+	assert.Less(t, block.size(), 64)
+}
+
+func TestHotCache_WindowAndCoverage(t *testing.T) {
+	cache := NewHotCache(time.Hour)
+	now := time.Now().Truncate(time.Second)
+	start := now.Add(-30 * time.Minute)
+
+	points := []MetricData{}
+	for i := 0; i < 60; i++ {
+		points = append(points, MetricData{VMID: "vm-1", Metric: "cpu_usage", Value: float64(i), Timestamp: start.Add(time.Duration(i) * 30 * time.Second)})
+	}
+	cache.Append(points)
+
+	latest, ok := cache.Latest("vm-1", "cpu_usage")
+	require.True(t, ok)
+	assert.Equal(t, 59.0, latest.Value)
+
+	window, ok := cache.Window("vm-1", "cpu_usage", start.Add(5*time.Minute), start.Add(10*time.Minute))
+	require.True(t, ok)
+	assert.Len(t, window, 11)
+	assert.Equal(t, 10.0, window[0].Value)
+	assert.True(t, window[0].Timestamp.Equal(start.Add(5*time.Minute)))
+
+	// 早于首个缓存点的范围需要回退数据库
+	_, ok = cache.Window("vm-1", "cpu_usage", start.Add(-time.Minute), now)
+	assert.False(t, ok)
+	_, ok = cache.Window("vm-2", "cpu_usage", start, now)
+	assert.False(t, ok)
+
+	last, ok := cache.LastN("vm-1", "cpu_usage", start.Add(10*time.Minute), 5)
+	require.True(t, ok)
+	assert.Equal(t, []float64{15, 16, 17, 18, 19}, []float64{last[0].Value, last[1].Value, last[2].Value, last[3].Value, last[4].Value})
+
+	_, ok = cache.LastN("vm-1", "cpu_usage", start.Add(time.Minute), 5)
+	assert.False(t, ok)
+}
+
+func TestHotCache_OutOfOrderAndTags(t *testing.T) {
+	cache := NewHotCache(time.Hour)
+	now := time.Now().Truncate(time.Second)
+
+	cache.Append([]MetricData{
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 1, Timestamp: now.Add(-2 * time.Minute), Tags: map[string]string{"host": "a"}},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 2, Timestamp: now.Add(-time.Minute), Tags: map[string]string{"host": "b"}},
+	})
+
+	window, ok := cache.Window("vm-1", "cpu_usage", now.Add(-2*time.Minute), now)
+	require.True(t, ok)
+	require.Len(t, window, 2)
+	assert.Equal(t, "a", window[0].Tags["host"])
+	assert.Equal(t, "b", window[1].Tags["host"])
+
+	// 丢弃乱序点后，包含该点的范围不再由缓存提供
+	cache.Append([]MetricData{
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 3, Timestamp: now.Add(-90 * time.Second)},
+	})
+	_, ok = cache.Window("vm-1", "cpu_usage", now.Add(-2*time.Minute), now)
+	assert.False(t, ok)
+	assert.False(t, cache.Covers("vm-1", "cpu_usage", now.Add(-time.Minute)))
+	cache.Append([]MetricData{{VMID: "vm-1", Metric: "cpu_usage", Value: 4, Timestamp: now}})
+	window, ok = cache.Window("vm-1", "cpu_usage", now.Add(-30*time.Second), now)
+	require.True(t, ok)
+	assert.Len(t, window, 1)
+
+	stats := cache.Stats()
+	assert.Equal(t, int64(1), stats.Dropped)
+	assert.Equal(t, 3, stats.Blocks)
+}
+
+func TestHotCache_Eviction(t *testing.T) {
+	cache := NewHotCache(time.Hour)
+	cache.pointsPerBlock = 10
+	now := time.Now().Truncate(time.Second)
+	old := now.Add(-3 * time.Hour)
+
+	points := []MetricData{}
+	for i := 0; i < 20; i++ {
+		points = append(points, MetricData{VMID: "vm-1", Metric: "cpu_usage", Value: float64(i), Timestamp: old.Add(time.Duration(i) * time.Minute)})
+	}
+	points = append(points, MetricData{VMID: "vm-1", Metric: "cpu_usage", Value: 99, Timestamp: now})
+	cache.Append(points)
+
+	assert.Equal(t, 1, cache.Stats().Blocks)
+	assert.False(t, cache.Covers("vm-1", "cpu_usage", old))
+	assert.True(t, cache.Covers("vm-1", "cpu_usage", old.Add(20*time.Minute)))
+}
+
+func TestTimeSeriesService_HotPath(t *testing.T) {
+	// 未配置数据库，命中热缓存时不会访问数据库
+	service := NewTimeSeriesService(nil)
+	service.SetHotCache(NewHotCache(time.Hour))
+	now := time.Now().Truncate(time.Second)
+
+	service.hot.Append([]MetricData{
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 10, Timestamp: now.Add(-2 * time.Minute)},
+		{VMID: "vm-2", Metric: "cpu_usage", Value: 20, Timestamp: now.Add(-90 * time.Second)},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 30, Timestamp: now.Add(-time.Minute)},
+	})
+
+	data, err := service.QueryMetrics([]string{"vm-1", "vm-2"}, []string{"cpu_usage"}, now.Add(-90*time.Second), now)
+	require.NoError(t, err)
+	require.Len(t, data, 2)
+	assert.Equal(t, "vm-2", data[0].VMID)
+	assert.Equal(t, 30.0, data[1].Value)
+
+	latest, err := service.GetLatestMetrics([]string{"vm-1"}, []string{"cpu_usage"})
+	require.NoError(t, err)
+	assert.Equal(t, 30.0, latest["vm-1"]["cpu_usage"])
+
+	lastN, err := service.QueryLastN("vm-1", "cpu_usage", now, 2)
+	require.NoError(t, err)
+	assert.Len(t, lastN, 2)
+}
+
+func TestTimeSeriesService_LatestPointsMergesDatabase(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE metric_records (
+		id TEXT PRIMARY KEY, vm_id TEXT, metric TEXT, value REAL, timestamp DATETIME, tags TEXT, created_at DATETIME
+	)`).Error)
+	now := time.Now().Truncate(time.Second)
+	insert := func(vmID, metric string, value float64, at time.Time) {
+		require.NoError(t, db.Exec("INSERT INTO metric_records (id, vm_id, metric, value, timestamp) VALUES (?, ?, ?, ?, ?)",
+			uuid.New(), vmID, metric, value, at).Error)
+	}
+	insert("vm-1", "cpu_usage", 10, now.Add(-time.Minute))
+	insert("vm-1", "memory_usage", 40, now.Add(-2*time.Hour))
+	insert("vm-2", "cpu_usage", 20, now.Add(-time.Minute))
+
+	service := NewTimeSeriesService(db)
+	service.SetHotCache(NewHotCache(time.Hour))
+	service.hot.Append([]MetricData{{VMID: "vm-1", Metric: "cpu_usage", Value: 30, Timestamp: now}})
+
+	// 缓存中只有vm-1的cpu_usage，其余序列从数据库补齐
+	points, err := service.GetLatestPoints([]string{"vm-1", "vm-2"})
+	require.NoError(t, err)
+	require.Len(t, points["vm-1"], 2)
+	assert.Equal(t, "cpu_usage", points["vm-1"][0].Metric)
+	assert.Equal(t, 30.0, points["vm-1"][0].Value)
+	assert.Equal(t, "memory_usage", points["vm-1"][1].Metric)
+	assert.Equal(t, 40.0, points["vm-1"][1].Value)
+	require.Len(t, points["vm-2"], 1)
+	assert.Equal(t, 20.0, points["vm-2"][0].Value)
+
+	// 缓存不可读（多实例部署）时全部读取数据库
+	service.SetHotCacheGuard(func() bool { return false })
+	points, err = service.GetLatestPoints([]string{"vm-1"})
+	require.NoError(t, err)
+	require.Len(t, points["vm-1"], 2)
+	assert.Equal(t, 10.0, points["vm-1"][0].Value)
+}
@@ -0,0 +1,616 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 事件管理的错误
+var (
+	ErrIncidentNotFound = errors.New("事件不存在")
+	ErrInvalidIncident  = errors.New("事件操作无效")
+)
+
+// IncidentGroupKeys 支持的自动归并维度
+var IncidentGroupKeys = []string{"rule", "cluster", "host", "datacenter", "vm"}
+
+// defaultIncidentWindow 默认时间窗口：同键事件的最近一条告警在该时间内才归入
+const defaultIncidentWindow = 30 * time.Minute
+
+// incidentOpenStatuses 可以继续归入告警的事件状态
+var incidentOpenStatuses = []string{models.IncidentStatusOpen, models.IncidentStatusInvestigating, models.IncidentStatusMitigated}
+
+// severityRank 告警级别的高低
+var severityRank = map[string]int{"low": 1, "medium": 2, "high": 3, "critical": 4}
+
+// IncidentAttachment 告警归入事件的结果，Notify表示按事件通知时需要发送（新事件或级别升高）
+type IncidentAttachment struct {
+	Incident *models.Incident
+	Created  bool
+	Notify   bool
+}
+
+// IncidentUpdate 事件的人工修改，字段为空表示不修改；CommanderID为uuid.Nil表示取消指挥官
+type IncidentUpdate struct {
+	Title       *string
+	Status      *string
+	CommanderID *uuid.UUID
+	Postmortem  *string
+}
+
+// IncidentDetail 事件详情：成员告警与时间线
+type IncidentDetail struct {
+	models.Incident
+	Alerts   []models.AlertRecord   `json:"alerts"`
+	Timeline []models.IncidentEvent `json:"timeline"`
+}
+
+// IncidentService 事件管理：按归并维度自动将告警归入事件，支持人工合并与拆分
+type IncidentService struct {
+	db                *gorm.DB
+	groupBy           []string
+	window            time.Duration
+	notifyPerIncident bool
+	attachMutex       sync.Mutex // 避免同一实例并发创建同键的事件
+}
+
+// NewIncidentService 创建事件服务，默认按集群归并
+func NewIncidentService(db *gorm.DB) *IncidentService {
+	return &IncidentService{
+		db:      db,
+		groupBy: []string{"cluster"},
+		window:  defaultIncidentWindow,
+	}
+}
+
+// SetGrouping 设置自动归并维度与时间窗口，维度为空表示不自动归并
+func (s *IncidentService) SetGrouping(keys []string, window time.Duration) error {
+	for _, key := range keys {
+		if !containsString(IncidentGroupKeys, key) {
+			return fmt.Errorf("不支持的归并维度: %s", key)
+		}
+	}
+	s.groupBy = keys
+	if window > 0 {
+		s.window = window
+	}
+	return nil
+}
+
+// SetNotifyPerIncident 设置是否按事件（而非每条告警）发送通知
+func (s *IncidentService) SetNotifyPerIncident(enabled bool) {
+	s.notifyPerIncident = enabled
+}
+
+// NotifyPerIncident 是否按事件发送通知
+func (s *IncidentService) NotifyPerIncident() bool {
+	return s.notifyPerIncident && len(s.groupBy) > 0
+}
+
+// incidentGroupKey 按归并维度计算告警的归并键与标题中的范围描述。某个维度取值为空时
+// （如VM未归属集群、聚合告警没有拓扑信息）改为按VM单独归并，避免无关告警因空值合并为同一事件；
+// 没有VM时返回空键，不归并
+func incidentGroupKey(keys []string, rule models.AlertRule, vm models.VM) (string, string) {
+	parts := make([]string, 0, len(keys))
+	labels := []string{}
+	label := func(id, name *string) string {
+		if name != nil && *name != "" {
+			return *name
+		}
+		return getStringValue(id)
+	}
+	for _, key := range keys {
+		var value, desc string
+		switch key {
+		case "rule":
+			value = rule.ID.String()
+		case "cluster":
+			value, desc = getStringValue(vm.ClusterID), label(vm.ClusterID, vm.ClusterName)
+		case "host":
+			value, desc = getStringValue(vm.HostID), label(vm.HostID, vm.HostName)
+		case "datacenter":
+			value, desc = getStringValue(vm.DatacenterID), label(vm.DatacenterID, vm.DatacenterName)
+		case "vm":
+			if vm.ID != uuid.Nil {
+				value, desc = vm.ID.String(), vm.Name
+			}
+		}
+		if value == "" {
+			if vm.ID == uuid.Nil {
+				return "", ""
+			}
+			return "vm=" + vm.ID.String(), vm.Name
+		}
+		parts = append(parts, key+"="+value)
+		if desc != "" {
+			labels = append(labels, desc)
+		}
+	}
+	return strings.Join(parts, "|"), strings.Join(labels, ", ")
+}
+
+// Attach 将新告警归入事件：存在同键、未结束且最近一条告警在时间窗口内的事件时加入，否则创建新事件
+func (s *IncidentService) Attach(alert *models.AlertRecord, rule models.AlertRule, vm models.VM) (*IncidentAttachment, error) {
+	if len(s.groupBy) == 0 {
+		return nil, nil
+	}
+	key, scope := incidentGroupKey(s.groupBy, rule, vm)
+	if key == "" {
+		return nil, nil
+	}
+
+	s.attachMutex.Lock()
+	defer s.attachMutex.Unlock()
+
+	result := &IncidentAttachment{}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var candidates []models.Incident
+		if err := tx.Where("group_key = ? AND status IN ? AND last_alert_at >= ?", key, incidentOpenStatuses, alert.TriggeredAt.Add(-s.window)).
+			Order("last_alert_at DESC").Limit(1).Find(&candidates).Error; err != nil {
+			return fmt.Errorf("查询事件失败: %w", err)
+		}
+
+		now := time.Now()
+		if len(candidates) == 0 {
+			title := rule.Name
+			if scope != "" {
+				title += " - " + scope
+			}
+			incident := models.Incident{
+				ID:          uuid.New(),
+				Title:       title,
+				Severity:    alert.Severity,
+				Status:      models.IncidentStatusOpen,
+				GroupKey:    key,
+				AlertCount:  1,
+				StartedAt:   alert.TriggeredAt,
+				LastAlertAt: alert.TriggeredAt,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			}
+			if err := tx.Create(&incident).Error; err != nil {
+				return fmt.Errorf("创建事件失败: %w", err)
+			}
+			if err := recordIncidentEvent(tx, incident.ID, models.IncidentEventCreated, "事件由告警 "+alert.RuleName+" 触发", &alert.ID, AlertActor{}, map[string]interface{}{"groupKey": key}); err != nil {
+				return err
+			}
+			result.Incident, result.Created, result.Notify = &incident, true, true
+		} else {
+			incident := candidates[0]
+			updates := map[string]interface{}{
+				"alert_count":   gorm.Expr("alert_count + 1"),
+				"last_alert_at": alert.TriggeredAt,
+				"updated_at":    now,
+			}
+			if severityRank[alert.Severity] > severityRank[incident.Severity] {
+				updates["severity"] = alert.Severity
+				if err := recordIncidentEvent(tx, incident.ID, models.IncidentEventSeverity,
+					fmt.Sprintf("级别由 %s 升为 %s", incident.Severity, alert.Severity), &alert.ID, AlertActor{}, nil); err != nil {
+					return err
+				}
+				result.Notify = true
+			}
+			if err := tx.Model(&models.Incident{}).Where("id = ?", incident.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("更新事件失败: %w", err)
+			}
+			if err := tx.Where("id = ?", incident.ID).First(&incident).Error; err != nil {
+				return err
+			}
+			result.Incident = &incident
+		}
+
+		if err := recordIncidentEvent(tx, result.Incident.ID, models.IncidentEventAlertAdded, alertDescription(alert), &alert.ID, AlertActor{}, nil); err != nil {
+			return err
+		}
+		return tx.Model(&models.AlertRecord{}).Where("id = ?", alert.ID).Update("incident_id", result.Incident.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	alert.IncidentID = &result.Incident.ID
+	return result, nil
+}
+
+// Notify 以事件为单位发送通知：标题与级别取事件，正文说明事件包含的告警数与最新告警
+func (s *IncidentService) Notify(notifier *NotificationService, incident *models.Incident, alert models.AlertRecord, config models.JSONMap) {
+	record := alert
+	record.RuleName = "[事件] " + incident.Title
+	record.Severity = incident.Severity
+	summary := fmt.Sprintf("事件包含 %d 条告警，最新: %s %s", incident.AlertCount, alert.RuleName, getStringValue(alert.ConditionStr))
+	record.ConditionStr = &summary
+
+	results := notifier.SendAlert(context.Background(), record, config)
+	methods := make([]string, 0, len(results))
+	for _, result := range results {
+		if result.Success {
+			methods = append(methods, result.Method)
+		}
+	}
+	if err := recordIncidentEvent(s.db, incident.ID, models.IncidentEventNotified, "已发送事件通知", &alert.ID, AlertActor{}, map[string]interface{}{"methods": methods}); err != nil {
+		logger.Error("记录事件通知失败", zap.String("incident_id", incident.ID.String()), zap.Error(err))
+	}
+}
+
+// SyncAlertClosed 告警结束（解决或忽略）后，事件的全部告警都已结束时自动解决事件
+func (s *IncidentService) SyncAlertClosed(alert *models.AlertRecord) {
+	if alert.IncidentID == nil {
+		return
+	}
+	incidentID := *alert.IncidentID
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var open int64
+		if err := tx.Model(&models.AlertRecord{}).Where("incident_id = ? AND status IN ?", incidentID, models.AlertOpenStatuses).Count(&open).Error; err != nil {
+			return err
+		}
+		if open > 0 {
+			return nil
+		}
+		now := time.Now()
+		result := tx.Model(&models.Incident{}).Where("id = ? AND status IN ?", incidentID, incidentOpenStatuses).
+			Updates(map[string]interface{}{"status": models.IncidentStatusResolved, "resolved_at": now, "updated_at": now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return recordIncidentEvent(tx, incidentID, models.IncidentEventStatus, "全部告警已结束，事件自动解决", &alert.ID, AlertActor{},
+			map[string]interface{}{"status": models.IncidentStatusResolved})
+	})
+	if err != nil {
+		logger.Error("同步事件状态失败", zap.String("incident_id", incidentID.String()), zap.Error(err))
+	}
+}
+
+// List 分页查询事件，status为空时不筛选
+func (s *IncidentService) List(status string, page, pageSize int) ([]models.Incident, int64, error) {
+	query := s.db.Model(&models.Incident{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询事件失败: %w", err)
+	}
+	var incidents []models.Incident
+	if err := query.Order("last_alert_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&incidents).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询事件失败: %w", err)
+	}
+	return incidents, total, nil
+}
+
+// Get 获取事件详情
+func (s *IncidentService) Get(id uuid.UUID) (*IncidentDetail, error) {
+	incident, err := findIncident(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	detail := &IncidentDetail{Incident: *incident}
+	if err := s.db.Where("incident_id = ?", id).Order("triggered_at").Find(&detail.Alerts).Error; err != nil {
+		return nil, fmt.Errorf("查询事件告警失败: %w", err)
+	}
+	timeline, err := s.Timeline(id)
+	if err != nil {
+		return nil, err
+	}
+	detail.Timeline = timeline
+	return detail, nil
+}
+
+// Timeline 事件时间线，按时间顺序
+func (s *IncidentService) Timeline(id uuid.UUID) ([]models.IncidentEvent, error) {
+	var events []models.IncidentEvent
+	if err := s.db.Where("incident_id = ?", id).Order("created_at").Find(&events).Error; err != nil {
+		return nil, fmt.Errorf("查询事件时间线失败: %w", err)
+	}
+	return events, nil
+}
+
+// Update 修改事件标题、状态、指挥官或复盘记录，每项修改写入时间线
+func (s *IncidentService) Update(id uuid.UUID, update IncidentUpdate, actor AlertActor) (*models.Incident, error) {
+	var incident *models.Incident
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if incident, err = findIncident(tx, id); err != nil {
+			return err
+		}
+		if incident.Status == models.IncidentStatusMerged {
+			return fmt.Errorf("%w: 事件已合并", ErrInvalidIncident)
+		}
+
+		now := time.Now()
+		updates := map[string]interface{}{"updated_at": now}
+		type change struct {
+			kind, message string
+			details       map[string]interface{}
+		}
+		var changes []change
+
+		if update.Title != nil && *update.Title != incident.Title {
+			title := strings.TrimSpace(*update.Title)
+			if title == "" {
+				return fmt.Errorf("%w: 标题不能为空", ErrInvalidIncident)
+			}
+			updates["title"] = title
+			changes = append(changes, change{models.IncidentEventTitle, "标题改为 " + title, map[string]interface{}{"old": incident.Title, "new": title}})
+		}
+		if update.Status != nil && *update.Status != incident.Status {
+			status := *update.Status
+			if !containsString(incidentOpenStatuses, status) && status != models.IncidentStatusResolved {
+				return fmt.Errorf("%w: 无效的状态 %s", ErrInvalidIncident, status)
+			}
+			updates["status"] = status
+			if status == models.IncidentStatusResolved {
+				updates["resolved_at"] = now
+			} else {
+				updates["resolved_at"] = nil
+			}
+			changes = append(changes, change{models.IncidentEventStatus, fmt.Sprintf("状态由 %s 变更为 %s", incident.Status, status), map[string]interface{}{"old": incident.Status, "new": status}})
+		}
+		if update.CommanderID != nil {
+			if *update.CommanderID == uuid.Nil {
+				if incident.CommanderID != nil {
+					updates["commander_id"], updates["commander_name"] = nil, nil
+					changes = append(changes, change{models.IncidentEventCommander, "取消指挥官", nil})
+				}
+			} else if incident.CommanderID == nil || *incident.CommanderID != *update.CommanderID {
+				var users []models.User
+				if err := tx.Select("id, name").Where("id = ?", *update.CommanderID).Limit(1).Find(&users).Error; err != nil {
+					return fmt.Errorf("查询用户失败: %w", err)
+				}
+				if len(users) == 0 {
+					return ErrAssigneeNotFound
+				}
+				updates["commander_id"], updates["commander_name"] = users[0].ID, users[0].Name
+				changes = append(changes, change{models.IncidentEventCommander, "指挥官: " + users[0].Name, map[string]interface{}{"commanderId": users[0].ID}})
+			}
+		}
+		if update.Postmortem != nil && *update.Postmortem != getStringValue(incident.Postmortem) {
+			updates["postmortem"] = optionalString(*update.Postmortem)
+			changes = append(changes, change{models.IncidentEventPostmortem, "更新复盘记录", nil})
+		}
+
+		if len(changes) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.Incident{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+			return fmt.Errorf("更新事件失败: %w", err)
+		}
+		for _, c := range changes {
+			if err := recordIncidentEvent(tx, id, c.kind, c.message, nil, actor, c.details); err != nil {
+				return err
+			}
+		}
+		incident, err = findIncident(tx, id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return incident, nil
+}
+
+// Merge 将源事件的告警并入目标事件，源事件标记为已合并
+func (s *IncidentService) Merge(targetID uuid.UUID, sourceIDs []uuid.UUID, actor AlertActor) (*models.Incident, error) {
+	var target *models.Incident
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if target, err = findIncident(tx, targetID); err != nil {
+			return err
+		}
+		if target.Status == models.IncidentStatusMerged {
+			return fmt.Errorf("%w: 目标事件已合并到其他事件", ErrInvalidIncident)
+		}
+
+		now := time.Now()
+		merged := make([]string, 0, len(sourceIDs))
+		for _, sourceID := range sourceIDs {
+			if sourceID == targetID {
+				return fmt.Errorf("%w: 不能将事件合并到自身", ErrInvalidIncident)
+			}
+			source, err := findIncident(tx, sourceID)
+			if err != nil {
+				return err
+			}
+			if source.Status == models.IncidentStatusMerged {
+				return fmt.Errorf("%w: 事件 %s 已合并", ErrInvalidIncident, source.Title)
+			}
+
+			if err := tx.Model(&models.AlertRecord{}).Where("incident_id = ?", sourceID).Update("incident_id", targetID).Error; err != nil {
+				return fmt.Errorf("移动告警失败: %w", err)
+			}
+			if err := tx.Model(&models.Incident{}).Where("id = ?", sourceID).Updates(map[string]interface{}{
+				"status": models.IncidentStatusMerged, "merged_into": targetID, "alert_count": 0, "resolved_at": now, "updated_at": now,
+			}).Error; err != nil {
+				return fmt.Errorf("更新事件失败: %w", err)
+			}
+			if err := recordIncidentEvent(tx, sourceID, models.IncidentEventMerged, "合并到事件 "+target.Title, nil, actor,
+				map[string]interface{}{"targetId": targetID}); err != nil {
+				return err
+			}
+			merged = append(merged, source.Title)
+		}
+
+		if err := recordIncidentEvent(tx, targetID, models.IncidentEventMerged, "合并了事件 "+strings.Join(merged, ", "), nil, actor,
+			map[string]interface{}{"sourceIds": sourceIDs}); err != nil {
+			return err
+		}
+		if err := recomputeIncident(tx, targetID, actor); err != nil {
+			return err
+		}
+		target, err = findIncident(tx, targetID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return target, nil
+}
+
+// Split 将事件中的部分告警拆分为新事件。新事件不参与自动归并，避免后续告警在两个事件间摇摆
+func (s *IncidentService) Split(incidentID uuid.UUID, alertIDs []uuid.UUID, title string, actor AlertActor) (*models.Incident, error) {
+	var created models.Incident
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		source, err := findIncident(tx, incidentID)
+		if err != nil {
+			return err
+		}
+		if source.Status == models.IncidentStatusMerged {
+			return fmt.Errorf("%w: 事件已合并", ErrInvalidIncident)
+		}
+
+		var members, total int64
+		if err := tx.Model(&models.AlertRecord{}).Where("incident_id = ? AND id IN ?", incidentID, alertIDs).Count(&members).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.AlertRecord{}).Where("incident_id = ?", incidentID).Count(&total).Error; err != nil {
+			return err
+		}
+		if len(alertIDs) == 0 || members != int64(len(alertIDs)) {
+			return fmt.Errorf("%w: 拆分的告警不属于该事件", ErrInvalidIncident)
+		}
+		if members == total {
+			return fmt.Errorf("%w: 不能拆分事件的全部告警", ErrInvalidIncident)
+		}
+
+		if title = strings.TrimSpace(title); title == "" {
+			title = "拆分自: " + source.Title
+		}
+		now := time.Now()
+		created = models.Incident{
+			ID:          uuid.New(),
+			Title:       title,
+			Severity:    source.Severity,
+			Status:      models.IncidentStatusOpen,
+			StartedAt:   now,
+			LastAlertAt: now,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if err := tx.Create(&created).Error; err != nil {
+			return fmt.Errorf("创建事件失败: %w", err)
+		}
+		if err := tx.Model(&models.AlertRecord{}).Where("id IN ?", alertIDs).Update("incident_id", created.ID).Error; err != nil {
+			return fmt.Errorf("移动告警失败: %w", err)
+		}
+
+		details := map[string]interface{}{"alertIds": alertIDs}
+		if err := recordIncidentEvent(tx, incidentID, models.IncidentEventSplit, fmt.Sprintf("拆分 %d 条告警到事件 %s", len(alertIDs), title), nil, actor, details); err != nil {
+			return err
+		}
+		if err := recordIncidentEvent(tx, created.ID, models.IncidentEventCreated, "由事件 "+source.Title+" 拆分", nil, actor, map[string]interface{}{"sourceId": incidentID}); err != nil {
+			return err
+		}
+		if err := recomputeIncident(tx, incidentID, actor); err != nil {
+			return err
+		}
+		if err := recomputeIncident(tx, created.ID, actor); err != nil {
+			return err
+		}
+		return tx.Where("id = ?", created.ID).First(&created).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// recomputeIncident 根据成员告警重新计算事件的告警数、级别与起止时间
+func recomputeIncident(tx *gorm.DB, id uuid.UUID, actor AlertActor) error {
+	var alerts []models.AlertRecord
+	if err := tx.Select("id, severity, triggered_at").Where("incident_id = ?", id).Find(&alerts).Error; err != nil {
+		return fmt.Errorf("查询事件告警失败: %w", err)
+	}
+	incident, err := findIncident(tx, id)
+	if err != nil {
+		return err
+	}
+
+	updates := map[string]interface{}{"alert_count": len(alerts), "updated_at": time.Now()}
+	if len(alerts) > 0 {
+		severity, started, last := "", alerts[0].TriggeredAt, alerts[0].TriggeredAt
+		for _, alert := range alerts {
+			if severityRank[alert.Severity] > severityRank[severity] {
+				severity = alert.Severity
+			}
+			if alert.TriggeredAt.Before(started) {
+				started = alert.TriggeredAt
+			}
+			if alert.TriggeredAt.After(last) {
+				last = alert.TriggeredAt
+			}
+		}
+		updates["started_at"], updates["last_alert_at"] = started, last
+		if severity != incident.Severity {
+			updates["severity"] = severity
+			if err := recordIncidentEvent(tx, id, models.IncidentEventSeverity, fmt.Sprintf("级别由 %s 变更为 %s", incident.Severity, severity), nil, actor, nil); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Model(&models.Incident{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// findIncident 按ID查询事件
+func findIncident(tx *gorm.DB, id uuid.UUID) (*models.Incident, error) {
+	var incident models.Incident
+	if err := tx.Where("id = ?", id).First(&incident).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrIncidentNotFound
+		}
+		return nil, fmt.Errorf("查询事件失败: %w", err)
+	}
+	return &incident, nil
+}
+
+// recordIncidentEvent 在tx中写入一条事件时间线
+func recordIncidentEvent(tx *gorm.DB, incidentID uuid.UUID, kind, message string, alertID *uuid.UUID, actor AlertActor, details map[string]interface{}) error {
+	event := models.IncidentEvent{
+		ID:         uuid.New(),
+		IncidentID: incidentID,
+		Type:       kind,
+		Message:    message,
+		AlertID:    alertID,
+		ActorID:    actor.ID,
+		ActorName:  optionalString(actor.Name),
+		Details:    models.JSONMap(details),
+		CreatedAt:  time.Now(),
+	}
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("写入事件时间线失败: %w", err)
+	}
+	return nil
+}
+
+// alertDescription 时间线中的告警描述
+func alertDescription(alert *models.AlertRecord) string {
+	desc := alert.RuleName
+	if alert.VMName != nil && *alert.VMName != "" {
+		desc += " @ " + *alert.VMName
+	}
+	if alert.ConditionStr != nil {
+		desc += ": " + *alert.ConditionStr
+	}
+	return desc
+}
+
+// containsString 切片中是否包含s
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,178 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupIncidentTestDB(t *testing.T) *gorm.DB {
+	db := setupWorkflowTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE incidents (
+		id TEXT PRIMARY KEY, title TEXT, severity TEXT, status TEXT, group_key TEXT, alert_count INTEGER DEFAULT 0,
+		commander_id TEXT, commander_name TEXT, postmortem TEXT, merged_into TEXT, started_at DATETIME,
+		last_alert_at DATETIME, resolved_at DATETIME, created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE incident_events (
+		id TEXT PRIMARY KEY, incident_id TEXT, type TEXT, message TEXT, alert_id TEXT,
+		actor_id TEXT, actor_name TEXT, details TEXT, created_at DATETIME
+	)`).Error)
+	return db
+}
+
+func TestIncidentGroupKey(t *testing.T) {
+	cluster, clusterName, host := "domain-c1", "prod", "host-1"
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high"}
+	vm := models.VM{ID: uuid.New(), Name: "web-01", ClusterID: &cluster, ClusterName: &clusterName, HostID: &host}
+
+	key, scope := incidentGroupKey([]string{"cluster", "host"}, rule, vm)
+	assert.Equal(t, "cluster=domain-c1|host=host-1", key)
+	assert.Equal(t, "prod, host-1", scope)
+
+	other := models.VM{ID: uuid.New(), Name: "web-02", ClusterID: &cluster, HostID: &host}
+	otherKey, _ := incidentGroupKey([]string{"cluster", "host"}, rule, other)
+	assert.Equal(t, key, otherKey)
+
+	// 维度取值为空时按VM单独归并，没有VM时不归并
+	loneKey, loneScope := incidentGroupKey([]string{"cluster", "host"}, rule, models.VM{ID: other.ID, Name: "web-02", ClusterID: &cluster})
+	assert.Equal(t, "vm="+other.ID.String(), loneKey)
+	assert.Equal(t, "web-02", loneScope)
+	noneKey, _ := incidentGroupKey([]string{"cluster"}, rule, models.VM{})
+	assert.Empty(t, noneKey)
+
+	service := NewIncidentService(nil)
+	assert.Error(t, service.SetGrouping([]string{"cluster", "tag"}, 0))
+}
+
+func TestIncidentService_AttachAndNotify(t *testing.T) {
+	db := setupIncidentTestDB(t)
+	incidents := NewIncidentService(db)
+	require.NoError(t, incidents.SetGrouping([]string{"cluster"}, 10*time.Minute))
+
+	engine := NewAlertEngine(db, nil)
+	engine.SetIncidents(incidents)
+
+	c1, c2 := "c1", "c2"
+	create := func(severity string, cluster *string, at time.Time) *models.AlertRecord {
+		rule := models.AlertRule{ID: uuid.New(), Name: "rule-" + severity, Severity: severity}
+		require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+		metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+		alert, err := engine.createAlert(rule, models.VM{ID: uuid.New(), Name: "vm", ClusterID: cluster}, metric, nil)
+		require.NoError(t, err)
+		require.NoError(t, db.Model(&models.AlertRecord{}).Where("id = ?", alert.ID).Update("triggered_at", at).Error)
+		alert.TriggeredAt = at
+		return alert
+	}
+
+	now := time.Now()
+	a1 := create("medium", &c1, now)
+	a2 := create("critical", &c1, now)
+	a3 := create("high", &c2, now)
+	require.NotNil(t, a1.IncidentID)
+	assert.Equal(t, *a1.IncidentID, *a2.IncidentID)
+	assert.NotEqual(t, *a1.IncidentID, *a3.IncidentID)
+
+	// 级别取成员最高级别
+	detail, err := incidents.Get(*a1.IncidentID)
+	require.NoError(t, err)
+	assert.Equal(t, "critical", detail.Severity)
+	assert.Equal(t, 2, detail.AlertCount)
+	assert.Len(t, detail.Alerts, 2)
+	var types []string
+	for _, event := range detail.Timeline {
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{models.IncidentEventCreated, models.IncidentEventAlertAdded, models.IncidentEventSeverity, models.IncidentEventAlertAdded}, types)
+
+	// 超出时间窗口后开始新事件
+	require.NoError(t, db.Model(&models.Incident{}).Where("id = ?", *a1.IncidentID).Update("last_alert_at", now.Add(-time.Hour)).Error)
+	a4 := create("low", &c1, now)
+	assert.NotEqual(t, *a1.IncidentID, *a4.IncidentID)
+
+	// 全部告警结束后事件自动解决
+	workflow := NewAlertWorkflowService(db)
+	workflow.SetIncidents(incidents)
+	_, err = workflow.Transition(a1.ID, models.AlertStatusResolved, AlertActor{}, "")
+	require.NoError(t, err)
+	incident, err := findIncident(db, *a1.IncidentID)
+	require.NoError(t, err)
+	assert.Equal(t, models.IncidentStatusOpen, incident.Status)
+	_, err = workflow.Transition(a2.ID, models.AlertStatusIgnored, AlertActor{}, "")
+	require.NoError(t, err)
+	incident, err = findIncident(db, *a1.IncidentID)
+	require.NoError(t, err)
+	assert.Equal(t, models.IncidentStatusResolved, incident.Status)
+	assert.NotNil(t, incident.ResolvedAt)
+}
+
+func TestIncidentService_MergeSplitUpdate(t *testing.T) {
+	db := setupIncidentTestDB(t)
+	incidents := NewIncidentService(db)
+	require.NoError(t, incidents.SetGrouping([]string{"vm"}, 0))
+
+	var alerts []*models.AlertRecord
+	for i, severity := range []string{"low", "high", "medium"} {
+		alert := &models.AlertRecord{ID: uuid.New(), RuleID: uuid.New(), RuleName: "r", Metric: "cpu_usage", Severity: severity,
+			Status: models.AlertStatusActive, TriggeredAt: time.Now().Add(time.Duration(i) * time.Minute)}
+		require.NoError(t, db.Create(alert).Error)
+		_, err := incidents.Attach(alert, models.AlertRule{ID: alert.RuleID, Name: "r"}, models.VM{ID: uuid.New()})
+		require.NoError(t, err)
+		alerts = append(alerts, alert)
+	}
+
+	commanderID := uuid.New()
+	require.NoError(t, db.Exec("INSERT INTO users (id, username, name) VALUES (?, ?, ?)", commanderID, "carol", "Carol").Error)
+	actor := AlertActor{ID: &commanderID, Name: "Carol"}
+
+	target := *alerts[0].IncidentID
+	_, err := incidents.Merge(target, []uuid.UUID{target}, actor)
+	assert.ErrorIs(t, err, ErrInvalidIncident)
+
+	merged, err := incidents.Merge(target, []uuid.UUID{*alerts[1].IncidentID, *alerts[2].IncidentID}, actor)
+	require.NoError(t, err)
+	assert.Equal(t, 3, merged.AlertCount)
+	assert.Equal(t, "high", merged.Severity)
+	source, err := findIncident(db, *alerts[1].IncidentID)
+	require.NoError(t, err)
+	assert.Equal(t, models.IncidentStatusMerged, source.Status)
+	assert.Equal(t, target, *source.MergedInto)
+	_, err = incidents.Merge(target, []uuid.UUID{source.ID}, actor)
+	assert.ErrorIs(t, err, ErrInvalidIncident)
+
+	// 拆分出高级别告警后原事件级别回落
+	_, err = incidents.Split(target, []uuid.UUID{alerts[0].ID, alerts[1].ID, alerts[2].ID}, "", actor)
+	assert.ErrorIs(t, err, ErrInvalidIncident)
+	split, err := incidents.Split(target, []uuid.UUID{alerts[1].ID}, "db outage", actor)
+	require.NoError(t, err)
+	assert.Equal(t, "db outage", split.Title)
+	assert.Equal(t, "high", split.Severity)
+	assert.Equal(t, 1, split.AlertCount)
+	remaining, err := findIncident(db, target)
+	require.NoError(t, err)
+	assert.Equal(t, 2, remaining.AlertCount)
+	assert.Equal(t, "medium", remaining.Severity)
+
+	status, postmortem := models.IncidentStatusInvestigating, "root cause: storage"
+	updated, err := incidents.Update(target, IncidentUpdate{Status: &status, CommanderID: &commanderID, Postmortem: &postmortem}, actor)
+	require.NoError(t, err)
+	assert.Equal(t, status, updated.Status)
+	assert.Equal(t, "Carol", *updated.CommanderName)
+	assert.Equal(t, postmortem, *updated.Postmortem)
+	bad := "merged"
+	_, err = incidents.Update(target, IncidentUpdate{Status: &bad}, actor)
+	assert.ErrorIs(t, err, ErrInvalidIncident)
+
+	timeline, err := incidents.Timeline(target)
+	require.NoError(t, err)
+	last := timeline[len(timeline)-3:]
+	assert.Equal(t, models.IncidentEventStatus, last[0].Type)
+	assert.Equal(t, models.IncidentEventCommander, last[1].Type)
+	assert.Equal(t, models.IncidentEventPostmortem, last[2].Type)
+	assert.Equal(t, "Carol", *last[2].ActorName)
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"fmt"
+
+	"vm-monitoring-system/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// AlertLabelNames 告警可用于抑制规则匹配的标签
+var AlertLabelNames = []string{"rule_id", "rule_name", "severity", "metric", "vm_id", "host_id", "cluster_id", "datacenter_id"}
+
+// AlertLabels 告警标签（标签名到取值）
+type AlertLabels map[string]string
+
+// NewAlertLabels 根据规则、VM与触发指标生成告警标签
+func NewAlertLabels(rule models.AlertRule, vm models.VM, metric string) AlertLabels {
+	labels := AlertLabels{
+		"rule_id":   rule.ID.String(),
+		"rule_name": rule.Name,
+		"severity":  rule.Severity,
+		"metric":    metric,
+		"vm_id":     vm.ID.String(),
+	}
+	if vm.HostID != nil {
+		labels["host_id"] = *vm.HostID
+	}
+	if vm.ClusterID != nil {
+		labels["cluster_id"] = *vm.ClusterID
+	}
+	if vm.DatacenterID != nil {
+		labels["datacenter_id"] = *vm.DatacenterID
+	}
+	return labels
+}
+
+// isAlertLabel 判断是否为支持的标签名
+func isAlertLabel(name string) bool {
+	for _, label := range AlertLabelNames {
+		if label == name {
+			return true
+		}
+	}
+	return false
+}
+
+// InhibitService 告警抑制规则服务
+type InhibitService struct {
+	db *gorm.DB
+}
+
+// NewInhibitService 创建告警抑制服务
+func NewInhibitService(db *gorm.DB) *InhibitService {
+	return &InhibitService{db: db}
+}
+
+// ValidateInhibitRule 校验抑制规则的标签名
+func ValidateInhibitRule(r *models.InhibitRule) error {
+	if len(r.SourceMatchers) == 0 || len(r.TargetMatchers) == 0 {
+		return fmt.Errorf("源条件和目标条件不能为空")
+	}
+	for _, matchers := range []models.JSONMap{r.SourceMatchers, r.TargetMatchers} {
+		for name, value := range matchers {
+			if !isAlertLabel(name) {
+				return fmt.Errorf("不支持的标签: %s", name)
+			}
+			if _, ok := value.(string); !ok {
+				return fmt.Errorf("标签 %s 的取值必须为字符串", name)
+			}
+		}
+	}
+	for _, name := range r.Equal {
+		if !isAlertLabel(name) {
+			return fmt.Errorf("不支持的标签: %s", name)
+		}
+	}
+	return nil
+}
+
+// matchLabels 判断标签是否满足全部匹配条件
+func matchLabels(matchers models.JSONMap, labels AlertLabels) bool {
+	for name, value := range matchers {
+		if labels[name] != fmt.Sprint(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// labelCondition 将标签条件转换为alert_records上的查询条件
+func labelCondition(name, value string) (string, interface{}) {
+	switch name {
+	case "host_id", "datacenter_id":
+		return "vm_id IN (SELECT id FROM vms WHERE " + name + " = ?)", value
+	default:
+		return name + " = ?", value
+	}
+}
+
+// FindInhibitor 查找抑制目标告警的规则及源告警（未结束的告警），未被抑制时返回nil
+func (s *InhibitService) FindInhibitor(target AlertLabels) (*models.InhibitRule, *models.AlertRecord, error) {
+	if s.db == nil {
+		return nil, nil, nil
+	}
+
+	var rules []models.InhibitRule
+	if err := s.db.Where("enabled = ?", true).Order("created_at").Find(&rules).Error; err != nil {
+		return nil, nil, fmt.Errorf("查询抑制规则失败: %w", err)
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if !matchLabels(rule.TargetMatchers, target) {
+			continue
+		}
+
+		// 告警不能抑制自身
+		cond := sqlCond{
+			sql:  "status IN ? AND NOT (rule_id = ? AND vm_id = ?)",
+			args: []interface{}{models.AlertOpenStatuses, target["rule_id"], target["vm_id"]},
+		}
+		for name, value := range rule.SourceMatchers {
+			sql, arg := labelCondition(name, fmt.Sprint(value))
+			cond = cond.and(sql, arg)
+		}
+
+		// 目标缺少需要相等的标签时规则不适用
+		applicable := true
+		for _, name := range rule.Equal {
+			if target[name] == "" {
+				applicable = false
+				break
+			}
+			sql, arg := labelCondition(name, target[name])
+			cond = cond.and(sql, arg)
+		}
+		if !applicable {
+			continue
+		}
+
+		var sources []models.AlertRecord
+		if err := s.db.Where(cond.sql, cond.args...).Order("triggered_at").Limit(1).Find(&sources).Error; err != nil {
+			return nil, nil, fmt.Errorf("查询源告警失败: %w", err)
+		}
+		if len(sources) > 0 {
+			return rule, &sources[0], nil
+		}
+	}
+	return nil, nil, nil
+}
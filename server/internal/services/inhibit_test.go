@@ -0,0 +1,92 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestValidateInhibitRule(t *testing.T) {
+	rule := &models.InhibitRule{
+		SourceMatchers: models.JSONMap{"metric": "host_down"},
+		TargetMatchers: models.JSONMap{"metric": "vm_offline"},
+		Equal:          models.StringArray{"host_id"},
+	}
+	require.NoError(t, ValidateInhibitRule(rule))
+
+	rule.Equal = models.StringArray{"hostname"}
+	assert.Error(t, ValidateInhibitRule(rule))
+	rule.Equal = nil
+	rule.TargetMatchers = models.JSONMap{}
+	assert.Error(t, ValidateInhibitRule(rule))
+}
+
+func setupInhibitTestDB(t *testing.T) *gorm.DB {
+	db := setupSilenceTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_inhibit_rules (
+		id TEXT PRIMARY KEY, name TEXT, description TEXT, enabled BOOLEAN, source_matchers TEXT,
+		target_matchers TEXT, equal TEXT, created_by TEXT, created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE vms (id TEXT PRIMARY KEY, host_id TEXT, datacenter_id TEXT)`).Error)
+	return db
+}
+
+func TestAlertEngine_Inhibition(t *testing.T) {
+	db := setupInhibitTestDB(t)
+	engine := NewAlertEngine(db, nil)
+	now := time.Now()
+
+	rules := []models.InhibitRule{
+		{ID: uuid.New(), Name: "host down", Enabled: true, SourceMatchers: models.JSONMap{"metric": "host_down"}, TargetMatchers: models.JSONMap{"metric": "vm_offline"}, Equal: models.StringArray{"host_id"}, CreatedAt: now},
+		{ID: uuid.New(), Name: "critical over medium", Enabled: true, SourceMatchers: models.JSONMap{"severity": "critical"}, TargetMatchers: models.JSONMap{"severity": "medium"}, Equal: models.StringArray{"vm_id", "metric"}, CreatedAt: now.Add(time.Second)},
+	}
+	for i := range rules {
+		require.NoError(t, db.Create(&rules[i]).Error)
+	}
+
+	hostA, hostB := "host-a", "host-b"
+	hostVM := models.VM{ID: uuid.New(), Name: "esxi-agent", HostID: &hostA}
+	vmA := models.VM{ID: uuid.New(), Name: "web-01", HostID: &hostA}
+	vmB := models.VM{ID: uuid.New(), Name: "web-02", HostID: &hostB}
+	for _, vm := range []models.VM{hostVM, vmA, vmB} {
+		require.NoError(t, db.Exec("INSERT INTO vms (id, host_id) VALUES (?, ?)", vm.ID, *vm.HostID).Error)
+	}
+
+	hostRule := models.AlertRule{ID: uuid.New(), Name: "host down", Severity: "critical"}
+	offlineRule := models.AlertRule{ID: uuid.New(), Name: "vm offline", Severity: "high"}
+	cpuCritical := models.AlertRule{ID: uuid.New(), Name: "cpu critical", Severity: "critical"}
+	cpuMedium := models.AlertRule{ID: uuid.New(), Name: "cpu medium", Severity: "medium"}
+	for _, r := range []models.AlertRule{hostRule, offlineRule, cpuCritical, cpuMedium} {
+		require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", r.ID).Error)
+	}
+	metric := func(name string) *AlertMetricData {
+		return &AlertMetricData{Metric: name, Value: 1, Condition: models.AlertCondition{Metric: name, Operator: ">", Threshold: 0}}
+	}
+
+	engine.applyEvaluation(hostRule, hostVM, true, metric("host_down"), now)
+	engine.applyEvaluation(offlineRule, vmA, true, metric("vm_offline"), now)
+	engine.applyEvaluation(offlineRule, vmB, true, metric("vm_offline"), now)
+
+	var record models.AlertRecord
+	require.NoError(t, db.Where("rule_id = ? AND vm_id = ?", offlineRule.ID, vmA.ID).First(&record).Error)
+	assert.True(t, record.Suppressed)
+	require.NotNil(t, record.InhibitedBy)
+	require.NotNil(t, record.SuppressionReason)
+	assert.Equal(t, "inhibited: host down", *record.SuppressionReason)
+
+	// 其他主机上的VM不受影响
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "rule_id = ? AND vm_id = ? AND suppressed = ?", offlineRule.ID, vmB.ID, false))
+
+	// 同一VM同一指标上critical抑制medium
+	engine.applyEvaluation(cpuCritical, vmB, true, metric("cpu_usage"), now)
+	engine.applyEvaluation(cpuMedium, vmB, true, metric("cpu_usage"), now)
+	engine.applyEvaluation(cpuMedium, vmA, true, metric("cpu_usage"), now)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "rule_id = ? AND suppressed = ?", cpuMedium.ID, true))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "rule_id = ? AND vm_id = ? AND suppressed = ?", cpuMedium.ID, vmA.ID, false))
+}
@@ -11,7 +11,6 @@ import (
 	"log"
 	"net/http"
 	"net/smtp"
-	"text/template"
 	"time"
 
 	"vm-monitoring-system/internal/models"
@@ -23,6 +22,7 @@ type NotificationService struct {
 	smtpConfig *SMTPConfig
 	smsConfig  *SMSConfig
 	httpClient *http.Client
+	templates  *NotificationTemplateService
 }
 
 // SMTPConfig SMTP配置
@@ -59,6 +59,14 @@ func NewNotificationService() *NotificationService {
 		httpClient: &http.Client{
 			Timeout: 10 * time.Second,
 		},
+		templates: NewNotificationTemplateService(nil),
+	}
+}
+
+// SetTemplates 设置通知模板服务，未设置时只使用内置模板
+func (s *NotificationService) SetTemplates(templates *NotificationTemplateService) {
+	if templates != nil {
+		s.templates = templates
 	}
 }
 
@@ -130,13 +138,13 @@ func (s *NotificationService) SendAlert(ctx context.Context, alert models.AlertR
 
 		switch method {
 		case "email":
-			result = s.sendEmailNotification(ctx, alert, notificationConfig.Email)
+			result = s.sendEmailNotification(ctx, alert, notificationConfig.Email, notificationConfig.Language)
 		case "sms":
-			result = s.sendSMSNotification(ctx, alert, notificationConfig.SMS)
+			result = s.sendSMSNotification(ctx, alert, notificationConfig.SMS, notificationConfig.Language)
 		case "webhook":
-			result = s.sendWebhookNotification(ctx, alert, notificationConfig.Webhook)
+			result = s.sendWebhookNotification(ctx, alert, notificationConfig.Webhook, notificationConfig.Language)
 		case "inApp":
-			result = s.sendInAppNotification(ctx, alert, notificationConfig.InApp)
+			result = s.sendInAppNotification(ctx, alert, notificationConfig.InApp, notificationConfig.Language)
 		default:
 			result = NotificationResult{
 				Method:    method,
@@ -161,7 +169,7 @@ func (s *NotificationService) sendEmailNotification(ctx context.Context, alert m
 	Recipients  []string `json:"recipients"`
 	CC          []string `json:"cc,omitempty"`
 	Template    string   `json:"template,omitempty"`
-}) NotificationResult {
+}, language string) NotificationResult {
 	if s.smtpConfig == nil || emailConfig == nil || !emailConfig.Enabled {
 		return NotificationResult{
 			Method:    "email",
@@ -180,9 +188,16 @@ func (s *NotificationService) sendEmailNotification(ctx context.Context, alert m
 		}
 	}
 
-	// 构建邮件内容
-	subject := fmt.Sprintf("【%s】VM监控告警: %s", getSeverityLabel(alert.Severity), alert.RuleName)
-	body := s.buildEmailBody(alert)
+	// 按模板构建邮件内容
+	content, err := s.templates.RenderAlert("email", emailConfig.Template, language, alert)
+	if err != nil {
+		return NotificationResult{
+			Method:    "email",
+			Success:   false,
+			Message:   "渲染邮件模板失败: " + err.Error(),
+			Timestamp: time.Now(),
+		}
+	}
 
 	// 发送邮件
 	addr := fmt.Sprintf("%s:%d", s.smtpConfig.Host, s.smtpConfig.Port)
@@ -190,11 +205,11 @@ func (s *NotificationService) sendEmailNotification(ctx context.Context, alert m
 
 	msg := []byte(fmt.Sprintf("To: %s\r\nSubject: %s\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n%s",
 		joinAddresses(emailConfig.Recipients),
-		subject,
-		body,
+		content.Subject,
+		content.Body,
 	))
 
-	err := smtp.SendMail(addr, auth, s.smtpConfig.From, emailConfig.Recipients, msg)
+	err = smtp.SendMail(addr, auth, s.smtpConfig.From, emailConfig.Recipients, msg)
 	if err != nil {
 		log.Printf("发送邮件失败: %v", err)
 		return NotificationResult{
@@ -218,7 +233,7 @@ func (s *NotificationService) sendSMSNotification(ctx context.Context, alert mod
 	Enabled      bool     `json:"enabled"`
 	PhoneNumbers []string `json:"phoneNumbers"`
 	Template     string   `json:"template,omitempty"`
-}) NotificationResult {
+}, language string) NotificationResult {
 	if s.smsConfig == nil || smsConfig == nil || !smsConfig.Enabled {
 		return NotificationResult{
 			Method:    "sms",
@@ -237,19 +252,21 @@ func (s *NotificationService) sendSMSNotification(ctx context.Context, alert mod
 		}
 	}
 
-	// 构建短信内容（暂时未使用）
-	_ = fmt.Sprintf("【VM监控】%s告警: %s, VM: %s, 当前值: %.2f, 阈值: %.2f",
-		getSeverityLabel(alert.Severity),
-		alert.RuleName,
-		getStringValue(alert.VMName),
-		alert.TriggerValue,
-		alert.Threshold,
-	)
+	// 按模板构建短信内容
+	content, err := s.templates.RenderAlert("sms", smsConfig.Template, language, alert)
+	if err != nil {
+		return NotificationResult{
+			Method:    "sms",
+			Success:   false,
+			Message:   "渲染短信模板失败: " + err.Error(),
+			Timestamp: time.Now(),
+		}
+	}
 
 	// TODO: 接入具体的短信服务商API
 	// 阿里云SMS、腾讯云SMS、Twilio等
 	
-	log.Printf("短信通知(模拟): %s -> %v", alert.RuleName, smsConfig.PhoneNumbers)
+	log.Printf("短信通知(模拟): %s -> %v", content.Body, smsConfig.PhoneNumbers)
 	return NotificationResult{
 		Method:    "sms",
 		Success:   true,
@@ -264,8 +281,9 @@ func (s *NotificationService) sendWebhookNotification(ctx context.Context, alert
 	URL     string            `json:"url"`
 	Method  string            `json:"method"`
 	Headers map[string]string `json:"headers,omitempty"`
-	Secret  string            `json:"secret,omitempty"`
-}) NotificationResult {
+	Secret   string            `json:"secret,omitempty"`
+	Template string            `json:"template,omitempty"`
+}, language string) NotificationResult {
 	if webhookConfig == nil || !webhookConfig.Enabled {
 		return NotificationResult{
 			Method:    "webhook",
@@ -284,28 +302,17 @@ func (s *NotificationService) sendWebhookNotification(ctx context.Context, alert
 		}
 	}
 
-	// 构建Webhook数据
-	payload := map[string]interface{}{
-		"id":           alert.ID,
-		"ruleName":     alert.RuleName,
-		"severity":     alert.Severity,
-		"metric":       alert.Metric,
-		"triggerValue": alert.TriggerValue,
-		"threshold":    alert.Threshold,
-		"vmName":       getStringValue(alert.VMName),
-		"triggeredAt":  alert.TriggeredAt,
-		"timestamp":    time.Now(),
-	}
-
-	jsonData, err := json.Marshal(payload)
+	// 按模板构建Webhook数据（渲染结果已校验为合法JSON）
+	content, err := s.templates.RenderAlert("webhook", webhookConfig.Template, language, alert)
 	if err != nil {
 		return NotificationResult{
 			Method:    "webhook",
 			Success:   false,
-			Message:   fmt.Sprintf("序列化数据失败: %v", err),
+			Message:   fmt.Sprintf("渲染Webhook模板失败: %v", err),
 			Timestamp: time.Now(),
 		}
 	}
+	jsonData := []byte(content.Body)
 
 	// 发送HTTP请求
 	method := webhookConfig.Method
@@ -365,13 +372,27 @@ func (s *NotificationService) sendWebhookNotification(ctx context.Context, alert
 
 // sendInAppNotification 发送应用内通知
 func (s *NotificationService) sendInAppNotification(ctx context.Context, alert models.AlertRecord, inAppConfig *struct {
-	Enabled bool     `json:"enabled"`
-	Users   []string `json:"users,omitempty"`
-}) NotificationResult {
+	Enabled  bool     `json:"enabled"`
+	Users    []string `json:"users,omitempty"`
+	Template string   `json:"template,omitempty"`
+}, language string) NotificationResult {
 	// 应用内通知直接写入通知表或推送到WebSocket
 	// TODO: 实现WebSocket推送或通知表写入
-	
-	log.Printf("应用内通知: %s - %s", alert.RuleName, getStringValue(alert.VMName))
+	ref := ""
+	if inAppConfig != nil {
+		ref = inAppConfig.Template
+	}
+	content, err := s.templates.RenderAlert("inApp", ref, language, alert)
+	if err != nil {
+		return NotificationResult{
+			Method:    "inApp",
+			Success:   false,
+			Message:   "渲染应用内通知模板失败: " + err.Error(),
+			Timestamp: time.Now(),
+		}
+	}
+
+	log.Printf("应用内通知: %s - %s", content.Subject, content.Body)
 	return NotificationResult{
 		Method:    "inApp",
 		Success:   true,
@@ -379,85 +400,6 @@ func (s *NotificationService) sendInAppNotification(ctx context.Context, alert m
 	}
 }
 
-// buildEmailBody 构建邮件正文
-func (s *NotificationService) buildEmailBody(alert models.AlertRecord) string {
-	tmpl := `<!DOCTYPE html>
-<html>
-<head>
-    <meta charset="UTF-8">
-    <style>
-        body { font-family: Arial, sans-serif; line-height: 1.6; }
-        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
-        .header { background: {{.HeaderColor}}; color: white; padding: 20px; border-radius: 5px 5px 0 0; }
-        .content { background: #f9f9f9; padding: 20px; border: 1px solid #ddd; }
-        .footer { background: #eee; padding: 10px; text-align: center; font-size: 12px; color: #666; }
-        .metric { background: white; padding: 15px; margin: 10px 0; border-left: 4px solid {{.HeaderColor}}; }
-        .label { font-weight: bold; color: #333; }
-        .value { color: #666; }
-        .critical { background: #f44336; }
-        .high { background: #ff9800; }
-        .medium { background: #2196f3; }
-        .low { background: #4caf50; }
-    </style>
-</head>
-<body>
-    <div class="container">
-        <div class="header {{.SeverityClass}}">
-            <h2>🚨 VM监控告警通知</h2>
-            <p>告警级别: {{.SeverityLabel}}</p>
-        </div>
-        <div class="content">
-            <h3>{{.RuleName}}</h3>
-            
-            <div class="metric">
-                <p><span class="label">虚拟机:</span> <span class="value">{{.VMName}}</span></p>
-                <p><span class="label">集群:</span> <span class="value">{{.ClusterName}}</span></p>
-                <p><span class="label">指标:</span> <span class="value">{{.Metric}}</span></p>
-                <p><span class="label">触发值:</span> <span class="value" style="color: {{.ValueColor}}; font-weight: bold;">{{.TriggerValue}}</span></p>
-                <p><span class="label">阈值:</span> <span class="value">{{.Threshold}}</span></p>
-                <p><span class="label">条件:</span> <span class="value">{{.Condition}}</span></p>
-                <p><span class="label">触发时间:</span> <span class="value">{{.TriggeredAt}}</span></p>
-            </div>
-            
-            <p>请尽快登录系统查看详情并处理此告警。</p>
-            
-            <a href="{{.DetailURL}}" style="display: inline-block; background: {{.HeaderColor}}; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">查看详情</a>
-        </div>
-        <div class="footer">
-            <p>此邮件由 VM监控系统 自动发送</p>
-            <p>{{.Timestamp}}</p>
-        </div>
-    </div>
-</body>
-</html>`
-
-	data := map[string]string{
-		"RuleName":     alert.RuleName,
-		"VMName":       getStringValue(alert.VMName),
-		"ClusterName":  getStringValue(alert.ClusterID),
-		"Metric":       alert.Metric,
-		"TriggerValue": fmt.Sprintf("%.2f", alert.TriggerValue),
-		"Threshold":    fmt.Sprintf("%.2f", alert.Threshold),
-		"Condition":    getStringValue(alert.ConditionStr),
-		"TriggeredAt":  alert.TriggeredAt.Format("2006-01-02 15:04:05"),
-		"SeverityLabel": getSeverityLabel(alert.Severity),
-		"SeverityClass": getSeverityClass(alert.Severity),
-		"HeaderColor":  getSeverityColor(alert.Severity),
-		"ValueColor":   getSeverityColor(alert.Severity),
-		"DetailURL":    fmt.Sprintf("https://your-domain.com/alerts/%s", alert.ID),
-		"Timestamp":    time.Now().Format("2006-01-02 15:04:05"),
-	}
-
-	t := template.Must(template.New("email").Parse(tmpl))
-	var buf bytes.Buffer
-	if err := t.Execute(&buf, data); err != nil {
-		return fmt.Sprintf("<h1>告警: %s</h1><p>虚拟机: %s</p><p>指标 %s 触发告警，当前值: %.2f，阈值: %.2f</p>",
-			alert.RuleName, getStringValue(alert.VMName), alert.Metric, alert.TriggerValue, alert.Threshold)
-	}
-
-	return buf.String()
-}
-
 // generateSignature 生成Webhook签名 (HMAC-SHA256)
 func (s *NotificationService) generateSignature(data []byte, secret string) string {
 	mac := hmac.New(sha256.New, []byte(secret))
@@ -495,21 +437,6 @@ func getSeverityLabel(severity string) string {
 	}
 }
 
-func getSeverityClass(severity string) string {
-	switch severity {
-	case "critical":
-		return "critical"
-	case "high":
-		return "high"
-	case "medium":
-		return "medium"
-	case "low":
-		return "low"
-	default:
-		return "medium"
-	}
-}
-
 func getSeverityColor(severity string) string {
 	switch severity {
 	case "critical":
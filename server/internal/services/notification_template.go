@@ -0,0 +1,557 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("通知模板不存在")
+	ErrInvalidTemplate  = errors.New("通知模板无效")
+)
+
+// TemplateChannels 支持自定义模板的通知渠道
+var TemplateChannels = []string{models.TemplateChannelEmail, models.TemplateChannelSMS, models.TemplateChannelWebhook, models.TemplateChannelInApp}
+
+// TemplateLinks 模板中可引用的前端页面链接
+type TemplateLinks struct {
+	Alert    string
+	Rule     string
+	VM       string
+	Incident string
+}
+
+// TemplateData 通知模板的渲染上下文
+type TemplateData struct {
+	Alert         models.AlertRecord
+	Rule          *models.AlertRule // 规则已删除时为空
+	VM            *models.VM        // 非单VM告警时为空
+	Snapshot      models.JSONMap
+	Links         TemplateLinks
+	Language      string
+	SeverityLabel string
+	SeverityColor string
+	Now           time.Time
+}
+
+// RenderedNotification 模板渲染结果，Template为空表示使用内置模板
+type RenderedNotification struct {
+	Channel  string `json:"channel"`
+	Language string `json:"language"`
+	Template string `json:"template,omitempty"`
+	Subject  string `json:"subject,omitempty"`
+	Body     string `json:"body"`
+}
+
+// templateFuncs 模板可用的辅助函数
+var templateFuncs = map[string]interface{}{
+	"severityLabel": getSeverityLabel,
+	"severityColor": getSeverityColor,
+	"deref":         getStringValue,
+	"upper":         strings.ToUpper,
+	"lower":         strings.ToLower,
+	"formatTime": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05")
+	},
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// builtinTemplates 未配置自定义模板时使用的内置模板（与原有通知内容一致）
+var builtinTemplates = map[string]models.NotificationTemplate{
+	models.TemplateChannelEmail: {
+		Channel: models.TemplateChannelEmail,
+		Subject: `【{{.SeverityLabel}}】VM监控告警: {{.Alert.RuleName}}`,
+		Body:    builtinEmailBody,
+	},
+	models.TemplateChannelSMS: {
+		Channel: models.TemplateChannelSMS,
+		Body:    `【VM监控】{{.SeverityLabel}}告警: {{.Alert.RuleName}}, VM: {{deref .Alert.VMName}}, 当前值: {{printf "%.2f" .Alert.TriggerValue}}, 阈值: {{printf "%.2f" .Alert.Threshold}}`,
+	},
+	models.TemplateChannelWebhook: {
+		Channel: models.TemplateChannelWebhook,
+		Body: `{"id":{{json .Alert.ID}},"ruleName":{{json .Alert.RuleName}},"severity":{{json .Alert.Severity}},"metric":{{json .Alert.Metric}},` +
+			`"triggerValue":{{json .Alert.TriggerValue}},"threshold":{{json .Alert.Threshold}},"vmName":{{json (deref .Alert.VMName)}},` +
+			`"triggeredAt":{{json .Alert.TriggeredAt}},"timestamp":{{json .Now}}}`,
+	},
+	models.TemplateChannelInApp: {
+		Channel: models.TemplateChannelInApp,
+		Subject: `{{.SeverityLabel}}告警: {{.Alert.RuleName}}`,
+		Body:    `{{deref .Alert.VMName}} {{.Alert.Metric}} 当前值 {{printf "%.2f" .Alert.TriggerValue}}，阈值 {{printf "%.2f" .Alert.Threshold}}`,
+	},
+}
+
+const builtinEmailBody = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <style>
+        body { font-family: Arial, sans-serif; line-height: 1.6; }
+        .container { max-width: 600px; margin: 0 auto; padding: 20px; }
+        .header { color: white; padding: 20px; border-radius: 5px 5px 0 0; }
+        .content { background: #f9f9f9; padding: 20px; border: 1px solid #ddd; }
+        .footer { background: #eee; padding: 10px; text-align: center; font-size: 12px; color: #666; }
+        .metric { background: white; padding: 15px; margin: 10px 0; border-left: 4px solid; }
+        .label { font-weight: bold; color: #333; }
+        .value { color: #666; }
+    </style>
+</head>
+<body>
+    <div class="container">
+        <div class="header" style="background: {{.SeverityColor}};">
+            <h2>🚨 VM监控告警通知</h2>
+            <p>告警级别: {{.SeverityLabel}}</p>
+        </div>
+        <div class="content">
+            <h3>{{.Alert.RuleName}}</h3>
+
+            <div class="metric" style="border-left-color: {{.SeverityColor}};">
+                <p><span class="label">虚拟机:</span> <span class="value">{{deref .Alert.VMName}}</span></p>
+                <p><span class="label">集群:</span> <span class="value">{{deref .Alert.ClusterID}}</span></p>
+                <p><span class="label">指标:</span> <span class="value">{{.Alert.Metric}}</span></p>
+                <p><span class="label">触发值:</span> <span class="value" style="color: {{.SeverityColor}}; font-weight: bold;">{{printf "%.2f" .Alert.TriggerValue}}</span></p>
+                <p><span class="label">阈值:</span> <span class="value">{{printf "%.2f" .Alert.Threshold}}</span></p>
+                <p><span class="label">条件:</span> <span class="value">{{deref .Alert.ConditionStr}}</span></p>
+                <p><span class="label">触发时间:</span> <span class="value">{{formatTime .Alert.TriggeredAt}}</span></p>
+            </div>
+
+            <p>请尽快登录系统查看详情并处理此告警。</p>
+
+            <a href="{{.Links.Alert}}" style="display: inline-block; background: {{.SeverityColor}}; color: white; padding: 10px 20px; text-decoration: none; border-radius: 5px;">查看详情</a>
+        </div>
+        <div class="footer">
+            <p>此邮件由 VM监控系统 自动发送</p>
+            <p>{{formatTime .Now}}</p>
+        </div>
+    </div>
+</body>
+</html>`
+
+// NotificationTemplateService 通知模板管理与渲染。
+// 规则通知配置中各渠道的template字段填写模板名称或ID，未填写时使用该渠道与语言的默认模板，都没有时使用内置模板
+type NotificationTemplateService struct {
+	db         *gorm.DB
+	consoleURL string
+}
+
+// NewNotificationTemplateService 创建通知模板服务，db为空时只使用内置模板
+func NewNotificationTemplateService(db *gorm.DB) *NotificationTemplateService {
+	return &NotificationTemplateService{db: db}
+}
+
+// SetConsoleURL 设置模板链接指向的前端地址，为空时生成相对路径
+func (s *NotificationTemplateService) SetConsoleURL(url string) {
+	s.consoleURL = strings.TrimRight(url, "/")
+}
+
+// List 获取模板列表，可按渠道与语言筛选
+func (s *NotificationTemplateService) List(channel, language string) ([]models.NotificationTemplate, error) {
+	query := s.db.Model(&models.NotificationTemplate{})
+	if channel != "" {
+		query = query.Where("channel = ?", channel)
+	}
+	if language != "" {
+		query = query.Where("language = ?", language)
+	}
+	var templates []models.NotificationTemplate
+	err := query.Order("channel ASC, language ASC, name ASC").Find(&templates).Error
+	return templates, err
+}
+
+// Get 获取模板
+func (s *NotificationTemplateService) Get(id uuid.UUID) (*models.NotificationTemplate, error) {
+	var tmpl models.NotificationTemplate
+	if err := s.db.First(&tmpl, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrTemplateNotFound
+		}
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// Create 创建模板，保存前使用示例数据试渲染
+func (s *NotificationTemplateService) Create(tmpl *models.NotificationTemplate) error {
+	if tmpl.Language == "" {
+		tmpl.Language = models.DefaultTemplateLanguage
+	}
+	if err := s.Validate(tmpl); err != nil {
+		return err
+	}
+	if tmpl.ID == uuid.Nil {
+		tmpl.ID = uuid.New()
+	}
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkTemplateName(tx, tmpl); err != nil {
+			return err
+		}
+		if tmpl.IsDefault {
+			if err := clearDefaultTemplate(tx, tmpl); err != nil {
+				return err
+			}
+		}
+		return tx.Create(tmpl).Error
+	})
+}
+
+// Update 修改模板（整体替换名称、渠道、语言与内容）
+func (s *NotificationTemplateService) Update(id uuid.UUID, input models.NotificationTemplate) (*models.NotificationTemplate, error) {
+	tmpl, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.Name = input.Name
+	tmpl.Channel = input.Channel
+	tmpl.Language = input.Language
+	if tmpl.Language == "" {
+		tmpl.Language = models.DefaultTemplateLanguage
+	}
+	tmpl.Subject = input.Subject
+	tmpl.Body = input.Body
+	tmpl.Description = input.Description
+	tmpl.IsDefault = input.IsDefault
+	if err := s.Validate(tmpl); err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		if err := checkTemplateName(tx, tmpl); err != nil {
+			return err
+		}
+		if tmpl.IsDefault {
+			if err := clearDefaultTemplate(tx, tmpl); err != nil {
+				return err
+			}
+		}
+		return tx.Save(tmpl).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tmpl, nil
+}
+
+// Delete 删除模板，引用该模板的规则回退到默认模板
+func (s *NotificationTemplateService) Delete(id uuid.UUID) error {
+	result := s.db.Delete(&models.NotificationTemplate{}, "id = ?", id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// checkTemplateName 同一渠道与语言下模板名称不能重复
+func checkTemplateName(tx *gorm.DB, tmpl *models.NotificationTemplate) error {
+	var count int64
+	err := tx.Model(&models.NotificationTemplate{}).
+		Where("name = ? AND channel = ? AND language = ? AND id <> ?", tmpl.Name, tmpl.Channel, tmpl.Language, tmpl.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%w: 模板名称 %s 已存在", ErrInvalidTemplate, tmpl.Name)
+	}
+	return nil
+}
+
+// clearDefaultTemplate 每个渠道与语言只保留一个默认模板
+func clearDefaultTemplate(tx *gorm.DB, tmpl *models.NotificationTemplate) error {
+	return tx.Model(&models.NotificationTemplate{}).
+		Where("channel = ? AND language = ? AND is_default = ? AND id <> ?", tmpl.Channel, tmpl.Language, true, tmpl.ID).
+		Update("is_default", false).Error
+}
+
+// Validate 校验模板字段并使用示例数据试渲染，语法错误或引用不存在的字段都会被拒绝
+func (s *NotificationTemplateService) Validate(tmpl *models.NotificationTemplate) error {
+	if strings.TrimSpace(tmpl.Name) == "" {
+		return fmt.Errorf("%w: 模板名称不能为空", ErrInvalidTemplate)
+	}
+	if !containsString(TemplateChannels, tmpl.Channel) {
+		return fmt.Errorf("%w: 不支持的渠道 %s", ErrInvalidTemplate, tmpl.Channel)
+	}
+	if strings.TrimSpace(tmpl.Body) == "" {
+		return fmt.Errorf("%w: 模板正文不能为空", ErrInvalidTemplate)
+	}
+	_, err := s.Render(tmpl, s.SampleData(tmpl.Language))
+	return err
+}
+
+// Resolve 查找渠道使用的模板：ref为模板ID或名称（优先匹配语言，其次默认语言），
+// 未找到时使用该渠道与语言的默认模板，仍未找到时返回内置模板
+func (s *NotificationTemplateService) Resolve(channel, ref, language string) models.NotificationTemplate {
+	if language == "" {
+		language = models.DefaultTemplateLanguage
+	}
+	if s.db != nil {
+		if ref != "" {
+			if tmpl, err := s.findByRef(channel, ref, language); err == nil {
+				return *tmpl
+			} else if !errors.Is(err, ErrTemplateNotFound) {
+				logger.Error("查询通知模板失败", zap.String("template", ref), zap.Error(err))
+			} else {
+				logger.Warn("通知模板不存在，使用默认模板", zap.String("channel", channel), zap.String("template", ref))
+			}
+		}
+		for _, lang := range templateLanguages(language) {
+			var tmpl models.NotificationTemplate
+			err := s.db.Where("channel = ? AND language = ? AND is_default = ?", channel, lang, true).First(&tmpl).Error
+			if err == nil {
+				return tmpl
+			}
+		}
+	}
+
+	tmpl := builtinTemplates[channel]
+	tmpl.Language = models.DefaultTemplateLanguage
+	return tmpl
+}
+
+// findByRef 按ID或名称查找渠道模板
+func (s *NotificationTemplateService) findByRef(channel, ref, language string) (*models.NotificationTemplate, error) {
+	if id, err := uuid.Parse(ref); err == nil {
+		var tmpl models.NotificationTemplate
+		if err := s.db.Where("id = ? AND channel = ?", id, channel).First(&tmpl).Error; err == nil {
+			return &tmpl, nil
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	var candidates []models.NotificationTemplate
+	if err := s.db.Where("name = ? AND channel = ?", ref, channel).Find(&candidates).Error; err != nil {
+		return nil, err
+	}
+	for _, lang := range templateLanguages(language) {
+		for i := range candidates {
+			if candidates[i].Language == lang {
+				return &candidates[i], nil
+			}
+		}
+	}
+	if len(candidates) > 0 {
+		return &candidates[0], nil
+	}
+	return nil, ErrTemplateNotFound
+}
+
+// templateLanguages 模板语言的查找顺序
+func templateLanguages(language string) []string {
+	if language == models.DefaultTemplateLanguage {
+		return []string{language}
+	}
+	return []string{language, models.DefaultTemplateLanguage}
+}
+
+// Render 渲染模板：邮件使用html/template转义，其余渠道使用text/template；Webhook结果必须是合法JSON
+func (s *NotificationTemplateService) Render(tmpl *models.NotificationTemplate, data TemplateData) (*RenderedNotification, error) {
+	subject, err := renderText("subject", tmpl.Subject, data)
+	if err != nil {
+		return nil, err
+	}
+
+	var body string
+	if tmpl.Channel == models.TemplateChannelEmail {
+		body, err = renderHTML(tmpl.Body, data)
+	} else {
+		body, err = renderText("body", tmpl.Body, data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tmpl.Channel == models.TemplateChannelWebhook && !json.Valid([]byte(body)) {
+		return nil, fmt.Errorf("%w: Webhook模板渲染结果不是合法的JSON", ErrInvalidTemplate)
+	}
+
+	return &RenderedNotification{
+		Channel:  tmpl.Channel,
+		Language: tmpl.Language,
+		Template: tmpl.Name,
+		Subject:  strings.TrimSpace(subject),
+		Body:     body,
+	}, nil
+}
+
+// renderText 使用text/template渲染
+func renderText(name, text string, data TemplateData) (string, error) {
+	if text == "" {
+		return "", nil
+	}
+	t, err := template.New(name).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return buf.String(), nil
+}
+
+// renderHTML 使用html/template渲染，告警字段中的HTML会被转义
+func renderHTML(text string, data TemplateData) (string, error) {
+	t, err := htmltemplate.New("body").Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidTemplate, err)
+	}
+	return buf.String(), nil
+}
+
+// BuildData 构建告警的渲染上下文，规则与VM从数据库加载
+func (s *NotificationTemplateService) BuildData(alert models.AlertRecord, language string) TemplateData {
+	data := s.newTemplateData(alert, language)
+	if s.db == nil {
+		return data
+	}
+
+	var rule models.AlertRule
+	if err := s.db.Where("id = ?", alert.RuleID).First(&rule).Error; err == nil {
+		data.Rule = &rule
+	}
+	if alert.VMID != nil {
+		var vm models.VM
+		if err := s.db.Where("id = ?", *alert.VMID).First(&vm).Error; err == nil {
+			data.VM = &vm
+		}
+	}
+	return data
+}
+
+// newTemplateData 填充告警本身可得到的字段与链接
+func (s *NotificationTemplateService) newTemplateData(alert models.AlertRecord, language string) TemplateData {
+	if language == "" {
+		language = models.DefaultTemplateLanguage
+	}
+	data := TemplateData{
+		Alert:         alert,
+		Snapshot:      alert.Snapshot,
+		Language:      language,
+		SeverityLabel: localizedSeverityLabel(alert.Severity, language),
+		SeverityColor: getSeverityColor(alert.Severity),
+		Now:           time.Now(),
+		Links: TemplateLinks{
+			Alert: fmt.Sprintf("%s/alerts/%s", s.consoleURL, alert.ID),
+			Rule:  fmt.Sprintf("%s/alerts/rules/%s", s.consoleURL, alert.RuleID),
+		},
+	}
+	if data.Snapshot == nil {
+		data.Snapshot = models.JSONMap{}
+	}
+	if alert.VMID != nil {
+		data.Links.VM = fmt.Sprintf("%s/vms/%s", s.consoleURL, *alert.VMID)
+	}
+	if alert.IncidentID != nil {
+		data.Links.Incident = fmt.Sprintf("%s/alerts/incidents/%s", s.consoleURL, *alert.IncidentID)
+	}
+	return data
+}
+
+// SampleData 预览与校验使用的示例告警
+func (s *NotificationTemplateService) SampleData(language string) TemplateData {
+	vmID, incidentID := uuid.New(), uuid.New()
+	vmName, cluster, clusterName, host := "web-server-01", "domain-c8", "prod-cluster", "esxi-01.example.com"
+	condition := "cpu_usage > 90"
+	rule := &models.AlertRule{ID: uuid.New(), Name: "CPU使用率过高", Severity: "high", Enabled: true}
+	alert := models.AlertRecord{
+		ID:           uuid.New(),
+		RuleID:       rule.ID,
+		RuleName:     rule.Name,
+		VMID:         &vmID,
+		VMName:       &vmName,
+		ClusterID:    &cluster,
+		Metric:       "cpu_usage",
+		Severity:     rule.Severity,
+		TriggerValue: 95.5,
+		Threshold:    90,
+		ConditionStr: &condition,
+		TriggeredAt:  time.Now().Add(-5 * time.Minute),
+		Status:       models.AlertStatusActive,
+		IncidentID:   &incidentID,
+		Snapshot: models.JSONMap{
+			"metric": "cpu_usage", "value": 95.5, "threshold": 90.0,
+		},
+	}
+
+	data := s.newTemplateData(alert, language)
+	data.Rule = rule
+	data.VM = &models.VM{ID: vmID, Name: vmName, ClusterID: &cluster, ClusterName: &clusterName, HostName: &host, Status: "online"}
+	return data
+}
+
+// Preview 渲染模板预览：alertID不为空时使用真实告警，否则使用示例告警
+func (s *NotificationTemplateService) Preview(tmpl *models.NotificationTemplate, alertID *uuid.UUID) (*RenderedNotification, error) {
+	if !containsString(TemplateChannels, tmpl.Channel) {
+		return nil, fmt.Errorf("%w: 不支持的渠道 %s", ErrInvalidTemplate, tmpl.Channel)
+	}
+	if tmpl.Language == "" {
+		tmpl.Language = models.DefaultTemplateLanguage
+	}
+	if alertID == nil {
+		return s.Render(tmpl, s.SampleData(tmpl.Language))
+	}
+
+	var alert models.AlertRecord
+	if err := s.db.First(&alert, "id = ?", *alertID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrAlertNotFound
+		}
+		return nil, err
+	}
+	return s.Render(tmpl, s.BuildData(alert, tmpl.Language))
+}
+
+// RenderAlert 按通知配置渲染告警在某渠道的内容，自定义模板渲染失败时回退到内置模板
+func (s *NotificationTemplateService) RenderAlert(channel, ref, language string, alert models.AlertRecord) (*RenderedNotification, error) {
+	tmpl := s.Resolve(channel, ref, language)
+	data := s.BuildData(alert, tmpl.Language)
+	rendered, err := s.Render(&tmpl, data)
+	if err == nil || tmpl.Name == "" {
+		return rendered, err
+	}
+
+	logger.Warn("通知模板渲染失败，使用内置模板", zap.String("template", tmpl.Name), zap.Error(err))
+	builtin := builtinTemplates[channel]
+	builtin.Language = models.DefaultTemplateLanguage
+	return s.Render(&builtin, data)
+}
+
+// localizedSeverityLabel 按模板语言返回告警级别名称
+func localizedSeverityLabel(severity, language string) string {
+	if strings.HasPrefix(language, "zh") {
+		return getSeverityLabel(severity)
+	}
+	switch severity {
+	case "critical":
+		return "Critical"
+	case "high":
+		return "High"
+	case "medium":
+		return "Medium"
+	case "low":
+		return "Low"
+	default:
+		return severity
+	}
+}
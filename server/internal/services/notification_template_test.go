@@ -0,0 +1,123 @@
+package services
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupTemplateTestDB(t *testing.T) *gorm.DB {
+	db := setupAlertStateTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE notification_templates (
+		id TEXT PRIMARY KEY, name TEXT, channel TEXT, language TEXT, subject TEXT, body TEXT,
+		description TEXT, is_default BOOLEAN DEFAULT 0, created_by TEXT, created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE vms (id TEXT PRIMARY KEY, name TEXT, cluster_name TEXT, status TEXT)`).Error)
+	return db
+}
+
+func TestNotificationTemplate_BuiltinRender(t *testing.T) {
+	service := NewNotificationTemplateService(nil)
+	service.SetConsoleURL("https://monitor.example.com/")
+	vmName := "<script>web-01</script>"
+	alert := models.AlertRecord{ID: uuid.New(), RuleID: uuid.New(), RuleName: "cpu high", VMName: &vmName,
+		Metric: "cpu_usage", Severity: "critical", TriggerValue: 97.123, Threshold: 90, TriggeredAt: time.Now()}
+
+	email, err := service.RenderAlert(models.TemplateChannelEmail, "", "", alert)
+	require.NoError(t, err)
+	assert.Equal(t, "【严重】VM监控告警: cpu high", email.Subject)
+	assert.Contains(t, email.Body, "97.12")
+	assert.Contains(t, email.Body, "https://monitor.example.com/alerts/"+alert.ID.String())
+	assert.NotContains(t, email.Body, "<script>")
+
+	webhook, err := service.RenderAlert(models.TemplateChannelWebhook, "missing", "en-US", alert)
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(webhook.Body), &payload))
+	assert.Equal(t, "cpu high", payload["ruleName"])
+	assert.Equal(t, vmName, payload["vmName"])
+	assert.Equal(t, 97.123, payload["triggerValue"])
+
+	sms, err := service.RenderAlert(models.TemplateChannelSMS, "", "", alert)
+	require.NoError(t, err)
+	assert.Contains(t, sms.Body, "当前值: 97.12, 阈值: 90.00")
+}
+
+func TestNotificationTemplate_ValidateAndResolve(t *testing.T) {
+	db := setupTemplateTestDB(t)
+	service := NewNotificationTemplateService(db)
+
+	// 语法错误、引用不存在的字段、Webhook非JSON都在保存时被拒绝
+	for _, body := range []string{"{{.Alert.RuleName", "{{.Alert.Unknown}}"} {
+		err := service.Create(&models.NotificationTemplate{Name: "bad", Channel: models.TemplateChannelSMS, Body: body})
+		assert.ErrorIs(t, err, ErrInvalidTemplate)
+	}
+	err := service.Create(&models.NotificationTemplate{Name: "bad", Channel: models.TemplateChannelWebhook, Body: "alert {{.Alert.RuleName}}"})
+	assert.ErrorIs(t, err, ErrInvalidTemplate)
+
+	zh := &models.NotificationTemplate{Name: "oncall", Channel: models.TemplateChannelSMS, Body: "值班: {{.Alert.RuleName}} {{.SeverityLabel}}"}
+	en := &models.NotificationTemplate{Name: "oncall", Channel: models.TemplateChannelSMS, Language: "en-US", Body: "On-call: {{.Alert.RuleName}} {{.SeverityLabel}}"}
+	require.NoError(t, service.Create(zh))
+	require.NoError(t, service.Create(en))
+	assert.Equal(t, models.DefaultTemplateLanguage, zh.Language)
+	assert.ErrorIs(t, service.Create(&models.NotificationTemplate{Name: "oncall", Channel: models.TemplateChannelSMS, Body: "x"}), ErrInvalidTemplate)
+
+	assert.Equal(t, en.ID, service.Resolve(models.TemplateChannelSMS, "oncall", "en-US").ID)
+	assert.Equal(t, zh.ID, service.Resolve(models.TemplateChannelSMS, "oncall", "ja-JP").ID)
+	assert.Equal(t, zh.ID, service.Resolve(models.TemplateChannelSMS, zh.ID.String(), "en-US").ID)
+	assert.Empty(t, service.Resolve(models.TemplateChannelSMS, "", "en-US").Name)
+
+	// 每个渠道与语言只保留一个默认模板
+	first := &models.NotificationTemplate{Name: "d1", Channel: models.TemplateChannelSMS, Language: "en-US", Body: "d1", IsDefault: true}
+	second := &models.NotificationTemplate{Name: "d2", Channel: models.TemplateChannelSMS, Language: "en-US", Body: "d2", IsDefault: true}
+	require.NoError(t, service.Create(first))
+	require.NoError(t, service.Create(second))
+	assert.Equal(t, second.ID, service.Resolve(models.TemplateChannelSMS, "", "en-US").ID)
+	stored, err := service.Get(first.ID)
+	require.NoError(t, err)
+	assert.False(t, stored.IsDefault)
+
+	alert := models.AlertRecord{ID: uuid.New(), RuleName: "disk full", Severity: "high"}
+	rendered, err := service.RenderAlert(models.TemplateChannelSMS, "oncall", "en-US", alert)
+	require.NoError(t, err)
+	assert.Equal(t, "On-call: disk full High", rendered.Body)
+	assert.Equal(t, "oncall", rendered.Template)
+
+	require.NoError(t, service.Delete(en.ID))
+	assert.ErrorIs(t, service.Delete(en.ID), ErrTemplateNotFound)
+}
+
+func TestNotificationTemplate_Preview(t *testing.T) {
+	db := setupTemplateTestDB(t)
+	service := NewNotificationTemplateService(db)
+	tmpl := &models.NotificationTemplate{Channel: models.TemplateChannelEmail, Subject: "{{.Alert.RuleName}} on {{.VM.Name}}",
+		Body: "<p>{{.VM.Name}} {{.Links.VM}}</p>"}
+
+	sample, err := service.Preview(tmpl, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "CPU使用率过高 on web-server-01", sample.Subject)
+	assert.Equal(t, models.DefaultTemplateLanguage, sample.Language)
+
+	vmID := uuid.New()
+	vmName := "db-01"
+	require.NoError(t, db.Exec("INSERT INTO vms (id, name, status) VALUES (?, ?, ?)", vmID, vmName, "online").Error)
+	alert := models.AlertRecord{ID: uuid.New(), RuleID: uuid.New(), RuleName: "mem high", VMID: &vmID, VMName: &vmName,
+		Metric: "memory_usage", Severity: "medium", Status: models.AlertStatusActive, TriggeredAt: time.Now()}
+	require.NoError(t, db.Create(&alert).Error)
+
+	real, err := service.Preview(tmpl, &alert.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "mem high on db-01", real.Subject)
+	assert.Equal(t, "<p>db-01 /vms/"+vmID.String()+"</p>", real.Body)
+
+	missing := uuid.New()
+	_, err = service.Preview(tmpl, &missing)
+	assert.ErrorIs(t, err, ErrAlertNotFound)
+}
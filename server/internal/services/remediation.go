@@ -0,0 +1,457 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRemediationNotFound = errors.New("处置记录不存在")
+	ErrInvalidRemediation  = errors.New("处置动作无效")
+	ErrRemediationHalted   = errors.New("自动处置已紧急停止")
+	// ErrVMOperationUnsupported VM操作器不支持该操作
+	ErrVMOperationUnsupported = errors.New("不支持的VM操作")
+)
+
+// VM操作
+const (
+	VMOperationRestartGuest = "restart_guest" // 重启客户机操作系统
+	VMOperationPowerCycle   = "power_cycle"   // 断电后重新上电
+)
+
+const (
+	defaultRemediationTimeout    = 30 * time.Second
+	defaultRemediationMaxTimeout = 5 * time.Minute
+	defaultRemediationRetryDelay = 30 * time.Second
+	defaultRemediationWorkers    = 4
+	maxRemediationRetries        = 5
+	remediationOutputLimit       = 16 * 1024 // 每次尝试记录的输出上限（字节）
+)
+
+// VMOperator 对VM执行操作的接口，由vSphere采集器实现
+type VMOperator interface {
+	RestartGuest(ctx context.Context, vm models.VM) error
+	PowerCycle(ctx context.Context, vm models.VM) error
+}
+
+// RemediationAction 规则通知配置actions中的一个处置动作。
+// Body与Args使用text/template渲染，上下文与通知模板相同
+type RemediationAction struct {
+	Name            string            `json:"name"`
+	Type            string            `json:"type"`                      // http, script, vm
+	URL             string            `json:"url,omitempty"`             // http: 请求地址
+	Method          string            `json:"method,omitempty"`          // http: 请求方法，默认POST
+	Headers         map[string]string `json:"headers,omitempty"`         // http: 请求头
+	Body            string            `json:"body,omitempty"`            // http: 请求体模板
+	Script          string            `json:"script,omitempty"`          // script: 白名单中的脚本名称
+	Args            []string          `json:"args,omitempty"`            // script: 参数模板，不经过shell
+	Operation       string            `json:"operation,omitempty"`       // vm: restart_guest, power_cycle
+	Timeout         int               `json:"timeout,omitempty"`         // 单次执行超时（秒）
+	Retries         int               `json:"retries,omitempty"`         // 失败后的重试次数
+	RetryDelay      int               `json:"retryDelay,omitempty"`      // 首次重试间隔（秒），之后每次翻倍
+	RequireApproval bool              `json:"requireApproval,omitempty"` // 无论告警级别都需要审批
+}
+
+// remediationActions 解析规则通知配置中的处置动作
+func remediationActions(config models.JSONMap) ([]RemediationAction, error) {
+	raw, ok := config["actions"]
+	if !ok || raw == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+	var actions []RemediationAction
+	if err := json.Unmarshal(data, &actions); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRemediation, err)
+	}
+	for i := range actions {
+		if actions[i].Name == "" {
+			actions[i].Name = fmt.Sprintf("%s-%d", actions[i].Type, i+1)
+		}
+	}
+	return actions, nil
+}
+
+// RemediationService 告警自动处置：告警触发时按规则配置生成执行记录，
+// 需要审批的动作等待人工审批，其余进入队列由后台按重试策略执行。
+// 执行状态保存在数据库中，多实例部署时通过条件更新认领，重启后继续执行
+type RemediationService struct {
+	db                 *gorm.DB
+	operator           VMOperator
+	templates          *NotificationTemplateService
+	httpClient         *http.Client
+	scripts            map[string]string
+	approvalSeverities []string
+	maxTimeout         time.Duration
+	interval           time.Duration
+	enabled            bool
+	slots              chan struct{}  // 同时执行的动作数量上限
+	inflight           sync.WaitGroup // 执行中的动作
+
+	unsaved      map[uuid.UUID]map[string]interface{} // 保存失败的执行结果，下次检查时重新写入
+	unsavedMutex sync.Mutex
+
+	stopChan     chan struct{}
+	isRunning    bool
+	runningMutex sync.Mutex
+}
+
+// NewRemediationService 创建自动处置服务
+func NewRemediationService(db *gorm.DB) *RemediationService {
+	return &RemediationService{
+		db:                 db,
+		templates:          NewNotificationTemplateService(db),
+		httpClient:         &http.Client{},
+		scripts:            map[string]string{},
+		approvalSeverities: []string{"critical"},
+		maxTimeout:         defaultRemediationMaxTimeout,
+		interval:           10 * time.Second,
+		enabled:            true,
+		slots:              make(chan struct{}, defaultRemediationWorkers),
+		unsaved:            map[uuid.UUID]map[string]interface{}{},
+	}
+}
+
+// SetEnabled 设置是否执行处置动作，关闭后告警不再生成执行记录
+func (s *RemediationService) SetEnabled(enabled bool) {
+	s.enabled = enabled
+}
+
+// SetOperator 设置VM操作接口
+func (s *RemediationService) SetOperator(operator VMOperator) {
+	s.operator = operator
+}
+
+// SetTemplates 设置渲染请求体与脚本参数使用的模板服务
+func (s *RemediationService) SetTemplates(templates *NotificationTemplateService) {
+	if templates != nil {
+		s.templates = templates
+	}
+}
+
+// SetScripts 设置脚本白名单（名称 -> 绝对路径）
+func (s *RemediationService) SetScripts(scripts map[string]string) error {
+	whitelist := make(map[string]string, len(scripts))
+	for name, path := range scripts {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("脚本 %s 的路径必须是绝对路径: %s", name, path)
+		}
+		whitelist[name] = filepath.Clean(path)
+	}
+	s.scripts = whitelist
+	return nil
+}
+
+// SetApprovalSeverities 设置需要人工审批的告警级别
+func (s *RemediationService) SetApprovalSeverities(severities []string) {
+	s.approvalSeverities = severities
+}
+
+// SetMaxTimeout 设置单次执行的最长时间
+func (s *RemediationService) SetMaxTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		s.maxTimeout = timeout
+	}
+}
+
+// SetConcurrency 设置同时执行的动作数量上限（需在Start前调用）
+func (s *RemediationService) SetConcurrency(n int) {
+	if n > 0 {
+		s.slots = make(chan struct{}, n)
+	}
+}
+
+// SetInterval 设置执行队列检查间隔
+func (s *RemediationService) SetInterval(interval time.Duration) {
+	if interval > 0 {
+		s.interval = interval
+	}
+}
+
+// validateAction 校验动作配置
+func (s *RemediationService) validateAction(action RemediationAction) error {
+	switch action.Type {
+	case models.RemediationTypeHTTP:
+		if !strings.HasPrefix(action.URL, "http://") && !strings.HasPrefix(action.URL, "https://") {
+			return fmt.Errorf("%w: HTTP动作的URL无效", ErrInvalidRemediation)
+		}
+	case models.RemediationTypeScript:
+		if _, ok := s.scripts[action.Script]; !ok {
+			return fmt.Errorf("%w: 脚本 %s 不在白名单中", ErrInvalidRemediation, action.Script)
+		}
+	case models.RemediationTypeVM:
+		if action.Operation != VMOperationRestartGuest && action.Operation != VMOperationPowerCycle {
+			return fmt.Errorf("%w: 不支持的VM操作 %s", ErrInvalidRemediation, action.Operation)
+		}
+	default:
+		return fmt.Errorf("%w: 不支持的动作类型 %s", ErrInvalidRemediation, action.Type)
+	}
+	if action.Retries < 0 || action.Retries > maxRemediationRetries {
+		return fmt.Errorf("%w: 重试次数须在0到%d之间", ErrInvalidRemediation, maxRemediationRetries)
+	}
+	return nil
+}
+
+// requiresApproval 动作是否需要人工审批
+func (s *RemediationService) requiresApproval(action RemediationAction, severity string) bool {
+	return action.RequireApproval || containsString(s.approvalSeverities, severity)
+}
+
+// Plan 按规则配置为新告警生成处置执行记录。配置无效的动作记为失败，紧急停止时记为已取消
+func (s *RemediationService) Plan(alert *models.AlertRecord, rule models.AlertRule) []models.RemediationExecution {
+	if !s.enabled {
+		return nil
+	}
+	actions, err := remediationActions(rule.NotificationConfig)
+	if err != nil {
+		logger.Error("解析处置动作失败", zap.String("rule_id", rule.ID.String()), zap.Error(err))
+		return nil
+	}
+	if len(actions) == 0 {
+		return nil
+	}
+	halted := s.halted()
+
+	now := time.Now()
+	executions := make([]models.RemediationExecution, 0, len(actions))
+	for _, action := range actions {
+		snapshot := models.JSONMap{}
+		if data, err := json.Marshal(action); err == nil {
+			_ = json.Unmarshal(data, &snapshot)
+		}
+		execution := models.RemediationExecution{
+			ID:          uuid.New(),
+			AlertID:     alert.ID,
+			RuleID:      rule.ID,
+			VMID:        alert.VMID,
+			ActionName:  action.Name,
+			ActionType:  action.Type,
+			Action:      snapshot,
+			MaxAttempts: 1 + action.Retries,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+
+		err := s.validateAction(action)
+		if err == nil && action.Type == models.RemediationTypeVM && alert.VMID == nil {
+			err = fmt.Errorf("%w: 告警未关联VM（如聚合告警），不能执行VM操作", ErrInvalidRemediation)
+		}
+		switch {
+		case err != nil:
+			execution.Status = models.RemediationFailed
+			execution.LastError = optionalString(err.Error())
+			execution.FinishedAt = &now
+			execution.Log = remediationLogLine(now, "动作配置无效: "+err.Error())
+		case halted:
+			execution.Status = models.RemediationCancelled
+			execution.Reason = optionalString(ErrRemediationHalted.Error())
+			execution.FinishedAt = &now
+			execution.Log = remediationLogLine(now, "紧急停止开关已打开，未执行")
+		case s.requiresApproval(action, alert.Severity):
+			execution.Status = models.RemediationPendingApproval
+			execution.Log = remediationLogLine(now, fmt.Sprintf("%s级别告警触发，等待审批", alert.Severity))
+		default:
+			execution.Status = models.RemediationQueued
+			execution.NextAttemptAt = &now
+			execution.Log = remediationLogLine(now, "已加入执行队列")
+		}
+		executions = append(executions, execution)
+	}
+
+	if err := s.db.Create(&executions).Error; err != nil {
+		logger.Error("保存处置执行记录失败", zap.String("alert_id", alert.ID.String()), zap.Error(err))
+		return nil
+	}
+	alert.Remediations = executions
+	return executions
+}
+
+// Approve 审批通过，动作进入执行队列
+func (s *RemediationService) Approve(id uuid.UUID, actor AlertActor) (*models.RemediationExecution, error) {
+	if s.halted() {
+		return nil, ErrRemediationHalted
+	}
+	now := time.Now()
+	return s.decide(id, actor, models.RemediationQueued, map[string]interface{}{
+		"next_attempt_at": now,
+	}, fmt.Sprintf("%s 审批通过", actorLabel(actor)))
+}
+
+// Reject 拒绝执行
+func (s *RemediationService) Reject(id uuid.UUID, actor AlertActor, reason string) (*models.RemediationExecution, error) {
+	now := time.Now()
+	message := fmt.Sprintf("%s 拒绝执行", actorLabel(actor))
+	if reason != "" {
+		message += ": " + reason
+	}
+	return s.decide(id, actor, models.RemediationRejected, map[string]interface{}{
+		"reason":      optionalString(reason),
+		"finished_at": now,
+	}, message)
+}
+
+// decide 将等待审批的执行记录改为指定状态（条件更新，防止重复审批）
+func (s *RemediationService) decide(id uuid.UUID, actor AlertActor, status string, updates map[string]interface{}, message string) (*models.RemediationExecution, error) {
+	execution, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if execution.Status != models.RemediationPendingApproval {
+		return nil, fmt.Errorf("%w: 当前状态为 %s，无需审批", ErrInvalidRemediation, execution.Status)
+	}
+
+	now := time.Now()
+	updates["status"] = status
+	updates["approved_by"] = actor.ID
+	updates["approved_by_name"] = optionalString(actor.Name)
+	updates["approved_at"] = now
+	updates["log"] = execution.Log + remediationLogLine(now, message)
+	updates["updated_at"] = now
+	result := s.db.Model(&models.RemediationExecution{}).
+		Where("id = ? AND status = ?", id, models.RemediationPendingApproval).
+		Updates(updates)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("%w: 已被其他用户处理", ErrInvalidRemediation)
+	}
+	return s.Get(id)
+}
+
+// Get 获取执行记录
+func (s *RemediationService) Get(id uuid.UUID) (*models.RemediationExecution, error) {
+	var execution models.RemediationExecution
+	if err := s.db.First(&execution, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRemediationNotFound
+		}
+		return nil, err
+	}
+	return &execution, nil
+}
+
+// ListForAlert 获取告警的全部处置执行记录
+func (s *RemediationService) ListForAlert(alertID uuid.UUID) ([]models.RemediationExecution, error) {
+	var executions []models.RemediationExecution
+	err := s.db.Where("alert_id = ?", alertID).Order("created_at ASC").Find(&executions).Error
+	return executions, err
+}
+
+// List 分页获取执行记录，可按状态筛选（如pending_approval查看待审批）
+func (s *RemediationService) List(status string, page, pageSize int) ([]models.RemediationExecution, int64, error) {
+	query := s.db.Model(&models.RemediationExecution{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var executions []models.RemediationExecution
+	err := query.Order("created_at DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&executions).Error
+	return executions, total, err
+}
+
+// KillSwitch 获取紧急停止开关状态
+func (s *RemediationService) KillSwitch() (*models.RemediationSwitch, error) {
+	var sw models.RemediationSwitch
+	err := s.db.Where("id = ?", 1).First(&sw).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.RemediationSwitch{ID: 1}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sw, nil
+}
+
+// SetKillSwitch 打开或关闭紧急停止开关。打开时取消所有等待审批和排队中的动作，执行中的动作不会被中断但不再重试
+func (s *RemediationService) SetKillSwitch(engaged bool, reason string, actor AlertActor) (*models.RemediationSwitch, error) {
+	sw := models.RemediationSwitch{
+		ID:            1,
+		Engaged:       engaged,
+		Reason:        optionalString(reason),
+		ChangedBy:     actor.ID,
+		ChangedByName: optionalString(actor.Name),
+		UpdatedAt:     time.Now(),
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&sw).Error; err != nil {
+			return err
+		}
+		if !engaged {
+			return nil
+		}
+		return cancelRemediations(tx, tx.Where("status IN ?", []string{models.RemediationPendingApproval, models.RemediationQueued}),
+			ErrRemediationHalted.Error())
+	})
+	if err != nil {
+		return nil, err
+	}
+	logger.Warn("自动处置紧急停止开关已变更", zap.Bool("engaged", engaged), zap.String("operator", actor.Name), zap.String("reason", reason))
+	return &sw, nil
+}
+
+// halted 紧急停止开关是否打开（查询失败时视为打开）
+func (s *RemediationService) halted() bool {
+	sw, err := s.KillSwitch()
+	if err != nil {
+		logger.Error("查询紧急停止开关失败", zap.Error(err))
+		return true
+	}
+	return sw.Engaged
+}
+
+// cancelRemediations 取消查询条件匹配的执行记录，并在日志中记录原因
+func cancelRemediations(tx *gorm.DB, scope *gorm.DB, reason string) error {
+	var executions []models.RemediationExecution
+	if err := scope.Find(&executions).Error; err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, execution := range executions {
+		if err := tx.Model(&models.RemediationExecution{}).
+			Where("id = ? AND status = ?", execution.ID, execution.Status).
+			Updates(map[string]interface{}{
+				"status":          models.RemediationCancelled,
+				"reason":          reason,
+				"next_attempt_at": nil,
+				"finished_at":     now,
+				"log":             execution.Log + remediationLogLine(now, "已取消: "+reason),
+				"updated_at":      now,
+			}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remediationLogLine 生成一行带时间的执行日志
+func remediationLogLine(at time.Time, message string) string {
+	return fmt.Sprintf("[%s] %s\n", at.Format("2006-01-02 15:04:05"), message)
+}
+
+// actorLabel 日志中的操作人名称
+func actorLabel(actor AlertActor) string {
+	if actor.Name == "" {
+		return "系统"
+	}
+	return actor.Name
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package services
+
+import "os/exec"
+
+// isolateProcess 非Unix平台只结束脚本进程本身
+func isolateProcess(cmd *exec.Cmd) {}
@@ -0,0 +1,426 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// Start 启动执行队列处理
+func (s *RemediationService) Start() error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("自动处置服务已经在运行")
+	}
+
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	go s.loop()
+
+	logger.Info("自动处置服务已启动", zap.Duration("检查间隔", s.interval), zap.Bool("enabled", s.enabled))
+	return nil
+}
+
+// Stop 停止执行队列处理，等待执行中的动作结束
+func (s *RemediationService) Stop() {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+	close(s.stopChan)
+	s.isRunning = false
+	s.Wait()
+	s.flushUnsaved()
+	logger.Info("自动处置服务已停止")
+}
+
+// Wait 等待执行中的动作结束
+func (s *RemediationService) Wait() {
+	s.inflight.Wait()
+}
+
+// loop 定期执行到期的动作
+func (s *RemediationService) loop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.RunOnce(time.Now()); err != nil {
+				logger.Error("执行自动处置失败", zap.Error(err))
+			}
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// RunOnce 在并发上限内异步执行到期的排队动作，返回本次开始执行的动作数量，
+// 超出上限的动作留在队列中由下次检查执行。紧急停止开关打开时取消排队中的动作；告警已结束的动作不再执行
+func (s *RemediationService) RunOnce(now time.Time) (int, error) {
+	if !s.enabled {
+		return 0, nil
+	}
+	if s.halted() {
+		return 0, cancelRemediations(s.db, s.db.Where("status = ?", models.RemediationQueued), ErrRemediationHalted.Error())
+	}
+	s.flushUnsaved()
+	s.recoverStale(now)
+
+	var executions []models.RemediationExecution
+	if err := s.db.Where("status = ? AND next_attempt_at <= ?", models.RemediationQueued, now).
+		Order("next_attempt_at").
+		Find(&executions).Error; err != nil {
+		return 0, fmt.Errorf("查询待执行动作失败: %w", err)
+	}
+
+	ran := 0
+	for _, execution := range executions {
+		var alert models.AlertRecord
+		if err := s.db.Where("id = ?", execution.AlertID).First(&alert).Error; err != nil || !isOpenStatus(alert.Status) {
+			if err := cancelRemediations(s.db, s.db.Where("id = ?", execution.ID), "告警已结束"); err != nil {
+				logger.Error("取消处置动作失败", zap.String("execution_id", execution.ID.String()), zap.Error(err))
+			}
+			continue
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			return ran, nil
+		}
+		if !s.claim(&execution, now) {
+			<-s.slots
+			continue
+		}
+
+		ran++
+		s.inflight.Add(1)
+		go func(execution models.RemediationExecution, alert models.AlertRecord) {
+			defer func() {
+				<-s.slots
+				s.inflight.Done()
+			}()
+			s.attempt(execution, alert)
+		}(execution, alert)
+	}
+	return ran, nil
+}
+
+// claim 认领排队中的动作（条件更新，多实例时只有一个实例执行）
+func (s *RemediationService) claim(execution *models.RemediationExecution, now time.Time) bool {
+	result := s.db.Model(&models.RemediationExecution{}).
+		Where("id = ? AND status = ? AND attempts = ?", execution.ID, models.RemediationQueued, execution.Attempts).
+		Updates(map[string]interface{}{
+			"status":          models.RemediationRunning,
+			"attempts":        execution.Attempts + 1,
+			"next_attempt_at": nil,
+			"started_at":      now,
+			"updated_at":      now,
+		})
+	if result.Error != nil {
+		logger.Error("认领处置动作失败", zap.String("execution_id", execution.ID.String()), zap.Error(result.Error))
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+	execution.Status = models.RemediationRunning
+	execution.Attempts++
+	execution.StartedAt = &now
+	return true
+}
+
+// recoverStale 执行中的实例退出后，超过最长执行时间仍处于running的动作按失败处理（可重试时重新排队）
+func (s *RemediationService) recoverStale(now time.Time) {
+	var stale []models.RemediationExecution
+	deadline := now.Add(-(s.maxTimeout + s.interval))
+	if err := s.db.Where("status = ? AND started_at < ?", models.RemediationRunning, deadline).Find(&stale).Error; err != nil {
+		logger.Error("查询超时的处置动作失败", zap.Error(err))
+		return
+	}
+	for _, execution := range stale {
+		if s.hasUnsaved(execution.ID) {
+			continue
+		}
+		s.finish(execution, "", errors.New("执行实例中断，未收到执行结果"), now)
+	}
+}
+
+// attempt 执行一次动作并记录结果
+func (s *RemediationService) attempt(execution models.RemediationExecution, alert models.AlertRecord) {
+	var action RemediationAction
+	if data, err := json.Marshal(execution.Action); err == nil {
+		_ = json.Unmarshal(data, &action)
+	}
+
+	timeout := defaultRemediationTimeout
+	if action.Timeout > 0 {
+		timeout = time.Duration(action.Timeout) * time.Second
+	}
+	if timeout > s.maxTimeout {
+		timeout = s.maxTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := s.execute(ctx, action, alert)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("执行超时（%s）: %w", timeout, err)
+	}
+	s.finish(execution, output, err, time.Now())
+}
+
+// execute 按动作类型执行
+func (s *RemediationService) execute(ctx context.Context, action RemediationAction, alert models.AlertRecord) (string, error) {
+	if err := s.validateAction(action); err != nil {
+		return "", err
+	}
+	data := s.templates.BuildData(alert, "")
+
+	switch action.Type {
+	case models.RemediationTypeHTTP:
+		return s.runHTTP(ctx, action, data)
+	case models.RemediationTypeScript:
+		return s.runScript(ctx, action, data)
+	default:
+		return s.runVMOperation(ctx, action, data)
+	}
+}
+
+// finish 记录一次尝试的结果：成功、失败后按指数退避重新排队，或重试次数用尽后失败
+func (s *RemediationService) finish(execution models.RemediationExecution, output string, err error, now time.Time) {
+	message := fmt.Sprintf("第%d次执行成功", execution.Attempts)
+	updates := map[string]interface{}{
+		"status":      models.RemediationSucceeded,
+		"last_error":  nil,
+		"finished_at": now,
+		"updated_at":  now,
+	}
+	if err != nil {
+		message = fmt.Sprintf("第%d次执行失败: %v", execution.Attempts, err)
+		updates["last_error"] = err.Error()
+		// 操作器不支持的操作重试也不会成功，直接失败
+		if execution.Attempts < execution.MaxAttempts && !errors.Is(err, ErrVMOperationUnsupported) {
+			delay := remediationRetryDelay(execution.Action) << (execution.Attempts - 1)
+			next := now.Add(delay)
+			message += fmt.Sprintf("，%s后重试", delay)
+			updates["status"] = models.RemediationQueued
+			updates["next_attempt_at"] = next
+			updates["finished_at"] = nil
+		} else {
+			updates["status"] = models.RemediationFailed
+		}
+	}
+
+	entry := remediationLogLine(now, message)
+	if output != "" {
+		entry += strings.TrimRight(output, "\n") + "\n"
+	}
+	updates["log"] = execution.Log + entry
+
+	if err := s.saveResult(execution.ID, updates); err != nil {
+		// 保留执行结果，下次检查时重新写入，避免已执行的动作被当作中断处理
+		logger.Error("保存处置执行结果失败", zap.String("execution_id", execution.ID.String()), zap.Error(err))
+		s.unsavedMutex.Lock()
+		s.unsaved[execution.ID] = updates
+		s.unsavedMutex.Unlock()
+		return
+	}
+	logger.Info("自动处置已执行",
+		zap.String("execution_id", execution.ID.String()),
+		zap.String("action", execution.ActionName),
+		zap.Any("status", updates["status"]),
+		zap.Int("attempt", execution.Attempts))
+}
+
+// saveResult 写入执行结果，只更新仍处于执行中的记录
+func (s *RemediationService) saveResult(id uuid.UUID, updates map[string]interface{}) error {
+	return s.db.Model(&models.RemediationExecution{}).
+		Where("id = ? AND status = ?", id, models.RemediationRunning).
+		Updates(updates).Error
+}
+
+// flushUnsaved 重新写入之前保存失败的执行结果
+func (s *RemediationService) flushUnsaved() {
+	s.unsavedMutex.Lock()
+	defer s.unsavedMutex.Unlock()
+
+	for id, updates := range s.unsaved {
+		if err := s.saveResult(id, updates); err != nil {
+			logger.Error("重新保存处置执行结果失败", zap.String("execution_id", id.String()), zap.Error(err))
+			continue
+		}
+		delete(s.unsaved, id)
+	}
+}
+
+// hasUnsaved 执行结果是否等待重新写入
+func (s *RemediationService) hasUnsaved(id uuid.UUID) bool {
+	s.unsavedMutex.Lock()
+	defer s.unsavedMutex.Unlock()
+	_, ok := s.unsaved[id]
+	return ok
+}
+
+// remediationRetryDelay 动作配置的首次重试间隔
+func remediationRetryDelay(action models.JSONMap) time.Duration {
+	if seconds, ok := action["retryDelay"].(float64); ok && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultRemediationRetryDelay
+}
+
+// runHTTP 调用HTTP接口，2xx视为成功
+func (s *RemediationService) runHTTP(ctx context.Context, action RemediationAction, data TemplateData) (string, error) {
+	body, err := renderText("body", action.Body, data)
+	if err != nil {
+		return "", err
+	}
+	method := strings.ToUpper(action.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, action.URL, strings.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range action.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, remediationOutputLimit))
+	output := fmt.Sprintf("%s %s -> %d\n%s", method, action.URL, resp.StatusCode, respBody)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return output, fmt.Errorf("接口返回非成功状态码: %d", resp.StatusCode)
+	}
+	return output, nil
+}
+
+// runScript 执行白名单中的脚本：参数直接传递不经过shell，环境变量只包含告警信息，工作目录为脚本所在目录
+func (s *RemediationService) runScript(ctx context.Context, action RemediationAction, data TemplateData) (string, error) {
+	path := s.scripts[action.Script]
+	args := make([]string, 0, len(action.Args))
+	for i, arg := range action.Args {
+		rendered, err := renderText(fmt.Sprintf("arg%d", i), arg, data)
+		if err != nil {
+			return "", err
+		}
+		args = append(args, rendered)
+	}
+
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Dir = filepath.Dir(path)
+	cmd.Env = scriptEnv(data)
+	cmd.WaitDelay = 5 * time.Second
+	isolateProcess(cmd)
+	output := &limitedBuffer{limit: remediationOutputLimit}
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	err := cmd.Run()
+	if err != nil {
+		return output.String(), fmt.Errorf("脚本执行失败: %w", err)
+	}
+	return output.String(), nil
+}
+
+// scriptEnv 脚本的受限环境变量，不继承服务进程的环境（避免泄露数据库密码等配置）
+func scriptEnv(data TemplateData) []string {
+	env := []string{
+		"PATH=/usr/local/bin:/usr/bin:/bin",
+		"LANG=C.UTF-8",
+		"ALERT_ID=" + data.Alert.ID.String(),
+		"ALERT_RULE=" + data.Alert.RuleName,
+		"ALERT_SEVERITY=" + data.Alert.Severity,
+		"ALERT_METRIC=" + data.Alert.Metric,
+		fmt.Sprintf("ALERT_VALUE=%g", data.Alert.TriggerValue),
+		fmt.Sprintf("ALERT_THRESHOLD=%g", data.Alert.Threshold),
+		"VM_NAME=" + getStringValue(data.Alert.VMName),
+	}
+	if data.Alert.VMID != nil {
+		env = append(env, "VM_ID="+data.Alert.VMID.String())
+	}
+	if data.VM != nil && data.VM.IP != nil {
+		env = append(env, "VM_IP="+*data.VM.IP)
+	}
+	return env
+}
+
+// runVMOperation 通过采集器对告警VM执行操作
+func (s *RemediationService) runVMOperation(ctx context.Context, action RemediationAction, data TemplateData) (string, error) {
+	if s.operator == nil {
+		return "", errors.New("未配置VM操作接口")
+	}
+	if data.VM == nil {
+		return "", errors.New("告警未关联VM")
+	}
+
+	var err error
+	if action.Operation == VMOperationPowerCycle {
+		err = s.operator.PowerCycle(ctx, *data.VM)
+	} else {
+		err = s.operator.RestartGuest(ctx, *data.VM)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("已对 %s 执行 %s", data.VM.Name, action.Operation), nil
+}
+
+// limitedBuffer 只保留前limit字节的输出缓冲
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write 写入输出，超出上限的部分丢弃
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// String 返回输出内容
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + "\n...（输出已截断）"
+	}
+	return b.buf.String()
+}
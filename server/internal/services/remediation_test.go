@@ -0,0 +1,363 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func setupRemediationTestDB(t *testing.T) *gorm.DB {
+	db := setupAlertStateTestDB(t)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1) // 动作在其他goroutine中执行，内存数据库每个连接相互独立
+	require.NoError(t, db.Exec(`CREATE TABLE remediation_executions (
+		id TEXT PRIMARY KEY, alert_id TEXT, rule_id TEXT, vm_id TEXT, action_name TEXT, action_type TEXT, action TEXT,
+		status TEXT, attempts INTEGER DEFAULT 0, max_attempts INTEGER DEFAULT 1, next_attempt_at DATETIME,
+		approved_by TEXT, approved_by_name TEXT, approved_at DATETIME, reason TEXT, last_error TEXT, log TEXT,
+		started_at DATETIME, finished_at DATETIME, created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE remediation_switch (
+		id INTEGER PRIMARY KEY, engaged BOOLEAN DEFAULT 0, reason TEXT, changed_by TEXT, changed_by_name TEXT, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE vms (id TEXT PRIMARY KEY, name TEXT, vmware_id TEXT, ip TEXT, status TEXT)`).Error)
+	return db
+}
+
+// createRemediationAlert 创建告警并按规则动作生成执行记录
+func createRemediationAlert(t *testing.T, db *gorm.DB, service *RemediationService, severity string, actions []interface{}) *models.AlertRecord {
+	vmID := uuid.New()
+	vmName := "web-01"
+	require.NoError(t, db.Exec("INSERT INTO vms (id, name, vmware_id, status) VALUES (?, ?, ?, ?)", vmID, vmName, "vm-42", "online").Error)
+	alert := &models.AlertRecord{ID: uuid.New(), RuleID: uuid.New(), RuleName: "cpu high", VMID: &vmID, VMName: &vmName,
+		Metric: "cpu_usage", Severity: severity, TriggerValue: 95, Threshold: 90, Status: models.AlertStatusActive, TriggeredAt: time.Now()}
+	require.NoError(t, db.Create(alert).Error)
+	rule := models.AlertRule{ID: alert.RuleID, Name: alert.RuleName, Severity: severity, NotificationConfig: models.JSONMap{"actions": actions}}
+	service.Plan(alert, rule)
+	return alert
+}
+
+type fakeVMOperator struct {
+	mu        sync.Mutex
+	restarted []string
+	onRestart func()
+}
+
+func (f *fakeVMOperator) RestartGuest(ctx context.Context, vm models.VM) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.restarted = append(f.restarted, vm.Name)
+	if f.onRestart != nil {
+		f.onRestart()
+	}
+	return nil
+}
+
+func (f *fakeVMOperator) restartedVMs() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.restarted...)
+}
+
+func (f *fakeVMOperator) PowerCycle(ctx context.Context, vm models.VM) error {
+	return nil
+}
+
+func TestRemediation_PlanAndHTTPRetry(t *testing.T) {
+	var calls int32
+	var mu sync.Mutex
+	var lastBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		lastBody = string(body)
+		mu.Unlock()
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.Write([]byte("restarted"))
+	}))
+	defer server.Close()
+
+	db := setupRemediationTestDB(t)
+	service := NewRemediationService(db)
+	operator := &fakeVMOperator{}
+	service.SetOperator(operator)
+
+	alert := createRemediationAlert(t, db, service, "high", []interface{}{
+		map[string]interface{}{"name": "restart-api", "type": "http", "url": server.URL, "body": `{"rule":"{{.Alert.RuleName}}","vm":"{{.VM.Name}}"}`, "retries": 1, "retryDelay": 1},
+		map[string]interface{}{"type": "vm", "operation": "restart_guest"},
+		map[string]interface{}{"type": "vm", "operation": "delete"},
+		map[string]interface{}{"type": "script", "script": "unknown"},
+		map[string]interface{}{"type": "vm", "operation": "power_cycle", "requireApproval": true},
+	})
+	executions, err := service.ListForAlert(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, executions, 5)
+	assert.Equal(t, "vm-2", executions[1].ActionName)
+	var statuses []string
+	for _, execution := range executions {
+		statuses = append(statuses, execution.Status)
+	}
+	assert.Equal(t, []string{models.RemediationQueued, models.RemediationQueued, models.RemediationFailed,
+		models.RemediationFailed, models.RemediationPendingApproval}, statuses)
+
+	// 首次失败后按退避时间重新排队
+	now := time.Now()
+	ran, err := service.RunOnce(now)
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 2, ran)
+	assert.Equal(t, []string{"web-01"}, operator.restartedVMs())
+	mu.Lock()
+	assert.JSONEq(t, `{"rule":"cpu high","vm":"web-01"}`, lastBody)
+	mu.Unlock()
+	httpExec, err := service.Get(executions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationQueued, httpExec.Status)
+	assert.Equal(t, 1, httpExec.Attempts)
+	assert.Contains(t, *httpExec.LastError, "502")
+
+	ran, err = service.RunOnce(now.Add(2 * time.Second))
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	httpExec, err = service.Get(executions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationSucceeded, httpExec.Status)
+	assert.Equal(t, 2, httpExec.Attempts)
+	assert.Contains(t, httpExec.Log, "第1次执行失败")
+	assert.Contains(t, httpExec.Log, "第2次执行成功")
+	assert.Contains(t, httpExec.Log, "restarted")
+}
+
+func TestRemediation_ScriptApprovalAndSandbox(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "restart.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$ALERT_RULE $1 ${SECRET_TOKEN:-unset}\"\n"), 0755))
+	slow := filepath.Join(dir, "slow.sh")
+	require.NoError(t, os.WriteFile(slow, []byte("#!/bin/sh\nsleep 5\n"), 0755))
+	t.Setenv("SECRET_TOKEN", "leaked")
+
+	db := setupRemediationTestDB(t)
+	service := NewRemediationService(db)
+	assert.Error(t, service.SetScripts(map[string]string{"relative": "scripts/x.sh"}))
+	require.NoError(t, service.SetScripts(map[string]string{"restart": script, "slow": slow}))
+
+	// critical级别告警需审批
+	alert := createRemediationAlert(t, db, service, "critical", []interface{}{
+		map[string]interface{}{"type": "script", "script": "restart", "args": []string{"{{.VM.Name}}"}},
+		map[string]interface{}{"type": "script", "script": "slow", "timeout": 1},
+	})
+	executions, err := service.ListForAlert(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, executions, 2)
+	ran, err := service.RunOnce(time.Now())
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 0, ran)
+
+	approverID := uuid.New()
+	approved, err := service.Approve(executions[0].ID, AlertActor{ID: &approverID, Name: "Alice"})
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationQueued, approved.Status)
+	assert.Equal(t, "Alice", *approved.ApprovedByName)
+	_, err = service.Approve(executions[0].ID, AlertActor{Name: "Bob"})
+	assert.ErrorIs(t, err, ErrInvalidRemediation)
+	_, err = service.Approve(executions[1].ID, AlertActor{Name: "Alice"})
+	require.NoError(t, err)
+
+	ran, err = service.RunOnce(time.Now())
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 2, ran)
+	done, err := service.Get(executions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationSucceeded, done.Status)
+	assert.Contains(t, done.Log, "Alice 审批通过")
+	assert.Contains(t, done.Log, "cpu high web-01 unset")
+
+	timedOut, err := service.Get(executions[1].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationFailed, timedOut.Status)
+	assert.Contains(t, *timedOut.LastError, "执行超时")
+}
+
+func TestRemediation_KillSwitchAndClosedAlert(t *testing.T) {
+	db := setupRemediationTestDB(t)
+	service := NewRemediationService(db)
+	service.SetOperator(&fakeVMOperator{})
+	restart := []interface{}{map[string]interface{}{"type": "vm", "operation": "restart_guest"}}
+	gated := []interface{}{map[string]interface{}{"type": "vm", "operation": "restart_guest", "requireApproval": true}}
+
+	queued := createRemediationAlert(t, db, service, "low", restart)
+	pending := createRemediationAlert(t, db, service, "low", gated)
+
+	sw, err := service.SetKillSwitch(true, "change freeze", AlertActor{Name: "Alice"})
+	require.NoError(t, err)
+	assert.True(t, sw.Engaged)
+	for _, alertID := range []uuid.UUID{queued.ID, pending.ID} {
+		executions, err := service.ListForAlert(alertID)
+		require.NoError(t, err)
+		assert.Equal(t, models.RemediationCancelled, executions[0].Status)
+	}
+
+	halted := createRemediationAlert(t, db, service, "low", gated)
+	executions, err := service.ListForAlert(halted.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationCancelled, executions[0].Status)
+	_, err = service.Approve(executions[0].ID, AlertActor{Name: "Alice"})
+	assert.ErrorIs(t, err, ErrRemediationHalted)
+
+	_, err = service.SetKillSwitch(false, "", AlertActor{Name: "Alice"})
+	require.NoError(t, err)
+
+	// 告警结束后排队中的动作不再执行
+	closed := createRemediationAlert(t, db, service, "low", restart)
+	require.NoError(t, db.Model(&models.AlertRecord{}).Where("id = ?", closed.ID).Update("status", models.AlertStatusResolved).Error)
+	ran, err := service.RunOnce(time.Now())
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 0, ran)
+	executions, err = service.ListForAlert(closed.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationCancelled, executions[0].Status)
+	assert.Equal(t, "告警已结束", *executions[0].Reason)
+}
+
+func TestRemediation_UnsupportedVMOperationFails(t *testing.T) {
+	db := setupRemediationTestDB(t)
+	service := NewRemediationService(db)
+	service.SetOperator(&VSphereCollector{isRunning: true})
+
+	alert := createRemediationAlert(t, db, service, "low", []interface{}{
+		map[string]interface{}{"type": "vm", "operation": "power_cycle", "retries": 2},
+	})
+	executions, err := service.ListForAlert(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, executions, 1)
+
+	ran, err := service.RunOnce(time.Now())
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	failed, err := service.Get(executions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationFailed, failed.Status)
+	assert.Equal(t, 1, failed.Attempts)
+	assert.Contains(t, *failed.LastError, "不支持")
+	assert.NotContains(t, failed.Log, "执行 power_cycle")
+}
+
+func TestRemediation_ConcurrencyLimit(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	db := setupRemediationTestDB(t)
+	service := NewRemediationService(db)
+	service.SetConcurrency(1)
+	hook := map[string]interface{}{"type": "http", "url": server.URL}
+	alert := createRemediationAlert(t, db, service, "low", []interface{}{hook, hook})
+
+	// 执行中的动作不阻塞下次检查，超出并发上限的动作留在队列中
+	ran, err := service.RunOnce(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	ran, err = service.RunOnce(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 0, ran)
+
+	close(release)
+	service.Wait()
+	ran, err = service.RunOnce(time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	service.Wait()
+
+	executions, err := service.ListForAlert(alert.ID)
+	require.NoError(t, err)
+	for _, execution := range executions {
+		assert.Equal(t, models.RemediationSucceeded, execution.Status)
+	}
+}
+
+func TestRemediation_PlanRejectsVMActionWithoutVM(t *testing.T) {
+	db := setupRemediationTestDB(t)
+	service := NewRemediationService(db)
+	service.SetOperator(&fakeVMOperator{})
+
+	// 聚合告警不关联VM，VM操作在生成时即失败，HTTP动作不受影响
+	alert := &models.AlertRecord{ID: uuid.New(), RuleID: uuid.New(), RuleName: "fleet cpu", Metric: "cpu_usage",
+		Severity: "low", Status: models.AlertStatusActive, TriggeredAt: time.Now()}
+	require.NoError(t, db.Create(alert).Error)
+	rule := models.AlertRule{ID: alert.RuleID, Name: alert.RuleName, Severity: "low", NotificationConfig: models.JSONMap{"actions": []interface{}{
+		map[string]interface{}{"type": "vm", "operation": "restart_guest", "retries": 2},
+		map[string]interface{}{"type": "http", "url": "http://127.0.0.1:1/hook"},
+	}}}
+	executions := service.Plan(alert, rule)
+	require.Len(t, executions, 2)
+	assert.Equal(t, models.RemediationFailed, executions[0].Status)
+	assert.Contains(t, *executions[0].LastError, "未关联VM")
+	assert.Equal(t, models.RemediationQueued, executions[1].Status)
+}
+
+func TestRemediation_ResultSavedAfterWriteFailure(t *testing.T) {
+	db := setupRemediationTestDB(t)
+	var failWrites atomic.Bool
+	require.NoError(t, db.Callback().Update().Before("gorm:update").Register("test:fail_writes", func(tx *gorm.DB) {
+		if failWrites.Load() {
+			tx.AddError(errors.New("database unavailable"))
+		}
+	}))
+	service := NewRemediationService(db)
+	// 动作执行期间数据库不可用，执行结果无法写入
+	service.SetOperator(&fakeVMOperator{onRestart: func() { failWrites.Store(true) }})
+
+	alert := createRemediationAlert(t, db, service, "low", []interface{}{
+		map[string]interface{}{"type": "vm", "operation": "restart_guest"},
+	})
+	executions, err := service.ListForAlert(alert.ID)
+	require.NoError(t, err)
+	require.Len(t, executions, 1)
+
+	now := time.Now()
+	ran, err := service.RunOnce(now)
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 1, ran)
+	failWrites.Store(false)
+	running, err := service.Get(executions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationRunning, running.Status)
+
+	// 下次检查时写入保存失败的结果，超时恢复不会将其当作中断
+	ran, err = service.RunOnce(now.Add(time.Hour))
+	service.Wait()
+	require.NoError(t, err)
+	assert.Equal(t, 0, ran)
+	done, err := service.Get(executions[0].ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.RemediationSucceeded, done.Status)
+	assert.Contains(t, done.Log, "第1次执行成功")
+	assert.NotContains(t, done.Log, "执行实例中断")
+}
@@ -0,0 +1,595 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RetentionPolicyReport 单个保留策略的执行（或预演）结果
+type RetentionPolicyReport struct {
+	PolicyID     *uuid.UUID `json:"policyId,omitempty"` // 为空表示默认策略
+	Name         string     `json:"name"`
+	Target       string     `json:"target"`
+	RawCutoff    time.Time  `json:"rawCutoff"`
+	RollupCutoff *time.Time `json:"rollupCutoff,omitempty"`
+	RawRows      int64      `json:"rawRows"`      // 删除的原始数据行数
+	RolledUpRows int64      `json:"rolledUpRows"` // 删除前被汇总的原始数据行数
+	RollupRows   int64      `json:"rollupRows"`   // 删除的汇总数据行数
+	Incomplete   bool       `json:"incomplete"`   // 达到单次批次上限，剩余数据留待下次执行
+}
+
+// RetentionReport 保留策略执行报告
+type RetentionReport struct {
+	DryRun     bool                    `json:"dryRun"`
+	StartedAt  time.Time               `json:"startedAt"`
+	Duration   time.Duration           `json:"duration"`
+	Policies   []RetentionPolicyReport `json:"policies"`
+	TotalRows  int64                   `json:"totalRows"`
+	Incomplete bool                    `json:"incomplete"`
+}
+
+// retentionScope 排序后的生效策略
+type retentionScope struct {
+	policy     *models.RetentionPolicy // nil 表示默认策略
+	name       string
+	rawDays    int
+	rollupDays int
+}
+
+// sqlCond 带参数的SQL条件片段
+type sqlCond struct {
+	sql  string
+	args []interface{}
+}
+
+// RetentionService 数据保留策略服务
+type RetentionService struct {
+	db                *gorm.DB
+	audit             *AuditLogService
+	interval          time.Duration
+	batchSize         int
+	maxBatches        int
+	defaultRawDays    int
+	defaultRollupDays int
+	auditLogDays      int
+
+	stopChan     chan struct{}
+	isRunning    bool
+	runningMutex sync.Mutex
+	enforceMutex sync.Mutex
+}
+
+// NewRetentionService 创建数据保留策略服务
+func NewRetentionService(db *gorm.DB) *RetentionService {
+	return &RetentionService{
+		db:                db,
+		audit:             NewAuditLogService(db),
+		interval:          time.Hour,
+		batchSize:         5000,
+		maxBatches:        200,
+		defaultRawDays:    30,
+		defaultRollupDays: 365,
+		auditLogDays:      30,
+	}
+}
+
+// SetInterval 设置定期执行间隔
+func (s *RetentionService) SetInterval(interval time.Duration) {
+	if interval > 0 {
+		s.interval = interval
+	}
+}
+
+// SetBatchLimits 设置每批删除行数和单次执行的最大批次数
+func (s *RetentionService) SetBatchLimits(batchSize, maxBatches int) {
+	if batchSize > 0 {
+		s.batchSize = batchSize
+	}
+	if maxBatches > 0 {
+		s.maxBatches = maxBatches
+	}
+}
+
+// SetDefaults 设置未匹配任何策略时使用的默认保留天数
+func (s *RetentionService) SetDefaults(rawDays, rollupDays, auditLogDays int) {
+	if rawDays > 0 {
+		s.defaultRawDays = rawDays
+	}
+	if rollupDays >= 0 {
+		s.defaultRollupDays = rollupDays
+	}
+	if auditLogDays > 0 {
+		s.auditLogDays = auditLogDays
+	}
+}
+
+// Start 启动定期执行
+func (s *RetentionService) Start() error {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if s.isRunning {
+		return fmt.Errorf("数据保留任务已经在运行")
+	}
+
+	s.isRunning = true
+	s.stopChan = make(chan struct{})
+	go s.enforceLoop()
+
+	logger.Info("数据保留任务已启动", zap.Duration("执行间隔", s.interval))
+	return nil
+}
+
+// Stop 停止定期执行
+func (s *RetentionService) Stop() {
+	s.runningMutex.Lock()
+	defer s.runningMutex.Unlock()
+
+	if !s.isRunning {
+		return
+	}
+
+	close(s.stopChan)
+	s.isRunning = false
+	logger.Info("数据保留任务已停止")
+}
+
+// enforceLoop 定期执行循环
+func (s *RetentionService) enforceLoop() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		report, err := s.Enforce()
+		if err != nil {
+			logger.Error("执行数据保留策略失败", zap.Error(err))
+		} else if report.TotalRows > 0 {
+			logger.Info("数据保留策略执行完成",
+				zap.Int64("删除行数", report.TotalRows),
+				zap.Duration("耗时", report.Duration),
+				zap.Bool("未完成", report.Incomplete))
+		}
+
+		select {
+		case <-ticker.C:
+		case <-s.stopChan:
+			return
+		}
+	}
+}
+
+// ListPolicies 获取全部保留策略（按生效顺序）
+func (s *RetentionService) ListPolicies() ([]models.RetentionPolicy, error) {
+	var policies []models.RetentionPolicy
+	if err := s.db.Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("查询保留策略失败: %w", err)
+	}
+	sortRetentionPolicies(policies)
+	return policies, nil
+}
+
+// ValidateRetentionPolicy 校验保留策略
+func ValidateRetentionPolicy(p *models.RetentionPolicy) error {
+	if strings.TrimSpace(p.Name) == "" {
+		return fmt.Errorf("策略名称不能为空")
+	}
+	switch p.Target {
+	case "":
+		p.Target = models.RetentionTargetMetrics
+	case models.RetentionTargetMetrics:
+	case models.RetentionTargetAuditLogs:
+		if p.Specificity() > 0 {
+			return fmt.Errorf("审计日志策略不支持指标/分组/标签匹配条件")
+		}
+		if p.RollupRetentionDays != 0 {
+			return fmt.Errorf("审计日志策略不支持汇总保留")
+		}
+	default:
+		return fmt.Errorf("未知的保留对象: %s", p.Target)
+	}
+	if p.RawRetentionDays <= 0 {
+		return fmt.Errorf("原始数据保留天数必须大于0")
+	}
+	if p.RollupRetentionDays < 0 {
+		return fmt.Errorf("汇总数据保留天数不能为负数")
+	}
+	if (p.TagKey == nil || *p.TagKey == "") != (p.TagValue == nil) {
+		return fmt.Errorf("标签键和标签值必须同时设置")
+	}
+	return nil
+}
+
+// DryRun 预演保留策略，统计每个策略将删除的行数
+func (s *RetentionService) DryRun() (*RetentionReport, error) {
+	return s.run(true)
+}
+
+// Enforce 执行保留策略，按批次删除过期数据
+func (s *RetentionService) Enforce() (*RetentionReport, error) {
+	s.enforceMutex.Lock()
+	defer s.enforceMutex.Unlock()
+	return s.run(false)
+}
+
+// run 执行或预演全部保留策略
+func (s *RetentionService) run(dryRun bool) (*RetentionReport, error) {
+	now := time.Now()
+	report := &RetentionReport{DryRun: dryRun, StartedAt: now, Policies: []RetentionPolicyReport{}}
+
+	var policies []models.RetentionPolicy
+	if err := s.db.Where("enabled = ?", true).Find(&policies).Error; err != nil {
+		return nil, fmt.Errorf("查询保留策略失败: %w", err)
+	}
+	sortRetentionPolicies(policies)
+
+	metricPolicies := []models.RetentionPolicy{}
+	var auditPolicy *models.RetentionPolicy
+	for i := range policies {
+		switch policies[i].Target {
+		case models.RetentionTargetAuditLogs:
+			if auditPolicy == nil {
+				auditPolicy = &policies[i]
+			}
+		default:
+			metricPolicies = append(metricPolicies, policies[i])
+		}
+	}
+
+	scopes := s.buildScopes(metricPolicies)
+	for i, scope := range scopes {
+		item, err := s.applyMetricScope(scopes, i, now, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("执行保留策略 %s 失败: %w", scope.name, err)
+		}
+		report.Policies = append(report.Policies, *item)
+	}
+
+	auditItem, err := s.applyAuditPolicy(auditPolicy, now, dryRun)
+	if err != nil {
+		return nil, fmt.Errorf("清理审计日志失败: %w", err)
+	}
+	report.Policies = append(report.Policies, *auditItem)
+
+	for _, item := range report.Policies {
+		report.TotalRows += item.RawRows + item.RollupRows
+		report.Incomplete = report.Incomplete || item.Incomplete
+	}
+	report.Duration = time.Since(now)
+
+	if !dryRun {
+		s.recordRuns(report, now)
+	}
+
+	return report, nil
+}
+
+// buildScopes 在已排序的策略末尾追加默认策略
+func (s *RetentionService) buildScopes(policies []models.RetentionPolicy) []retentionScope {
+	scopes := make([]retentionScope, 0, len(policies)+1)
+	for i := range policies {
+		p := &policies[i]
+		scopes = append(scopes, retentionScope{
+			policy:     p,
+			name:       p.Name,
+			rawDays:    p.RawRetentionDays,
+			rollupDays: p.RollupRetentionDays,
+		})
+	}
+	return append(scopes, retentionScope{
+		name:       "default",
+		rawDays:    s.defaultRawDays,
+		rollupDays: s.defaultRollupDays,
+	})
+}
+
+// applyMetricScope 对第 idx 个策略生效范围内的指标数据执行保留
+func (s *RetentionService) applyMetricScope(scopes []retentionScope, idx int, now time.Time, dryRun bool) (*RetentionPolicyReport, error) {
+	scope := scopes[idx]
+	item := &RetentionPolicyReport{
+		Name:      scope.name,
+		Target:    models.RetentionTargetMetrics,
+		RawCutoff: now.AddDate(0, 0, -scope.rawDays),
+	}
+	if scope.policy != nil {
+		item.PolicyID = &scope.policy.ID
+	}
+
+	var rollupCutoff time.Time
+	keepRollup := scope.rollupDays > scope.rawDays
+	if scope.rollupDays > 0 {
+		rollupCutoff = now.AddDate(0, 0, -scope.rollupDays)
+		item.RollupCutoff = &rollupCutoff
+	}
+
+	// 原始数据
+	raw := effectiveCondition(scopes, idx, false)
+	raw = raw.and("timestamp < ?", item.RawCutoff)
+
+	if dryRun {
+		if err := s.db.Table("metric_records").Where(raw.sql, raw.args...).Count(&item.RawRows).Error; err != nil {
+			return nil, err
+		}
+		if keepRollup {
+			toRollup := raw.and("timestamp >= ?", rollupCutoff)
+			if err := s.db.Table("metric_records").Where(toRollup.sql, toRollup.args...).Count(&item.RolledUpRows).Error; err != nil {
+				return nil, err
+			}
+		}
+	} else if keepRollup {
+		deleted, rolled, more, err := s.rollupAndDelete(raw, rollupCutoff)
+		if err != nil {
+			return nil, err
+		}
+		item.RawRows, item.RolledUpRows, item.Incomplete = deleted, rolled, more
+	} else {
+		deleted, more, err := deleteInBatches(s.db, "metric_records", raw, s.batchSize, s.maxBatches)
+		if err != nil {
+			return nil, err
+		}
+		item.RawRows, item.Incomplete = deleted, more
+	}
+
+	// 汇总数据，保留天数为0时删除该范围内全部汇总
+	rollup := effectiveCondition(scopes, idx, true)
+	if scope.rollupDays > 0 {
+		rollup = rollup.and("bucket < ?", rollupCutoff)
+	}
+
+	if dryRun {
+		if err := s.db.Table("metric_rollups").Where(rollup.sql, rollup.args...).Count(&item.RollupRows).Error; err != nil {
+			return nil, err
+		}
+	} else {
+		deleted, more, err := deleteInBatches(s.db, "metric_rollups", rollup, s.batchSize, s.maxBatches)
+		if err != nil {
+			return nil, err
+		}
+		item.RollupRows = deleted
+		item.Incomplete = item.Incomplete || more
+	}
+
+	return item, nil
+}
+
+// rollupAndDelete 分批读取过期原始数据，汇总到小时级汇总表后删除
+func (s *RetentionService) rollupAndDelete(cond sqlCond, rollupCutoff time.Time) (int64, int64, bool, error) {
+	var deleted, rolled int64
+
+	for batch := 0; batch < s.maxBatches; batch++ {
+		var records []MetricRecord
+		if err := s.db.Table("metric_records").
+			Select("id, vm_id, metric, value, timestamp").
+			Where(cond.sql, cond.args...).
+			Limit(s.batchSize).
+			Find(&records).Error; err != nil {
+			return deleted, rolled, false, err
+		}
+		if len(records) == 0 {
+			return deleted, rolled, false, nil
+		}
+
+		ids := make([]uuid.UUID, len(records))
+		for i, r := range records {
+			ids[i] = r.ID
+		}
+		rollups := buildRollups(records, rollupCutoff)
+
+		err := s.db.Transaction(func(tx *gorm.DB) error {
+			if len(rollups) > 0 {
+				if err := upsertRollups(tx, rollups); err != nil {
+					return err
+				}
+			}
+			return tx.Where("id IN ?", ids).Delete(&MetricRecord{}).Error
+		})
+		if err != nil {
+			return deleted, rolled, false, err
+		}
+
+		deleted += int64(len(records))
+		for _, r := range rollups {
+			rolled += r.Count
+		}
+
+		if len(records) < s.batchSize {
+			return deleted, rolled, false, nil
+		}
+	}
+
+	return deleted, rolled, true, nil
+}
+
+// applyAuditPolicy 清理审计日志
+func (s *RetentionService) applyAuditPolicy(policy *models.RetentionPolicy, now time.Time, dryRun bool) (*RetentionPolicyReport, error) {
+	days := s.auditLogDays
+	item := &RetentionPolicyReport{Name: "default", Target: models.RetentionTargetAuditLogs}
+	if policy != nil {
+		days = policy.RawRetentionDays
+		item.Name = policy.Name
+		item.PolicyID = &policy.ID
+	}
+	item.RawCutoff = now.AddDate(0, 0, -days)
+
+	if dryRun {
+		err := s.db.Model(&AuditLog{}).Where("created_at < ?", item.RawCutoff).Count(&item.RawRows).Error
+		return item, err
+	}
+
+	deleted, more, err := s.audit.CleanupInBatches(item.RawCutoff, s.batchSize, s.maxBatches)
+	item.RawRows, item.Incomplete = deleted, more
+	return item, err
+}
+
+// recordRuns 记录每个策略的最近执行结果
+func (s *RetentionService) recordRuns(report *RetentionReport, at time.Time) {
+	for _, item := range report.Policies {
+		if item.PolicyID == nil {
+			continue
+		}
+		if err := s.db.Model(&models.RetentionPolicy{}).
+			Where("id = ?", *item.PolicyID).
+			UpdateColumns(map[string]interface{}{
+				"last_enforced_at":  at,
+				"last_deleted_rows": item.RawRows + item.RollupRows,
+			}).Error; err != nil {
+			logger.Error("记录保留策略执行结果失败", zap.String("policy", item.Name), zap.Error(err))
+		}
+	}
+}
+
+// sortRetentionPolicies 按生效顺序排序：优先级高 > 条件更具体 > 创建更早
+func sortRetentionPolicies(policies []models.RetentionPolicy) {
+	sort.SliceStable(policies, func(i, j int) bool {
+		if policies[i].Priority != policies[j].Priority {
+			return policies[i].Priority > policies[j].Priority
+		}
+		if si, sj := policies[i].Specificity(), policies[j].Specificity(); si != sj {
+			return si > sj
+		}
+		return policies[i].CreatedAt.Before(policies[j].CreatedAt)
+	})
+}
+
+// effectiveCondition 第 idx 个策略实际生效的范围：匹配自身且不被更高优先级策略匹配
+func effectiveCondition(scopes []retentionScope, idx int, rollup bool) sqlCond {
+	cond := policySelector(scopes[idx].policy, rollup)
+	for j := 0; j < idx; j++ {
+		prev := policySelector(scopes[j].policy, rollup)
+		cond = cond.and("NOT ("+prev.sql+")", prev.args...)
+	}
+	return cond
+}
+
+// policySelector 将策略匹配条件转换为SQL
+// 汇总数据不含标签，带标签条件的策略不匹配任何汇总数据。
+func policySelector(p *models.RetentionPolicy, rollup bool) sqlCond {
+	cond := sqlCond{sql: "1 = 1"}
+	if p == nil {
+		return cond
+	}
+
+	if p.TagKey != nil && *p.TagKey != "" {
+		if rollup {
+			return sqlCond{sql: "1 = 0"}
+		}
+		value := ""
+		if p.TagValue != nil {
+			value = *p.TagValue
+		}
+		cond = cond.and("tags ->> CAST(? AS TEXT) = ?", *p.TagKey, value)
+	}
+	if p.MetricPattern != nil && *p.MetricPattern != "" {
+		cond = cond.and("metric LIKE ?", globToLike(*p.MetricPattern))
+	}
+	if p.GroupID != nil {
+		cond = cond.and("vm_id IN (SELECT CAST(id AS VARCHAR(100)) FROM vms WHERE group_id = ? "+
+			"UNION SELECT CAST(vm_id AS VARCHAR(100)) FROM vm_group_members WHERE group_id = ?)",
+			*p.GroupID, *p.GroupID)
+	}
+	return cond
+}
+
+// and 追加 AND 条件
+func (c sqlCond) and(sql string, args ...interface{}) sqlCond {
+	merged := make([]interface{}, 0, len(c.args)+len(args))
+	merged = append(merged, c.args...)
+	merged = append(merged, args...)
+	return sqlCond{sql: c.sql + " AND " + sql, args: merged}
+}
+
+// globToLike 将 * / ? 通配符转换为 LIKE 模式
+func globToLike(pattern string) string {
+	replacer := strings.NewReplacer("*", "%", "?", "_")
+	return replacer.Replace(pattern)
+}
+
+// buildRollups 将一批原始数据按 (VM, 指标, 小时) 汇总，早于 cutoff 的数据不再汇总
+func buildRollups(records []MetricRecord, cutoff time.Time) []models.MetricRollup {
+	type key struct {
+		vmID   string
+		metric string
+		bucket time.Time
+	}
+
+	index := make(map[key]int)
+	rollups := []models.MetricRollup{}
+	for _, r := range records {
+		if r.Timestamp.Before(cutoff) {
+			continue
+		}
+
+		k := key{vmID: r.VMID, metric: r.Metric, bucket: r.Timestamp.Truncate(time.Hour)}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(rollups)
+			rollups = append(rollups, models.MetricRollup{
+				ID:       uuid.New(),
+				VMID:     r.VMID,
+				Metric:   r.Metric,
+				Bucket:   k.bucket,
+				MinValue: r.Value,
+				MaxValue: r.Value,
+			})
+			i = len(rollups) - 1
+		}
+
+		agg := &rollups[i]
+		if r.Value < agg.MinValue {
+			agg.MinValue = r.Value
+		}
+		if r.Value > agg.MaxValue {
+			agg.MaxValue = r.Value
+		}
+		agg.SumValue += r.Value
+		agg.Count++
+	}
+
+	for i := range rollups {
+		rollups[i].AvgValue = rollups[i].SumValue / float64(rollups[i].Count)
+	}
+	return rollups
+}
+
+// upsertRollups 写入汇总数据，与已存在的同一小时汇总合并
+func upsertRollups(tx *gorm.DB, rollups []models.MetricRollup) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "vm_id"}, {Name: "metric"}, {Name: "bucket"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"count":     gorm.Expr("metric_rollups.count + excluded.count"),
+			"sum_value": gorm.Expr("metric_rollups.sum_value + excluded.sum_value"),
+			"avg_value": gorm.Expr("(metric_rollups.sum_value + excluded.sum_value) / (metric_rollups.count + excluded.count)"),
+			"min_value": gorm.Expr("CASE WHEN excluded.min_value < metric_rollups.min_value THEN excluded.min_value ELSE metric_rollups.min_value END"),
+			"max_value": gorm.Expr("CASE WHEN excluded.max_value > metric_rollups.max_value THEN excluded.max_value ELSE metric_rollups.max_value END"),
+		}),
+	}).CreateInBatches(rollups, 500).Error
+}
+
+// deleteInBatches 分批删除满足条件的行，返回删除行数以及是否因达到批次上限而仍有剩余
+func deleteInBatches(db *gorm.DB, table string, cond sqlCond, batchSize, maxBatches int) (int64, bool, error) {
+	var total int64
+	stmt := fmt.Sprintf("DELETE FROM %s WHERE id IN (SELECT id FROM %s WHERE %s LIMIT %d)", table, table, cond.sql, batchSize)
+
+	for batch := 0; batch < maxBatches; batch++ {
+		result := db.Exec(stmt, cond.args...)
+		if result.Error != nil {
+			return total, false, result.Error
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < int64(batchSize) {
+			return total, false, nil
+		}
+	}
+
+	return total, true, nil
+}
@@ -0,0 +1,197 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRetentionTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+
+	// metric_records 由SQL迁移创建，这里手工建表
+	require.NoError(t, db.Exec(`CREATE TABLE metric_records (
+		id TEXT PRIMARY KEY,
+		vm_id TEXT NOT NULL,
+		metric TEXT NOT NULL,
+		value REAL NOT NULL,
+		timestamp DATETIME NOT NULL,
+		tags TEXT,
+		created_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE audit_logs (id TEXT PRIMARY KEY, created_at DATETIME)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE retention_policies (
+		id TEXT PRIMARY KEY, name TEXT, description TEXT, target TEXT, metric_pattern TEXT,
+		group_id TEXT, tag_key TEXT, tag_value TEXT, raw_retention_days INTEGER,
+		rollup_retention_days INTEGER, priority INTEGER, enabled BOOLEAN,
+		last_enforced_at DATETIME, last_deleted_rows INTEGER, created_at DATETIME,
+		updated_at DATETIME, created_by TEXT
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE metric_rollups (
+		id TEXT PRIMARY KEY, vm_id TEXT, metric TEXT, bucket DATETIME,
+		avg_value REAL, min_value REAL, max_value REAL, sum_value REAL, count INTEGER,
+		UNIQUE (vm_id, metric, bucket)
+	)`).Error)
+
+	return db
+}
+
+func insertMetricRows(t *testing.T, db *gorm.DB, vmID, metric string, ts time.Time, values ...float64) {
+	for i, v := range values {
+		require.NoError(t, db.Exec(
+			"INSERT INTO metric_records (id, vm_id, metric, value, timestamp) VALUES (?, ?, ?, ?, ?)",
+			uuid.New().String(), vmID, metric, v, ts.Add(time.Duration(i)*time.Minute),
+		).Error)
+	}
+}
+
+func countRows(t *testing.T, db *gorm.DB, table, where string, args ...interface{}) int64 {
+	var n int64
+	require.NoError(t, db.Table(table).Where(where, args...).Count(&n).Error)
+	return n
+}
+
+func TestSortRetentionPolicies(t *testing.T) {
+	cpu := "cpu_*"
+	now := time.Now()
+	policies := []models.RetentionPolicy{
+		{Name: "catch-all", CreatedAt: now},
+		{Name: "cpu", MetricPattern: &cpu, CreatedAt: now},
+		{Name: "urgent", Priority: 10, CreatedAt: now.Add(time.Hour)},
+	}
+
+	sortRetentionPolicies(policies)
+	assert.Equal(t, "urgent", policies[0].Name)
+	assert.Equal(t, "cpu", policies[1].Name)
+	assert.Equal(t, "catch-all", policies[2].Name)
+}
+
+func TestPolicySelector(t *testing.T) {
+	cpu := "cpu_*"
+	key, value := "env", "prod"
+	p := &models.RetentionPolicy{MetricPattern: &cpu, TagKey: &key, TagValue: &value}
+
+	raw := policySelector(p, false)
+	assert.Contains(t, raw.sql, "metric LIKE ?")
+	assert.Contains(t, raw.args, "cpu_%")
+
+	// 汇总数据没有标签，带标签条件的策略不应匹配
+	assert.Equal(t, "1 = 0", policySelector(p, true).sql)
+	assert.Equal(t, "1 = 1", policySelector(nil, false).sql)
+}
+
+func TestValidateRetentionPolicy(t *testing.T) {
+	key := "env"
+	assert.Error(t, ValidateRetentionPolicy(&models.RetentionPolicy{Name: "x"}))
+	assert.Error(t, ValidateRetentionPolicy(&models.RetentionPolicy{Name: "x", RawRetentionDays: 7, TagKey: &key}))
+	assert.Error(t, ValidateRetentionPolicy(&models.RetentionPolicy{Name: "x", RawRetentionDays: 7, Target: "logs"}))
+
+	p := &models.RetentionPolicy{Name: "x", RawRetentionDays: 7}
+	assert.NoError(t, ValidateRetentionPolicy(p))
+	assert.Equal(t, models.RetentionTargetMetrics, p.Target)
+}
+
+func TestBuildRollups(t *testing.T) {
+	hour := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	records := []MetricRecord{
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 10, Timestamp: hour.Add(5 * time.Minute)},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 30, Timestamp: hour.Add(50 * time.Minute)},
+		{VMID: "vm-1", Metric: "cpu_usage", Value: 99, Timestamp: hour.Add(-2 * time.Hour)},
+	}
+
+	rollups := buildRollups(records, hour.Add(-time.Hour))
+	require.Len(t, rollups, 1)
+	assert.Equal(t, hour, rollups[0].Bucket)
+	assert.Equal(t, int64(2), rollups[0].Count)
+	assert.Equal(t, 20.0, rollups[0].AvgValue)
+	assert.Equal(t, 10.0, rollups[0].MinValue)
+	assert.Equal(t, 30.0, rollups[0].MaxValue)
+}
+
+func TestRetentionService_Enforce(t *testing.T) {
+	db := setupRetentionTestDB(t)
+	now := time.Now()
+
+	cpu := "cpu_*"
+	require.NoError(t, db.Create(&models.RetentionPolicy{
+		ID: uuid.New(), Name: "cpu-short", Target: models.RetentionTargetMetrics, MetricPattern: &cpu,
+		RawRetentionDays: 1, RollupRetentionDays: 30, Enabled: true,
+	}).Error)
+
+	// cpu: 3天前的数据应被汇总后删除，40天前的数据直接删除
+	insertMetricRows(t, db, "vm-1", "cpu_usage", now.AddDate(0, 0, -3), 10, 20, 30)
+	insertMetricRows(t, db, "vm-1", "cpu_usage", now.AddDate(0, 0, -40), 50)
+	insertMetricRows(t, db, "vm-1", "cpu_usage", now.Add(-time.Hour), 40)
+	// memory: 由默认策略（7天）管理，3天前的数据保留
+	insertMetricRows(t, db, "vm-1", "memory_usage", now.AddDate(0, 0, -3), 60)
+	insertMetricRows(t, db, "vm-1", "memory_usage", now.AddDate(0, 0, -10), 70)
+
+	service := NewRetentionService(db)
+	service.SetDefaults(7, 0, 30)
+	service.SetBatchLimits(2, 100)
+
+	t.Run("DryRun", func(t *testing.T) {
+		report, err := service.DryRun()
+		require.NoError(t, err)
+		require.Len(t, report.Policies, 3)
+
+		assert.Equal(t, "cpu-short", report.Policies[0].Name)
+		assert.Equal(t, int64(4), report.Policies[0].RawRows)
+		assert.Equal(t, int64(3), report.Policies[0].RolledUpRows)
+		assert.Equal(t, "default", report.Policies[1].Name)
+		assert.Equal(t, int64(1), report.Policies[1].RawRows)
+
+		// 预演不删除数据
+		assert.Equal(t, int64(7), countRows(t, db, "metric_records", "1 = 1"))
+	})
+
+	t.Run("Enforce", func(t *testing.T) {
+		report, err := service.Enforce()
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), report.TotalRows)
+		assert.False(t, report.Incomplete)
+
+		assert.Equal(t, int64(1), countRows(t, db, "metric_records", "metric = ?", "cpu_usage"))
+		assert.Equal(t, int64(1), countRows(t, db, "metric_records", "metric = ?", "memory_usage"))
+
+		var rollups []models.MetricRollup
+		require.NoError(t, db.Find(&rollups).Error)
+		var total int64
+		var sum float64
+		for _, r := range rollups {
+			total += r.Count
+			sum += r.SumValue
+		}
+		assert.Equal(t, int64(3), total)
+		assert.Equal(t, 60.0, sum)
+
+		var policy models.RetentionPolicy
+		require.NoError(t, db.Where("name = ?", "cpu-short").First(&policy).Error)
+		assert.NotNil(t, policy.LastEnforcedAt)
+		assert.Equal(t, int64(4), policy.LastDeletedRows)
+	})
+}
+
+func TestDeleteInBatches_Bounded(t *testing.T) {
+	db := setupRetentionTestDB(t)
+	insertMetricRows(t, db, "vm-1", "cpu_usage", time.Now().AddDate(0, 0, -10), 1, 2, 3, 4, 5)
+
+	cond := sqlCond{sql: "timestamp < ?", args: []interface{}{time.Now()}}
+	deleted, more, err := deleteInBatches(db, "metric_records", cond, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), deleted)
+	assert.True(t, more)
+
+	deleted, more, err = deleteInBatches(db, "metric_records", cond, 2, 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.False(t, more)
+}
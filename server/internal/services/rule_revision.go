@@ -0,0 +1,279 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrRevisionNotFound 规则或指定版本的修订记录不存在
+var ErrRevisionNotFound = errors.New("修订记录不存在")
+
+// RuleFieldChange 两个版本之间的单个字段差异，Field为字段路径，如 conditions[0].threshold
+type RuleFieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RuleRevisionService 告警规则修订历史：每次创建、修改、删除规则时在同一事务中写入修订记录
+type RuleRevisionService struct {
+	db *gorm.DB
+}
+
+// NewRuleRevisionService 创建规则修订服务
+func NewRuleRevisionService(db *gorm.DB) *RuleRevisionService {
+	return &RuleRevisionService{db: db}
+}
+
+// Record 在事务tx中为规则的当前状态写入新版本，并计算与上一版本的差异
+func (s *RuleRevisionService) Record(tx *gorm.DB, ruleID uuid.UUID, action string, authorID *uuid.UUID, note string) (*models.AlertRuleRevision, error) {
+	snapshot, err := ruleSnapshot(tx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous []models.AlertRuleRevision
+	if err := tx.Where("rule_id = ?", ruleID).Order("version DESC").Limit(1).Find(&previous).Error; err != nil {
+		return nil, fmt.Errorf("查询修订记录失败: %w", err)
+	}
+
+	revision := models.AlertRuleRevision{
+		ID:        uuid.New(),
+		RuleID:    ruleID,
+		Version:   1,
+		Action:    action,
+		Snapshot:  snapshot,
+		AuthorID:  authorID,
+		CreatedAt: time.Now(),
+	}
+	if len(previous) > 0 {
+		revision.Version = previous[0].Version + 1
+		revision.Changes = diffSnapshots(previous[0].Snapshot, snapshot)
+	}
+	if note != "" {
+		revision.Note = &note
+	}
+	if authorID != nil {
+		var users []models.User
+		if err := tx.Select("name").Where("id = ?", *authorID).Limit(1).Find(&users).Error; err == nil && len(users) > 0 {
+			revision.AuthorName = &users[0].Name
+		}
+	}
+
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, fmt.Errorf("写入修订记录失败: %w", err)
+	}
+	return &revision, nil
+}
+
+// EnsureBaseline 规则还没有修订记录时（启用修订历史前创建的规则），在修改前补录当前状态作为第一个版本
+func (s *RuleRevisionService) EnsureBaseline(tx *gorm.DB, ruleID uuid.UUID) error {
+	var count int64
+	if err := tx.Model(&models.AlertRuleRevision{}).Where("rule_id = ?", ruleID).Count(&count).Error; err != nil {
+		return fmt.Errorf("查询修订记录失败: %w", err)
+	}
+	if count > 0 {
+		return nil
+	}
+
+	var rule models.AlertRule
+	if err := tx.Where("id = ?", ruleID).First(&rule).Error; err != nil {
+		return err
+	}
+	revision, err := s.Record(tx, ruleID, models.RuleRevisionBaseline, rule.UpdatedBy, "")
+	if err != nil {
+		return err
+	}
+	// 补录的版本时间取规则最后修改时间
+	return tx.Model(revision).Update("created_at", rule.UpdatedAt).Error
+}
+
+// List 按版本倒序返回规则的修订记录
+func (s *RuleRevisionService) List(ruleID uuid.UUID) ([]models.AlertRuleRevision, error) {
+	var revisions []models.AlertRuleRevision
+	if err := s.db.Where("rule_id = ?", ruleID).Order("version DESC").Find(&revisions).Error; err != nil {
+		return nil, fmt.Errorf("查询修订记录失败: %w", err)
+	}
+	return revisions, nil
+}
+
+// Get 获取规则指定版本的修订记录
+func (s *RuleRevisionService) Get(ruleID uuid.UUID, version int) (*models.AlertRuleRevision, error) {
+	return getRevision(s.db, ruleID, version)
+}
+
+// Diff 比较规则的两个版本，返回按字段路径排序的差异
+func (s *RuleRevisionService) Diff(ruleID uuid.UUID, from, to int) ([]RuleFieldChange, error) {
+	older, err := getRevision(s.db, ruleID, from)
+	if err != nil {
+		return nil, err
+	}
+	newer, err := getRevision(s.db, ruleID, to)
+	if err != nil {
+		return nil, err
+	}
+	return fieldChanges(diffSnapshots(older.Snapshot, newer.Snapshot)), nil
+}
+
+// Rollback 将规则恢复为指定版本的内容（已删除的规则会被恢复），并写入一个新的rollback版本
+func (s *RuleRevisionService) Rollback(ruleID uuid.UUID, version int, authorID *uuid.UUID, note string) (*models.AlertRuleRevision, error) {
+	var revision *models.AlertRuleRevision
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		target, err := getRevision(tx, ruleID, version)
+		if err != nil {
+			return err
+		}
+
+		var spec RuleSpec
+		data, err := json.Marshal(target.Snapshot)
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return fmt.Errorf("解析版本%d失败: %w", version, err)
+		}
+		expression, err := spec.Validate()
+		if err != nil {
+			return fmt.Errorf("版本%d的规则无效: %w", version, err)
+		}
+
+		var rule models.AlertRule
+		if err := tx.Where("id = ?", ruleID).First(&rule).Error; err != nil {
+			return err
+		}
+		if err := s.EnsureBaseline(tx, ruleID); err != nil {
+			return err
+		}
+
+		spec.apply(&rule, expression.String())
+		rule.IsDeleted = false
+		rule.DeletedAt = nil
+		rule.UpdatedBy = authorID
+		if err := tx.Save(&rule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rule_id = ?", ruleID).Delete(&models.AlertCondition{}).Error; err != nil {
+			return err
+		}
+		for _, cond := range spec.conditionModels() {
+			cond.ID = uuid.New()
+			cond.RuleID = ruleID
+			if err := tx.Create(&cond).Error; err != nil {
+				return err
+			}
+		}
+
+		if note == "" {
+			note = fmt.Sprintf("回滚到版本%d", version)
+		}
+		revision, err = s.Record(tx, ruleID, models.RuleRevisionRollback, authorID, note)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// getRevision 查询规则的指定版本
+func getRevision(db *gorm.DB, ruleID uuid.UUID, version int) (*models.AlertRuleRevision, error) {
+	var revision models.AlertRuleRevision
+	err := db.Where("rule_id = ? AND version = ?", ruleID, version).First(&revision).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("%w: 版本%d", ErrRevisionNotFound, version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// ruleSnapshot 读取规则（含已删除）及条件，转换为与导出格式一致的快照，并记录删除状态
+func ruleSnapshot(tx *gorm.DB, ruleID uuid.UUID) (models.JSONMap, error) {
+	var rule models.AlertRule
+	if err := tx.Where("id = ?", ruleID).First(&rule).Error; err != nil {
+		return nil, fmt.Errorf("查询规则失败: %w", err)
+	}
+	var conditions []models.AlertCondition
+	if err := tx.Where("rule_id = ?", ruleID).Order("sort_order").Find(&conditions).Error; err != nil {
+		return nil, fmt.Errorf("查询规则条件失败: %w", err)
+	}
+
+	data, err := json.Marshal(NewRuleSpec(rule, conditions))
+	if err != nil {
+		return nil, err
+	}
+	snapshot := models.JSONMap{}
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	snapshot["isDeleted"] = rule.IsDeleted
+	return snapshot, nil
+}
+
+// diffSnapshots 将两个快照展开为字段路径后逐项比较
+func diffSnapshots(older, newer models.JSONMap) models.JSONMap {
+	before := map[string]interface{}{}
+	after := map[string]interface{}{}
+	flattenSnapshot("", map[string]interface{}(older), before)
+	flattenSnapshot("", map[string]interface{}(newer), after)
+
+	changes := models.JSONMap{}
+	for field, value := range before {
+		if other, ok := after[field]; !ok || !reflect.DeepEqual(value, other) {
+			changes[field] = map[string]interface{}{"old": value, "new": after[field]}
+		}
+	}
+	for field, value := range after {
+		if _, ok := before[field]; !ok {
+			changes[field] = map[string]interface{}{"old": nil, "new": value}
+		}
+	}
+	return changes
+}
+
+// flattenSnapshot 将嵌套的map与数组展开为 a.b[0].c 形式的字段路径
+func flattenSnapshot(prefix string, value interface{}, out map[string]interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			path := key
+			if prefix != "" {
+				path = prefix + "." + key
+			}
+			flattenSnapshot(path, item, out)
+		}
+	case models.JSONMap:
+		flattenSnapshot(prefix, map[string]interface{}(v), out)
+	case []interface{}:
+		for i, item := range v {
+			flattenSnapshot(prefix+"["+strconv.Itoa(i)+"]", item, out)
+		}
+	default:
+		out[prefix] = v
+	}
+}
+
+// fieldChanges 将差异转换为按字段路径排序的列表
+func fieldChanges(changes models.JSONMap) []RuleFieldChange {
+	result := make([]RuleFieldChange, 0, len(changes))
+	for field, value := range changes {
+		change := RuleFieldChange{Field: field}
+		if pair, ok := value.(map[string]interface{}); ok {
+			change.Old, change.New = pair["old"], pair["new"]
+		}
+		result = append(result, change)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Field < result[j].Field })
+	return result
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"testing"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRuleRevisionService_HistoryAndRollback(t *testing.T) {
+	db := setupRuleTestDB(t)
+	service := NewRuleRevisionService(db)
+	author := uuid.New()
+	require.NoError(t, db.Exec("INSERT INTO users (id, name) VALUES (?, ?)", author, "张三").Error)
+
+	// 启用修订历史前已存在的规则：首次修改时补录原始版本
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu", Scope: "all", Severity: "high", ConditionLogic: "and", Enabled: true, Cooldown: 300, FlapWindow: 3600, NotificationConfig: models.JSONMap{}}
+	require.NoError(t, db.Create(&rule).Error)
+	require.NoError(t, db.Create(&models.AlertCondition{ID: uuid.New(), RuleID: rule.ID, Metric: "cpu_usage", MetricType: "cpu_usage", Operator: ">", Threshold: 80, Type: ConditionTypeThreshold}).Error)
+
+	transfer := NewRuleTransferService(db)
+	_, err := transfer.Import([]RuleSpec{{
+		Name:       "cpu",
+		Severity:   "critical",
+		Enabled:    true,
+		Cooldown:   300,
+		Conditions: []RuleConditionSpec{{Metric: "cpu_usage", Operator: ">", Threshold: 95}},
+	}}, ImportStrategyUpsert, false, &author)
+	require.NoError(t, err)
+
+	revisions, err := service.List(rule.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, models.RuleRevisionImport, revisions[0].Action)
+	assert.Equal(t, "张三", *revisions[0].AuthorName)
+	assert.Equal(t, models.RuleRevisionBaseline, revisions[1].Action)
+	assert.Empty(t, revisions[1].Changes)
+
+	changes, err := service.Diff(rule.ID, 1, 2)
+	require.NoError(t, err)
+	fields := map[string]RuleFieldChange{}
+	for _, change := range changes {
+		fields[change.Field] = change
+	}
+	assert.Equal(t, 80.0, fields["conditions[0].threshold"].Old)
+	assert.Equal(t, 95.0, fields["conditions[0].threshold"].New)
+	assert.Equal(t, "high", fields["severity"].Old)
+	assert.Equal(t, "critical", fields["severity"].New)
+
+	// 删除后回滚到原始版本：恢复规则与条件，并生成新版本
+	require.NoError(t, db.Model(&rule).Update("is_deleted", true).Error)
+	_, err = service.Record(db, rule.ID, models.RuleRevisionDelete, &author, "")
+	require.NoError(t, err)
+	changes, err = service.Diff(rule.ID, 2, 3)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, RuleFieldChange{Field: "isDeleted", Old: false, New: true}, changes[0])
+
+	revision, err := service.Rollback(rule.ID, 1, &author, "")
+	require.NoError(t, err)
+	assert.Equal(t, 4, revision.Version)
+	assert.Equal(t, "回滚到版本1", *revision.Note)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_rules", "id = ? AND is_deleted = ? AND severity = ?", rule.ID, false, "high"))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_conditions", "rule_id = ? AND threshold = ?", rule.ID, 80))
+
+	// 原始版本没有表达式，回滚时由条件翻译生成
+	changes, err = service.Diff(rule.ID, 1, 4)
+	require.NoError(t, err)
+	require.Len(t, changes, 1)
+	assert.Equal(t, "expression", changes[0].Field)
+
+	_, err = service.Diff(rule.ID, 1, 9)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+	_, err = service.Rollback(uuid.New(), 1, nil, "")
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+}
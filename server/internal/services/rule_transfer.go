@@ -0,0 +1,848 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// RuleDocumentVersion 规则导出文档的格式版本
+const RuleDocumentVersion = "v1"
+
+// 规则导入冲突（同名规则已存在）的处理策略
+const (
+	ImportStrategyUpsert = "upsert" // 覆盖已存在的同名规则
+	ImportStrategySkip   = "skip"   // 跳过同名规则
+	ImportStrategyRename = "rename" // 以新名称创建
+)
+
+// RuleConditionSpec 导出/导入的规则条件
+type RuleConditionSpec struct {
+	Metric      string  `json:"metric" yaml:"metric"`
+	MetricType  string  `json:"metricType,omitempty" yaml:"metricType,omitempty"`
+	Operator    string  `json:"operator" yaml:"operator"`
+	Threshold   float64 `json:"threshold" yaml:"threshold"`
+	Duration    int     `json:"duration,omitempty" yaml:"duration,omitempty"`
+	Aggregation string  `json:"aggregation,omitempty" yaml:"aggregation,omitempty"`
+	Type        string  `json:"type,omitempty" yaml:"type,omitempty"`
+	Algorithm   string  `json:"algorithm,omitempty" yaml:"algorithm,omitempty"`
+}
+
+// RuleSpec 导出/导入的规则定义（不含ID、触发统计等运行时信息）
+type RuleSpec struct {
+	Name               string                 `json:"name" yaml:"name"`
+	Description        string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Scope              string                 `json:"scope" yaml:"scope"`
+	ScopeID            *uuid.UUID             `json:"scopeId,omitempty" yaml:"scopeId,omitempty"`
+	ScopeName          string                 `json:"scopeName,omitempty" yaml:"scopeName,omitempty"`
+	Severity           string                 `json:"severity" yaml:"severity"`
+	Enabled            bool                   `json:"enabled" yaml:"enabled"`
+	Cooldown           int                    `json:"cooldown" yaml:"cooldown"`
+	For                int                    `json:"for,omitempty" yaml:"for,omitempty"`
+	KeepFiringFor      int                    `json:"keepFiringFor,omitempty" yaml:"keepFiringFor,omitempty"`
+	FlapThreshold      int                    `json:"flapThreshold,omitempty" yaml:"flapThreshold,omitempty"`
+	FlapWindow         int                    `json:"flapWindow,omitempty" yaml:"flapWindow,omitempty"`
+	NoDataState        string                 `json:"noDataState,omitempty" yaml:"noDataState,omitempty"`
+	EvaluationMode     string                 `json:"evaluationMode,omitempty" yaml:"evaluationMode,omitempty"`
+	ConditionLogic     string                 `json:"conditionLogic,omitempty" yaml:"conditionLogic,omitempty"`
+	Expression         string                 `json:"expression,omitempty" yaml:"expression,omitempty"`
+	Conditions         []RuleConditionSpec    `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	NotificationConfig map[string]interface{} `json:"notificationConfig,omitempty" yaml:"notificationConfig,omitempty"`
+}
+
+// RuleDocument 规则导出文档（JSON/YAML）
+type RuleDocument struct {
+	Version    string     `json:"version" yaml:"version"`
+	ExportedAt time.Time  `json:"exportedAt" yaml:"exportedAt"`
+	Rules      []RuleSpec `json:"rules" yaml:"rules"`
+}
+
+// NewRuleSpec 由规则与条件生成导出定义
+func NewRuleSpec(rule models.AlertRule, conditions []models.AlertCondition) RuleSpec {
+	spec := RuleSpec{
+		Name:               rule.Name,
+		Scope:              rule.Scope,
+		ScopeID:            rule.ScopeID,
+		Severity:           rule.Severity,
+		Enabled:            rule.Enabled,
+		Cooldown:           rule.Cooldown,
+		For:                rule.ForDuration,
+		KeepFiringFor:      rule.KeepFiringFor,
+		FlapThreshold:      rule.FlapThreshold,
+		FlapWindow:         rule.FlapWindow,
+		NoDataState:        rule.NoDataState,
+		EvaluationMode:     rule.EvaluationMode,
+		ConditionLogic:     rule.ConditionLogic,
+		NotificationConfig: rule.NotificationConfig,
+	}
+	if rule.Description != nil {
+		spec.Description = *rule.Description
+	}
+	if rule.ScopeName != nil {
+		spec.ScopeName = *rule.ScopeName
+	}
+	if rule.Expression != nil {
+		spec.Expression = *rule.Expression
+	}
+	for _, cond := range conditions {
+		condSpec := RuleConditionSpec{
+			Metric:      cond.Metric,
+			MetricType:  cond.MetricType,
+			Operator:    cond.Operator,
+			Threshold:   cond.Threshold,
+			Duration:    cond.Duration,
+			Aggregation: cond.Aggregation,
+			Type:        cond.Type,
+		}
+		if cond.Algorithm != nil {
+			condSpec.Algorithm = *cond.Algorithm
+		}
+		spec.Conditions = append(spec.Conditions, condSpec)
+	}
+	return spec
+}
+
+// conditionModels 将条件定义转换为条件模型
+func (s RuleSpec) conditionModels() []models.AlertCondition {
+	conditions := make([]models.AlertCondition, len(s.Conditions))
+	for i, c := range s.Conditions {
+		conditions[i] = models.AlertCondition{
+			Metric:      c.Metric,
+			MetricType:  c.MetricType,
+			Operator:    c.Operator,
+			Threshold:   c.Threshold,
+			Duration:    c.Duration,
+			Aggregation: c.Aggregation,
+			Type:        c.Type,
+			SortOrder:   i,
+		}
+		if conditions[i].MetricType == "" {
+			conditions[i].MetricType = c.Metric
+		}
+		if conditions[i].Type == "" {
+			conditions[i].Type = ConditionTypeThreshold
+		}
+		if c.Algorithm != "" {
+			algorithm := c.Algorithm
+			conditions[i].Algorithm = &algorithm
+		}
+	}
+	return conditions
+}
+
+// Validate 校验规则定义并补全默认值，返回规范化的规则表达式
+func (s *RuleSpec) Validate() (*Expression, error) {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" || len(s.Name) > 200 {
+		return nil, fmt.Errorf("规则名称不能为空且不能超过200个字符")
+	}
+	if s.Scope == "" {
+		s.Scope = "all"
+	}
+	switch s.Scope {
+	case "all":
+	case "vm", "group", "cluster", "host", "datacenter":
+		if s.ScopeID == nil {
+			return nil, fmt.Errorf("范围 %s 需要指定scopeId", s.Scope)
+		}
+	default:
+		return nil, fmt.Errorf("未知的范围: %s", s.Scope)
+	}
+	switch s.Severity {
+	case "critical", "high", "medium", "low":
+	default:
+		return nil, fmt.Errorf("未知的告警级别: %s", s.Severity)
+	}
+	if s.ConditionLogic == "" {
+		s.ConditionLogic = "and"
+	}
+	if s.FlapWindow == 0 {
+		s.FlapWindow = 3600
+	}
+	switch s.NoDataState {
+	case "":
+		s.NoDataState = models.NoDataStateKeepLast
+	case models.NoDataStateOK, models.NoDataStateAlerting, models.NoDataStateKeepLast:
+	default:
+		return nil, fmt.Errorf("未知的无数据处理方式: %s", s.NoDataState)
+	}
+	switch s.EvaluationMode {
+	case "":
+		s.EvaluationMode = models.EvaluationModePerVM
+	case models.EvaluationModePerVM:
+	case models.EvaluationModeAggregate:
+		if s.Scope == "vm" {
+			return nil, fmt.Errorf("VM范围不支持聚合评估")
+		}
+	default:
+		return nil, fmt.Errorf("未知的评估模式: %s", s.EvaluationMode)
+	}
+	if s.Cooldown < 0 || s.For < 0 || s.KeepFiringFor < 0 || s.FlapThreshold < 0 || s.FlapWindow < 0 {
+		return nil, fmt.Errorf("时长与阈值不能为负数")
+	}
+
+	var expression *Expression
+	var err error
+	if strings.TrimSpace(s.Expression) != "" {
+		expression, err = ParseExpression(s.Expression)
+	} else if len(s.Conditions) == 0 {
+		return nil, fmt.Errorf("表达式和条件不能同时为空")
+	} else {
+		expression, err = ExpressionFromConditions(s.conditionModels(), s.ConditionLogic)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := expression.CheckEvaluationMode(s.EvaluationMode); err != nil {
+		return nil, err
+	}
+	return expression, nil
+}
+
+// apply 将规则定义写入规则模型（不修改ID与统计信息）
+func (s RuleSpec) apply(rule *models.AlertRule, expression string) {
+	rule.Name = s.Name
+	rule.Description = nil
+	if s.Description != "" {
+		description := s.Description
+		rule.Description = &description
+	}
+	rule.Scope = s.Scope
+	rule.ScopeID = s.ScopeID
+	rule.ScopeName = nil
+	if s.ScopeName != "" {
+		scopeName := s.ScopeName
+		rule.ScopeName = &scopeName
+	}
+	rule.Severity = s.Severity
+	rule.Enabled = s.Enabled
+	rule.Cooldown = s.Cooldown
+	rule.ForDuration = s.For
+	rule.KeepFiringFor = s.KeepFiringFor
+	rule.FlapThreshold = s.FlapThreshold
+	rule.FlapWindow = s.FlapWindow
+	rule.NoDataState = s.NoDataState
+	rule.EvaluationMode = s.EvaluationMode
+	rule.ConditionLogic = s.ConditionLogic
+	rule.Expression = &expression
+	rule.NotificationConfig = models.JSONMap(s.NotificationConfig)
+	if rule.NotificationConfig == nil {
+		rule.NotificationConfig = models.JSONMap{}
+	}
+}
+
+// ========== 导出 ==========
+
+// MarshalRuleDocument 将规则定义编码为json或yaml文档
+func MarshalRuleDocument(specs []RuleSpec, format string) ([]byte, error) {
+	doc := RuleDocument{Version: RuleDocumentVersion, ExportedAt: time.Now(), Rules: specs}
+	switch format {
+	case "json":
+		return json.MarshalIndent(doc, "", "  ")
+	case "yaml":
+		return yaml.Marshal(doc)
+	}
+	return nil, fmt.Errorf("不支持的导出格式: %s", format)
+}
+
+// ========== Prometheus 规则组 ==========
+
+// PrometheusRule Prometheus告警规则
+type PrometheusRule struct {
+	Alert         string            `yaml:"alert" json:"alert"`
+	Expr          string            `yaml:"expr" json:"expr"`
+	For           string            `yaml:"for,omitempty" json:"for,omitempty"`
+	KeepFiringFor string            `yaml:"keep_firing_for,omitempty" json:"keep_firing_for,omitempty"`
+	Labels        map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+	Annotations   map[string]string `yaml:"annotations,omitempty" json:"annotations,omitempty"`
+}
+
+// PrometheusRuleGroup Prometheus规则组
+type PrometheusRuleGroup struct {
+	Name     string           `yaml:"name" json:"name"`
+	Interval string           `yaml:"interval,omitempty" json:"interval,omitempty"`
+	Rules    []PrometheusRule `yaml:"rules" json:"rules"`
+}
+
+// PrometheusRuleFile Prometheus规则文件
+type PrometheusRuleFile struct {
+	Groups []PrometheusRuleGroup `yaml:"groups" json:"groups"`
+}
+
+// Prometheus规则中保存本系统专有配置的注解
+const (
+	promAnnotationCooldown      = "cooldown"
+	promAnnotationFlapThreshold = "flap_threshold"
+	promAnnotationFlapWindow    = "flap_window"
+	promAnnotationEnabled       = "enabled"
+	promAnnotationNotification  = "notification_config"
+)
+
+// ToPrometheusRules 将规则定义转换为Prometheus规则组。表达式中的not会按德摩根定律展开；
+// 异常检测函数没有PromQL对应，包含时返回错误
+func ToPrometheusRules(specs []RuleSpec, groupName string, interval time.Duration) (*PrometheusRuleFile, error) {
+	if groupName == "" {
+		groupName = "vm-monitoring"
+	}
+	group := PrometheusRuleGroup{Name: groupName, Rules: []PrometheusRule{}}
+	if interval > 0 {
+		group.Interval = formatExprDuration(interval)
+	}
+
+	for _, spec := range specs {
+		expression, err := spec.Validate()
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s: %w", spec.Name, err)
+		}
+		promExpr, err := promQL(pushDownNot(expression.root, false))
+		if err != nil {
+			return nil, fmt.Errorf("规则 %s 无法转换为PromQL: %w", spec.Name, err)
+		}
+
+		rule := PrometheusRule{
+			Alert:       spec.Name,
+			Expr:        promExpr,
+			Labels:      map[string]string{"severity": spec.Severity, "scope": spec.Scope},
+			Annotations: map[string]string{promAnnotationCooldown: formatPromSeconds(spec.Cooldown)},
+		}
+		if spec.For > 0 {
+			rule.For = formatPromSeconds(spec.For)
+		}
+		if spec.KeepFiringFor > 0 {
+			rule.KeepFiringFor = formatPromSeconds(spec.KeepFiringFor)
+		}
+		if spec.ScopeID != nil {
+			rule.Labels["scope_id"] = spec.ScopeID.String()
+		}
+		if spec.ScopeName != "" {
+			rule.Labels["scope_name"] = spec.ScopeName
+		}
+		if spec.Description != "" {
+			rule.Annotations["description"] = spec.Description
+		}
+		if spec.FlapThreshold > 0 {
+			rule.Annotations[promAnnotationFlapThreshold] = strconv.Itoa(spec.FlapThreshold)
+			rule.Annotations[promAnnotationFlapWindow] = formatPromSeconds(spec.FlapWindow)
+		}
+		if !spec.Enabled {
+			rule.Annotations[promAnnotationEnabled] = "false"
+		}
+		if len(spec.NotificationConfig) > 0 {
+			data, err := json.Marshal(spec.NotificationConfig)
+			if err != nil {
+				return nil, fmt.Errorf("规则 %s 的通知配置无法编码: %w", spec.Name, err)
+			}
+			rule.Annotations[promAnnotationNotification] = string(data)
+		}
+		group.Rules = append(group.Rules, rule)
+	}
+	return &PrometheusRuleFile{Groups: []PrometheusRuleGroup{group}}, nil
+}
+
+// MarshalPrometheusRules 将规则定义编码为Prometheus规则文件（YAML）
+func MarshalPrometheusRules(specs []RuleSpec, groupName string, interval time.Duration) ([]byte, error) {
+	file, err := ToPrometheusRules(specs, groupName, interval)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(file)
+}
+
+// FromPrometheusRules 将Prometheus规则组转换为规则定义，不支持的PromQL返回带规则名的错误
+func FromPrometheusRules(file *PrometheusRuleFile) ([]RuleSpec, error) {
+	var specs []RuleSpec
+	for _, group := range file.Groups {
+		for _, rule := range group.Rules {
+			if rule.Alert == "" {
+				// 记录规则（recording rule）不是告警
+				continue
+			}
+			spec, err := fromPrometheusRule(rule)
+			if err != nil {
+				return nil, fmt.Errorf("规则组 %s 的规则 %s: %w", group.Name, rule.Alert, err)
+			}
+			specs = append(specs, spec)
+		}
+	}
+	return specs, nil
+}
+
+func fromPrometheusRule(rule PrometheusRule) (RuleSpec, error) {
+	expression, err := expressionFromPromQL(rule.Expr)
+	if err != nil {
+		return RuleSpec{}, err
+	}
+
+	spec := RuleSpec{
+		Name:       rule.Alert,
+		Scope:      rule.Labels["scope"],
+		ScopeName:  rule.Labels["scope_name"],
+		Severity:   rule.Labels["severity"],
+		Enabled:    rule.Annotations[promAnnotationEnabled] != "false",
+		Expression: expression.String(),
+	}
+	if spec.Severity == "" {
+		spec.Severity = "medium"
+	}
+	if id := rule.Labels["scope_id"]; id != "" {
+		scopeID, err := uuid.Parse(id)
+		if err != nil {
+			return RuleSpec{}, fmt.Errorf("scope_id格式错误: %s", id)
+		}
+		spec.ScopeID = &scopeID
+	}
+	spec.Description = rule.Annotations["description"]
+	if spec.Description == "" {
+		spec.Description = rule.Annotations["summary"]
+	}
+
+	durations := []struct {
+		value  string
+		target *int
+	}{
+		{rule.For, &spec.For},
+		{rule.KeepFiringFor, &spec.KeepFiringFor},
+		{rule.Annotations[promAnnotationCooldown], &spec.Cooldown},
+		{rule.Annotations[promAnnotationFlapWindow], &spec.FlapWindow},
+	}
+	for _, d := range durations {
+		if d.value == "" {
+			continue
+		}
+		seconds, err := parsePromDuration(d.value)
+		if err != nil {
+			return RuleSpec{}, err
+		}
+		*d.target = seconds
+	}
+	if rule.Annotations[promAnnotationCooldown] == "" {
+		spec.Cooldown = 300
+	}
+	if threshold := rule.Annotations[promAnnotationFlapThreshold]; threshold != "" {
+		if spec.FlapThreshold, err = strconv.Atoi(threshold); err != nil {
+			return RuleSpec{}, fmt.Errorf("flap_threshold格式错误: %s", threshold)
+		}
+	}
+	if config := rule.Annotations[promAnnotationNotification]; config != "" {
+		if err := json.Unmarshal([]byte(config), &spec.NotificationConfig); err != nil {
+			return RuleSpec{}, fmt.Errorf("notification_config格式错误: %w", err)
+		}
+	}
+	return spec, nil
+}
+
+// formatPromSeconds 将秒数格式化为Prometheus时长
+func formatPromSeconds(seconds int) string {
+	if seconds <= 0 {
+		return "0s"
+	}
+	return formatExprDuration(time.Duration(seconds) * time.Second)
+}
+
+// promDurationPattern Prometheus时长，如 1h30m、5m、1d
+var promDurationPattern = regexp.MustCompile(`^(?:(\d+)w)?(?:(\d+)d)?(?:(\d+)h)?(?:(\d+)m)?(?:(\d+)s)?$`)
+
+// parsePromDuration 解析Prometheus时长为秒数
+func parsePromDuration(value string) (int, error) {
+	match := promDurationPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil || value == "" {
+		return 0, fmt.Errorf("无效的时长: %s", value)
+	}
+	seconds := 0
+	for i, unit := range []int{604800, 86400, 3600, 60, 1} {
+		if match[i+1] != "" {
+			n, _ := strconv.Atoi(match[i+1])
+			seconds += n * unit
+		}
+	}
+	return seconds, nil
+}
+
+// promRangeFunctions PromQL区间函数与表达式函数的对应关系
+var promRangeFunctions = map[string]string{
+	"last_over_time":   "last",
+	"avg_over_time":    "avg",
+	"max_over_time":    "max",
+	"min_over_time":    "min",
+	"sum_over_time":    "sum",
+	"rate":             "rate",
+	"delta":            "delta",
+	"increase":         "delta",
+	"absent_over_time": "absent",
+}
+
+// promRangeCall PromQL区间函数调用，如 avg_over_time(cpu_usage[5m])
+var promRangeCall = regexp.MustCompile(`([A-Za-z_]+)\(\s*([A-Za-z_:][A-Za-z0-9_:]*)\s*\[\s*([0-9a-z]+)\s*\]\s*\)`)
+
+// expressionFromPromQL 将PromQL子集（指标、区间函数、abs、算术、比较与and/or）转换为规则表达式
+func expressionFromPromQL(promExpr string) (*Expression, error) {
+	var convErr error
+	converted := promRangeCall.ReplaceAllStringFunc(promExpr, func(call string) string {
+		parts := promRangeCall.FindStringSubmatch(call)
+		fn, ok := promRangeFunctions[parts[1]]
+		if !ok {
+			convErr = fmt.Errorf("不支持的PromQL函数: %s", parts[1])
+			return call
+		}
+		seconds, err := parsePromDuration(parts[3])
+		if err != nil {
+			convErr = err
+			return call
+		}
+		return fmt.Sprintf("%s(%s, %s)", fn, parts[2], formatPromSeconds(seconds))
+	})
+	if convErr != nil {
+		return nil, convErr
+	}
+	if strings.ContainsAny(converted, "{}[]") {
+		return nil, fmt.Errorf("不支持标签选择器或区间向量: %s", promExpr)
+	}
+	return ParseExpression(converted)
+}
+
+// pushDownNot 按德摩根定律消除not（PromQL没有布尔取反）
+func pushDownNot(n exprNode, negate bool) exprNode {
+	switch n := n.(type) {
+	case *metricNode:
+		// absent没有对应的取反函数，保留not由promQL报错
+		if negate && n.fn == "absent" {
+			return &unaryNode{op: "not", operand: n, offset: n.offset}
+		}
+	case *fleetNode:
+		return &fleetNode{fn: n.fn, agg: n.agg, arg: pushDownNot(n.arg, false), percentile: n.percentile, offset: n.offset}
+	case *unaryNode:
+		if n.op == "not" {
+			return pushDownNot(n.operand, !negate)
+		}
+	case *binaryNode:
+		switch n.op {
+		case "and", "or":
+			op := n.op
+			if negate {
+				op = map[string]string{"and": "or", "or": "and"}[op]
+			}
+			return &binaryNode{op: op, left: pushDownNot(n.left, negate), right: pushDownNot(n.right, negate), offset: n.offset}
+		case ">", ">=", "<", "<=", "==", "!=":
+			if negate {
+				inverse := map[string]string{">": "<=", ">=": "<", "<": ">=", "<=": ">", "==": "!=", "!=": "=="}
+				return &binaryNode{op: inverse[n.op], left: n.left, right: n.right, offset: n.offset}
+			}
+		}
+	}
+	return n
+}
+
+// promQL 将（已消除not的）语法树输出为PromQL
+func promQL(n exprNode) (string, error) {
+	switch n := n.(type) {
+	case *numberNode:
+		return n.String(), nil
+
+	case *metricNode:
+		if !isPromMetricName(n.metric) {
+			return "", fmt.Errorf("指标名 %s 不是合法的Prometheus指标名", n.metric)
+		}
+		switch n.aggregation {
+		case "anomaly":
+			return "", fmt.Errorf("异常检测函数没有对应的PromQL")
+		case "absent":
+			return fmt.Sprintf("absent_over_time(%s[%s])", n.metric, formatExprDuration(n.window)), nil
+		case "rate", "delta":
+			return fmt.Sprintf("%s(%s[%s])", n.aggregation, n.metric, formatExprDuration(n.window)), nil
+		}
+		if !n.explicit {
+			return n.metric, nil
+		}
+		return fmt.Sprintf("%s_over_time(%s[%s])", n.aggregation, n.metric, formatExprDuration(n.window)), nil
+
+	case *fleetNode:
+		arg, err := promQL(n.arg)
+		if err != nil {
+			return "", err
+		}
+		switch n.agg {
+		case "percentile":
+			return fmt.Sprintf("quantile(%s, %s)", strconv.FormatFloat(n.percentile/100, 'g', -1, 64), arg), nil
+		case "count":
+			return "count(" + arg + ")", nil
+		}
+		return n.agg + "(" + arg + ")", nil
+
+	case *callNode:
+		args := make([]string, len(n.args))
+		for i, arg := range n.args {
+			s, err := promQL(arg)
+			if err != nil {
+				return "", err
+			}
+			args[i] = s
+		}
+		return n.fn + "(" + strings.Join(args, ", ") + ")", nil
+
+	case *unaryNode:
+		if n.op == "not" {
+			return "", fmt.Errorf("PromQL不支持not")
+		}
+		operand, err := promQL(n.operand)
+		if err != nil {
+			return "", err
+		}
+		if b, ok := n.operand.(*binaryNode); ok && exprPrecedence(b.op) < precUnary {
+			operand = "(" + operand + ")"
+		}
+		return "-" + operand, nil
+
+	case *binaryNode:
+		left, err := promQL(n.left)
+		if err != nil {
+			return "", err
+		}
+		right, err := promQL(n.right)
+		if err != nil {
+			return "", err
+		}
+		prec := exprPrecedence(n.op)
+		if b, ok := n.left.(*binaryNode); ok && exprPrecedence(b.op) < prec {
+			left = "(" + left + ")"
+		}
+		if b, ok := n.right.(*binaryNode); ok && exprPrecedence(b.op) <= prec {
+			right = "(" + right + ")"
+		}
+		return left + " " + n.op + " " + right, nil
+	}
+	return "", fmt.Errorf("未知的表达式节点 %T", n)
+}
+
+// isPromMetricName 判断是否为合法的Prometheus指标名
+func isPromMetricName(name string) bool {
+	return isMetricIdent(name) && !strings.Contains(name, ".")
+}
+
+// ========== 导入 ==========
+
+// RuleImportItem 单条规则的导入结果
+type RuleImportItem struct {
+	Index  int        `json:"index"`
+	Name   string     `json:"name"`
+	Action string     `json:"action"` // created/updated/skipped/renamed/invalid
+	RuleID *uuid.UUID `json:"ruleId,omitempty"`
+	Error  string     `json:"error,omitempty"`
+}
+
+// RuleImportResult 规则导入结果
+type RuleImportResult struct {
+	DryRun  bool             `json:"dryRun"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Skipped int              `json:"skipped"`
+	Renamed int              `json:"renamed"`
+	Invalid int              `json:"invalid"`
+	Items   []RuleImportItem `json:"items"`
+}
+
+// RuleTransferService 告警规则导入导出服务
+type RuleTransferService struct {
+	db *gorm.DB
+}
+
+// NewRuleTransferService 创建规则导入导出服务
+func NewRuleTransferService(db *gorm.DB) *RuleTransferService {
+	return &RuleTransferService{db: db}
+}
+
+// Export 导出指定规则（ids为空时导出全部未删除的规则），内置数据中断规则没有条件，不参与导出
+func (s *RuleTransferService) Export(ids []uuid.UUID) ([]RuleSpec, error) {
+	query := s.db.Where("is_deleted = ? AND id <> ?", false, DeadmanRuleID).Order("name")
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	var rules []models.AlertRule
+	if err := query.Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("查询规则失败: %w", err)
+	}
+
+	specs := make([]RuleSpec, 0, len(rules))
+	for _, rule := range rules {
+		var conditions []models.AlertCondition
+		if err := s.db.Where("rule_id = ?", rule.ID).Order("sort_order").Find(&conditions).Error; err != nil {
+			return nil, fmt.Errorf("查询规则条件失败: %w", err)
+		}
+		specs = append(specs, NewRuleSpec(rule, conditions))
+	}
+	return specs, nil
+}
+
+// ParseRuleImport 解析导入内容：Prometheus规则文件（含groups）或本系统的JSON/YAML文档
+func ParseRuleImport(content []byte) ([]RuleSpec, error) {
+	var probe struct {
+		Groups []PrometheusRuleGroup `yaml:"groups"`
+		Rules  []RuleSpec            `yaml:"rules"`
+	}
+	// YAML是JSON的超集，两种格式统一按YAML解析
+	if err := yaml.Unmarshal(content, &probe); err != nil {
+		return nil, fmt.Errorf("解析导入内容失败: %w", err)
+	}
+	if len(probe.Groups) > 0 {
+		return FromPrometheusRules(&PrometheusRuleFile{Groups: probe.Groups})
+	}
+	if len(probe.Rules) == 0 {
+		return nil, fmt.Errorf("导入内容中没有规则")
+	}
+	return probe.Rules, nil
+}
+
+// Import 导入规则：先校验全部规则，存在无效规则时不写入；同名规则按策略覆盖、跳过或重命名。
+// dryRun时只返回计划的操作
+func (s *RuleTransferService) Import(specs []RuleSpec, strategy string, dryRun bool, userID *uuid.UUID) (*RuleImportResult, error) {
+	switch strategy {
+	case ImportStrategyUpsert, ImportStrategySkip, ImportStrategyRename:
+	default:
+		return nil, fmt.Errorf("未知的冲突处理策略: %s", strategy)
+	}
+
+	// 内置数据中断规则不能被导入的同名规则覆盖
+	var builtin []string
+	if err := s.db.Model(&models.AlertRule{}).Where("id = ? AND is_deleted = ?", DeadmanRuleID, false).Pluck("name", &builtin).Error; err != nil {
+		return nil, fmt.Errorf("查询规则失败: %w", err)
+	}
+
+	result := &RuleImportResult{DryRun: dryRun, Items: []RuleImportItem{}}
+	expressions := make([]string, len(specs))
+	seen := map[string]int{}
+	for i := range specs {
+		item := RuleImportItem{Index: i, Name: specs[i].Name}
+		expression, err := specs[i].Validate()
+		if err == nil {
+			if first, dup := seen[specs[i].Name]; dup {
+				err = fmt.Errorf("与第%d条规则重名", first+1)
+			} else if strategy == ImportStrategyUpsert && containsString(builtin, specs[i].Name) {
+				err = fmt.Errorf("与内置规则重名，不能覆盖")
+			}
+		}
+		if err != nil {
+			item.Action, item.Error = "invalid", err.Error()
+			result.Invalid++
+			result.Items = append(result.Items, item)
+			continue
+		}
+		seen[specs[i].Name] = i
+		expressions[i] = expression.String()
+		result.Items = append(result.Items, item)
+	}
+	if result.Invalid > 0 {
+		return result, nil
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for i := range specs {
+			if err := s.importOne(tx, specs[i], expressions[i], strategy, dryRun, userID, &result.Items[i]); err != nil {
+				return fmt.Errorf("导入规则 %s 失败: %w", specs[i].Name, err)
+			}
+			switch result.Items[i].Action {
+			case "created":
+				result.Created++
+			case "updated":
+				result.Updated++
+			case "skipped":
+				result.Skipped++
+			case "renamed":
+				result.Renamed++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importOne 按冲突策略导入单条规则
+func (s *RuleTransferService) importOne(tx *gorm.DB, spec RuleSpec, expression, strategy string, dryRun bool, userID *uuid.UUID, item *RuleImportItem) error {
+	var existing []models.AlertRule
+	if err := tx.Where("name = ? AND is_deleted = ?", spec.Name, false).Limit(1).Find(&existing).Error; err != nil {
+		return err
+	}
+
+	rule := models.AlertRule{ID: uuid.New(), CreatedBy: userID}
+	item.Action = "created"
+	if len(existing) > 0 {
+		switch strategy {
+		case ImportStrategySkip:
+			item.Action = "skipped"
+			item.RuleID = &existing[0].ID
+			return nil
+		case ImportStrategyUpsert:
+			rule = existing[0]
+			item.Action = "updated"
+		case ImportStrategyRename:
+			name, err := s.uniqueName(tx, spec.Name)
+			if err != nil {
+				return err
+			}
+			spec.Name = name
+			item.Name = name
+			item.Action = "renamed"
+		}
+	}
+
+	item.RuleID = &rule.ID
+	if dryRun {
+		return nil
+	}
+
+	revisions := NewRuleRevisionService(tx)
+	action := models.RuleRevisionCreate
+	if item.Action == "updated" {
+		action = models.RuleRevisionImport
+		if err := revisions.EnsureBaseline(tx, rule.ID); err != nil {
+			return err
+		}
+	}
+
+	spec.apply(&rule, expression)
+	rule.UpdatedBy = userID
+	if item.Action == "updated" {
+		if err := tx.Save(&rule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("rule_id = ?", rule.ID).Delete(&models.AlertCondition{}).Error; err != nil {
+			return err
+		}
+	} else if err := tx.Create(&rule).Error; err != nil {
+		return err
+	}
+
+	for _, cond := range spec.conditionModels() {
+		cond.ID = uuid.New()
+		cond.RuleID = rule.ID
+		if err := tx.Create(&cond).Error; err != nil {
+			return err
+		}
+	}
+	_, err := revisions.Record(tx, rule.ID, action, userID, "导入")
+	return err
+}
+
+// uniqueName 生成不与现有规则重名的名称，如 “CPU告警 (2)”
+func (s *RuleTransferService) uniqueName(tx *gorm.DB, name string) (string, error) {
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s (%d)", name, n)
+		var count int64
+		if err := tx.Model(&models.AlertRule{}).Where("name = ? AND is_deleted = ?", candidate, false).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func setupRuleTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	require.NoError(t, err)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_rules (
+		id TEXT PRIMARY KEY, name TEXT, description TEXT, scope TEXT, scope_id TEXT, scope_name TEXT,
+		condition_logic TEXT, expression TEXT, enabled BOOLEAN, cooldown INTEGER, for_duration INTEGER,
+		keep_firing_for INTEGER, flap_threshold INTEGER, flap_window INTEGER, no_data_state TEXT, evaluation_mode TEXT, severity TEXT,
+		notification_config TEXT, trigger_count INTEGER DEFAULT 0, last_triggered_at DATETIME,
+		is_deleted BOOLEAN DEFAULT 0, deleted_at DATETIME, created_at DATETIME, updated_at DATETIME,
+		created_by TEXT, updated_by TEXT
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_conditions (
+		id TEXT PRIMARY KEY, rule_id TEXT, metric TEXT, metric_type TEXT, operator TEXT, threshold REAL,
+		threshold_str TEXT, duration INTEGER, aggregation TEXT, type TEXT, algorithm TEXT,
+		sort_order INTEGER, created_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_rule_revisions (
+		id TEXT PRIMARY KEY, rule_id TEXT, version INTEGER, action TEXT, snapshot TEXT, changes TEXT,
+		note TEXT, author_id TEXT, author_name TEXT, created_at DATETIME, UNIQUE (rule_id, version)
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE users (id TEXT PRIMARY KEY, name TEXT)`).Error)
+	return db
+}
+
+func TestPrometheusRules_RoundTrip(t *testing.T) {
+	vmID := uuid.New()
+	specs := []RuleSpec{{
+		Name:               "cpu high",
+		Scope:              "vm",
+		ScopeID:            &vmID,
+		Severity:           "critical",
+		Enabled:            true,
+		Cooldown:           600,
+		For:                300,
+		FlapThreshold:      4,
+		Expression:         "avg(cpu_usage, 5m) > 90 and not (memory_usage < 10 or rate(net_tx, 1m) > 1e6)",
+		NotificationConfig: map[string]interface{}{"methods": []interface{}{"email"}},
+	}}
+
+	file, err := ToPrometheusRules(specs, "", time.Minute)
+	require.NoError(t, err)
+	require.Len(t, file.Groups, 1)
+	assert.Equal(t, "1m", file.Groups[0].Interval)
+	rule := file.Groups[0].Rules[0]
+	assert.Equal(t, "avg_over_time(cpu_usage[5m]) > 90 and (memory_usage >= 10 and rate(net_tx[1m]) <= 1e+06)", rule.Expr)
+	assert.Equal(t, "5m", rule.For)
+	assert.Equal(t, "critical", rule.Labels["severity"])
+
+	back, err := FromPrometheusRules(file)
+	require.NoError(t, err)
+	require.Len(t, back, 1)
+	assert.Equal(t, "avg(cpu_usage, 5m) > 90 and (memory_usage >= 10 and rate(net_tx, 1m) <= 1e+06)", back[0].Expression)
+	assert.Equal(t, vmID, *back[0].ScopeID)
+	assert.Equal(t, 600, back[0].Cooldown)
+	assert.Equal(t, 300, back[0].For)
+	assert.Equal(t, 4, back[0].FlapThreshold)
+	assert.Equal(t, 3600, back[0].FlapWindow)
+	assert.Equal(t, specs[0].NotificationConfig, back[0].NotificationConfig)
+
+	// 异常检测与标签选择器无法转换
+	_, err = ToPrometheusRules([]RuleSpec{{Name: "anomaly", Severity: "low", Expression: "anomaly(cpu_usage) > 3"}}, "", 0)
+	assert.Error(t, err)
+	_, err = expressionFromPromQL(`cpu_usage{vm="a"} > 90`)
+	assert.Error(t, err)
+	_, err = expressionFromPromQL(`quantile_over_time(cpu_usage[5m]) > 90`)
+	assert.Error(t, err)
+
+	expr, err := expressionFromPromQL("increase(disk_errors[1h30m]) > 0 or node_load1 > 4")
+	require.NoError(t, err)
+	assert.Equal(t, "delta(disk_errors, 90m) > 0 or node_load1 > 4", expr.String())
+}
+
+func TestParseRuleImport_Document(t *testing.T) {
+	groupID := uuid.New()
+	specs := []RuleSpec{{
+		Name:           "memory",
+		Scope:          "group",
+		ScopeID:        &groupID,
+		Severity:       "high",
+		ConditionLogic: "or",
+		Conditions:     []RuleConditionSpec{{Metric: "memory_usage", Operator: ">", Threshold: 85, Duration: 120, Aggregation: "avg"}},
+	}}
+
+	for _, format := range []string{"json", "yaml"} {
+		data, err := MarshalRuleDocument(specs, format)
+		require.NoError(t, err, format)
+
+		parsed, err := ParseRuleImport(data)
+		require.NoError(t, err, format)
+		require.Len(t, parsed, 1, format)
+		assert.Equal(t, groupID, *parsed[0].ScopeID, format)
+		assert.Equal(t, specs[0].Conditions, parsed[0].Conditions, format)
+	}
+
+	promFile, err := ToPrometheusRules(specs, "team", 0)
+	require.NoError(t, err)
+	data, err := yaml.Marshal(promFile)
+	require.NoError(t, err)
+	parsed, err := ParseRuleImport(data)
+	require.NoError(t, err)
+	assert.Equal(t, "avg(memory_usage, 2m) > 85", parsed[0].Expression)
+
+	_, err = ParseRuleImport([]byte("version: v1\n"))
+	assert.Error(t, err)
+}
+
+func TestRuleTransferService_Import(t *testing.T) {
+	db := setupRuleTestDB(t)
+	service := NewRuleTransferService(db)
+
+	existing := models.AlertRule{ID: uuid.New(), Name: "cpu", Scope: "all", Severity: "low", ConditionLogic: "and", NotificationConfig: models.JSONMap{}}
+	require.NoError(t, db.Create(&existing).Error)
+
+	specs := func() []RuleSpec {
+		return []RuleSpec{
+			{Name: "cpu", Severity: "critical", Enabled: true, Expression: "cpu_usage > 95"},
+			{Name: "disk", Severity: "high", Conditions: []RuleConditionSpec{{Metric: "disk_usage", Operator: ">=", Threshold: 90}}},
+		}
+	}
+
+	// 存在无效规则时不写入
+	invalid := append(specs(), RuleSpec{Name: "bad", Severity: "high", Expression: "cpu_usage >"})
+	result, err := service.Import(invalid, ImportStrategyUpsert, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Invalid)
+	assert.Equal(t, "invalid", result.Items[2].Action)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_rules", "1 = 1"))
+
+	result, err = service.Import(specs(), ImportStrategySkip, true, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_rules", "1 = 1"))
+
+	result, err = service.Import(specs(), ImportStrategyRename, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Renamed)
+	assert.Equal(t, "cpu (2)", result.Items[0].Name)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_conditions", "metric = ?", "disk_usage"))
+
+	result, err = service.Import(specs()[:1], ImportStrategyUpsert, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, existing.ID, *result.Items[0].RuleID)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_rules", "id = ? AND severity = ? AND expression = ?", existing.ID, "critical", "cpu_usage > 95"))
+
+	exported, err := service.Export(nil)
+	require.NoError(t, err)
+	require.Len(t, exported, 3)
+	assert.Equal(t, "cpu", exported[0].Name)
+	assert.Equal(t, "disk", exported[2].Name)
+	assert.Equal(t, "disk_usage", exported[2].Conditions[0].Metric)
+
+	_, err = service.Import(specs(), "merge", false, nil)
+	assert.Error(t, err)
+}
+
+func TestRuleTransferService_SkipsDeadmanRule(t *testing.T) {
+	db := setupRuleTestDB(t)
+	service := NewRuleTransferService(db)
+	require.NoError(t, db.Create(&models.AlertRule{ID: DeadmanRuleID, Name: "VM数据中断", Scope: "all", Severity: "high",
+		ConditionLogic: "and", Enabled: true, NotificationConfig: models.JSONMap{}}).Error)
+
+	// 内置规则没有条件，导出全部规则时跳过
+	exported, err := service.Export(nil)
+	require.NoError(t, err)
+	assert.Empty(t, exported)
+	_, err = MarshalPrometheusRules(exported, "vm-monitoring", time.Minute)
+	require.NoError(t, err)
+
+	spec := RuleSpec{Name: "VM数据中断", Severity: "low", Expression: "cpu_usage > 95"}
+	result, err := service.Import([]RuleSpec{spec}, ImportStrategyUpsert, false, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Invalid)
+	assert.Equal(t, int64(1), countRows(t, db, "alert_rules", "id = ? AND severity = ?", DeadmanRuleID, "high"))
+
+	_, err = NewBacktestService(db, time.Minute).Run(models.AlertRule{ID: DeadmanRuleID}, nil, BacktestOptions{})
+	assert.ErrorIs(t, err, ErrInvalidBacktest)
+}
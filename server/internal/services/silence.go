@@ -0,0 +1,233 @@
+package services
+
+import (
+	"fmt"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SilenceTarget 待匹配静默的告警目标
+type SilenceTarget struct {
+	RuleID   uuid.UUID
+	VM       models.VM
+	Severity string
+}
+
+// SilenceWindow 静默的一个生效区间
+type SilenceWindow struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// SilenceService 告警静默与维护窗口服务
+type SilenceService struct {
+	db *gorm.DB
+}
+
+// NewSilenceService 创建静默服务
+func NewSilenceService(db *gorm.DB) *SilenceService {
+	return &SilenceService{db: db}
+}
+
+// ValidateSilence 校验静默配置，并补全默认值
+func ValidateSilence(s *models.Silence) error {
+	if s.Type == "" {
+		s.Type = models.SilenceTypeSilence
+	}
+	if s.Mode == "" {
+		s.Mode = models.SilenceModeNotification
+	}
+	if s.Timezone == "" {
+		s.Timezone = "UTC"
+	}
+
+	if s.Mode != models.SilenceModeNotification && s.Mode != models.SilenceModeCreation {
+		return fmt.Errorf("未知的抑制方式: %s", s.Mode)
+	}
+	if _, err := time.LoadLocation(s.Timezone); err != nil {
+		return fmt.Errorf("无效的时区: %s", s.Timezone)
+	}
+	if s.TagValue != nil && s.TagKey == nil {
+		return fmt.Errorf("指定标签值时必须指定标签名")
+	}
+	if s.EndsAt != nil && !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("结束时间必须晚于开始时间")
+	}
+
+	switch s.Type {
+	case models.SilenceTypeSilence:
+		if s.EndsAt == nil {
+			return fmt.Errorf("一次性静默必须指定结束时间")
+		}
+	case models.SilenceTypeMaintenance:
+		if s.Schedule == nil || *s.Schedule == "" {
+			return fmt.Errorf("维护窗口必须指定cron表达式")
+		}
+		if _, err := ParseCron(*s.Schedule); err != nil {
+			return err
+		}
+		if s.DurationMinutes <= 0 {
+			return fmt.Errorf("维护窗口持续时间必须大于0")
+		}
+	default:
+		return fmt.Errorf("未知的静默类型: %s", s.Type)
+	}
+	return nil
+}
+
+// SilenceActiveAt 判断静默在指定时间是否生效（不考虑匹配条件）。
+// 维护窗口在其时区内按cron计算开始时间，当前时间落在某次开始后的DurationMinutes内即生效。
+func SilenceActiveAt(s *models.Silence, now time.Time) bool {
+	if !s.Enabled || now.Before(s.StartsAt) || (s.EndsAt != nil && !now.Before(*s.EndsAt)) {
+		return false
+	}
+	if s.Type != models.SilenceTypeMaintenance {
+		return true
+	}
+
+	schedule, loc, ok := silenceSchedule(s)
+	if !ok {
+		return false
+	}
+	duration := time.Duration(s.DurationMinutes) * time.Minute
+	start, found := schedule.Prev(now.In(loc), duration)
+	return found && now.Before(start.Add(duration))
+}
+
+// NextWindows 返回从指定时间起的最多n个生效区间（包括当前正在生效的区间）
+func NextWindows(s *models.Silence, from time.Time, n int) []SilenceWindow {
+	windows := []SilenceWindow{}
+	if s.Type != models.SilenceTypeMaintenance {
+		if s.EndsAt != nil && s.EndsAt.After(from) {
+			windows = append(windows, SilenceWindow{Start: s.StartsAt, End: *s.EndsAt})
+		}
+		return windows
+	}
+
+	schedule, loc, ok := silenceSchedule(s)
+	if !ok {
+		return windows
+	}
+	duration := time.Duration(s.DurationMinutes) * time.Minute
+
+	cursor := from.In(loc)
+	if start, found := schedule.Prev(cursor, duration); found && cursor.Before(start.Add(duration)) {
+		cursor = start.Add(-time.Minute)
+	}
+	if cursor.Before(s.StartsAt) {
+		cursor = s.StartsAt.In(loc).Add(-time.Minute)
+	}
+
+	for len(windows) < n {
+		start := schedule.Next(cursor)
+		if start.IsZero() || (s.EndsAt != nil && !start.Before(*s.EndsAt)) {
+			break
+		}
+		windows = append(windows, SilenceWindow{Start: start, End: start.Add(duration)})
+		cursor = start
+	}
+	return windows
+}
+
+// silenceSchedule 解析维护窗口的cron表达式与时区
+func silenceSchedule(s *models.Silence) (*CronSchedule, *time.Location, bool) {
+	if s.Schedule == nil {
+		return nil, nil, false
+	}
+	schedule, err := ParseCron(*s.Schedule)
+	if err != nil {
+		return nil, nil, false
+	}
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, nil, false
+	}
+	return schedule, loc, true
+}
+
+// List 获取静默列表，activeOnly时只返回当前生效的静默
+func (s *SilenceService) List(activeOnly bool, now time.Time) ([]models.Silence, error) {
+	var silences []models.Silence
+	query := s.db.Order("starts_at DESC")
+	if activeOnly {
+		query = query.Where("enabled = ? AND starts_at <= ? AND (ends_at IS NULL OR ends_at > ?)", true, now, now)
+	}
+	if err := query.Find(&silences).Error; err != nil {
+		return nil, err
+	}
+
+	if !activeOnly {
+		return silences, nil
+	}
+	active := []models.Silence{}
+	for _, silence := range silences {
+		if SilenceActiveAt(&silence, now) {
+			active = append(active, silence)
+		}
+	}
+	return active, nil
+}
+
+// Match 查找当前命中告警目标的静默；同时命中多个时优先返回抑制创建的静默
+func (s *SilenceService) Match(target SilenceTarget, now time.Time) (*models.Silence, error) {
+	if s.db == nil {
+		return nil, nil
+	}
+
+	silences, err := s.List(true, now)
+	if err != nil {
+		return nil, fmt.Errorf("查询静默失败: %w", err)
+	}
+
+	var matched *models.Silence
+	for i := range silences {
+		silence := &silences[i]
+		if !s.matches(silence, target) {
+			continue
+		}
+		if silence.Mode == models.SilenceModeCreation {
+			return silence, nil
+		}
+		if matched == nil {
+			matched = silence
+		}
+	}
+	return matched, nil
+}
+
+// matches 判断静默的匹配条件是否全部满足
+func (s *SilenceService) matches(silence *models.Silence, target SilenceTarget) bool {
+	vm := target.VM
+	if silence.RuleID != nil && *silence.RuleID != target.RuleID {
+		return false
+	}
+	if silence.VMID != nil && *silence.VMID != vm.ID {
+		return false
+	}
+	if silence.Severity != nil && *silence.Severity != target.Severity {
+		return false
+	}
+	if silence.ClusterID != nil && (vm.ClusterID == nil || *vm.ClusterID != *silence.ClusterID) {
+		return false
+	}
+	if silence.TagKey != nil {
+		value, ok := vm.Tags[*silence.TagKey]
+		if !ok || (silence.TagValue != nil && fmt.Sprint(value) != *silence.TagValue) {
+			return false
+		}
+	}
+	if silence.GroupID != nil && (vm.GroupID == nil || *vm.GroupID != *silence.GroupID) {
+		var count int64
+		s.db.Model(&models.VMGroupMember{}).
+			Where("vm_id = ? AND group_id = ?", vm.ID, *silence.GroupID).
+			Count(&count)
+		if count == 0 {
+			return false
+		}
+	}
+	return true
+}
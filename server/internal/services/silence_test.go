@@ -0,0 +1,156 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"vm-monitoring-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestValidateSilence(t *testing.T) {
+	now := time.Now()
+	end := now.Add(time.Hour)
+	silence := &models.Silence{Name: "patch", StartsAt: now, EndsAt: &end}
+	require.NoError(t, ValidateSilence(silence))
+	assert.Equal(t, models.SilenceTypeSilence, silence.Type)
+	assert.Equal(t, models.SilenceModeNotification, silence.Mode)
+	assert.Equal(t, "UTC", silence.Timezone)
+
+	assert.Error(t, ValidateSilence(&models.Silence{Name: "no end", StartsAt: now}))
+
+	schedule := "0 2 * * 6"
+	maintenance := &models.Silence{Name: "weekly", Type: models.SilenceTypeMaintenance, StartsAt: now, Schedule: &schedule, DurationMinutes: 120, Timezone: "Asia/Shanghai"}
+	require.NoError(t, ValidateSilence(maintenance))
+
+	maintenance.Timezone = "Mars/Olympus"
+	assert.Error(t, ValidateSilence(maintenance))
+	maintenance.Timezone = "UTC"
+	maintenance.DurationMinutes = 0
+	assert.Error(t, ValidateSilence(maintenance))
+}
+
+func TestSilenceActiveAt_MaintenanceTimezone(t *testing.T) {
+	// 上海时间每周六02:00开始，持续2小时（即UTC周五18:00-20:00）
+	schedule := "0 2 * * 6"
+	silence := &models.Silence{
+		Type:            models.SilenceTypeMaintenance,
+		Enabled:         true,
+		StartsAt:        time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Schedule:        &schedule,
+		DurationMinutes: 120,
+		Timezone:        "Asia/Shanghai",
+	}
+
+	assert.True(t, SilenceActiveAt(silence, time.Date(2026, 3, 6, 18, 30, 0, 0, time.UTC)))
+	assert.False(t, SilenceActiveAt(silence, time.Date(2026, 3, 6, 20, 0, 0, 0, time.UTC)))
+	assert.False(t, SilenceActiveAt(silence, time.Date(2026, 3, 7, 2, 30, 0, 0, time.UTC)))
+
+	windows := NextWindows(silence, time.Date(2026, 3, 6, 19, 0, 0, 0, time.UTC), 2)
+	require.Len(t, windows, 2)
+	assert.True(t, windows[0].Start.Equal(time.Date(2026, 3, 6, 18, 0, 0, 0, time.UTC)))
+	assert.True(t, windows[1].Start.Equal(time.Date(2026, 3, 13, 18, 0, 0, 0, time.UTC)))
+
+	// 有效期结束后不再生效
+	end := time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)
+	silence.EndsAt = &end
+	assert.False(t, SilenceActiveAt(silence, time.Date(2026, 3, 13, 18, 30, 0, 0, time.UTC)))
+	assert.Len(t, NextWindows(silence, time.Date(2026, 3, 6, 19, 0, 0, 0, time.UTC), 5), 1)
+}
+
+func setupSilenceTestDB(t *testing.T) *gorm.DB {
+	db := setupAlertStateTestDB(t)
+	require.NoError(t, db.Exec(`CREATE TABLE alert_silences (
+		id TEXT PRIMARY KEY, name TEXT, type TEXT, mode TEXT, comment TEXT, enabled BOOLEAN,
+		rule_id TEXT, vm_id TEXT, group_id TEXT, cluster_id TEXT, tag_key TEXT, tag_value TEXT,
+		severity TEXT, starts_at DATETIME, ends_at DATETIME, schedule TEXT, duration_minutes INTEGER,
+		timezone TEXT, created_by TEXT, created_by_name TEXT, created_at DATETIME, updated_at DATETIME
+	)`).Error)
+	require.NoError(t, db.Exec(`CREATE TABLE vm_group_members (
+		id TEXT PRIMARY KEY, vm_id TEXT, group_id TEXT, created_at DATETIME
+	)`).Error)
+	return db
+}
+
+func TestSilenceService_Match(t *testing.T) {
+	db := setupSilenceTestDB(t)
+	service := NewSilenceService(db)
+	now := time.Now()
+	end := now.Add(time.Hour)
+
+	groupID := uuid.New()
+	cluster := "cluster-a"
+	vm := models.VM{ID: uuid.New(), Name: "db-01", ClusterID: &cluster, Tags: models.JSONMap{"env": "prod"}}
+	require.NoError(t, db.Exec("INSERT INTO vm_group_members (id, vm_id, group_id) VALUES (?, ?, ?)", uuid.New(), vm.ID, groupID).Error)
+
+	env, prod, staging := "env", "prod", "staging"
+	critical := "critical"
+	silences := []models.Silence{
+		{ID: uuid.New(), Name: "staging", Enabled: true, TagKey: &env, TagValue: &staging, StartsAt: now.Add(-time.Minute), EndsAt: &end},
+		{ID: uuid.New(), Name: "critical only", Enabled: true, Severity: &critical, StartsAt: now.Add(-time.Minute), EndsAt: &end},
+		{ID: uuid.New(), Name: "group", Mode: models.SilenceModeNotification, Enabled: true, GroupID: &groupID, ClusterID: &cluster, StartsAt: now.Add(-time.Minute), EndsAt: &end},
+	}
+	for i := range silences {
+		require.NoError(t, ValidateSilence(&silences[i]))
+		require.NoError(t, db.Create(&silences[i]).Error)
+	}
+
+	target := SilenceTarget{RuleID: uuid.New(), VM: vm, Severity: "high"}
+	matched, err := service.Match(target, now)
+	require.NoError(t, err)
+	require.NotNil(t, matched)
+	assert.Equal(t, "group", matched.Name)
+
+	// 抑制创建的静默优先
+	creation := models.Silence{ID: uuid.New(), Name: "prod", Mode: models.SilenceModeCreation, Enabled: true, TagKey: &env, TagValue: &prod, StartsAt: now.Add(-time.Minute), EndsAt: &end}
+	require.NoError(t, ValidateSilence(&creation))
+	require.NoError(t, db.Create(&creation).Error)
+	matched, err = service.Match(target, now)
+	require.NoError(t, err)
+	assert.Equal(t, "prod", matched.Name)
+
+	// 其他VM不匹配
+	matched, err = service.Match(SilenceTarget{RuleID: target.RuleID, VM: models.VM{ID: uuid.New()}, Severity: "high"}, now)
+	require.NoError(t, err)
+	assert.Nil(t, matched)
+}
+
+func TestAlertEngine_SilenceSuppression(t *testing.T) {
+	db := setupSilenceTestDB(t)
+	engine := NewAlertEngine(db, nil)
+
+	rule := models.AlertRule{ID: uuid.New(), Name: "cpu high", Severity: "high"}
+	require.NoError(t, db.Exec("INSERT INTO alert_rules (id) VALUES (?)", rule.ID).Error)
+	vmA := models.VM{ID: uuid.New(), Name: "web-01"}
+	vmB := models.VM{ID: uuid.New(), Name: "web-02"}
+	metric := &AlertMetricData{Metric: "cpu_usage", Value: 95, Condition: models.AlertCondition{Metric: "cpu_usage", Operator: ">", Threshold: 90}}
+	now := time.Now()
+	end := now.Add(time.Hour)
+
+	notifyOnly := models.Silence{ID: uuid.New(), Name: "mute web-01", Enabled: true, VMID: &vmA.ID, StartsAt: now.Add(-time.Minute), EndsAt: &end}
+	noCreate := models.Silence{ID: uuid.New(), Name: "drop web-02", Mode: models.SilenceModeCreation, Enabled: true, VMID: &vmB.ID, StartsAt: now.Add(-time.Minute), EndsAt: &end}
+	for _, s := range []*models.Silence{&notifyOnly, &noCreate} {
+		require.NoError(t, ValidateSilence(s))
+		require.NoError(t, db.Create(s).Error)
+	}
+
+	// 抑制通知：记录照常创建并标记抑制
+	engine.applyEvaluation(rule, vmA, true, metric, now)
+	var record models.AlertRecord
+	require.NoError(t, db.Where("vm_id = ?", vmA.ID).First(&record).Error)
+	assert.True(t, record.Suppressed)
+	require.NotNil(t, record.SilenceID)
+	assert.Equal(t, notifyOnly.ID, *record.SilenceID)
+
+	// 抑制创建：不创建记录，静默结束后再触发
+	engine.applyEvaluation(rule, vmB, true, metric, now)
+	assert.Equal(t, int64(0), countRows(t, db, "alert_records", "vm_id = ?", vmB.ID))
+	assert.Equal(t, models.AlertStateInactive, engine.stateFor(rule.ID, vmB.ID).State)
+
+	engine.applyEvaluation(rule, vmB, true, metric, end.Add(time.Minute))
+	assert.Equal(t, int64(1), countRows(t, db, "alert_records", "vm_id = ? AND suppressed = ?", vmB.ID, false))
+}
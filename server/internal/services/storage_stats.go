@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"vm-monitoring-system/internal/logger"
+	"vm-monitoring-system/internal/models"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	storageStatsCacheKey = "system:storage_stats"
+	// bloatDeadRatio 死元组占比超过该值视为存在膨胀
+	bloatDeadRatio = 0.2
+	// exactCountLimit 行数估计低于该值时按指标精确计数，否则采样估计
+	exactCountLimit = 1000000
+)
+
+// storageTables 需要统计的业务表
+var storageTables = []string{
+	"metric_records",
+	"metric_rollups",
+	"alert_records",
+	"audit_logs",
+	"anomaly_baselines",
+	"vms",
+}
+
+// TableStorage 单表存储统计
+type TableStorage struct {
+	Table         string     `json:"table"`
+	TotalBytes    int64      `json:"totalBytes"`
+	TableBytes    int64      `json:"tableBytes"`
+	IndexBytes    int64      `json:"indexBytes"`
+	EstimatedRows int64      `json:"estimatedRows"`
+	LiveRows      int64      `json:"liveRows"`
+	DeadRows      int64      `json:"deadRows"`
+	DeadRatio     float64    `json:"deadRatio"`
+	Bloated       bool       `json:"bloated"`
+	LastVacuum    *time.Time `json:"lastVacuum,omitempty"`
+	LastAnalyze   *time.Time `json:"lastAnalyze,omitempty"`
+}
+
+// TierStorage 数据分层统计（原始 / 汇总）
+type TierStorage struct {
+	Tier          string     `json:"tier"`
+	Table         string     `json:"table"`
+	EstimatedRows int64      `json:"estimatedRows"`
+	TotalBytes    int64      `json:"totalBytes"`
+	OldestRecord  *time.Time `json:"oldestRecord,omitempty"`
+	LatestRecord  *time.Time `json:"latestRecord,omitempty"`
+}
+
+// MetricRowEstimate 单个指标的行数估计
+type MetricRowEstimate struct {
+	Metric        string `json:"metric"`
+	EstimatedRows int64  `json:"estimatedRows"`
+}
+
+// IngestionStats 写入速率统计
+type IngestionStats struct {
+	RowsLastHour  int64   `json:"rowsLastHour"`
+	RowsLast24h   int64   `json:"rowsLast24h"`
+	RowsPerSecond float64 `json:"rowsPerSecond"`
+	BytesPerRow   float64 `json:"bytesPerRow"`
+	BytesPerDay   float64 `json:"bytesPerDay"`
+}
+
+// GrowthProjection 按当前写入速率线性预测的存储增长（未扣除保留策略清理）
+type GrowthProjection struct {
+	Days           int   `json:"days"`
+	ProjectedBytes int64 `json:"projectedBytes"`
+	ProjectedRows  int64 `json:"projectedRows"`
+}
+
+// SystemStorageStats 系统存储统计
+type SystemStorageStats struct {
+	Database      string              `json:"database"`
+	DatabaseBytes int64               `json:"databaseBytes"`
+	Tables        []TableStorage      `json:"tables"`
+	Tiers         []TierStorage       `json:"tiers"`
+	ByMetric      []MetricRowEstimate `json:"byMetric"`
+	MetricSource  string              `json:"metricSource"` // pg_stats / exact / sample
+	Ingestion     IngestionStats      `json:"ingestion"`
+	Projections   []GrowthProjection  `json:"projections"`
+	BloatedTables []string            `json:"bloatedTables"`
+	GeneratedAt   time.Time           `json:"generatedAt"`
+	Cached        bool                `json:"cached"`
+}
+
+// StorageStatsService 存储统计服务（结果缓存，避免频繁查询系统表）
+type StorageStatsService struct {
+	db       *gorm.DB
+	cacheTTL time.Duration
+
+	mutex    sync.Mutex
+	cached   *SystemStorageStats
+	cachedAt time.Time
+	collect  func() (*SystemStorageStats, error)
+}
+
+// NewStorageStatsService 创建存储统计服务
+func NewStorageStatsService(db *gorm.DB) *StorageStatsService {
+	s := &StorageStatsService{
+		db:       db,
+		cacheTTL: 5 * time.Minute,
+	}
+	s.collect = s.collectStats
+	return s
+}
+
+// SetCacheTTL 设置缓存时间
+func (s *StorageStatsService) SetCacheTTL(ttl time.Duration) {
+	if ttl > 0 {
+		s.cacheTTL = ttl
+	}
+}
+
+// GetStats 获取存储统计，refresh 为 true 时跳过缓存
+func (s *StorageStatsService) GetStats(refresh bool) (*SystemStorageStats, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !refresh {
+		if s.cached != nil && time.Since(s.cachedAt) < s.cacheTTL {
+			stats := *s.cached
+			stats.Cached = true
+			return &stats, nil
+		}
+		if stats := s.loadFromRedis(); stats != nil {
+			s.cached, s.cachedAt = stats, stats.GeneratedAt
+			result := *stats
+			result.Cached = true
+			return &result, nil
+		}
+	}
+
+	stats, err := s.collect()
+	if err != nil {
+		return nil, err
+	}
+
+	s.cached, s.cachedAt = stats, stats.GeneratedAt
+	s.saveToRedis(stats)
+	return stats, nil
+}
+
+// loadFromRedis 从Redis读取其他实例生成的缓存
+func (s *StorageStatsService) loadFromRedis() *SystemStorageStats {
+	if models.Cache == nil {
+		return nil
+	}
+
+	data, err := models.Cache.Get(context.Background(), storageStatsCacheKey).Bytes()
+	if err != nil || len(data) == 0 {
+		return nil
+	}
+
+	var stats SystemStorageStats
+	if err := json.Unmarshal(data, &stats); err != nil {
+		return nil
+	}
+	if time.Since(stats.GeneratedAt) >= s.cacheTTL {
+		return nil
+	}
+	return &stats
+}
+
+// saveToRedis 写入Redis缓存
+func (s *StorageStatsService) saveToRedis(stats *SystemStorageStats) {
+	if models.Cache == nil {
+		return
+	}
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return
+	}
+	if err := models.Cache.Set(context.Background(), storageStatsCacheKey, data, s.cacheTTL).Err(); err != nil {
+		logger.Error("缓存存储统计失败", zap.Error(err))
+	}
+}
+
+// collectStats 从PostgreSQL系统表收集存储统计
+func (s *StorageStatsService) collectStats() (*SystemStorageStats, error) {
+	if s.db.Dialector.Name() != "postgres" {
+		return nil, fmt.Errorf("存储统计仅支持PostgreSQL")
+	}
+
+	now := time.Now()
+	stats := &SystemStorageStats{GeneratedAt: now}
+
+	var dbInfo struct {
+		Name string
+		Size int64
+	}
+	if err := s.db.Raw("SELECT current_database() AS name, pg_database_size(current_database()) AS size").
+		Scan(&dbInfo).Error; err != nil {
+		return nil, fmt.Errorf("查询数据库大小失败: %w", err)
+	}
+	stats.Database, stats.DatabaseBytes = dbInfo.Name, dbInfo.Size
+
+	tables, err := s.tableStorage()
+	if err != nil {
+		return nil, err
+	}
+	stats.Tables = tables
+	stats.BloatedTables = []string{}
+	byTable := make(map[string]TableStorage, len(tables))
+	for _, t := range tables {
+		byTable[t.Table] = t
+		if t.Bloated {
+			stats.BloatedTables = append(stats.BloatedTables, t.Table)
+		}
+	}
+
+	raw := byTable["metric_records"]
+	rollup := byTable["metric_rollups"]
+	stats.Tiers = []TierStorage{
+		s.tierStorage("raw", "metric_records", "timestamp", raw),
+		s.tierStorage("rollup", "metric_rollups", "bucket", rollup),
+	}
+
+	stats.ByMetric, stats.MetricSource, err = s.metricRowEstimates(raw.EstimatedRows)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Table("metric_records").Where("timestamp >= ?", now.Add(-time.Hour)).
+		Count(&stats.Ingestion.RowsLastHour).Error; err != nil {
+		return nil, fmt.Errorf("统计写入速率失败: %w", err)
+	}
+	if err := s.db.Table("metric_records").Where("timestamp >= ?", now.Add(-24*time.Hour)).
+		Count(&stats.Ingestion.RowsLast24h).Error; err != nil {
+		return nil, fmt.Errorf("统计写入速率失败: %w", err)
+	}
+	stats.Ingestion = computeIngestion(stats.Ingestion.RowsLastHour, stats.Ingestion.RowsLast24h, raw.TotalBytes, raw.EstimatedRows)
+	stats.Projections = projectGrowth(stats.Ingestion, raw.TotalBytes, raw.EstimatedRows, []int{7, 30, 90})
+
+	return stats, nil
+}
+
+// tableStorage 查询各表大小与膨胀情况
+func (s *StorageStatsService) tableStorage() ([]TableStorage, error) {
+	var rows []struct {
+		Table       string
+		TotalBytes  int64
+		TableBytes  int64
+		IndexBytes  int64
+		Reltuples   float64
+		LiveRows    int64
+		DeadRows    int64
+		LastVacuum  *time.Time
+		LastAnalyze *time.Time
+	}
+
+	err := s.db.Raw(`
+		SELECT c.relname AS "table",
+			pg_total_relation_size(c.oid) AS total_bytes,
+			pg_relation_size(c.oid) AS table_bytes,
+			pg_indexes_size(c.oid) AS index_bytes,
+			c.reltuples AS reltuples,
+			COALESCE(st.n_live_tup, 0) AS live_rows,
+			COALESCE(st.n_dead_tup, 0) AS dead_rows,
+			GREATEST(st.last_vacuum, st.last_autovacuum) AS last_vacuum,
+			GREATEST(st.last_analyze, st.last_autoanalyze) AS last_analyze
+		FROM pg_class c
+		JOIN pg_namespace n ON n.oid = c.relnamespace
+		LEFT JOIN pg_stat_user_tables st ON st.relid = c.oid
+		WHERE c.relkind IN ('r', 'p') AND n.nspname = current_schema() AND c.relname IN ?`,
+		storageTables).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("查询表大小失败: %w", err)
+	}
+
+	tables := make([]TableStorage, 0, len(rows))
+	for _, r := range rows {
+		t := TableStorage{
+			Table:         r.Table,
+			TotalBytes:    r.TotalBytes,
+			TableBytes:    r.TableBytes,
+			IndexBytes:    r.IndexBytes,
+			EstimatedRows: int64(r.Reltuples),
+			LiveRows:      r.LiveRows,
+			DeadRows:      r.DeadRows,
+			LastVacuum:    r.LastVacuum,
+			LastAnalyze:   r.LastAnalyze,
+		}
+		// reltuples 为 -1 表示从未分析过
+		if t.EstimatedRows < 0 {
+			t.EstimatedRows = r.LiveRows
+		}
+		t.DeadRatio, t.Bloated = bloatOf(r.LiveRows, r.DeadRows)
+
+		// TimescaleDB 超表的数据存储在分块中，父表大小为0
+		if r.Table == "metric_records" {
+			s.applyHypertableSize(&t)
+		}
+		tables = append(tables, t)
+	}
+
+	sort.Slice(tables, func(i, j int) bool { return tables[i].TotalBytes > tables[j].TotalBytes })
+	return tables, nil
+}
+
+// applyHypertableSize 若为TimescaleDB超表则使用超表函数获取大小与行数
+func (s *StorageStatsService) applyHypertableSize(t *TableStorage) {
+	var isHypertable bool
+	if err := s.db.Raw(`SELECT EXISTS (
+			SELECT 1 FROM pg_extension WHERE extname = 'timescaledb'
+		) AND EXISTS (
+			SELECT 1 FROM timescaledb_information.hypertables WHERE hypertable_name = ?
+		)`, t.Table).Scan(&isHypertable).Error; err != nil || !isHypertable {
+		return
+	}
+
+	var size struct {
+		TableBytes int64
+		IndexBytes int64
+		TotalBytes int64
+	}
+	if err := s.db.Raw("SELECT table_bytes, index_bytes, total_bytes FROM hypertable_detailed_size(?::regclass)", t.Table).
+		Scan(&size).Error; err == nil {
+		t.TableBytes, t.IndexBytes, t.TotalBytes = size.TableBytes, size.IndexBytes, size.TotalBytes
+	}
+
+	var rows int64
+	if err := s.db.Raw("SELECT approximate_row_count(?::regclass)", t.Table).Scan(&rows).Error; err == nil {
+		t.EstimatedRows = rows
+	}
+}
+
+// tierStorage 统计数据层的时间范围（利用时间索引只读取首尾一行）
+func (s *StorageStatsService) tierStorage(tier, table, timeColumn string, t TableStorage) TierStorage {
+	result := TierStorage{
+		Tier:          tier,
+		Table:         table,
+		EstimatedRows: t.EstimatedRows,
+		TotalBytes:    t.TotalBytes,
+	}
+
+	var oldest, latest []time.Time
+	if err := s.db.Table(table).Order(timeColumn+" ASC").Limit(1).Pluck(timeColumn, &oldest).Error; err == nil && len(oldest) > 0 {
+		result.OldestRecord = &oldest[0]
+	}
+	if err := s.db.Table(table).Order(timeColumn+" DESC").Limit(1).Pluck(timeColumn, &latest).Error; err == nil && len(latest) > 0 {
+		result.LatestRecord = &latest[0]
+	}
+	return result
+}
+
+// metricRowEstimates 按指标估计行数：优先使用 pg_stats 的高频值统计，否则精确计数或采样
+func (s *StorageStatsService) metricRowEstimates(totalRows int64) ([]MetricRowEstimate, string, error) {
+	var mcv struct {
+		Vals  string
+		Freqs string
+	}
+	err := s.db.Raw(`SELECT array_to_json(most_common_vals::text::text[])::text AS vals,
+			array_to_json(most_common_freqs)::text AS freqs
+		FROM pg_stats
+		WHERE schemaname = current_schema() AND tablename = 'metric_records' AND attname = 'metric'`).
+		Scan(&mcv).Error
+	if err == nil && mcv.Vals != "" && totalRows > 0 {
+		var vals []string
+		var freqs []float64
+		if json.Unmarshal([]byte(mcv.Vals), &vals) == nil && json.Unmarshal([]byte(mcv.Freqs), &freqs) == nil {
+			return estimateFromFrequencies(vals, freqs, totalRows), "pg_stats", nil
+		}
+	}
+
+	var rows []struct {
+		Metric string
+		Count  int64
+	}
+	source := "exact"
+	query := "SELECT metric, COUNT(*) AS count FROM metric_records GROUP BY metric"
+	scale := int64(1)
+	if totalRows > exactCountLimit {
+		source = "sample"
+		query = "SELECT metric, COUNT(*) AS count FROM metric_records TABLESAMPLE SYSTEM (1) GROUP BY metric"
+		scale = 100
+	}
+	if err := s.db.Raw(query).Scan(&rows).Error; err != nil {
+		return nil, "", fmt.Errorf("按指标统计行数失败: %w", err)
+	}
+
+	result := make([]MetricRowEstimate, 0, len(rows))
+	for _, r := range rows {
+		result = append(result, MetricRowEstimate{Metric: r.Metric, EstimatedRows: r.Count * scale})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].EstimatedRows > result[j].EstimatedRows })
+	return result, source, nil
+}
+
+// estimateFromFrequencies 根据高频值占比估算各指标行数
+func estimateFromFrequencies(vals []string, freqs []float64, totalRows int64) []MetricRowEstimate {
+	result := make([]MetricRowEstimate, 0, len(vals))
+	for i, v := range vals {
+		if i >= len(freqs) {
+			break
+		}
+		result = append(result, MetricRowEstimate{Metric: v, EstimatedRows: int64(freqs[i]*float64(totalRows) + 0.5)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].EstimatedRows > result[j].EstimatedRows })
+	return result
+}
+
+// bloatOf 计算死元组占比及是否膨胀
+func bloatOf(live, dead int64) (float64, bool) {
+	if live+dead == 0 {
+		return 0, false
+	}
+	ratio := float64(dead) / float64(live+dead)
+	return ratio, ratio > bloatDeadRatio
+}
+
+// computeIngestion 计算写入速率，优先使用近24小时数据以平滑波动
+func computeIngestion(lastHour, last24h, totalBytes, totalRows int64) IngestionStats {
+	stats := IngestionStats{RowsLastHour: lastHour, RowsLast24h: last24h}
+	if last24h > 0 {
+		stats.RowsPerSecond = float64(last24h) / (24 * 3600)
+	} else {
+		stats.RowsPerSecond = float64(lastHour) / 3600
+	}
+	if totalRows > 0 {
+		stats.BytesPerRow = float64(totalBytes) / float64(totalRows)
+	}
+	stats.BytesPerDay = stats.RowsPerSecond * 24 * 3600 * stats.BytesPerRow
+	return stats
+}
+
+// projectGrowth 线性预测若干天后的存储大小
+func projectGrowth(ingestion IngestionStats, currentBytes, currentRows int64, days []int) []GrowthProjection {
+	projections := make([]GrowthProjection, 0, len(days))
+	rowsPerDay := ingestion.RowsPerSecond * 24 * 3600
+	for _, d := range days {
+		projections = append(projections, GrowthProjection{
+			Days:           d,
+			ProjectedBytes: currentBytes + int64(ingestion.BytesPerDay*float64(d)),
+			ProjectedRows:  currentRows + int64(rowsPerDay*float64(d)),
+		})
+	}
+	return projections
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageStatsService_Cache(t *testing.T) {
+	service := NewStorageStatsService(nil)
+	calls := 0
+	service.collect = func() (*SystemStorageStats, error) {
+		calls++
+		return &SystemStorageStats{Database: "vm_monitoring", GeneratedAt: time.Now()}, nil
+	}
+
+	stats, err := service.GetStats(false)
+	require.NoError(t, err)
+	assert.False(t, stats.Cached)
+
+	stats, err = service.GetStats(false)
+	require.NoError(t, err)
+	assert.True(t, stats.Cached)
+	assert.Equal(t, 1, calls)
+
+	_, err = service.GetStats(true)
+	require.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	// 缓存过期后重新收集
+	service.cachedAt = time.Now().Add(-time.Hour)
+	_, err = service.GetStats(false)
+	require.NoError(t, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestEstimateFromFrequencies(t *testing.T) {
+	estimates := estimateFromFrequencies(
+		[]string{"memory_usage", "cpu_usage", "disk_usage"},
+		[]float64{0.25, 0.5, 0.25},
+		1000,
+	)
+
+	require.Len(t, estimates, 3)
+	assert.Equal(t, "cpu_usage", estimates[0].Metric)
+	assert.Equal(t, int64(500), estimates[0].EstimatedRows)
+	assert.Equal(t, int64(250), estimates[2].EstimatedRows)
+}
+
+func TestBloatOf(t *testing.T) {
+	ratio, bloated := bloatOf(0, 0)
+	assert.Equal(t, 0.0, ratio)
+	assert.False(t, bloated)
+
+	ratio, bloated = bloatOf(700, 300)
+	assert.InDelta(t, 0.3, ratio, 1e-9)
+	assert.True(t, bloated)
+
+	_, bloated = bloatOf(900, 100)
+	assert.False(t, bloated)
+}
+
+func TestIngestionAndProjection(t *testing.T) {
+	ingestion := computeIngestion(3600, 86400, 100*1000, 1000)
+	assert.InDelta(t, 1.0, ingestion.RowsPerSecond, 1e-9)
+	assert.InDelta(t, 100.0, ingestion.BytesPerRow, 1e-9)
+	assert.InDelta(t, 8640000.0, ingestion.BytesPerDay, 1e-6)
+
+	projections := projectGrowth(ingestion, 100*1000, 1000, []int{7, 30})
+	require.Len(t, projections, 2)
+	assert.Equal(t, int64(100*1000+7*8640000), projections[0].ProjectedBytes)
+	assert.Equal(t, int64(1000+30*86400), projections[1].ProjectedRows)
+
+	// 无24小时数据时使用最近1小时
+	ingestion = computeIngestion(7200, 0, 0, 0)
+	assert.InDelta(t, 2.0, ingestion.RowsPerSecond, 1e-9)
+	assert.Equal(t, 0.0, ingestion.BytesPerDay)
+}
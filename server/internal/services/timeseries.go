@@ -1,8 +1,11 @@
 package services
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"math"
+	"sort"
 	"time"
 
 	"github.com/google/uuid"
@@ -21,7 +24,10 @@ type MetricData struct {
 
 // TimeSeriesService 时序数据服务
 type TimeSeriesService struct {
-	db *gorm.DB
+	db       *gorm.DB
+	hot      *HotCache
+	hotGuard func() bool        // 热缓存是否可读，返回false时查询回退到数据库
+	onIngest func([]MetricData) // 写入成功后的回调（写入驱动的告警评估）
 }
 
 // NewTimeSeriesService 创建时序数据服务
@@ -31,6 +37,35 @@ func NewTimeSeriesService(db *gorm.DB) *TimeSeriesService {
 	}
 }
 
+// SetHotCache 设置热数据缓存（写入时同步更新，短时间范围查询优先读取）
+func (s *TimeSeriesService) SetHotCache(hot *HotCache) {
+	s.hot = hot
+}
+
+// SetHotCacheGuard 设置热缓存是否可读的判断。缓存只包含本进程写入的数据点，
+// 多实例部署时其他实例写入的数据不在缓存中，此时应返回false使查询全部回退到数据库
+func (s *TimeSeriesService) SetHotCacheGuard(guard func() bool) {
+	s.hotGuard = guard
+}
+
+// readableHot 返回可用于查询的热缓存，未设置或当前不可读时返回nil
+func (s *TimeSeriesService) readableHot() *HotCache {
+	if s.hot == nil || (s.hotGuard != nil && !s.hotGuard()) {
+		return nil
+	}
+	return s.hot
+}
+
+// SetIngestListener 设置写入监听器，每次批量写入成功后以写入的数据调用（不应阻塞）
+func (s *TimeSeriesService) SetIngestListener(listener func([]MetricData)) {
+	s.onIngest = listener
+}
+
+// HotCache 获取热数据缓存
+func (s *TimeSeriesService) HotCache() *HotCache {
+	return s.hot
+}
+
 // InsertMetrics 批量插入指标数据
 func (s *TimeSeriesService) InsertMetrics(metrics []MetricData) error {
 	if len(metrics) == 0 {
@@ -56,12 +91,76 @@ func (s *TimeSeriesService) InsertMetrics(metrics []MetricData) error {
 		return fmt.Errorf("批量插入指标数据失败: %w", err)
 	}
 
+	if s.hot != nil {
+		s.hot.Append(metrics)
+	}
+	s.touchLastSeen(metrics)
+	if s.onIngest != nil {
+		s.onIngest(metrics)
+	}
+
 	log.Printf("已保存 %d 条指标数据", len(records))
 	return nil
 }
 
+// touchLastSeen 将VM的最后上报时间更新为本批次中的最新数据时间（数据中断检测依据该时间）
+func (s *TimeSeriesService) touchLastSeen(metrics []MetricData) {
+	latest := make(map[string]time.Time)
+	for _, m := range metrics {
+		if m.Timestamp.After(latest[m.VMID]) {
+			latest[m.VMID] = m.Timestamp
+		}
+	}
+
+	// 同一批次的VM通常时间相同，按时间分组更新
+	byTime := make(map[time.Time][]string)
+	for vmID, ts := range latest {
+		byTime[ts] = append(byTime[ts], vmID)
+	}
+	for ts, vmIDs := range byTime {
+		if err := s.db.Table("vms").
+			Where("id IN ? AND (last_seen IS NULL OR last_seen < ?)", vmIDs, ts).
+			UpdateColumn("last_seen", ts).Error; err != nil {
+			log.Printf("更新VM最后上报时间失败: %v", err)
+		}
+	}
+}
+
+// LastSampleTimes 查询VM各指标在since之后的最后一个数据点时间（vmID → 指标 → 时间）
+func (s *TimeSeriesService) LastSampleTimes(vmIDs []string, since time.Time) (map[string]map[string]time.Time, error) {
+	result := make(map[string]map[string]time.Time, len(vmIDs))
+	if len(vmIDs) == 0 {
+		return result, nil
+	}
+
+	var rows []struct {
+		VMID      string
+		Metric    string
+		Timestamp time.Time
+	}
+	if err := s.db.Model(&MetricRecord{}).
+		Select("vm_id, metric, MAX(timestamp) AS timestamp").
+		Where("vm_id IN ? AND timestamp >= ?", vmIDs, since).
+		Group("vm_id, metric").
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询指标最后数据时间失败: %w", err)
+	}
+
+	for _, r := range rows {
+		if result[r.VMID] == nil {
+			result[r.VMID] = make(map[string]time.Time)
+		}
+		result[r.VMID][r.Metric] = r.Timestamp
+	}
+	return result, nil
+}
+
 // QueryMetrics 查询指标数据
 func (s *TimeSeriesService) QueryMetrics(vmIDs []string, metrics []string, startTime, endTime time.Time) ([]MetricData, error) {
+	if result, ok := s.queryHot(vmIDs, metrics, startTime, endTime); ok {
+		return result, nil
+	}
+
 	var records []MetricRecord
 
 	query := s.db.Model(&MetricRecord{}).
@@ -95,6 +194,182 @@ func (s *TimeSeriesService) QueryMetrics(vmIDs []string, metrics []string, start
 	return result, nil
 }
 
+// QueryLastN 查询单个序列在指定时间之前的最近n个数据点（按时间升序返回）
+func (s *TimeSeriesService) QueryLastN(vmID string, metric string, before time.Time, n int) ([]MetricData, error) {
+	if n <= 0 {
+		return []MetricData{}, nil
+	}
+
+	if hot := s.readableHot(); hot != nil {
+		if result, ok := hot.LastN(vmID, metric, before, n); ok {
+			return result, nil
+		}
+	}
+
+	var records []MetricRecord
+	if err := s.db.Model(&MetricRecord{}).
+		Where("vm_id = ? AND metric = ? AND timestamp < ?", vmID, metric, before).
+		Order("timestamp DESC").
+		Limit(n).
+		Find(&records).Error; err != nil {
+		return nil, fmt.Errorf("查询最近指标数据失败: %w", err)
+	}
+
+	result := make([]MetricData, len(records))
+	for i, r := range records {
+		result[len(records)-1-i] = MetricData{
+			ID:        r.ID,
+			VMID:      r.VMID,
+			Metric:    r.Metric,
+			Value:     r.Value,
+			Timestamp: r.Timestamp,
+			Tags:      r.Tags,
+		}
+	}
+
+	return result, nil
+}
+
+// queryHot 指定了全部VM和指标且范围均在热缓存覆盖内时，直接从缓存读取
+func (s *TimeSeriesService) queryHot(vmIDs []string, metrics []string, startTime, endTime time.Time) ([]MetricData, bool) {
+	hot := s.readableHot()
+	if hot == nil || len(vmIDs) == 0 || len(metrics) == 0 {
+		return nil, false
+	}
+
+	for _, vmID := range vmIDs {
+		for _, metric := range metrics {
+			if !hot.Covers(vmID, metric, startTime) {
+				return nil, false
+			}
+		}
+	}
+
+	result := []MetricData{}
+	for _, vmID := range vmIDs {
+		for _, metric := range metrics {
+			points, ok := hot.Window(vmID, metric, startTime, endTime)
+			if !ok {
+				return nil, false
+			}
+			result = append(result, points...)
+		}
+	}
+	sortMetricData(result)
+	return result, true
+}
+
+// ErrNoData 查询窗口内没有任何数据点
+var ErrNoData = errors.New("窗口内无数据")
+
+// WindowAggregate 计算单个序列在[startTime, endTime]内的聚合值（last/first/avg/max/min/sum）。
+// 返回值的Timestamp为窗口内最新数据点的时间（first为最早数据点的时间）；窗口内没有数据时返回ErrNoData。
+func (s *TimeSeriesService) WindowAggregate(vmID string, metric string, aggregation string, startTime, endTime time.Time) (*MetricData, error) {
+	if aggregation == "" {
+		aggregation = "last"
+	}
+	switch aggregation {
+	case "last", "first", "avg", "max", "min", "sum":
+	default:
+		return nil, fmt.Errorf("不支持的聚合方式: %s", aggregation)
+	}
+
+	if hot := s.readableHot(); hot != nil {
+		if points, ok := hot.Window(vmID, metric, startTime, endTime); ok {
+			if len(points) == 0 {
+				return nil, ErrNoData
+			}
+			return aggregateWindow(points, aggregation), nil
+		}
+	}
+
+	if s.db == nil {
+		return nil, ErrNoData
+	}
+
+	base := func() *gorm.DB {
+		return s.db.Model(&MetricRecord{}).
+			Where("vm_id = ? AND metric = ? AND timestamp BETWEEN ? AND ?", vmID, metric, startTime, endTime)
+	}
+
+	// first取窗口内最早的点，其余聚合的时间为最新点的时间
+	order := "timestamp DESC"
+	if aggregation == "first" {
+		order = "timestamp ASC"
+	}
+
+	var last struct {
+		Value     float64
+		Timestamp time.Time
+	}
+	result := base().Select("value, timestamp").Order(order).Limit(1).Scan(&last)
+	if result.Error != nil {
+		return nil, fmt.Errorf("查询指标数据失败: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNoData
+	}
+
+	data := &MetricData{VMID: vmID, Metric: metric, Value: last.Value, Timestamp: last.Timestamp}
+	if aggregation == "last" || aggregation == "first" {
+		return data, nil
+	}
+
+	var agg struct {
+		AvgValue float64
+		MaxValue float64
+		MinValue float64
+		SumValue float64
+	}
+	if err := base().
+		Select("AVG(value) AS avg_value, MAX(value) AS max_value, MIN(value) AS min_value, SUM(value) AS sum_value").
+		Scan(&agg).Error; err != nil {
+		return nil, fmt.Errorf("聚合指标数据失败: %w", err)
+	}
+
+	switch aggregation {
+	case "avg":
+		data.Value = agg.AvgValue
+	case "max":
+		data.Value = agg.MaxValue
+	case "min":
+		data.Value = agg.MinValue
+	case "sum":
+		data.Value = agg.SumValue
+	}
+	return data, nil
+}
+
+// aggregateWindow 对按时间升序排列的非空数据点计算聚合值
+func aggregateWindow(points []MetricData, aggregation string) *MetricData {
+	last := points[len(points)-1]
+	data := &MetricData{VMID: last.VMID, Metric: last.Metric, Value: last.Value, Timestamp: last.Timestamp}
+
+	switch aggregation {
+	case "first":
+		first := points[0]
+		data.Value, data.Timestamp = first.Value, first.Timestamp
+	case "avg", "sum":
+		sum := 0.0
+		for _, p := range points {
+			sum += p.Value
+		}
+		if aggregation == "avg" {
+			sum /= float64(len(points))
+		}
+		data.Value = sum
+	case "max":
+		for _, p := range points {
+			data.Value = math.Max(data.Value, p.Value)
+		}
+	case "min":
+		for _, p := range points {
+			data.Value = math.Min(data.Value, p.Value)
+		}
+	}
+	return data
+}
+
 // AggregateMetrics 聚合指标数据
 func (s *TimeSeriesService) AggregateMetrics(vmIDs []string, metrics []string, startTime, endTime time.Time, interval time.Duration, aggregation string) ([]MetricAggregate, error) {
 	// 构建时间桶查询
@@ -139,6 +414,33 @@ func (s *TimeSeriesService) AggregateMetrics(vmIDs []string, metrics []string, s
 
 // GetLatestMetrics 获取最新指标数据
 func (s *TimeSeriesService) GetLatestMetrics(vmIDs []string, metrics []string) (map[string]map[string]float64, error) {
+	result := make(map[string]map[string]float64)
+
+	// 明确指定VM和指标时优先读取热缓存，仅对缓存未命中的部分查询数据库
+	if hot := s.readableHot(); hot != nil && len(vmIDs) > 0 && len(metrics) > 0 {
+		missingVMs := []string{}
+		for _, vmID := range vmIDs {
+			missing := false
+			for _, metric := range metrics {
+				if p, ok := hot.Latest(vmID, metric); ok {
+					if _, exists := result[vmID]; !exists {
+						result[vmID] = make(map[string]float64)
+					}
+					result[vmID][metric] = p.Value
+				} else {
+					missing = true
+				}
+			}
+			if missing {
+				missingVMs = append(missingVMs, vmID)
+			}
+		}
+		if len(missingVMs) == 0 {
+			return result, nil
+		}
+		vmIDs = missingVMs
+	}
+
 	var records []MetricRecord
 
 	subQuery := s.db.Model(&MetricRecord{}).
@@ -163,17 +465,78 @@ func (s *TimeSeriesService) GetLatestMetrics(vmIDs []string, metrics []string) (
 	}
 
 	// 组织结果
-	result := make(map[string]map[string]float64)
 	for _, r := range records {
 		if _, ok := result[r.VMID]; !ok {
 			result[r.VMID] = make(map[string]float64)
 		}
-		result[r.VMID][r.Metric] = r.Value
+		if _, ok := result[r.VMID][r.Metric]; !ok {
+			result[r.VMID][r.Metric] = r.Value
+		}
 	}
 
 	return result, nil
 }
 
+// GetLatestPoints 获取VM各指标的最新数据点（含时间戳）。已缓存的序列读取热缓存，
+// 其余指标（已淘汰或由其他进程写入）查询数据库后合并
+func (s *TimeSeriesService) GetLatestPoints(vmIDs []string) (map[string][]MetricData, error) {
+	result := make(map[string][]MetricData, len(vmIDs))
+	if len(vmIDs) == 0 {
+		return result, nil
+	}
+
+	// 数据库只查询缓存中没有的序列
+	var uncached *gorm.DB
+	addUncached := func(query string, args ...interface{}) {
+		if uncached == nil {
+			uncached = s.db.Where(query, args...)
+		} else {
+			uncached = uncached.Or(query, args...)
+		}
+	}
+	hot := s.readableHot()
+	for _, vmID := range vmIDs {
+		var cached []string
+		if hot != nil {
+			for _, p := range hot.LatestForVM(vmID) {
+				result[vmID] = append(result[vmID], p)
+				cached = append(cached, p.Metric)
+			}
+		}
+		if len(cached) > 0 {
+			addUncached("vm_id = ? AND metric NOT IN ?", vmID, cached)
+		} else {
+			addUncached("vm_id = ?", vmID)
+		}
+	}
+
+	subQuery := s.db.Model(&MetricRecord{}).
+		Select("vm_id, metric, MAX(timestamp) as timestamp").
+		Where(uncached).
+		Group("vm_id, metric")
+
+	var rows []struct {
+		VMID      string
+		Metric    string
+		Value     float64
+		Timestamp time.Time
+	}
+	if err := s.db.Table("metric_records m").
+		Select("m.vm_id, m.metric, m.value, m.timestamp").
+		Joins("INNER JOIN (?) sub ON m.vm_id = sub.vm_id AND m.metric = sub.metric AND m.timestamp = sub.timestamp", subQuery).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询最新指标数据失败: %w", err)
+	}
+
+	for _, r := range rows {
+		result[r.VMID] = append(result[r.VMID], MetricData{VMID: r.VMID, Metric: r.Metric, Value: r.Value, Timestamp: r.Timestamp})
+	}
+	for _, points := range result {
+		sort.Slice(points, func(i, j int) bool { return points[i].Metric < points[j].Metric })
+	}
+	return result, nil
+}
+
 // generateTimeBuckets 生成时间桶
 func (s *TimeSeriesService) generateTimeBuckets(startTime, endTime time.Time, interval time.Duration) []TimeBucket {
 	buckets := []TimeBucket{}
@@ -245,16 +608,17 @@ func (s *TimeSeriesService) calculateAggregate(records []MetricRecord, aggregati
 	return result
 }
 
-// CleanOldData 清理旧数据
+// CleanOldData 按统一保留天数分批清理旧数据
+// Deprecated: 定期清理由 RetentionService 按保留策略执行，此方法仅用于手动清理全部指标。
 func (s *TimeSeriesService) CleanOldData(retentionDays int) error {
 	cutoffTime := time.Now().AddDate(0, 0, -retentionDays)
 
-	result := s.db.Where("timestamp < ?", cutoffTime).Delete(&MetricRecord{})
-	if result.Error != nil {
-		return fmt.Errorf("清理旧数据失败: %w", result.Error)
+	deleted, _, err := deleteInBatches(s.db, MetricRecord{}.TableName(), sqlCond{sql: "timestamp < ?", args: []interface{}{cutoffTime}}, 5000, 1000)
+	if err != nil {
+		return fmt.Errorf("清理旧数据失败: %w", err)
 	}
 
-	log.Printf("已清理 %d 条旧数据 (保留 %d 天)", result.RowsAffected, retentionDays)
+	log.Printf("已清理 %d 条旧数据 (保留 %d 天)", deleted, retentionDays)
 	return nil
 }
 
@@ -267,19 +631,21 @@ func (s *TimeSeriesService) GetStorageStats() (*StorageStats, error) {
 		return nil, err
 	}
 
-	// 最早记录时间
-	var minTime time.Time
-	if err := s.db.Model(&MetricRecord{}).Select("MIN(timestamp)").Scan(&minTime).Error; err != nil {
+	// 最早/最新记录时间：按时间索引只读取首尾一行，避免 MIN/MAX 全表扫描
+	var oldest, latest []time.Time
+	if err := s.db.Model(&MetricRecord{}).Order("timestamp ASC").Limit(1).Pluck("timestamp", &oldest).Error; err != nil {
 		return nil, err
 	}
-	stats.OldestRecord = minTime
+	if len(oldest) > 0 {
+		stats.OldestRecord = oldest[0]
+	}
 
-	// 最新记录时间
-	var maxTime time.Time
-	if err := s.db.Model(&MetricRecord{}).Select("MAX(timestamp)").Scan(&maxTime).Error; err != nil {
+	if err := s.db.Model(&MetricRecord{}).Order("timestamp DESC").Limit(1).Pluck("timestamp", &latest).Error; err != nil {
 		return nil, err
 	}
-	stats.LatestRecord = maxTime
+	if len(latest) > 0 {
+		stats.LatestRecord = latest[0]
+	}
 
 	// 按指标类型统计
 	var byMetric []struct {
@@ -1,9 +1,13 @@
 package services
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"time"
 
+	"vm-monitoring-system/internal/models"
+
 	"gorm.io/gorm"
 )
 
@@ -49,4 +53,29 @@ func (c *VSphereCollector) Stop() {
 // IsRunning 检查运行状态
 func (c *VSphereCollector) IsRunning() bool {
 	return c.isRunning
-}
\ No newline at end of file
+}
+
+// RestartGuest 重启VM客户机操作系统（简化版本暂不支持）
+func (c *VSphereCollector) RestartGuest(ctx context.Context, vm models.VM) error {
+	return c.vmOperation(ctx, vm, "重启客户机")
+}
+
+// PowerCycle 对VM断电后重新上电（简化版本暂不支持）
+func (c *VSphereCollector) PowerCycle(ctx context.Context, vm models.VM) error {
+	return c.vmOperation(ctx, vm, "断电重启")
+}
+
+// vmOperation 执行VM操作。简化版本的采集器没有接入vCenter API，操作一律返回错误，
+// 避免处置记录在未实际执行时显示为成功
+func (c *VSphereCollector) vmOperation(ctx context.Context, vm models.VM, operation string) error {
+	if !c.isRunning {
+		return fmt.Errorf("vSphere采集器未运行")
+	}
+	if vm.VMwareID == nil {
+		return fmt.Errorf("VM %s 未关联vCenter", vm.Name)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: 简化版本的vSphere采集器不支持%s (%s)", ErrVMOperationUnsupported, operation, vm.Name)
+}